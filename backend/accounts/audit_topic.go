@@ -0,0 +1,65 @@
+package wallet
+
+import (
+	"log"
+	"os"
+)
+
+// auditTopicMemo is the memo of topics created for the audit trail
+const auditTopicMemo = "OnCure reward audit trail"
+
+// AuditTopic is the HCS topic reward records are anchored to. A nil
+// *AuditTopic means the audit trail is disabled; Enabled reports this.
+type AuditTopic struct {
+	topicID string
+	ledger  Ledger
+}
+
+// NewAuditTopic creates an audit topic for an existing topic ID
+func NewAuditTopic(topicID string, ledger Ledger) *AuditTopic {
+	return &AuditTopic{topicID: topicID, ledger: ledger}
+}
+
+// NewAuditTopicFromEnv returns the topic configured by AUDIT_TOPIC_ID.
+// Without it the in-memory ledger gets a fresh topic and any other ledger
+// runs with the audit trail disabled (nil).
+func NewAuditTopicFromEnv(ledger Ledger) (*AuditTopic, error) {
+	topicID := os.Getenv("AUDIT_TOPIC_ID")
+	if topicID == "" {
+		if _, ok := ledger.(*MemoryLedger); !ok {
+			log.Println("AUDIT_TOPIC_ID not set, audit trail disabled")
+			return nil, nil
+		}
+		var err error
+		topicID, err = CreateAuditTopic(ledger)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewAuditTopic(topicID, ledger), nil
+}
+
+// CreateAuditTopic creates a new topic for the audit trail
+func CreateAuditTopic(ledger Ledger) (string, error) {
+	return ledger.CreateTopic(auditTopicMemo)
+}
+
+// Enabled reports whether an audit topic is configured
+func (t *AuditTopic) Enabled() bool {
+	return t != nil
+}
+
+// TopicID returns the ledger ID of the topic
+func (t *AuditTopic) TopicID() string {
+	return t.topicID
+}
+
+// Submit anchors a message on the topic and returns its sequence number
+func (t *AuditTopic) Submit(message []byte) (int64, error) {
+	return t.ledger.SubmitTopicMessage(t.topicID, message)
+}
+
+// Message returns the message anchored at a sequence number
+func (t *AuditTopic) Message(sequenceNumber int64) ([]byte, error) {
+	return t.ledger.GetTopicMessage(t.topicID, sequenceNumber)
+}
@@ -0,0 +1,124 @@
+package wallet
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+// Defaults for the badge NFT collection when the environment does not override them
+const (
+	DefaultBadgeCollectionName   = "OnCure Achievement Badges"
+	DefaultBadgeCollectionSymbol = "CUREBADGE"
+)
+
+// BadgeConfigFromEnv reads the badge collection configuration from BADGE_*
+// environment variables
+func BadgeConfigFromEnv() TokenConfig {
+	config := TokenConfig{
+		TokenID:            os.Getenv("BADGE_TOKEN_ID"),
+		Name:               os.Getenv("BADGE_TOKEN_NAME"),
+		Symbol:             os.Getenv("BADGE_TOKEN_SYMBOL"),
+		TreasuryAccountID:  os.Getenv("BADGE_TREASURY_ID"),
+		TreasuryPrivateKey: os.Getenv("BADGE_TREASURY_KEY"),
+		SupplyPrivateKey:   os.Getenv("BADGE_SUPPLY_KEY"),
+		NonFungible:        true,
+	}
+	if config.Name == "" {
+		config.Name = DefaultBadgeCollectionName
+	}
+	if config.Symbol == "" {
+		config.Symbol = DefaultBadgeCollectionSymbol
+	}
+	return config
+}
+
+// BadgeCollection is the NFT collection achievement badges are minted from.
+// Each badge is minted into the treasury and then transferred to the user's
+// wallet. A nil *BadgeCollection means badges are recorded but not minted;
+// Enabled reports this.
+type BadgeCollection struct {
+	config TokenConfig
+	ledger Ledger
+}
+
+// NewBadgeCollection creates a badge collection from its configuration
+func NewBadgeCollection(config TokenConfig, ledger Ledger) (*BadgeCollection, error) {
+	if config.TokenID == "" {
+		return nil, fmt.Errorf("badge token ID is not set")
+	}
+	if config.TreasuryAccountID == "" || config.TreasuryPrivateKey == "" || config.SupplyPrivateKey == "" {
+		return nil, fmt.Errorf("badge collection %s needs BADGE_TREASURY_ID, BADGE_TREASURY_KEY and BADGE_SUPPLY_KEY", config.TokenID)
+	}
+	return &BadgeCollection{config: config, ledger: ledger}, nil
+}
+
+// NewBadgeCollectionFromEnv returns the badge collection configured in the
+// environment. Without BADGE_TOKEN_ID the in-memory ledger gets a fresh
+// collection and any other ledger runs with minting disabled (nil).
+func NewBadgeCollectionFromEnv(ledger Ledger) (*BadgeCollection, error) {
+	config := BadgeConfigFromEnv()
+
+	if config.TokenID == "" {
+		memory, ok := ledger.(*MemoryLedger)
+		if !ok {
+			log.Println("BADGE_TOKEN_ID not set, badge minting disabled")
+			return nil, nil
+		}
+		return bootstrapMemoryBadgeCollection(memory, config)
+	}
+
+	return NewBadgeCollection(config, ledger)
+}
+
+// bootstrapMemoryBadgeCollection creates a treasury account and collection on the in-memory ledger
+func bootstrapMemoryBadgeCollection(ledger *MemoryLedger, config TokenConfig) (*BadgeCollection, error) {
+	treasuryID, treasuryKey, err := ledger.CreateAccount(0)
+	if err != nil {
+		return nil, err
+	}
+	config.TreasuryAccountID = treasuryID
+	config.TreasuryPrivateKey = treasuryKey
+	config.SupplyPrivateKey = "memory-badge-supply-key"
+
+	config.TokenID, err = ledger.CreateToken(config.Spec())
+	if err != nil {
+		return nil, err
+	}
+	return NewBadgeCollection(config, ledger)
+}
+
+// Enabled reports whether a badge collection is configured
+func (b *BadgeCollection) Enabled() bool {
+	return b != nil
+}
+
+// TokenID returns the ledger ID of the collection
+func (b *BadgeCollection) TokenID() string {
+	return b.config.TokenID
+}
+
+// EnsureAssociated associates a user's wallet with the collection. Badge
+// associations are not tracked on the wallet, and associating twice is not
+// an error, so this runs before every delivery.
+func (b *BadgeCollection) EnsureAssociated(userWallet *models.UserWallet) error {
+	privateKey, err := userWallet.DecryptPrivateKey()
+	if err != nil {
+		return fmt.Errorf("failed to decrypt private key: %v", err)
+	}
+	return b.ledger.AssociateToken(userWallet.HederaAccountID, privateKey, b.config.TokenID)
+}
+
+// Mint mints one badge NFT into the treasury and returns its serial number
+func (b *BadgeCollection) Mint(metadata []byte) (int64, error) {
+	return b.ledger.MintNFT(b.config.TokenID, b.config.SupplyPrivateKey, metadata)
+}
+
+// SendFromTreasury transfers a minted badge from the treasury to an account
+// and returns the transaction ID. A non-empty transactionID submits the
+// transfer under that reserved ID.
+func (b *BadgeCollection) SendFromTreasury(transactionID string, serialNumber int64, toAccountID string) (string, error) {
+	return b.ledger.TransferNFT(transactionID, b.config.TokenID, serialNumber, b.config.TreasuryAccountID, toAccountID, b.config.TreasuryPrivateKey)
+}
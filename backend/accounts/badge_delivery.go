@@ -0,0 +1,114 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+// DeliverBadge mints the NFT for a pending badge and transfers it to the
+// user's wallet. The serial number is stored as soon as the NFT is minted so
+// a retry transfers the same NFT, and the transfer is claimed under a
+// reserved transaction ID like other payments. Badges of users without a
+// wallet stay pending until one exists.
+func (s *TransferService) DeliverBadge(badge *models.UserBadge) error {
+	if !s.badges.Enabled() || badge.Status != models.BadgeStatusPending {
+		return nil
+	}
+
+	userWallet, err := models.GetUserWallet(s.db, badge.UserID)
+	if err != nil {
+		return err
+	}
+	if userWallet == nil {
+		return nil
+	}
+	if err := s.badges.EnsureAssociated(userWallet); err != nil {
+		return fmt.Errorf("failed to associate badge collection: %v", err)
+	}
+
+	if badge.SerialNumber == nil {
+		metadata := []byte(fmt.Sprintf("oncure:badge:%s:%d", badge.BadgeType, badge.UserID))
+		serialNumber, err := s.badges.Mint(metadata)
+		if err != nil {
+			return fmt.Errorf("failed to mint badge: %v", err)
+		}
+		if err := models.SetBadgeSerial(s.db, badge.ID, s.badges.TokenID(), serialNumber); err != nil {
+			// The NFT stays in the treasury; the next attempt mints another
+			return fmt.Errorf("failed to record badge serial %d: %v", serialNumber, err)
+		}
+		badge.TokenID = s.badges.TokenID()
+		badge.SerialNumber = &serialNumber
+	}
+
+	transactionID, err := s.ledger.NewTransactionID()
+	if err != nil {
+		return fmt.Errorf("failed to reserve transaction ID: %v", err)
+	}
+
+	claimed, err := models.MarkBadgeSubmitted(s.db, badge.ID, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to record badge delivery: %v", err)
+	}
+	if !claimed {
+		return nil
+	}
+	badge.Status = models.BadgeStatusSubmitted
+	badge.TransactionID = transactionID
+
+	_, err = s.badges.SendFromTreasury(transactionID, *badge.SerialNumber, userWallet.HederaAccountID)
+	if err != nil {
+		log.Printf("Badge %d submission error: %v", badge.ID, err)
+		if errors.Is(err, ErrLedgerTimeout) {
+			// An unanswered submission is left for recovery to settle from its receipt
+			return nil
+		}
+		if resolveErr := s.resolveBadgeDelivery(badge, err.Error()); resolveErr != nil {
+			log.Printf("Badge %d left submitted: %v", badge.ID, resolveErr)
+		}
+		return nil
+	}
+
+	s.completeBadgeDelivery(badge)
+	return nil
+}
+
+// resolveBadgeDelivery settles a submitted badge from its receipt
+func (s *TransferService) resolveBadgeDelivery(badge *models.UserBadge, notFoundReason string) error {
+	result, reason, err := s.outcome(badge.TransactionID, notFoundReason)
+	if err != nil {
+		return err
+	}
+	switch result {
+	case outcomeFailed:
+		if err := models.MarkBadgeFailed(s.db, badge.ID, reason); err != nil {
+			log.Printf("Failed to mark badge %d failed: %v", badge.ID, err)
+			return nil
+		}
+		badge.Status = models.BadgeStatusFailed
+		badge.FailureReason = reason
+	case outcomeUnconfirmed:
+		if err := models.MarkBadgeNeedsReview(s.db, badge.ID, reason); err != nil {
+			log.Printf("Failed to mark badge %d for review: %v", badge.ID, err)
+			return nil
+		}
+		log.Printf("Badge %d needs review: %s", badge.ID, reason)
+		badge.Status = models.BadgeStatusNeedsReview
+		badge.FailureReason = reason
+	default:
+		s.completeBadgeDelivery(badge)
+	}
+	return nil
+}
+
+// completeBadgeDelivery marks a badge minted, leaving it submitted for recovery if the update fails
+func (s *TransferService) completeBadgeDelivery(badge *models.UserBadge) {
+	if _, err := models.MarkBadgeMinted(s.db, badge.ID); err != nil {
+		log.Printf("Failed to mark badge %d minted: %v", badge.ID, err)
+		return
+	}
+	badge.Status = models.BadgeStatusMinted
+	badge.FailureReason = ""
+}
@@ -0,0 +1,216 @@
+package wallet
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+// Defaults for the community token when the environment does not override them
+const (
+	DefaultCommunityTokenName     = "OnCure Community Token"
+	DefaultCommunityTokenSymbol   = "CURE"
+	DefaultCommunityTokenDecimals = 2
+)
+
+// memoryTokenSupply is the whole-token supply minted for the in-memory ledger's token
+const memoryTokenSupply = "1000000"
+
+// TokenConfig is the configuration of the community token or badge collection
+type TokenConfig struct {
+	TokenID            string
+	Name               string
+	Symbol             string
+	Decimals           int
+	TreasuryAccountID  string
+	TreasuryPrivateKey string
+	SupplyPrivateKey   string
+	NonFungible        bool
+}
+
+// TokenConfigFromEnv reads the community token configuration from
+// COMMUNITY_TOKEN_* environment variables
+func TokenConfigFromEnv() (TokenConfig, error) {
+	config := TokenConfig{
+		TokenID:            os.Getenv("COMMUNITY_TOKEN_ID"),
+		Name:               os.Getenv("COMMUNITY_TOKEN_NAME"),
+		Symbol:             os.Getenv("COMMUNITY_TOKEN_SYMBOL"),
+		Decimals:           DefaultCommunityTokenDecimals,
+		TreasuryAccountID:  os.Getenv("COMMUNITY_TOKEN_TREASURY_ID"),
+		TreasuryPrivateKey: os.Getenv("COMMUNITY_TOKEN_TREASURY_KEY"),
+		SupplyPrivateKey:   os.Getenv("COMMUNITY_TOKEN_SUPPLY_KEY"),
+	}
+	if config.Name == "" {
+		config.Name = DefaultCommunityTokenName
+	}
+	if config.Symbol == "" {
+		config.Symbol = DefaultCommunityTokenSymbol
+	}
+	if decimals := os.Getenv("COMMUNITY_TOKEN_DECIMALS"); decimals != "" {
+		parsed, err := strconv.Atoi(decimals)
+		if err != nil || parsed < 0 || parsed > 18 {
+			return config, fmt.Errorf("invalid COMMUNITY_TOKEN_DECIMALS %q", decimals)
+		}
+		config.Decimals = parsed
+	}
+	return config, nil
+}
+
+// CommunityToken is the platform's HTS reward token. Rewards are paid from
+// its treasury so they never spend users' HBAR. A nil *CommunityToken means
+// the token is not configured; Enabled reports this.
+type CommunityToken struct {
+	config TokenConfig
+	ledger Ledger
+}
+
+// NewCommunityToken creates a community token from its configuration
+func NewCommunityToken(config TokenConfig, ledger Ledger) (*CommunityToken, error) {
+	if config.TokenID == "" {
+		return nil, fmt.Errorf("community token ID is not set")
+	}
+	if config.TreasuryAccountID == "" || config.TreasuryPrivateKey == "" {
+		return nil, fmt.Errorf("community token %s needs COMMUNITY_TOKEN_TREASURY_ID and COMMUNITY_TOKEN_TREASURY_KEY", config.TokenID)
+	}
+	return &CommunityToken{config: config, ledger: ledger}, nil
+}
+
+// NewCommunityTokenFromEnv returns the community token configured in the
+// environment. Without COMMUNITY_TOKEN_ID the in-memory ledger gets a fresh
+// token so offline runs behave like production, and any other ledger runs
+// with the token disabled (nil).
+func NewCommunityTokenFromEnv(ledger Ledger) (*CommunityToken, error) {
+	config, err := TokenConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.TokenID == "" {
+		memory, ok := ledger.(*MemoryLedger)
+		if !ok {
+			log.Println("COMMUNITY_TOKEN_ID not set, community token disabled")
+			return nil, nil
+		}
+		return bootstrapMemoryToken(memory, config)
+	}
+
+	return NewCommunityToken(config, ledger)
+}
+
+// bootstrapMemoryToken creates a treasury account and token on the in-memory ledger
+func bootstrapMemoryToken(ledger *MemoryLedger, config TokenConfig) (*CommunityToken, error) {
+	treasuryID, treasuryKey, err := ledger.CreateAccount(0)
+	if err != nil {
+		return nil, err
+	}
+	config.TreasuryAccountID = treasuryID
+	config.TreasuryPrivateKey = treasuryKey
+	config.SupplyPrivateKey = "memory-supply-key"
+
+	config.TokenID, err = ledger.CreateToken(config.Spec())
+	if err != nil {
+		return nil, err
+	}
+
+	// Give the treasury a supply to pay rewards from
+	supply, _ := money.ParseUnits(memoryTokenSupply, config.Decimals)
+	if err := ledger.MintToken(config.TokenID, config.SupplyPrivateKey, supply); err != nil {
+		return nil, err
+	}
+	return NewCommunityToken(config, ledger)
+}
+
+// Spec returns the token spec used to create the configured token
+func (c TokenConfig) Spec() TokenSpec {
+	return TokenSpec{
+		Name:               c.Name,
+		Symbol:             c.Symbol,
+		Decimals:           c.Decimals,
+		TreasuryAccountID:  c.TreasuryAccountID,
+		TreasuryPrivateKey: c.TreasuryPrivateKey,
+		SupplyPrivateKey:   c.SupplyPrivateKey,
+		NonFungible:        c.NonFungible,
+	}
+}
+
+// Enabled reports whether a community token is configured
+func (t *CommunityToken) Enabled() bool {
+	return t != nil
+}
+
+// TokenID returns the ledger ID of the token
+func (t *CommunityToken) TokenID() string {
+	return t.config.TokenID
+}
+
+// Symbol returns the token symbol
+func (t *CommunityToken) Symbol() string {
+	return t.config.Symbol
+}
+
+// Decimals returns the number of decimal places of the token
+func (t *CommunityToken) Decimals() int {
+	return t.config.Decimals
+}
+
+// TreasuryAccountID returns the account rewards are paid from
+func (t *CommunityToken) TreasuryAccountID() string {
+	return t.config.TreasuryAccountID
+}
+
+// Format formats token units as a decimal string, e.g. 150 units as "1.50"
+func (t *CommunityToken) Format(units int64) string {
+	return money.FormatUnits(units, t.config.Decimals)
+}
+
+// Parse parses a decimal token amount into units
+func (t *CommunityToken) Parse(amount string) (int64, error) {
+	return money.ParseUnits(amount, t.config.Decimals)
+}
+
+// Associate lets an account hold the token
+func (t *CommunityToken) Associate(accountID, privateKey string) error {
+	return t.ledger.AssociateToken(accountID, privateKey, t.config.TokenID)
+}
+
+// EnsureAssociated associates a user's wallet with the token unless it
+// already is and records the association on the wallet
+func (t *CommunityToken) EnsureAssociated(db *sql.DB, userWallet *models.UserWallet) error {
+	if userWallet.TokenAssociatedAt != nil {
+		return nil
+	}
+
+	privateKey, err := userWallet.DecryptPrivateKey()
+	if err != nil {
+		return fmt.Errorf("failed to decrypt private key: %v", err)
+	}
+	if err := t.Associate(userWallet.HederaAccountID, privateKey); err != nil {
+		return err
+	}
+	return models.MarkTokenAssociated(db, userWallet.UserID)
+}
+
+// Mint mints units of the token into the treasury
+func (t *CommunityToken) Mint(units int64) error {
+	if t.config.SupplyPrivateKey == "" {
+		return fmt.Errorf("COMMUNITY_TOKEN_SUPPLY_KEY is not set")
+	}
+	return t.ledger.MintToken(t.config.TokenID, t.config.SupplyPrivateKey, units)
+}
+
+// SendFromTreasury pays units of the token from the treasury to an account
+// and returns the transaction ID. A non-empty transactionID submits the
+// transfer under that reserved ID.
+func (t *CommunityToken) SendFromTreasury(transactionID, toAccountID string, units int64) (string, error) {
+	return t.ledger.TransferToken(transactionID, t.config.TokenID, t.config.TreasuryAccountID, toAccountID, t.config.TreasuryPrivateKey, units)
+}
+
+// Balance returns the units of the token held by an account
+func (t *CommunityToken) Balance(accountID string) (int64, error) {
+	return t.ledger.GetTokenBalance(accountID, t.config.TokenID)
+}
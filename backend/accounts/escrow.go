@@ -0,0 +1,130 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+// ErrEscrowDisabled is returned for escrow payments when no escrow account is configured
+var ErrEscrowDisabled = errors.New("escrow account is not configured")
+
+// EscrowAccount is the platform account paid mentorship sessions are held in
+// until they are released to the coach or refunded to the patient. It pays
+// the fees of releases and refunds, so it should keep a small HBAR float. A
+// nil *EscrowAccount means paid sessions are disabled; Enabled reports this.
+type EscrowAccount struct {
+	accountID  string
+	privateKey string
+}
+
+// NewEscrowAccount creates an escrow account from its ID and private key
+func NewEscrowAccount(accountID, privateKey string) (*EscrowAccount, error) {
+	if accountID == "" || privateKey == "" {
+		return nil, fmt.Errorf("escrow account needs ESCROW_ACCOUNT_ID and ESCROW_ACCOUNT_KEY")
+	}
+	return &EscrowAccount{accountID: accountID, privateKey: privateKey}, nil
+}
+
+// NewEscrowAccountFromEnv returns the escrow account configured by
+// ESCROW_ACCOUNT_ID and ESCROW_ACCOUNT_KEY. Without them the in-memory
+// ledger gets a fresh account and any other ledger runs with paid sessions
+// disabled (nil).
+func NewEscrowAccountFromEnv(ledger Ledger) (*EscrowAccount, error) {
+	accountID := os.Getenv("ESCROW_ACCOUNT_ID")
+	if accountID == "" {
+		memory, ok := ledger.(*MemoryLedger)
+		if !ok {
+			log.Println("ESCROW_ACCOUNT_ID not set, paid sessions disabled")
+			return nil, nil
+		}
+		accountID, privateKey, err := memory.CreateAccount(InitialAccountBalance)
+		if err != nil {
+			return nil, err
+		}
+		return NewEscrowAccount(accountID, privateKey)
+	}
+	return NewEscrowAccount(accountID, os.Getenv("ESCROW_ACCOUNT_KEY"))
+}
+
+// Enabled reports whether an escrow account is configured
+func (e *EscrowAccount) Enabled() bool {
+	return e != nil
+}
+
+// AccountID returns the ledger ID of the escrow account
+func (e *EscrowAccount) AccountID() string {
+	return e.accountID
+}
+
+// FundEscrow moves a session payment from the patient's wallet into the
+// escrow account. Retrying with the same idempotency key returns the
+// original transfer.
+func (s *TransferService) FundEscrow(escrow *models.Escrow, idempotencyKey string) (*models.Transfer, error) {
+	if !s.escrow.Enabled() {
+		return nil, ErrEscrowDisabled
+	}
+
+	patientWallet, err := models.GetUserWallet(s.db, escrow.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	if patientWallet == nil {
+		return nil, ErrSenderWalletNotFound
+	}
+
+	// The escrow account receives the payment; the escrow names the coach
+	return s.send(models.Transfer{
+		Kind:              models.TransferKindEscrowFund,
+		FromUserID:        escrow.PatientID,
+		ExternalAccountID: s.escrow.accountID,
+		Amount:            escrow.Amount,
+		IdempotencyKey:    idempotencyKey,
+		EscrowID:          &escrow.ID,
+	}, walletParty(patientWallet), party{accountID: s.escrow.accountID, privateKey: s.escrow.privateKey})
+}
+
+// ReleaseEscrow pays a held session payment out to the coach. Callers make
+// sure only one release of an escrow is in flight through its status.
+func (s *TransferService) ReleaseEscrow(escrow *models.Escrow) (*models.Transfer, error) {
+	return s.payOutEscrow(escrow, models.TransferKindEscrowRelease, escrow.CoachID)
+}
+
+// RefundEscrow returns a held session payment to the patient. Callers make
+// sure only one refund of an escrow is in flight through its status.
+func (s *TransferService) RefundEscrow(escrow *models.Escrow) (*models.Transfer, error) {
+	return s.payOutEscrow(escrow, models.TransferKindEscrowRefund, escrow.PatientID)
+}
+
+// payOutEscrow sends an escrow's amount from the escrow account to a user.
+// Each attempt gets an idempotency key from the escrow and the payouts
+// already tried, so concurrent attempts record one transfer between them.
+func (s *TransferService) payOutEscrow(escrow *models.Escrow, kind string, toUserID int) (*models.Transfer, error) {
+	if !s.escrow.Enabled() {
+		return nil, ErrEscrowDisabled
+	}
+
+	receiverWallet, err := models.GetUserWallet(s.db, toUserID)
+	if err != nil {
+		return nil, err
+	}
+	if receiverWallet == nil {
+		return nil, ErrReceiverWalletNotFound
+	}
+	attempts, err := models.CountEscrowTransfers(s.db, escrow.ID, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.send(models.Transfer{
+		Kind:              kind,
+		ExternalAccountID: s.escrow.accountID,
+		ToUserID:          toUserID,
+		Amount:            escrow.Amount,
+		IdempotencyKey:    fmt.Sprintf("escrow-%d-%s-%d", escrow.ID, kind, attempts+1),
+		EscrowID:          &escrow.ID,
+	}, party{accountID: s.escrow.accountID, privateKey: s.escrow.privateKey}, walletParty(receiverWallet))
+}
@@ -0,0 +1,46 @@
+package wallet
+
+import (
+	"os"
+	"strings"
+)
+
+// ExplorerURL returns the base URL of the Hashscan-style network explorer:
+// HEDERA_EXPLORER_URL, or Hashscan for the network HEDERA_NETWORK names. It
+// is "" for the in-memory ledger and custom networks, whose transactions no
+// public explorer knows.
+func ExplorerURL() string {
+	if url := os.Getenv("HEDERA_EXPLORER_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	network := networkName()
+	if os.Getenv("LEDGER_BACKEND") == "memory" || network == NetworkCustom {
+		return ""
+	}
+	return "https://hashscan.io/" + network
+}
+
+// TransactionURL links to a transaction on the network explorer. It returns
+// "" when there is no explorer or no transaction ID.
+func TransactionURL(transactionID string) string {
+	base := ExplorerURL()
+	if base == "" || transactionID == "" {
+		return ""
+	}
+	return base + "/transaction/" + mirrorTransactionID(transactionID)
+}
+
+// mirrorTransactionID converts an SDK transaction ID such as
+// 0.0.2@1700000000.000000001 to the form 0.0.2-1700000000-000000001 used in
+// mirror node and explorer URLs
+func mirrorTransactionID(sdkID string) string {
+	account, validStart, ok := strings.Cut(sdkID, "@")
+	if !ok {
+		return sdkID
+	}
+	seconds, nanos, ok := strings.Cut(validStart, ".")
+	if !ok {
+		return sdkID
+	}
+	return account + "-" + seconds + "-" + nanos
+}
@@ -0,0 +1,159 @@
+package wallet
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	hedera "github.com/hashgraph/hedera-sdk-go/v2"
+)
+
+// Networks HEDERA_NETWORK selects by name. Any other value is read as the
+// node list of a custom network such as a local node.
+const (
+	NetworkTestnet    = "testnet"
+	NetworkPreviewnet = "previewnet"
+	NetworkMainnet    = "mainnet"
+	NetworkCustom     = "custom"
+)
+
+const (
+	defaultCallTimeout = 30 * time.Second
+	defaultMaxAttempts = 5
+)
+
+// HederaConfig configures the Hedera client shared by all ledger calls
+type HederaConfig struct {
+	// Network is testnet, previewnet, mainnet or custom
+	Network string
+	// Nodes maps the addresses of a custom network's nodes to their account IDs
+	Nodes       map[string]hedera.AccountID
+	OperatorID  hedera.AccountID
+	OperatorKey hedera.PrivateKey
+	// CallTimeout bounds each ledger call, including the SDK's retries
+	CallTimeout time.Duration
+	// MaxAttempts is how often a request is tried when nodes are busy or unreachable
+	MaxAttempts int
+}
+
+// HederaConfigFromEnv reads and validates the Hedera client configuration:
+// HEDERA_NETWORK (testnet by default, previewnet, mainnet, or a custom
+// network as comma-separated address=node account pairs such as
+// "127.0.0.1:50211=0.0.3"), the operator HEDERA_CLIENT_ID and
+// HEDERA_PRIVATE_KEY, HEDERA_CALL_TIMEOUT_SECONDS and HEDERA_MAX_ATTEMPTS.
+func HederaConfigFromEnv() (HederaConfig, error) {
+	config := HederaConfig{CallTimeout: defaultCallTimeout, MaxAttempts: defaultMaxAttempts}
+
+	network := strings.TrimSpace(os.Getenv("HEDERA_NETWORK"))
+	switch strings.ToLower(network) {
+	case "", NetworkTestnet:
+		config.Network = NetworkTestnet
+	case NetworkPreviewnet, NetworkMainnet:
+		config.Network = strings.ToLower(network)
+	default:
+		nodes, err := parseNetworkNodes(network)
+		if err != nil {
+			return config, fmt.Errorf("invalid HEDERA_NETWORK: %v", err)
+		}
+		config.Network = NetworkCustom
+		config.Nodes = nodes
+	}
+
+	operatorID := os.Getenv("HEDERA_CLIENT_ID")
+	if operatorID == "" {
+		return config, fmt.Errorf("HEDERA_CLIENT_ID is not set")
+	}
+	id, err := hedera.AccountIDFromString(operatorID)
+	if err != nil {
+		return config, fmt.Errorf("invalid HEDERA_CLIENT_ID %q: %v", operatorID, err)
+	}
+	config.OperatorID = id
+
+	privateKey := os.Getenv("HEDERA_PRIVATE_KEY")
+	if privateKey == "" {
+		return config, fmt.Errorf("HEDERA_PRIVATE_KEY is not set")
+	}
+	key, err := hedera.PrivateKeyFromString(privateKey)
+	if err != nil {
+		return config, fmt.Errorf("invalid HEDERA_PRIVATE_KEY: %v", err)
+	}
+	config.OperatorKey = key
+
+	if value := os.Getenv("HEDERA_CALL_TIMEOUT_SECONDS"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			return config, fmt.Errorf("invalid HEDERA_CALL_TIMEOUT_SECONDS %q", value)
+		}
+		config.CallTimeout = time.Duration(seconds) * time.Second
+	}
+	if value := os.Getenv("HEDERA_MAX_ATTEMPTS"); value != "" {
+		attempts, err := strconv.Atoi(value)
+		if err != nil || attempts <= 0 {
+			return config, fmt.Errorf("invalid HEDERA_MAX_ATTEMPTS %q", value)
+		}
+		config.MaxAttempts = attempts
+	}
+	return config, nil
+}
+
+// parseNetworkNodes parses "address=account,..." node lists
+func parseNetworkNodes(value string) (map[string]hedera.AccountID, error) {
+	nodes := map[string]hedera.AccountID{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		address, account, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(address) == "" {
+			return nil, fmt.Errorf("expected testnet, previewnet, mainnet or address=account pairs, got %q", entry)
+		}
+		nodeID, err := hedera.AccountIDFromString(strings.TrimSpace(account))
+		if err != nil {
+			return nil, fmt.Errorf("invalid node account ID in %q: %v", entry, err)
+		}
+		nodes[strings.TrimSpace(address)] = nodeID
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no nodes given")
+	}
+	return nodes, nil
+}
+
+// NewClient creates a Hedera client for the configured network, paid for
+// by the operator account
+func (c HederaConfig) NewClient() (*hedera.Client, error) {
+	var client *hedera.Client
+	switch c.Network {
+	case NetworkTestnet:
+		client = hedera.ClientForTestnet()
+	case NetworkPreviewnet:
+		client = hedera.ClientForPreviewnet()
+	case NetworkMainnet:
+		client = hedera.ClientForMainnet()
+	case NetworkCustom:
+		client = hedera.ClientForNetwork(c.Nodes)
+	default:
+		return nil, fmt.Errorf("unknown Hedera network %q", c.Network)
+	}
+
+	client.SetOperator(c.OperatorID, c.OperatorKey)
+	client.SetMaxAttempts(c.MaxAttempts)
+	timeout := c.CallTimeout
+	client.SetRequestTimeout(&timeout)
+	return client, nil
+}
+
+// networkName is the network named by HEDERA_NETWORK, or NetworkCustom
+func networkName() string {
+	network := strings.ToLower(strings.TrimSpace(os.Getenv("HEDERA_NETWORK")))
+	switch network {
+	case "":
+		return NetworkTestnet
+	case NetworkTestnet, NetworkPreviewnet, NetworkMainnet:
+		return network
+	}
+	return NetworkCustom
+}
@@ -0,0 +1,668 @@
+package wallet
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/money"
+	hedera "github.com/hashgraph/hedera-sdk-go/v2"
+)
+
+// HederaLedger is the Ledger backed by the Hedera network selected by
+// HEDERA_NETWORK. All calls share one long-lived client paid for by the
+// operator account HEDERA_CLIENT_ID.
+type HederaLedger struct {
+	config HederaConfig
+	client *hedera.Client
+}
+
+// defaultMirrorNodeURLs are the public mirror nodes of each network, used
+// when HEDERA_MIRROR_NODE_URL is not set. A custom network defaults to the
+// mirror node of a local node.
+var defaultMirrorNodeURLs = map[string]string{
+	NetworkTestnet:    "https://testnet.mirrornode.hedera.com",
+	NetworkPreviewnet: "https://previewnet.mirrornode.hedera.com",
+	NetworkMainnet:    "https://mainnet-public.mirrornode.hedera.com",
+	NetworkCustom:     "http://localhost:5551",
+}
+
+// MirrorNodeURL returns the base URL of the mirror node REST API
+func MirrorNodeURL() string {
+	if url := os.Getenv("HEDERA_MIRROR_NODE_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return defaultMirrorNodeURLs[networkName()]
+}
+
+// NewHederaLedger creates a Hedera-backed ledger with a client for config
+func NewHederaLedger(config HederaConfig) (*HederaLedger, error) {
+	client, err := config.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	return &HederaLedger{config: config, client: client}, nil
+}
+
+// NewHederaLedgerFromEnv creates a Hedera-backed ledger configured by HederaConfigFromEnv
+func NewHederaLedgerFromEnv() (*HederaLedger, error) {
+	config, err := HederaConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewHederaLedger(config)
+}
+
+// Close closes the ledger's client
+func (l *HederaLedger) Close() error {
+	return l.client.Close()
+}
+
+// Ping checks that the network answers and knows the operator account by
+// querying the operator's balance, which is free
+func (l *HederaLedger) Ping() error {
+	return l.call("ping", func() error {
+		_, err := hedera.NewAccountBalanceQuery().
+			SetAccountID(l.config.OperatorID).
+			Execute(l.client)
+		return err
+	})
+}
+
+// call runs one ledger operation and gives up once the configured call
+// timeout passes. The SDK retries busy and unreachable nodes within that
+// time. An operation that timed out may still reach consensus, so callers
+// that reserved a transaction ID settle it from its receipt later.
+func (l *HederaLedger) call(operation string, fn func() error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), l.config.CallTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w after %s", operation, ErrLedgerTimeout, l.config.CallTimeout)
+	}
+}
+
+// CreateAccount creates a new Hedera account funded by the operator
+func (l *HederaLedger) CreateAccount(initialBalance money.Tinybars) (string, string, error) {
+	var accountID, privateKey string
+	err := l.call("account creation", func() (err error) {
+		accountID, privateKey, err = createAccount(l.client, initialBalance)
+		return err
+	})
+	return accountID, privateKey, err
+}
+
+// NewTransactionID generates a transaction ID paid for by the operator account
+func (l *HederaLedger) NewTransactionID() (string, error) {
+	return hedera.TransactionIDGenerate(l.config.OperatorID).String(), nil
+}
+
+// TransferHbar transfers HBAR between two accounts
+func (l *HederaLedger) TransferHbar(transactionID, fromAccountID, toAccountID, fromPrivateKey string, amount money.Tinybars) (string, error) {
+	// Parse account IDs
+	fromID, err := hedera.AccountIDFromString(fromAccountID)
+	if err != nil {
+		return "", fmt.Errorf("invalid from account ID: %v", err)
+	}
+
+	toID, err := hedera.AccountIDFromString(toAccountID)
+	if err != nil {
+		return "", fmt.Errorf("invalid to account ID: %v", err)
+	}
+
+	// Parse private key
+	privateKey, err := hedera.PrivateKeyFromString(fromPrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %v", err)
+	}
+
+	// Create transfer transaction
+	transferTx := hedera.NewTransferTransaction().
+		AddHbarTransfer(fromID, hedera.HbarFromTinybar(-amount.Int64())).
+		AddHbarTransfer(toID, hedera.HbarFromTinybar(amount.Int64()))
+	if transactionID != "" {
+		txID, err := hedera.TransactionIdFromString(transactionID)
+		if err != nil {
+			return "", fmt.Errorf("invalid transaction ID: %v", err)
+		}
+		transferTx = transferTx.SetTransactionID(txID)
+	}
+	transferTx, err = transferTx.FreezeWith(l.client)
+	if err != nil {
+		return "", fmt.Errorf("failed to create transfer transaction: %v", err)
+	}
+
+	// Sign and execute
+	transferTx = transferTx.Sign(privateKey)
+	var response hedera.TransactionResponse
+	err = l.call("transfer", func() (err error) {
+		response, err = transferTx.Execute(l.client)
+		return err
+	})
+	if err != nil {
+		if hederaStatus(err) == hedera.StatusDuplicateTransaction {
+			return "", ErrDuplicateTransaction
+		}
+		return "", fmt.Errorf("failed to execute transfer: %w", err)
+	}
+
+	// Get receipt
+	err = l.call("transfer receipt", func() (err error) {
+		_, err = response.GetReceipt(l.client)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("transfer failed: %w", err)
+	}
+
+	return response.TransactionID.String(), nil
+}
+
+// GetAccountBalance gets the HBAR balance of an account
+func (l *HederaLedger) GetAccountBalance(accountID string) (money.Tinybars, error) {
+	// Parse account ID
+	accID, err := hedera.AccountIDFromString(accountID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid account ID: %v", err)
+	}
+
+	// Query balance
+	var balance hedera.AccountBalance
+	err = l.call("balance query", func() (err error) {
+		balance, err = hedera.NewAccountBalanceQuery().
+			SetAccountID(accID).
+			Execute(l.client)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query balance: %w", err)
+	}
+
+	return money.Tinybars(balance.Hbars.AsTinybar()), nil
+}
+
+// GetTransactionReceipt queries the receipt of a transaction by its ID
+func (l *HederaLedger) GetTransactionReceipt(transactionID string) (*Receipt, error) {
+	txID, err := hedera.TransactionIdFromString(transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction ID: %v", err)
+	}
+
+	var receipt hedera.TransactionReceipt
+	err = l.call("receipt query", func() (err error) {
+		receipt, err = hedera.NewTransactionReceiptQuery().
+			SetTransactionID(txID).
+			Execute(l.client)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrLedgerTimeout) {
+			return nil, err
+		}
+		if receipt.Status == hedera.StatusReceiptNotFound {
+			return nil, ErrReceiptNotFound
+		}
+		// Failed transactions still carry a status in their receipt
+		if receipt.Status != hedera.StatusOk && receipt.Status != hedera.StatusUnknown {
+			return &Receipt{TransactionID: transactionID, Status: receipt.Status.String()}, nil
+		}
+		return nil, fmt.Errorf("failed to query receipt: %w", err)
+	}
+
+	return &Receipt{TransactionID: transactionID, Status: receipt.Status.String()}, nil
+}
+
+// KeepsReceipts reports false; Hedera nodes only keep receipts for a few
+// minutes after consensus
+func (l *HederaLedger) KeepsReceipts() bool {
+	return false
+}
+
+// CreateToken creates a fungible token or NFT collection with the treasury and supply key from spec
+func (l *HederaLedger) CreateToken(spec TokenSpec) (string, error) {
+	treasuryID, err := hedera.AccountIDFromString(spec.TreasuryAccountID)
+	if err != nil {
+		return "", fmt.Errorf("invalid treasury account ID: %v", err)
+	}
+	treasuryKey, err := hedera.PrivateKeyFromString(spec.TreasuryPrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid treasury private key: %v", err)
+	}
+	supplyKey, err := hedera.PrivateKeyFromString(spec.SupplyPrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid supply private key: %v", err)
+	}
+
+	tokenCreateTx := hedera.NewTokenCreateTransaction().
+		SetTokenName(spec.Name).
+		SetTokenSymbol(spec.Symbol).
+		SetTreasuryAccountID(treasuryID).
+		SetInitialSupply(0).
+		SetSupplyKey(supplyKey.PublicKey())
+	if spec.NonFungible {
+		tokenCreateTx = tokenCreateTx.SetTokenType(hedera.TokenTypeNonFungibleUnique)
+	} else {
+		tokenCreateTx = tokenCreateTx.SetDecimals(uint(spec.Decimals))
+	}
+	tokenCreateTx, err = tokenCreateTx.FreezeWith(l.client)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token transaction: %v", err)
+	}
+
+	var response hedera.TransactionResponse
+	err = l.call("token creation", func() (err error) {
+		response, err = tokenCreateTx.Sign(treasuryKey).Execute(l.client)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to execute token creation: %w", err)
+	}
+	var receipt hedera.TransactionReceipt
+	err = l.call("token creation receipt", func() (err error) {
+		receipt, err = response.GetReceipt(l.client)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("token creation failed: %w", err)
+	}
+
+	return receipt.TokenID.String(), nil
+}
+
+// AssociateToken associates a token with an account, signed with the account's key
+func (l *HederaLedger) AssociateToken(accountID, privateKey, tokenID string) error {
+	accID, err := hedera.AccountIDFromString(accountID)
+	if err != nil {
+		return fmt.Errorf("invalid account ID: %v", err)
+	}
+	key, err := hedera.PrivateKeyFromString(privateKey)
+	if err != nil {
+		return fmt.Errorf("invalid private key: %v", err)
+	}
+	tokID, err := hedera.TokenIDFromString(tokenID)
+	if err != nil {
+		return fmt.Errorf("invalid token ID: %v", err)
+	}
+
+	associateTx, err := hedera.NewTokenAssociateTransaction().
+		SetAccountID(accID).
+		SetTokenIDs(tokID).
+		FreezeWith(l.client)
+	if err != nil {
+		return fmt.Errorf("failed to create association transaction: %v", err)
+	}
+
+	err = l.call("token association", func() error {
+		response, err := associateTx.Sign(key).Execute(l.client)
+		if err == nil {
+			_, err = response.GetReceipt(l.client)
+		}
+		return err
+	})
+	if err != nil {
+		if hederaStatus(err) == hedera.StatusTokenAlreadyAssociatedToAccount {
+			return nil
+		}
+		return fmt.Errorf("token association failed: %w", err)
+	}
+	return nil
+}
+
+// MintToken mints units of a token into its treasury, signed with the supply key
+func (l *HederaLedger) MintToken(tokenID, supplyPrivateKey string, amount int64) error {
+	if amount <= 0 {
+		return fmt.Errorf("invalid mint amount %d", amount)
+	}
+	tokID, err := hedera.TokenIDFromString(tokenID)
+	if err != nil {
+		return fmt.Errorf("invalid token ID: %v", err)
+	}
+	supplyKey, err := hedera.PrivateKeyFromString(supplyPrivateKey)
+	if err != nil {
+		return fmt.Errorf("invalid supply private key: %v", err)
+	}
+
+	mintTx, err := hedera.NewTokenMintTransaction().
+		SetTokenID(tokID).
+		SetAmount(uint64(amount)).
+		FreezeWith(l.client)
+	if err != nil {
+		return fmt.Errorf("failed to create mint transaction: %v", err)
+	}
+
+	var response hedera.TransactionResponse
+	err = l.call("mint", func() (err error) {
+		response, err = mintTx.Sign(supplyKey).Execute(l.client)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute mint: %w", err)
+	}
+	err = l.call("mint receipt", func() (err error) {
+		_, err = response.GetReceipt(l.client)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("mint failed: %w", err)
+	}
+	return nil
+}
+
+// TransferToken transfers token units between two accounts
+func (l *HederaLedger) TransferToken(transactionID, tokenID, fromAccountID, toAccountID, fromPrivateKey string, amount int64) (string, error) {
+	tokID, err := hedera.TokenIDFromString(tokenID)
+	if err != nil {
+		return "", fmt.Errorf("invalid token ID: %v", err)
+	}
+	fromID, err := hedera.AccountIDFromString(fromAccountID)
+	if err != nil {
+		return "", fmt.Errorf("invalid from account ID: %v", err)
+	}
+	toID, err := hedera.AccountIDFromString(toAccountID)
+	if err != nil {
+		return "", fmt.Errorf("invalid to account ID: %v", err)
+	}
+	privateKey, err := hedera.PrivateKeyFromString(fromPrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %v", err)
+	}
+
+	operation := "token transfer"
+	transferTx := hedera.NewTransferTransaction().
+		AddTokenTransfer(tokID, fromID, -amount).
+		AddTokenTransfer(tokID, toID, amount)
+	if transactionID != "" {
+		txID, err := hedera.TransactionIdFromString(transactionID)
+		if err != nil {
+			return "", fmt.Errorf("invalid transaction ID: %v", err)
+		}
+		transferTx = transferTx.SetTransactionID(txID)
+	}
+	transferTx, err = transferTx.FreezeWith(l.client)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token transfer transaction: %v", err)
+	}
+
+	var response hedera.TransactionResponse
+	err = l.call(operation, func() (err error) {
+		response, err = transferTx.Sign(privateKey).Execute(l.client)
+		if err == nil {
+			_, err = response.GetReceipt(l.client)
+		}
+		return err
+	})
+	if err != nil {
+		switch hederaStatus(err) {
+		case hedera.StatusDuplicateTransaction:
+			return "", ErrDuplicateTransaction
+		case hedera.StatusTokenNotAssociatedToAccount:
+			return "", ErrTokenNotAssociated
+		case hedera.StatusInsufficientTokenBalance:
+			return "", ErrInsufficientBalance
+		}
+		return "", fmt.Errorf("token transfer failed: %w", err)
+	}
+
+	return response.TransactionID.String(), nil
+}
+
+// GetTokenBalance gets the units of a token held by an account
+func (l *HederaLedger) GetTokenBalance(accountID, tokenID string) (int64, error) {
+	accID, err := hedera.AccountIDFromString(accountID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid account ID: %v", err)
+	}
+	tokID, err := hedera.TokenIDFromString(tokenID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid token ID: %v", err)
+	}
+
+	var balance hedera.AccountBalance
+	err = l.call("token balance query", func() (err error) {
+		balance, err = hedera.NewAccountBalanceQuery().
+			SetAccountID(accID).
+			Execute(l.client)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query balance: %w", err)
+	}
+
+	return int64(balance.Tokens.Get(tokID)), nil //nolint:staticcheck
+}
+
+// MintNFT mints one NFT with metadata into its collection's treasury, signed with the supply key
+func (l *HederaLedger) MintNFT(tokenID, supplyPrivateKey string, metadata []byte) (int64, error) {
+	tokID, err := hedera.TokenIDFromString(tokenID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid token ID: %v", err)
+	}
+	supplyKey, err := hedera.PrivateKeyFromString(supplyPrivateKey)
+	if err != nil {
+		return 0, fmt.Errorf("invalid supply private key: %v", err)
+	}
+
+	mintTx, err := hedera.NewTokenMintTransaction().
+		SetTokenID(tokID).
+		SetMetadata(metadata).
+		FreezeWith(l.client)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create NFT mint transaction: %v", err)
+	}
+
+	var response hedera.TransactionResponse
+	err = l.call("NFT mint", func() (err error) {
+		response, err = mintTx.Sign(supplyKey).Execute(l.client)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute NFT mint: %w", err)
+	}
+	var receipt hedera.TransactionReceipt
+	err = l.call("NFT mint receipt", func() (err error) {
+		receipt, err = response.GetReceipt(l.client)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("NFT mint failed: %w", err)
+	}
+	if len(receipt.SerialNumbers) == 0 {
+		return 0, fmt.Errorf("NFT mint receipt has no serial number")
+	}
+	return receipt.SerialNumbers[0], nil
+}
+
+// TransferNFT transfers one NFT between two accounts
+func (l *HederaLedger) TransferNFT(transactionID, tokenID string, serialNumber int64, fromAccountID, toAccountID, fromPrivateKey string) (string, error) {
+	tokID, err := hedera.TokenIDFromString(tokenID)
+	if err != nil {
+		return "", fmt.Errorf("invalid token ID: %v", err)
+	}
+	fromID, err := hedera.AccountIDFromString(fromAccountID)
+	if err != nil {
+		return "", fmt.Errorf("invalid from account ID: %v", err)
+	}
+	toID, err := hedera.AccountIDFromString(toAccountID)
+	if err != nil {
+		return "", fmt.Errorf("invalid to account ID: %v", err)
+	}
+	privateKey, err := hedera.PrivateKeyFromString(fromPrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %v", err)
+	}
+
+	operation := "NFT transfer"
+	transferTx := hedera.NewTransferTransaction().
+		AddNftTransfer(hedera.NftID{TokenID: tokID, SerialNumber: serialNumber}, fromID, toID)
+	if transactionID != "" {
+		txID, err := hedera.TransactionIdFromString(transactionID)
+		if err != nil {
+			return "", fmt.Errorf("invalid transaction ID: %v", err)
+		}
+		transferTx = transferTx.SetTransactionID(txID)
+	}
+	transferTx, err = transferTx.FreezeWith(l.client)
+	if err != nil {
+		return "", fmt.Errorf("failed to create NFT transfer transaction: %v", err)
+	}
+
+	var response hedera.TransactionResponse
+	err = l.call(operation, func() (err error) {
+		response, err = transferTx.Sign(privateKey).Execute(l.client)
+		if err == nil {
+			_, err = response.GetReceipt(l.client)
+		}
+		return err
+	})
+	if err != nil {
+		switch hederaStatus(err) {
+		case hedera.StatusDuplicateTransaction:
+			return "", ErrDuplicateTransaction
+		case hedera.StatusTokenNotAssociatedToAccount:
+			return "", ErrTokenNotAssociated
+		case hedera.StatusSenderDoesNotOwnNftSerialNo:
+			return "", ErrNFTNotOwned
+		}
+		return "", fmt.Errorf("NFT transfer failed: %w", err)
+	}
+
+	return response.TransactionID.String(), nil
+}
+
+// CreateTopic creates a consensus topic whose submit key is the operator's key
+func (l *HederaLedger) CreateTopic(memo string) (string, error) {
+	var receipt hedera.TransactionReceipt
+	err := l.call("topic creation", func() error {
+		response, err := hedera.NewTopicCreateTransaction().
+			SetTopicMemo(memo).
+			SetSubmitKey(l.client.GetOperatorPublicKey()).
+			Execute(l.client)
+		if err == nil {
+			receipt, err = response.GetReceipt(l.client)
+		}
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("topic creation failed: %w", err)
+	}
+
+	return receipt.TopicID.String(), nil
+}
+
+// SubmitTopicMessage submits a single-chunk message to a topic
+func (l *HederaLedger) SubmitTopicMessage(topicID string, message []byte) (int64, error) {
+	topID, err := hedera.TopicIDFromString(topicID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid topic ID: %v", err)
+	}
+
+	var response hedera.TransactionResponse
+	err = l.call("topic message", func() (err error) {
+		response, err = hedera.NewTopicMessageSubmitTransaction().
+			SetTopicID(topID).
+			SetMessage(message).
+			Execute(l.client)
+		return err
+	})
+	if err != nil {
+		if hederaStatus(err) == hedera.StatusInvalidTopicID {
+			return 0, ErrTopicNotFound
+		}
+		return 0, fmt.Errorf("failed to submit topic message: %w", err)
+	}
+	var receipt hedera.TransactionReceipt
+	err = l.call("topic message receipt", func() (err error) {
+		receipt, err = response.GetReceipt(l.client)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("topic message failed: %w", err)
+	}
+
+	return int64(receipt.TopicSequenceNumber), nil
+}
+
+// GetTopicMessage fetches a topic message from the mirror node. Messages
+// reach the mirror node a few seconds after consensus.
+func (l *HederaLedger) GetTopicMessage(topicID string, sequenceNumber int64) ([]byte, error) {
+	url := fmt.Sprintf("%s/api/v1/topics/%s/messages/%d", MirrorNodeURL(), topicID, sequenceNumber)
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	response, err := httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query mirror node: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrMessageNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("mirror node returned %s", response.Status)
+	}
+
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid mirror node response: %v", err)
+	}
+	message, err := base64.StdEncoding.DecodeString(body.Message)
+	if err != nil {
+		return nil, fmt.Errorf("invalid topic message encoding: %v", err)
+	}
+	return message, nil
+}
+
+// hederaStatus extracts the network status from a precheck or receipt error
+func hederaStatus(err error) hedera.Status {
+	var precheckErr hedera.ErrHederaPreCheckStatus
+	if errors.As(err, &precheckErr) {
+		return precheckErr.Status
+	}
+	var receiptErr hedera.ErrHederaReceiptStatus
+	if errors.As(err, &receiptErr) {
+		return receiptErr.Status
+	}
+	return hedera.StatusUnknown
+}
+
+// createAccount creates a Hedera account with a freshly generated key
+func createAccount(client *hedera.Client, initialBalance money.Tinybars) (string, string, error) {
+	privateKey, err := hedera.GeneratePrivateKey()
+	if err != nil {
+		return "", "", err
+	}
+	publicKey := privateKey.PublicKey()
+
+	transaction, err := hedera.NewAccountCreateTransaction().
+		SetKey(publicKey).
+		SetInitialBalance(hedera.HbarFromTinybar(initialBalance.Int64())).
+		SetAccountMemo("OnCure user account").
+		Execute(client)
+	if err != nil {
+		return "", "", err
+	}
+
+	receipt, err := transaction.GetReceipt(client)
+	if err != nil {
+		return "", "", err
+	}
+
+	log.Printf("Created new account %s with %s HBAR initial balance", receipt.AccountID.String(), initialBalance)
+	return receipt.AccountID.String(), privateKey.String(), nil
+}
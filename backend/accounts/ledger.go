@@ -0,0 +1,139 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+// InitialAccountBalance is the amount every new user account is funded with
+const InitialAccountBalance = 5 * money.TinybarsPerHbar
+
+// ReceiptStatusSuccess is the receipt status reported for a transaction that reached consensus successfully
+const ReceiptStatusSuccess = "SUCCESS"
+
+var (
+	ErrAccountNotFound      = errors.New("account not found")
+	ErrInvalidSignature     = errors.New("private key does not match account")
+	ErrInsufficientBalance  = errors.New("insufficient balance")
+	ErrReceiptNotFound      = errors.New("transaction receipt not found")
+	ErrTransactionNotFound  = errors.New("transaction not found on the mirror node")
+	ErrDuplicateTransaction = errors.New("transaction ID has already been submitted")
+	ErrTokenNotFound        = errors.New("token not found")
+	ErrTokenNotAssociated   = errors.New("token is not associated with account")
+	ErrNFTNotOwned          = errors.New("account does not own this NFT")
+	ErrTopicNotFound        = errors.New("topic not found")
+	ErrMessageNotFound      = errors.New("topic message not found")
+	ErrLedgerTimeout        = errors.New("ledger call timed out")
+)
+
+// Receipt is the outcome of a submitted ledger transaction
+type Receipt struct {
+	TransactionID string `json:"transaction_id"`
+	Status        string `json:"status"`
+}
+
+// TokenSpec describes a token to create on the ledger. NonFungible creates
+// an NFT collection whose tokens are minted one serial number at a time.
+type TokenSpec struct {
+	Name               string
+	Symbol             string
+	Decimals           int
+	TreasuryAccountID  string
+	TreasuryPrivateKey string
+	SupplyPrivateKey   string
+	NonFungible        bool
+}
+
+// Ledger is the set of ledger operations the application depends on.
+// Handlers receive a Ledger instead of calling the Hedera SDK directly so the
+// registration and tipping flows can run against an in-memory fake.
+type Ledger interface {
+	// CreateAccount creates a new account funded with initialBalance
+	// and returns its account ID and private key
+	CreateAccount(initialBalance money.Tinybars) (string, string, error)
+
+	// NewTransactionID reserves a transaction ID so a transfer can be
+	// recorded before it is submitted and looked up again afterwards
+	NewTransactionID() (string, error)
+
+	// TransferHbar moves amount between two accounts, signed with the
+	// sender's private key, and returns the transaction ID. A non-empty
+	// transactionID submits the transfer under that reserved ID.
+	TransferHbar(transactionID, fromAccountID, toAccountID, fromPrivateKey string, amount money.Tinybars) (string, error)
+
+	// GetAccountBalance returns the HBAR balance of an account
+	GetAccountBalance(accountID string) (money.Tinybars, error)
+
+	// GetTransactionReceipt looks up the receipt of a submitted transaction
+	GetTransactionReceipt(transactionID string) (*Receipt, error)
+
+	// KeepsReceipts reports whether the ledger keeps every receipt, so a
+	// transaction whose receipt is not found never reached it. Ledgers that
+	// drop old receipts leave the outcome to the mirror node.
+	KeepsReceipts() bool
+
+	// CreateToken creates a fungible token or NFT collection with no initial
+	// supply, held by the spec's treasury and minted with its supply key, and
+	// returns its ID
+	CreateToken(spec TokenSpec) (string, error)
+
+	// AssociateToken lets an account hold a token. Associating an account
+	// that is already associated is not an error.
+	AssociateToken(accountID, privateKey, tokenID string) error
+
+	// MintToken mints amount units of a token into its treasury
+	MintToken(tokenID, supplyPrivateKey string, amount int64) error
+
+	// TransferToken moves amount units of a token between two associated
+	// accounts and returns the transaction ID. A non-empty transactionID
+	// submits the transfer under that reserved ID.
+	TransferToken(transactionID, tokenID, fromAccountID, toAccountID, fromPrivateKey string, amount int64) (string, error)
+
+	// GetTokenBalance returns the units of a token held by an account. For
+	// an NFT collection this is the number of NFTs the account holds.
+	GetTokenBalance(accountID, tokenID string) (int64, error)
+
+	// MintNFT mints one NFT of a collection into its treasury with the given
+	// metadata and returns its serial number
+	MintNFT(tokenID, supplyPrivateKey string, metadata []byte) (int64, error)
+
+	// TransferNFT moves one NFT between two accounts associated with its
+	// collection and returns the transaction ID. A non-empty transactionID
+	// submits the transfer under that reserved ID.
+	TransferNFT(transactionID, tokenID string, serialNumber int64, fromAccountID, toAccountID, fromPrivateKey string) (string, error)
+
+	// CreateTopic creates a consensus topic only the operator can submit
+	// messages to and returns its ID
+	CreateTopic(memo string) (string, error)
+
+	// SubmitTopicMessage submits a message to a topic and returns the
+	// sequence number it was assigned
+	SubmitTopicMessage(topicID string, message []byte) (int64, error)
+
+	// GetTopicMessage returns the message a topic holds at sequenceNumber
+	GetTopicMessage(topicID string, sequenceNumber int64) ([]byte, error)
+
+	// Ping checks that the ledger can be reached with the operator account
+	Ping() error
+}
+
+// NewLedgerFromEnv returns the ledger selected by LEDGER_BACKEND.
+// "memory" selects the in-memory fake and "hedera" or an unset variable
+// selects Hedera; any other value is an error.
+func NewLedgerFromEnv() (Ledger, error) {
+	switch backend := os.Getenv("LEDGER_BACKEND"); backend {
+	case "memory":
+		return NewMemoryLedger(), nil
+	case "", "hedera":
+		ledger, err := NewHederaLedgerFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return ledger, nil
+	default:
+		return nil, fmt.Errorf("unknown LEDGER_BACKEND %q", backend)
+	}
+}
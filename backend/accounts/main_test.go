@@ -0,0 +1,11 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/On-cure/Oncure/pkg/db/dbtest"
+)
+
+func TestMain(m *testing.M) {
+	dbtest.Main(m)
+}
@@ -0,0 +1,401 @@
+package wallet
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+// memoryOperatorAccountID pays for transactions submitted to the in-memory ledger
+const memoryOperatorAccountID = "0.0.2"
+
+// memoryAccount is an account held by the in-memory ledger
+type memoryAccount struct {
+	privateKey string
+	balance    money.Tinybars
+	tokens     map[string]int64
+}
+
+// memoryToken is a fungible token or NFT collection held by the in-memory ledger
+type memoryToken struct {
+	treasuryAccountID string
+	supplyPrivateKey  string
+	nonFungible       bool
+	nftOwners         map[int64]string
+}
+
+// MemoryLedger is a deterministic in-memory Ledger for running registration
+// and tipping flows offline. Account IDs, keys and transaction IDs are
+// generated from counters so repeated runs produce the same values. Nothing
+// is persisted, so it must start with a database that has no wallets.
+type MemoryLedger struct {
+	mu          sync.Mutex
+	accounts    map[string]*memoryAccount
+	receipts    map[string]*Receipt
+	tokens      map[string]*memoryToken
+	topics      map[string][][]byte
+	nextAccount int64
+	nextTx      int64
+}
+
+// NewMemoryLedger creates an empty in-memory ledger
+func NewMemoryLedger() *MemoryLedger {
+	return &MemoryLedger{
+		accounts:    make(map[string]*memoryAccount),
+		receipts:    make(map[string]*Receipt),
+		tokens:      make(map[string]*memoryToken),
+		topics:      make(map[string][][]byte),
+		nextAccount: 1001,
+		nextTx:      1,
+	}
+}
+
+// CreateAccount creates an account funded with initialBalance
+func (l *MemoryLedger) CreateAccount(initialBalance money.Tinybars) (string, string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	accountID := fmt.Sprintf("0.0.%d", l.nextAccount)
+	privateKey := fmt.Sprintf("memory-key-%d", l.nextAccount)
+	l.nextAccount++
+
+	l.accounts[accountID] = &memoryAccount{
+		privateKey: privateKey,
+		balance:    initialBalance,
+		tokens:     make(map[string]int64),
+	}
+	return accountID, privateKey, nil
+}
+
+// NewTransactionID reserves the next transaction ID
+func (l *MemoryLedger) NewTransactionID() (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.newTransactionID(memoryOperatorAccountID), nil
+}
+
+// TransferHbar moves HBAR between two in-memory accounts
+func (l *MemoryLedger) TransferHbar(transactionID, fromAccountID, toAccountID, fromPrivateKey string, amount money.Tinybars) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, submitted := l.receipts[transactionID]; submitted {
+		return "", ErrDuplicateTransaction
+	}
+
+	from, ok := l.accounts[fromAccountID]
+	if !ok {
+		return "", fmt.Errorf("invalid from account ID: %w", ErrAccountNotFound)
+	}
+	to, ok := l.accounts[toAccountID]
+	if !ok {
+		return "", fmt.Errorf("invalid to account ID: %w", ErrAccountNotFound)
+	}
+	if from.privateKey != fromPrivateKey {
+		return "", ErrInvalidSignature
+	}
+	if amount <= 0 {
+		return "", fmt.Errorf("invalid transfer amount %s", amount)
+	}
+	if from.balance < amount {
+		return "", ErrInsufficientBalance
+	}
+
+	from.balance -= amount
+	to.balance += amount
+
+	if transactionID == "" {
+		transactionID = l.newTransactionID(memoryOperatorAccountID)
+	}
+	l.receipts[transactionID] = &Receipt{TransactionID: transactionID, Status: ReceiptStatusSuccess}
+	return transactionID, nil
+}
+
+// GetAccountBalance returns the HBAR balance of an in-memory account
+func (l *MemoryLedger) GetAccountBalance(accountID string) (money.Tinybars, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	account, ok := l.accounts[accountID]
+	if !ok {
+		return 0, ErrAccountNotFound
+	}
+	return account.balance, nil
+}
+
+// GetTransactionReceipt returns the receipt recorded for a transaction
+func (l *MemoryLedger) GetTransactionReceipt(transactionID string) (*Receipt, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	receipt, ok := l.receipts[transactionID]
+	if !ok {
+		return nil, ErrReceiptNotFound
+	}
+	copied := *receipt
+	return &copied, nil
+}
+
+// KeepsReceipts reports true; the in-memory ledger never drops a receipt
+func (l *MemoryLedger) KeepsReceipts() bool {
+	return true
+}
+
+// CreateToken creates a token whose treasury is an existing in-memory account
+func (l *MemoryLedger) CreateToken(spec TokenSpec) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	treasury, ok := l.accounts[spec.TreasuryAccountID]
+	if !ok {
+		return "", fmt.Errorf("invalid treasury account ID: %w", ErrAccountNotFound)
+	}
+	if treasury.privateKey != spec.TreasuryPrivateKey {
+		return "", ErrInvalidSignature
+	}
+
+	// Tokens share the account number space, as they do on Hedera
+	tokenID := fmt.Sprintf("0.0.%d", l.nextAccount)
+	l.nextAccount++
+
+	l.tokens[tokenID] = &memoryToken{
+		treasuryAccountID: spec.TreasuryAccountID,
+		supplyPrivateKey:  spec.SupplyPrivateKey,
+		nonFungible:       spec.NonFungible,
+		nftOwners:         make(map[int64]string),
+	}
+	treasury.tokens[tokenID] = 0
+	return tokenID, nil
+}
+
+// AssociateToken lets an in-memory account hold a token
+func (l *MemoryLedger) AssociateToken(accountID, privateKey, tokenID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	account, ok := l.accounts[accountID]
+	if !ok {
+		return ErrAccountNotFound
+	}
+	if account.privateKey != privateKey {
+		return ErrInvalidSignature
+	}
+	if _, ok := l.tokens[tokenID]; !ok {
+		return ErrTokenNotFound
+	}
+	if _, associated := account.tokens[tokenID]; !associated {
+		account.tokens[tokenID] = 0
+	}
+	return nil
+}
+
+// MintToken mints units of a token into its treasury account
+func (l *MemoryLedger) MintToken(tokenID, supplyPrivateKey string, amount int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	token, ok := l.tokens[tokenID]
+	if !ok {
+		return ErrTokenNotFound
+	}
+	if token.supplyPrivateKey != supplyPrivateKey {
+		return ErrInvalidSignature
+	}
+	if token.nonFungible {
+		return fmt.Errorf("token %s is an NFT collection", tokenID)
+	}
+	if amount <= 0 {
+		return fmt.Errorf("invalid mint amount %d", amount)
+	}
+
+	l.accounts[token.treasuryAccountID].tokens[tokenID] += amount
+	return nil
+}
+
+// TransferToken moves token units between two associated in-memory accounts
+func (l *MemoryLedger) TransferToken(transactionID, tokenID, fromAccountID, toAccountID, fromPrivateKey string, amount int64) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, submitted := l.receipts[transactionID]; submitted {
+		return "", ErrDuplicateTransaction
+	}
+	if token, ok := l.tokens[tokenID]; !ok {
+		return "", ErrTokenNotFound
+	} else if token.nonFungible {
+		return "", fmt.Errorf("token %s is an NFT collection", tokenID)
+	}
+
+	from, ok := l.accounts[fromAccountID]
+	if !ok {
+		return "", fmt.Errorf("invalid from account ID: %w", ErrAccountNotFound)
+	}
+	to, ok := l.accounts[toAccountID]
+	if !ok {
+		return "", fmt.Errorf("invalid to account ID: %w", ErrAccountNotFound)
+	}
+	if from.privateKey != fromPrivateKey {
+		return "", ErrInvalidSignature
+	}
+	fromBalance, fromAssociated := from.tokens[tokenID]
+	_, toAssociated := to.tokens[tokenID]
+	if !fromAssociated || !toAssociated {
+		return "", ErrTokenNotAssociated
+	}
+	if amount <= 0 {
+		return "", fmt.Errorf("invalid transfer amount %d", amount)
+	}
+	if fromBalance < amount {
+		return "", ErrInsufficientBalance
+	}
+
+	from.tokens[tokenID] -= amount
+	to.tokens[tokenID] += amount
+
+	if transactionID == "" {
+		transactionID = l.newTransactionID(memoryOperatorAccountID)
+	}
+	l.receipts[transactionID] = &Receipt{TransactionID: transactionID, Status: ReceiptStatusSuccess}
+	return transactionID, nil
+}
+
+// GetTokenBalance returns the units of a token held by an in-memory account
+func (l *MemoryLedger) GetTokenBalance(accountID, tokenID string) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	account, ok := l.accounts[accountID]
+	if !ok {
+		return 0, ErrAccountNotFound
+	}
+	return account.tokens[tokenID], nil
+}
+
+// MintNFT mints the next serial number of an in-memory NFT collection into its treasury
+func (l *MemoryLedger) MintNFT(tokenID, supplyPrivateKey string, metadata []byte) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	token, ok := l.tokens[tokenID]
+	if !ok {
+		return 0, ErrTokenNotFound
+	}
+	if token.supplyPrivateKey != supplyPrivateKey {
+		return 0, ErrInvalidSignature
+	}
+	if !token.nonFungible {
+		return 0, fmt.Errorf("token %s is not an NFT collection", tokenID)
+	}
+	if len(metadata) > 100 {
+		return 0, fmt.Errorf("NFT metadata is %d bytes, limit is 100", len(metadata))
+	}
+
+	serialNumber := int64(len(token.nftOwners) + 1)
+	token.nftOwners[serialNumber] = token.treasuryAccountID
+	l.accounts[token.treasuryAccountID].tokens[tokenID]++
+	return serialNumber, nil
+}
+
+// TransferNFT moves one NFT between two associated in-memory accounts
+func (l *MemoryLedger) TransferNFT(transactionID, tokenID string, serialNumber int64, fromAccountID, toAccountID, fromPrivateKey string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, submitted := l.receipts[transactionID]; submitted {
+		return "", ErrDuplicateTransaction
+	}
+	token, ok := l.tokens[tokenID]
+	if !ok || !token.nonFungible {
+		return "", ErrTokenNotFound
+	}
+
+	from, ok := l.accounts[fromAccountID]
+	if !ok {
+		return "", fmt.Errorf("invalid from account ID: %w", ErrAccountNotFound)
+	}
+	to, ok := l.accounts[toAccountID]
+	if !ok {
+		return "", fmt.Errorf("invalid to account ID: %w", ErrAccountNotFound)
+	}
+	if from.privateKey != fromPrivateKey {
+		return "", ErrInvalidSignature
+	}
+	if _, associated := to.tokens[tokenID]; !associated {
+		return "", ErrTokenNotAssociated
+	}
+	if token.nftOwners[serialNumber] != fromAccountID {
+		return "", ErrNFTNotOwned
+	}
+
+	token.nftOwners[serialNumber] = toAccountID
+	from.tokens[tokenID]--
+	to.tokens[tokenID]++
+
+	if transactionID == "" {
+		transactionID = l.newTransactionID(memoryOperatorAccountID)
+	}
+	l.receipts[transactionID] = &Receipt{TransactionID: transactionID, Status: ReceiptStatusSuccess}
+	return transactionID, nil
+}
+
+// CreateTopic creates an empty in-memory topic
+func (l *MemoryLedger) CreateTopic(memo string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Topics share the account number space, as they do on Hedera
+	topicID := fmt.Sprintf("0.0.%d", l.nextAccount)
+	l.nextAccount++
+
+	l.topics[topicID] = [][]byte{}
+	return topicID, nil
+}
+
+// SubmitTopicMessage appends a message to an in-memory topic
+func (l *MemoryLedger) SubmitTopicMessage(topicID string, message []byte) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	messages, ok := l.topics[topicID]
+	if !ok {
+		return 0, ErrTopicNotFound
+	}
+	if len(message) == 0 || len(message) > 1024 {
+		return 0, fmt.Errorf("topic message is %d bytes, must be 1 to 1024", len(message))
+	}
+
+	l.topics[topicID] = append(messages, append([]byte(nil), message...))
+	return int64(len(messages) + 1), nil
+}
+
+// GetTopicMessage returns a message of an in-memory topic by sequence number
+func (l *MemoryLedger) GetTopicMessage(topicID string, sequenceNumber int64) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	messages, ok := l.topics[topicID]
+	if !ok {
+		return nil, ErrTopicNotFound
+	}
+	if sequenceNumber < 1 || sequenceNumber > int64(len(messages)) {
+		return nil, ErrMessageNotFound
+	}
+	return append([]byte(nil), messages[sequenceNumber-1]...), nil
+}
+
+// Ping always succeeds; the in-memory ledger cannot be unreachable
+func (l *MemoryLedger) Ping() error {
+	return nil
+}
+
+// newTransactionID builds a Hedera-formatted transaction ID from a counter,
+// paid for by the given account. Callers must hold l.mu.
+func (l *MemoryLedger) newTransactionID(payerAccountID string) string {
+	seq := l.nextTx
+	l.nextTx++
+	validStart := time.Unix(1700000000+seq, 0).UTC()
+	return fmt.Sprintf("%s@%d.%09d", payerAccountID, validStart.Unix(), validStart.Nanosecond())
+}
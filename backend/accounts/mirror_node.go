@@ -0,0 +1,243 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+// MirrorNode reads balances and transactions from a Hedera mirror node REST API
+type MirrorNode struct {
+	baseURL string
+	client  *http.Client
+}
+
+// MirrorTransaction is a transaction as reported by the mirror node
+type MirrorTransaction struct {
+	// TransactionID is in the SDK format stored on transfers, e.g. 0.0.2@1700000000.000000001
+	TransactionID      string
+	ConsensusTimestamp string
+	Name               string
+	Result             string
+	PayerAccountID     string
+	ChargedFee         money.Tinybars
+	Transfers          []MirrorTransfer
+}
+
+// MirrorTransfer is one HBAR movement within a transaction
+type MirrorTransfer struct {
+	AccountID string
+	Amount    money.Tinybars
+}
+
+// NewMirrorNode creates a client for the mirror node at baseURL
+func NewMirrorNode(baseURL string) *MirrorNode {
+	return &MirrorNode{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// NewMirrorNodeFromEnv creates a client for MirrorNodeURL. It returns nil for
+// the in-memory ledger unless HEDERA_MIRROR_NODE_URL points at a stand-in,
+// since a public mirror node knows nothing about in-memory accounts.
+func NewMirrorNodeFromEnv() *MirrorNode {
+	if os.Getenv("LEDGER_BACKEND") == "memory" && os.Getenv("HEDERA_MIRROR_NODE_URL") == "" {
+		return nil
+	}
+	return NewMirrorNode(MirrorNodeURL())
+}
+
+// Succeeded reports whether the transaction reached consensus successfully
+func (t MirrorTransaction) Succeeded() bool {
+	return t.Result == "SUCCESS"
+}
+
+// NetAmount returns how much HBAR the transaction moved into (positive) or
+// out of (negative) an account, leaving out the fee when the account paid it
+func (t MirrorTransaction) NetAmount(accountID string) money.Tinybars {
+	var net money.Tinybars
+	for _, transfer := range t.Transfers {
+		if transfer.AccountID == accountID {
+			net += transfer.Amount
+		}
+	}
+	if t.PayerAccountID == accountID {
+		net += t.ChargedFee
+	}
+	return net
+}
+
+// GetAccountBalance returns the HBAR balance of an account
+func (m *MirrorNode) GetAccountBalance(accountID string) (money.Tinybars, error) {
+	var body struct {
+		Balances []struct {
+			Account string `json:"account"`
+			Balance int64  `json:"balance"`
+		} `json:"balances"`
+	}
+	if err := m.get("/api/v1/balances?account.id="+url.QueryEscape(accountID), &body); err != nil {
+		return 0, err
+	}
+	for _, balance := range body.Balances {
+		if balance.Account == accountID {
+			return money.Tinybars(balance.Balance), nil
+		}
+	}
+	return 0, ErrAccountNotFound
+}
+
+// GetTransactions returns up to limit crypto transfers involving an account
+// with a consensus timestamp after the given one, oldest first. An empty
+// after starts from the account's first transaction.
+func (m *MirrorNode) GetTransactions(accountID, after string, limit int) ([]MirrorTransaction, error) {
+	query := url.Values{}
+	query.Set("account.id", accountID)
+	query.Set("transactiontype", "CRYPTOTRANSFER")
+	query.Set("order", "asc")
+	query.Set("limit", fmt.Sprint(min(limit, 100)))
+	if after != "" {
+		query.Set("timestamp", "gt:"+after)
+	}
+
+	var transactions []MirrorTransaction
+	next := "/api/v1/transactions?" + query.Encode()
+	for next != "" && len(transactions) < limit {
+		var body struct {
+			Transactions []mirrorTransactionJSON `json:"transactions"`
+			Links        struct {
+				Next string `json:"next"`
+			} `json:"links"`
+		}
+		if err := m.get(next, &body); err != nil {
+			return nil, err
+		}
+
+		for _, t := range body.Transactions {
+			transactions = append(transactions, t.transaction())
+		}
+		next = body.Links.Next
+	}
+
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+	}
+	return transactions, nil
+}
+
+// GetTransaction returns the transaction with an SDK-format transaction ID,
+// or ErrTransactionNotFound when it never reached consensus or the mirror
+// node has not imported it yet. A transaction ID submitted more than once is
+// reported as its successful attempt when there is one.
+func (m *MirrorNode) GetTransaction(transactionID string) (*MirrorTransaction, error) {
+	var body struct {
+		Transactions []mirrorTransactionJSON `json:"transactions"`
+	}
+	err := m.get("/api/v1/transactions/"+url.PathEscape(mirrorTransactionID(transactionID)), &body)
+	if errors.Is(err, ErrAccountNotFound) || (err == nil && len(body.Transactions) == 0) {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	transaction := body.Transactions[0].transaction()
+	for _, t := range body.Transactions[1:] {
+		if t.Result == "SUCCESS" {
+			transaction = t.transaction()
+		}
+	}
+	return &transaction, nil
+}
+
+// mirrorTransactionJSON is a transaction in a mirror node response
+type mirrorTransactionJSON struct {
+	TransactionID      string `json:"transaction_id"`
+	ConsensusTimestamp string `json:"consensus_timestamp"`
+	Name               string `json:"name"`
+	Result             string `json:"result"`
+	ChargedTxFee       int64  `json:"charged_tx_fee"`
+	Transfers          []struct {
+		Account string `json:"account"`
+		Amount  int64  `json:"amount"`
+	} `json:"transfers"`
+}
+
+// transaction converts a mirror node transaction to a MirrorTransaction
+func (t mirrorTransactionJSON) transaction() MirrorTransaction {
+	transaction := MirrorTransaction{
+		TransactionID:      sdkTransactionID(t.TransactionID),
+		ConsensusTimestamp: t.ConsensusTimestamp,
+		Name:               t.Name,
+		Result:             t.Result,
+		PayerAccountID:     strings.SplitN(t.TransactionID, "-", 2)[0],
+		ChargedFee:         money.Tinybars(t.ChargedTxFee),
+	}
+	for _, transfer := range t.Transfers {
+		transaction.Transfers = append(transaction.Transfers, MirrorTransfer{
+			AccountID: transfer.Account,
+			Amount:    money.Tinybars(transfer.Amount),
+		})
+	}
+	return transaction
+}
+
+// get fetches a mirror node path and decodes the JSON response
+func (m *MirrorNode) get(path string, out interface{}) error {
+	response, err := m.client.Get(m.baseURL + path)
+	if err != nil {
+		return fmt.Errorf("failed to query mirror node: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return ErrAccountNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("mirror node returned %s", response.Status)
+	}
+	if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid mirror node response: %v", err)
+	}
+	return nil
+}
+
+// sdkTransactionID converts a mirror node transaction ID such as
+// 0.0.2-1700000000-000000001 to the SDK form 0.0.2@1700000000.000000001
+func sdkTransactionID(mirrorID string) string {
+	parts := strings.Split(mirrorID, "-")
+	if len(parts) != 3 {
+		return mirrorID
+	}
+	return parts[0] + "@" + parts[1] + "." + parts[2]
+}
+
+// transactionValidStart returns the valid start time encoded in an SDK
+// transaction ID
+func transactionValidStart(sdkID string) (time.Time, bool) {
+	_, validStart, ok := strings.Cut(sdkID, "@")
+	if !ok {
+		return time.Time{}, false
+	}
+	secondsPart, nanosPart, ok := strings.Cut(validStart, ".")
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(secondsPart, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	nanos, err := strconv.ParseInt(nanosPart, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, nanos), true
+}
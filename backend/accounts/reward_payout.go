@@ -0,0 +1,131 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+var ErrCommunityTokenDisabled = errors.New("community token is not configured")
+
+// PayRewardAllocation pays a pending reward allocation from the community
+// token treasury. Like transfers it runs in two phases: the allocation is
+// claimed under a reserved transaction ID before the payment is submitted,
+// so a crash or lost response is settled later from the receipt.
+func (s *TransferService) PayRewardAllocation(allocation *models.RewardAllocation) error {
+	if !s.token.Enabled() {
+		return ErrCommunityTokenDisabled
+	}
+	if allocation.Status != models.RewardAllocationPending {
+		return nil
+	}
+
+	userWallet, err := models.GetUserWallet(s.db, allocation.UserID)
+	if err != nil {
+		return err
+	}
+	if userWallet == nil {
+		s.failRewardPayment(allocation, "recipient has no wallet")
+		return nil
+	}
+	if err := s.token.EnsureAssociated(s.db, userWallet); err != nil {
+		s.failRewardPayment(allocation, "failed to associate community token: "+err.Error())
+		return nil
+	}
+
+	transactionID, err := s.ledger.NewTransactionID()
+	if err != nil {
+		return fmt.Errorf("failed to reserve transaction ID: %v", err)
+	}
+
+	// Phase one: claim the allocation so no other payer submits it
+	claimed, err := models.MarkRewardAllocationSubmitted(s.db, allocation.ID, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to record reward payment: %v", err)
+	}
+	if !claimed {
+		return nil
+	}
+	allocation.Status = models.RewardAllocationSubmitted
+	allocation.TransactionID = transactionID
+
+	// Phase two: submit and settle the allocation
+	_, err = s.token.SendFromTreasury(transactionID, userWallet.HederaAccountID, allocation.AmountUnits)
+	if err != nil {
+		log.Printf("Reward allocation %d submission error: %v", allocation.ID, err)
+		if errors.Is(err, ErrLedgerTimeout) {
+			// An unanswered submission is left for recovery to settle from its receipt
+			return nil
+		}
+		if resolveErr := s.resolveRewardPayment(allocation, err.Error()); resolveErr != nil {
+			log.Printf("Reward allocation %d left submitted: %v", allocation.ID, resolveErr)
+		}
+		return nil
+	}
+
+	s.completeRewardPayment(allocation)
+	return nil
+}
+
+// resolveRewardPayment settles a submitted allocation from its receipt
+func (s *TransferService) resolveRewardPayment(allocation *models.RewardAllocation, notFoundReason string) error {
+	result, reason, err := s.outcome(allocation.TransactionID, notFoundReason)
+	if err != nil {
+		return err
+	}
+	switch result {
+	case outcomeFailed:
+		s.failRewardPayment(allocation, reason)
+	case outcomeUnconfirmed:
+		if err := models.MarkRewardAllocationNeedsReview(s.db, allocation.ID, reason); err != nil {
+			log.Printf("Failed to mark reward allocation %d for review: %v", allocation.ID, err)
+			return nil
+		}
+		log.Printf("Reward allocation %d needs review: %s", allocation.ID, reason)
+		allocation.Status = models.RewardAllocationNeedsReview
+		allocation.FailureReason = reason
+	default:
+		s.completeRewardPayment(allocation)
+	}
+	return nil
+}
+
+// completeRewardPayment marks an allocation paid and tells the recipient
+func (s *TransferService) completeRewardPayment(allocation *models.RewardAllocation) {
+	completed, err := models.MarkRewardAllocationCompleted(s.db, allocation.ID)
+	if err != nil {
+		log.Printf("Failed to mark reward allocation %d completed: %v", allocation.ID, err)
+		return
+	}
+	allocation.Status = models.RewardAllocationCompleted
+	allocation.FailureReason = ""
+
+	if completed {
+		message := fmt.Sprintf("You earned %s %s in contributor rewards",
+			s.token.Format(allocation.AmountUnits), s.token.Symbol())
+		if _, err := models.CreateNotification(s.db, allocation.UserID, "reward_received", message, allocation.RunID); err != nil {
+			log.Printf("Failed to create reward notification for allocation %d: %v", allocation.ID, err)
+		}
+	}
+	s.settleRewardRun(allocation.RunID)
+}
+
+// failRewardPayment marks an allocation failed, leaving it for recovery if the update fails
+func (s *TransferService) failRewardPayment(allocation *models.RewardAllocation, reason string) {
+	if err := models.MarkRewardAllocationFailed(s.db, allocation.ID, reason); err != nil {
+		log.Printf("Failed to mark reward allocation %d failed: %v", allocation.ID, err)
+		return
+	}
+	allocation.Status = models.RewardAllocationFailed
+	allocation.FailureReason = reason
+	s.settleRewardRun(allocation.RunID)
+}
+
+// settleRewardRun completes a run once all of its payments are settled
+func (s *TransferService) settleRewardRun(runID int) {
+	if err := models.CompleteRewardRunIfSettled(s.db, runID); err != nil {
+		log.Printf("Failed to settle reward run %d: %v", runID, err)
+	}
+}
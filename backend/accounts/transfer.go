@@ -0,0 +1,421 @@
+package wallet
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+var (
+	ErrSenderWalletNotFound   = errors.New("sender wallet not found")
+	ErrReceiverWalletNotFound = errors.New("receiver wallet not found")
+	ErrIdempotencyKeyReused   = errors.New("idempotency key was already used for a different transfer")
+)
+
+// consensusDeadline is how long after its valid start a transaction the
+// mirror node does not know is taken to have never reached consensus.
+// Transactions expire at most three minutes after their valid start; the
+// rest leaves the mirror node time to import them.
+const consensusDeadline = 10 * time.Minute
+
+// TransferService runs HBAR transfers between users in two phases: the
+// transfer is recorded as pending before it is submitted to the ledger and
+// resolved to completed or failed afterwards. Pending rows left behind by a
+// crash or a lost response are resolved by ResolvePendingTransfers.
+type TransferService struct {
+	db     *sql.DB
+	ledger Ledger
+	mirror *MirrorNode
+	token  *CommunityToken
+	badges *BadgeCollection
+	escrow *EscrowAccount
+}
+
+// NewTransferService creates a transfer service. Receipts are only kept by
+// the network for a few minutes, so transactions whose receipt is gone are
+// looked up on mirror. mirror, token, badges and escrow may be nil when they
+// are not configured; without a mirror node such transactions are held for
+// review, and the others disable reward payouts, badge minting and paid
+// sessions respectively.
+func NewTransferService(db *sql.DB, ledger Ledger, mirror *MirrorNode, token *CommunityToken, badges *BadgeCollection, escrow *EscrowAccount) *TransferService {
+	return &TransferService{db: db, ledger: ledger, mirror: mirror, token: token, badges: badges, escrow: escrow}
+}
+
+// party is one side of a ledger transfer: a user's custodial wallet, or a
+// platform account when wallet is nil
+type party struct {
+	accountID  string
+	privateKey string
+	wallet     *models.UserWallet
+}
+
+// walletParty returns the party for a user's wallet
+func walletParty(w *models.UserWallet) party {
+	return party{accountID: w.HederaAccountID, wallet: w}
+}
+
+// signingKey returns the private key the party signs transfers with
+func (p party) signingKey() (string, error) {
+	if p.wallet != nil {
+		return p.wallet.DecryptPrivateKey()
+	}
+	return p.privateKey, nil
+}
+
+// Transfer sends amount from one user to another. Retrying with the same
+// idempotency key returns the original transfer instead of sending again.
+func (s *TransferService) Transfer(fromUserID, toUserID int, amount money.Tinybars, idempotencyKey string) (*models.Transfer, error) {
+	return s.Send(models.Transfer{
+		FromUserID:     fromUserID,
+		ToUserID:       toUserID,
+		Amount:         amount,
+		IdempotencyKey: idempotencyKey,
+	})
+}
+
+// TipPost sends a tip to the author of a post and links it to the post
+func (s *TransferService) TipPost(fromUserID int, post *models.Post, amount money.Tinybars, idempotencyKey string) (*models.Transfer, error) {
+	return s.Send(models.Transfer{
+		FromUserID:     fromUserID,
+		ToUserID:       post.UserID,
+		Amount:         amount,
+		IdempotencyKey: idempotencyKey,
+		PostID:         &post.ID,
+	})
+}
+
+// TipComment sends a tip to the author of a comment and links it to the comment
+func (s *TransferService) TipComment(fromUserID int, comment *models.Comment, amount money.Tinybars, idempotencyKey string) (*models.Transfer, error) {
+	return s.Send(models.Transfer{
+		FromUserID:     fromUserID,
+		ToUserID:       comment.UserID,
+		Amount:         amount,
+		IdempotencyKey: idempotencyKey,
+		CommentID:      &comment.ID,
+	})
+}
+
+// Send runs the transfer described by request, which carries the sender,
+// receiver, amount, idempotency key and the content a tip is linked to.
+func (s *TransferService) Send(request models.Transfer) (*models.Transfer, error) {
+	senderWallet, err := models.GetUserWallet(s.db, request.FromUserID)
+	if err != nil {
+		return nil, err
+	}
+	if senderWallet == nil {
+		return nil, ErrSenderWalletNotFound
+	}
+
+	receiverWallet, err := models.GetUserWallet(s.db, request.ToUserID)
+	if err != nil {
+		return nil, err
+	}
+	if receiverWallet == nil {
+		return nil, ErrReceiverWalletNotFound
+	}
+
+	return s.send(request, walletParty(senderWallet), walletParty(receiverWallet))
+}
+
+// send records request as pending, submits it from one party to the other
+// and settles it
+func (s *TransferService) send(request models.Transfer, from, to party) (*models.Transfer, error) {
+	if request.Kind == "" {
+		request.Kind = models.TransferKindTransfer
+	}
+
+	transactionID, err := s.ledger.NewTransactionID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve transaction ID: %v", err)
+	}
+
+	// Phase one: record the intent before anything reaches the ledger
+	request.TransactionID = transactionID
+	transfer, created, err := models.CreatePendingTransfer(s.db, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record transfer: %v", err)
+	}
+	if !created {
+		if transfer.Kind != request.Kind || transfer.ToUserID != request.ToUserID || transfer.Amount != request.Amount ||
+			!sameID(transfer.PostID, request.PostID) || !sameID(transfer.CommentID, request.CommentID) ||
+			!sameID(transfer.EscrowID, request.EscrowID) {
+			return nil, ErrIdempotencyKeyReused
+		}
+		return transfer, nil
+	}
+
+	privateKey, err := from.signingKey()
+	if err != nil {
+		s.fail(transfer, "failed to decrypt private key")
+		return transfer, nil
+	}
+
+	// Phase two: submit and settle the row
+	_, err = s.ledger.TransferHbar(
+		transfer.TransactionID,
+		from.accountID,
+		to.accountID,
+		privateKey,
+		request.Amount,
+	)
+	if err != nil {
+		// The submission may still have reached consensus, so let the receipt decide
+		log.Printf("Transfer %d submission error: %v", transfer.ID, err)
+		if errors.Is(err, ErrLedgerTimeout) {
+			// An unanswered submission is left for recovery to settle from its receipt
+			return transfer, nil
+		}
+		if resolveErr := s.resolve(transfer, err.Error()); resolveErr != nil {
+			log.Printf("Transfer %d left pending: %v", transfer.ID, resolveErr)
+		}
+		return transfer, nil
+	}
+
+	s.complete(transfer)
+	for _, p := range []party{from, to} {
+		if p.wallet != nil {
+			s.syncBalances(p.wallet)
+		}
+	}
+	return transfer, nil
+}
+
+// ResolvePendingTransfers settles pending transfers, submitted reward
+// payments and submitted badge deliveries older than staleAfter by checking
+// their transaction receipts and returns how many were resolved
+func (s *TransferService) ResolvePendingTransfers(staleAfter time.Duration) (int, error) {
+	cutoff := time.Now().Add(-staleAfter)
+	transfers, err := models.GetStalePendingTransfers(s.db, cutoff, 100)
+	if err != nil {
+		return 0, err
+	}
+
+	resolved := 0
+	for i := range transfers {
+		if err := s.resolve(&transfers[i], "transaction was not found on the ledger"); err != nil {
+			log.Printf("Failed to resolve pending transfer %d: %v", transfers[i].ID, err)
+			continue
+		}
+		resolved++
+	}
+
+	allocations, err := models.GetStaleSubmittedRewardAllocations(s.db, cutoff, 100)
+	if err != nil {
+		return resolved, err
+	}
+	for i := range allocations {
+		if err := s.resolveRewardPayment(&allocations[i], "transaction was not found on the ledger"); err != nil {
+			log.Printf("Failed to resolve reward allocation %d: %v", allocations[i].ID, err)
+			continue
+		}
+		resolved++
+	}
+
+	badges, err := models.GetStaleSubmittedBadges(s.db, cutoff, 100)
+	if err != nil {
+		return resolved, err
+	}
+	for i := range badges {
+		if err := s.resolveBadgeDelivery(&badges[i], "transaction was not found on the ledger"); err != nil {
+			log.Printf("Failed to resolve badge %d: %v", badges[i].ID, err)
+			continue
+		}
+		resolved++
+	}
+	return resolved, nil
+}
+
+// RunRecoveryWorker resolves stale pending transfers every interval
+func (s *TransferService) RunRecoveryWorker(interval, staleAfter time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		resolved, err := s.ResolvePendingTransfers(staleAfter)
+		if err != nil {
+			log.Printf("Transfer recovery failed: %v", err)
+			continue
+		}
+		if resolved > 0 {
+			log.Printf("Transfer recovery resolved %d pending transfers", resolved)
+		}
+	}
+}
+
+// resolve settles a pending transfer from its receipt. notFoundReason is
+// recorded when the transaction never reached consensus.
+func (s *TransferService) resolve(transfer *models.Transfer, notFoundReason string) error {
+	if transfer.TransactionID == "" {
+		s.fail(transfer, "transfer was never submitted")
+		return nil
+	}
+
+	result, reason, err := s.outcome(transfer.TransactionID, notFoundReason)
+	if err != nil {
+		return err
+	}
+	switch result {
+	case outcomeFailed:
+		s.fail(transfer, reason)
+	case outcomeUnconfirmed:
+		s.holdForReview(transfer, reason)
+	default:
+		s.complete(transfer)
+		s.syncUserBalances(transfer.FromUserID, transfer.ToUserID)
+	}
+	return nil
+}
+
+// outcomeResult is what became of a submitted transaction
+type outcomeResult int
+
+const (
+	outcomeSucceeded outcomeResult = iota
+	outcomeFailed
+	outcomeUnconfirmed
+)
+
+// outcome checks what became of a submitted transaction, first from its
+// receipt and, once the network no longer has the receipt, from the mirror
+// node. It returns the reason the transaction failed or could not be
+// confirmed, and an error when the outcome cannot be determined yet.
+func (s *TransferService) outcome(transactionID, notFoundReason string) (outcomeResult, string, error) {
+	receipt, err := s.ledger.GetTransactionReceipt(transactionID)
+	if err == nil {
+		if receipt.Status != ReceiptStatusSuccess {
+			return outcomeFailed, "transaction failed with status " + receipt.Status, nil
+		}
+		return outcomeSucceeded, "", nil
+	}
+	if !errors.Is(err, ErrReceiptNotFound) {
+		return 0, "", err
+	}
+
+	if s.ledger.KeepsReceipts() {
+		return outcomeFailed, notFoundReason, nil
+	}
+	if s.mirror == nil {
+		return outcomeUnconfirmed, "receipt expired and no mirror node is configured to confirm the transaction", nil
+	}
+
+	transaction, err := s.mirror.GetTransaction(transactionID)
+	if err == nil {
+		if !transaction.Succeeded() {
+			return outcomeFailed, "transaction failed with status " + transaction.Result, nil
+		}
+		return outcomeSucceeded, "", nil
+	}
+	if !errors.Is(err, ErrTransactionNotFound) {
+		return 0, "", fmt.Errorf("failed to look up transaction on the mirror node: %w", err)
+	}
+
+	validStart, ok := transactionValidStart(transactionID)
+	if !ok {
+		return outcomeUnconfirmed, "receipt expired and the transaction ID has no valid start time", nil
+	}
+	if time.Since(validStart) < consensusDeadline {
+		return 0, "", fmt.Errorf("transaction %s is not on the mirror node yet", transactionID)
+	}
+	return outcomeFailed, notFoundReason, nil
+}
+
+// complete marks a transfer completed, leaving it pending for recovery if the update fails
+func (s *TransferService) complete(transfer *models.Transfer) {
+	completed, err := models.MarkTransferCompleted(s.db, transfer)
+	if err != nil {
+		log.Printf("Failed to mark transfer %d completed: %v", transfer.ID, err)
+		return
+	}
+	transfer.Status = models.TransferStatusCompleted
+	transfer.FailureReason = ""
+
+	// Only the call that settled the row announces the tip
+	if completed && (transfer.PostID != nil || transfer.CommentID != nil) {
+		s.announceTip(transfer)
+	}
+}
+
+// announceTip notifies the author of tipped content and records the tip in
+// their activity
+func (s *TransferService) announceTip(transfer *models.Transfer) {
+	targetType, targetID := "post", 0
+	if transfer.PostID != nil {
+		targetID = *transfer.PostID
+	} else {
+		targetType, targetID = "comment", *transfer.CommentID
+	}
+
+	tipper, err := models.GetUserById(s.db, transfer.FromUserID)
+	if err != nil || tipper == nil {
+		log.Printf("Failed to load tipper for transfer %d: %v", transfer.ID, err)
+		return
+	}
+
+	message := fmt.Sprintf("%s %s tipped your %s %s HBAR", tipper.FirstName, tipper.LastName, targetType, transfer.Amount)
+	if _, err := models.CreateNotification(s.db, transfer.ToUserID, "tip_received", message, targetID); err != nil {
+		log.Printf("Failed to create tip notification for transfer %d: %v", transfer.ID, err)
+	}
+	if err := models.CreateTipActivity(s.db, transfer.ToUserID, targetType, targetID, transfer.FromUserID, transfer.ID, transfer.Amount); err != nil {
+		log.Printf("Failed to record tip activity for transfer %d: %v", transfer.ID, err)
+	}
+}
+
+// fail marks a transfer failed, leaving it pending for recovery if the update fails
+func (s *TransferService) fail(transfer *models.Transfer, reason string) {
+	if err := models.MarkTransferFailed(s.db, transfer.ID, reason); err != nil {
+		log.Printf("Failed to mark transfer %d failed: %v", transfer.ID, err)
+		return
+	}
+	transfer.Status = models.TransferStatusFailed
+	transfer.FailureReason = reason
+}
+
+// holdForReview marks a transfer whose outcome cannot be confirmed as needing
+// review, leaving it pending for recovery if the update fails
+func (s *TransferService) holdForReview(transfer *models.Transfer, reason string) {
+	if err := models.MarkTransferNeedsReview(s.db, transfer.ID, reason); err != nil {
+		log.Printf("Failed to mark transfer %d for review: %v", transfer.ID, err)
+		return
+	}
+	log.Printf("Transfer %d needs review: %s", transfer.ID, reason)
+	transfer.Status = models.TransferStatusNeedsReview
+	transfer.FailureReason = reason
+}
+
+// syncBalances refreshes the cached balances of both wallets from the ledger
+func (s *TransferService) syncBalances(wallets ...*models.UserWallet) {
+	for _, w := range wallets {
+		balance, err := s.ledger.GetAccountBalance(w.HederaAccountID)
+		if err != nil {
+			log.Printf("Failed to refresh balance for user %d: %v", w.UserID, err)
+			continue
+		}
+		if err := models.SyncWalletBalance(s.db, w.UserID, balance); err != nil {
+			log.Printf("Failed to store balance for user %d: %v", w.UserID, err)
+		}
+	}
+}
+
+// syncUserBalances refreshes the cached balances of the given users' wallets
+func (s *TransferService) syncUserBalances(userIDs ...int) {
+	for _, userID := range userIDs {
+		w, err := models.GetUserWallet(s.db, userID)
+		if err != nil || w == nil {
+			continue
+		}
+		s.syncBalances(w)
+	}
+}
+
+// sameID reports whether two optional IDs are equal
+func sameID(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,203 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db/dbtest"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+func TestTransferSettlesOnTheLedger(t *testing.T) {
+	ledger := NewMemoryLedger()
+	service := NewTransferService(dbtest.DB, ledger, nil, nil, nil, nil)
+	sender := dbtest.NewWalletUser(t, ledger, "user", money.Hbar(100))
+	receiver := dbtest.NewWalletUser(t, ledger, "user", money.Hbar(100))
+
+	transfer, err := service.Transfer(sender.ID, receiver.ID, money.Hbar(5), "send-1")
+	if err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	if transfer.Status != models.TransferStatusCompleted {
+		t.Fatalf("status = %q, want completed (%s)", transfer.Status, transfer.FailureReason)
+	}
+	if got := dbtest.LedgerBalance(t, ledger, sender.ID); got != money.Hbar(95) {
+		t.Errorf("sender balance = %s, want 95", got)
+	}
+	if got := dbtest.LedgerBalance(t, ledger, receiver.ID); got != money.Hbar(105) {
+		t.Errorf("receiver balance = %s, want 105", got)
+	}
+	cached, err := models.GetUserWallet(dbtest.DB, receiver.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.TokenBalance != money.Hbar(105) {
+		t.Errorf("cached receiver balance = %s, want 105", cached.TokenBalance)
+	}
+
+	// A retry returns the original transfer without sending again
+	retried, err := service.Transfer(sender.ID, receiver.ID, money.Hbar(5), "send-1")
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if retried.ID != transfer.ID {
+		t.Errorf("retry created transfer %d, want %d", retried.ID, transfer.ID)
+	}
+	if got := dbtest.LedgerBalance(t, ledger, sender.ID); got != money.Hbar(95) {
+		t.Errorf("sender balance after retry = %s, want 95", got)
+	}
+
+	if _, err := service.Transfer(sender.ID, receiver.ID, money.Hbar(6), "send-1"); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("reused key error = %v, want ErrIdempotencyKeyReused", err)
+	}
+}
+
+func TestTransferFailsWithoutFunds(t *testing.T) {
+	ledger := NewMemoryLedger()
+	service := NewTransferService(dbtest.DB, ledger, nil, nil, nil, nil)
+	sender := dbtest.NewWalletUser(t, ledger, "user", money.Hbar(1))
+	receiver := dbtest.NewWalletUser(t, ledger, "user", money.Hbar(1))
+
+	transfer, err := service.Transfer(sender.ID, receiver.ID, money.Hbar(5), "broke-1")
+	if err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	if transfer.Status != models.TransferStatusFailed || transfer.FailureReason == "" {
+		t.Fatalf("status = %q (%q), want failed with a reason", transfer.Status, transfer.FailureReason)
+	}
+	if got := dbtest.LedgerBalance(t, ledger, sender.ID); got != money.Hbar(1) {
+		t.Errorf("sender balance = %s, want 1", got)
+	}
+}
+
+func TestResolveSettlesFromTheReceipt(t *testing.T) {
+	ledger := NewMemoryLedger()
+	service := NewTransferService(dbtest.DB, ledger, nil, nil, nil, nil)
+	sender := dbtest.NewWalletUser(t, ledger, "user", money.Hbar(100))
+	receiver := dbtest.NewWalletUser(t, ledger, "user", money.Hbar(100))
+	senderWallet, _ := models.GetUserWallet(dbtest.DB, sender.ID)
+	receiverWallet, _ := models.GetUserWallet(dbtest.DB, receiver.ID)
+	senderKey, err := senderWallet.DecryptPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reached the ledger but the process stopped before the row was settled
+	submittedID, _ := ledger.NewTransactionID()
+	if _, err := ledger.TransferHbar(submittedID, senderWallet.HederaAccountID, receiverWallet.HederaAccountID, senderKey, money.Hbar(3)); err != nil {
+		t.Fatal(err)
+	}
+	submitted := createPending(t, sender.ID, receiver.ID, submittedID, "resolve-submitted")
+
+	// Never reached the ledger
+	lostID, _ := ledger.NewTransactionID()
+	lost := createPending(t, sender.ID, receiver.ID, lostID, "resolve-lost")
+
+	for _, transfer := range []*models.Transfer{submitted, lost} {
+		if err := service.resolve(transfer, "transaction was not found on the ledger"); err != nil {
+			t.Fatalf("resolve %d: %v", transfer.ID, err)
+		}
+	}
+	assertStatus(t, submitted.ID, models.TransferStatusCompleted)
+	assertStatus(t, lost.ID, models.TransferStatusFailed)
+}
+
+// receiptlessLedger is a ledger whose receipts have expired, so outcomes
+// come from the mirror node
+type receiptlessLedger struct {
+	*MemoryLedger
+}
+
+func (receiptlessLedger) GetTransactionReceipt(string) (*Receipt, error) {
+	return nil, ErrReceiptNotFound
+}
+
+func (receiptlessLedger) KeepsReceipts() bool {
+	return false
+}
+
+func TestResolveConfirmsExpiredReceiptsOnTheMirrorNode(t *testing.T) {
+	memory := NewMemoryLedger()
+	sender := dbtest.NewWalletUser(t, memory, "user", money.Hbar(100))
+	receiver := dbtest.NewWalletUser(t, memory, "user", money.Hbar(100))
+
+	old := time.Now().Add(-time.Hour).Unix()
+	recent := time.Now().Unix()
+	results := map[string]string{
+		fmt.Sprintf("0.0.2-%d-000000001", old): "SUCCESS",
+		fmt.Sprintf("0.0.2-%d-000000002", old): "INSUFFICIENT_PAYER_BALANCE",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/transactions/")
+		result, ok := results[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"transactions": []map[string]interface{}{{"transaction_id": id, "result": result}},
+		})
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		transactionID string
+		mirror        *MirrorNode
+		wantStatus    string
+		wantErr       bool
+	}{
+		{"succeeded", fmt.Sprintf("0.0.2@%d.000000001", old), NewMirrorNode(server.URL), models.TransferStatusCompleted, false},
+		{"failed", fmt.Sprintf("0.0.2@%d.000000002", old), NewMirrorNode(server.URL), models.TransferStatusFailed, false},
+		{"unknown after the deadline", fmt.Sprintf("0.0.2@%d.000000003", old), NewMirrorNode(server.URL), models.TransferStatusFailed, false},
+		{"unknown before the deadline", fmt.Sprintf("0.0.2@%d.000000004", recent), NewMirrorNode(server.URL), models.TransferStatusPending, true},
+		{"no mirror node", fmt.Sprintf("0.0.2@%d.000000005", old), nil, models.TransferStatusNeedsReview, false},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := NewTransferService(dbtest.DB, receiptlessLedger{memory}, test.mirror, nil, nil, nil)
+			transfer := createPending(t, sender.ID, receiver.ID, test.transactionID, fmt.Sprintf("mirror-%d", i))
+
+			err := service.resolve(transfer, "transaction was not found on the ledger")
+			if (err != nil) != test.wantErr {
+				t.Fatalf("resolve error = %v, want error %v", err, test.wantErr)
+			}
+			assertStatus(t, transfer.ID, test.wantStatus)
+		})
+	}
+}
+
+// createPending records a pending transfer with a transaction ID
+func createPending(t *testing.T, fromUserID, toUserID int, transactionID, idempotencyKey string) *models.Transfer {
+	t.Helper()
+	transfer, created, err := models.CreatePendingTransfer(dbtest.DB, models.Transfer{
+		FromUserID:     fromUserID,
+		ToUserID:       toUserID,
+		Amount:         money.Hbar(3),
+		TransactionID:  transactionID,
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil || !created {
+		t.Fatalf("create pending transfer: %v (created %v)", err, created)
+	}
+	return transfer
+}
+
+// assertStatus checks the stored status of a transfer
+func assertStatus(t *testing.T, transferID int, want string) {
+	t.Helper()
+	transfer, err := models.GetTransferByID(dbtest.DB, transferID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transfer.Status != want {
+		t.Errorf("transfer %d status = %q (%q), want %q", transferID, transfer.Status, transfer.FailureReason, want)
+	}
+}
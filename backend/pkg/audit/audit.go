@@ -0,0 +1,330 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	wallet "github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+// Record types anchored on the audit topic
+const (
+	RecordTypeTip          = "tip"
+	RecordTypeRewardPayout = "reward_payout"
+	RecordTypeBadgeMint    = "badge_mint"
+)
+
+// messageVersion identifies the layout of anchored messages
+const messageVersion = 1
+
+var (
+	ErrUnknownRecordType = errors.New("unknown audit record type")
+	ErrRecordNotFound    = errors.New("audit record not found")
+	ErrNotAuditable      = errors.New("record is not settled and cannot be audited")
+)
+
+// Record is the canonical form of an audited row. Its JSON encoding is what
+// gets hashed, so fields must only be added with a new message version.
+type Record struct {
+	Type string      `json:"type"`
+	ID   int         `json:"id"`
+	Data interface{} `json:"data"`
+}
+
+// tipData is the hashed content of a tip
+type tipData struct {
+	FromUserID    int    `json:"from_user_id"`
+	ToUserID      int    `json:"to_user_id"`
+	Amount        string `json:"amount"`
+	TransactionID string `json:"transaction_id"`
+	PostID        *int   `json:"post_id"`
+	CommentID     *int   `json:"comment_id"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// rewardPayoutData is the hashed content of a reward payout
+type rewardPayoutData struct {
+	RunID         int    `json:"run_id"`
+	UserID        int    `json:"user_id"`
+	Score         int    `json:"score"`
+	AmountUnits   int64  `json:"amount_units"`
+	TransactionID string `json:"transaction_id"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// badgeMintData is the hashed content of a badge mint
+type badgeMintData struct {
+	UserID        int    `json:"user_id"`
+	BadgeType     string `json:"badge_type"`
+	TokenID       string `json:"token_id"`
+	SerialNumber  *int64 `json:"serial_number"`
+	TransactionID string `json:"transaction_id"`
+	EarnedAt      string `json:"earned_at"`
+}
+
+// Message is what is submitted to the topic for each record
+type Message struct {
+	Version int    `json:"v"`
+	Type    string `json:"type"`
+	ID      int    `json:"id"`
+	Hash    string `json:"sha256"`
+}
+
+// TipRecord returns the canonical record of a tip
+func TipRecord(transfer *models.Transfer) Record {
+	return Record{Type: RecordTypeTip, ID: transfer.ID, Data: tipData{
+		FromUserID:    transfer.FromUserID,
+		ToUserID:      transfer.ToUserID,
+		Amount:        transfer.Amount.String(),
+		TransactionID: transfer.TransactionID,
+		PostID:        transfer.PostID,
+		CommentID:     transfer.CommentID,
+		CreatedAt:     timestamp(transfer.CreatedAt),
+	}}
+}
+
+// RewardPayoutRecord returns the canonical record of a reward payout
+func RewardPayoutRecord(allocation *models.RewardAllocation) Record {
+	return Record{Type: RecordTypeRewardPayout, ID: allocation.ID, Data: rewardPayoutData{
+		RunID:         allocation.RunID,
+		UserID:        allocation.UserID,
+		Score:         allocation.Score,
+		AmountUnits:   allocation.AmountUnits,
+		TransactionID: allocation.TransactionID,
+		CreatedAt:     timestamp(allocation.CreatedAt),
+	}}
+}
+
+// BadgeMintRecord returns the canonical record of a badge mint
+func BadgeMintRecord(badge *models.UserBadge) Record {
+	return Record{Type: RecordTypeBadgeMint, ID: badge.ID, Data: badgeMintData{
+		UserID:        badge.UserID,
+		BadgeType:     badge.BadgeType,
+		TokenID:       badge.TokenID,
+		SerialNumber:  badge.SerialNumber,
+		TransactionID: badge.TransactionID,
+		EarnedAt:      timestamp(badge.EarnedAt),
+	}}
+}
+
+// Hash returns the hex SHA-256 of the record's canonical JSON
+func Hash(record Record) (string, error) {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// timestamp formats times with second precision in UTC, which every
+// supported database round-trips unchanged
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Trail anchors settled tips, reward payouts and badge mints on an HCS topic
+// and verifies rows against what was anchored
+type Trail struct {
+	db     *sql.DB
+	ledger wallet.Ledger
+	topic  *wallet.AuditTopic
+}
+
+// NewTrail creates an audit trail. topic may be nil, in which case nothing
+// is anchored but existing anchors can still be verified.
+func NewTrail(db *sql.DB, ledger wallet.Ledger, topic *wallet.AuditTopic) *Trail {
+	return &Trail{db: db, ledger: ledger, topic: topic}
+}
+
+// Run anchors newly settled records every interval
+func (t *Trail) Run(interval time.Duration) {
+	if !t.topic.Enabled() {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if anchored := t.AnchorPending(); anchored > 0 {
+			log.Printf("Audit trail anchored %d records on topic %s", anchored, t.topic.TopicID())
+		}
+	}
+}
+
+// AnchorPending submits every settled record that has not been anchored yet
+// and returns how many were anchored
+func (t *Trail) AnchorPending() int {
+	anchored := 0
+
+	tips, err := models.GetUnauditedTips(t.db, 100)
+	if err != nil {
+		log.Printf("Failed to list unaudited tips: %v", err)
+	}
+	for i := range tips {
+		if t.anchor(models.AuditTableTransfers, TipRecord(&tips[i])) {
+			anchored++
+		}
+	}
+
+	allocations, err := models.GetUnauditedRewardAllocations(t.db, 100)
+	if err != nil {
+		log.Printf("Failed to list unaudited reward payouts: %v", err)
+	}
+	for i := range allocations {
+		if t.anchor(models.AuditTableRewardAllocations, RewardPayoutRecord(&allocations[i])) {
+			anchored++
+		}
+	}
+
+	badges, err := models.GetUnauditedBadges(t.db, 100)
+	if err != nil {
+		log.Printf("Failed to list unaudited badges: %v", err)
+	}
+	for i := range badges {
+		if t.anchor(models.AuditTableUserBadges, BadgeMintRecord(&badges[i])) {
+			anchored++
+		}
+	}
+
+	return anchored
+}
+
+// anchor hashes a record, submits it to the topic and stores the result on
+// its row. If storing fails after submission the record is anchored again
+// on the next pass; verification uses the position stored on the row.
+func (t *Trail) anchor(table string, record Record) bool {
+	hash, err := Hash(record)
+	if err != nil {
+		log.Printf("Failed to hash %s %d: %v", record.Type, record.ID, err)
+		return false
+	}
+	message, err := json.Marshal(Message{Version: messageVersion, Type: record.Type, ID: record.ID, Hash: hash})
+	if err != nil {
+		log.Printf("Failed to encode audit message for %s %d: %v", record.Type, record.ID, err)
+		return false
+	}
+
+	sequenceNumber, err := t.topic.Submit(message)
+	if err != nil {
+		log.Printf("Failed to anchor %s %d: %v", record.Type, record.ID, err)
+		return false
+	}
+	if err := models.SetAuditEntry(t.db, table, record.ID, hash, t.topic.TopicID(), sequenceNumber); err != nil {
+		log.Printf("Failed to store audit entry for %s %d: %v", record.Type, record.ID, err)
+		return false
+	}
+	return true
+}
+
+// Verification is the result of checking a row against the audit trail
+type Verification struct {
+	Record         Record `json:"record"`
+	ComputedHash   string `json:"computed_hash"`
+	StoredHash     string `json:"stored_hash,omitempty"`
+	TopicID        string `json:"topic_id,omitempty"`
+	SequenceNumber *int64 `json:"sequence_number,omitempty"`
+	AnchoredHash   string `json:"anchored_hash,omitempty"`
+	Anchored       bool   `json:"anchored"`
+	Verified       bool   `json:"verified"`
+	Detail         string `json:"detail"`
+}
+
+// Verify recomputes the hash of a record from its current row and compares
+// it with the hash anchored on the topic
+func (t *Trail) Verify(recordType string, recordID int) (*Verification, error) {
+	record, entry, err := t.load(recordType, recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := Hash(record)
+	if err != nil {
+		return nil, err
+	}
+	verification := &Verification{
+		Record:         record,
+		ComputedHash:   hash,
+		StoredHash:     entry.AuditHash,
+		TopicID:        entry.AuditTopicID,
+		SequenceNumber: entry.AuditSequenceNumber,
+	}
+
+	if entry.AuditSequenceNumber == nil {
+		verification.Detail = "record has not been anchored yet"
+		return verification, nil
+	}
+
+	raw, err := t.ledger.GetTopicMessage(entry.AuditTopicID, *entry.AuditSequenceNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read anchored message: %w", err)
+	}
+	var message Message
+	if err := json.Unmarshal(raw, &message); err != nil || message.Type != record.Type || message.ID != record.ID {
+		verification.Detail = "anchored message does not belong to this record"
+		return verification, nil
+	}
+
+	verification.Anchored = true
+	verification.AnchoredHash = message.Hash
+	verification.Verified = message.Hash == hash
+	if verification.Verified {
+		verification.Detail = "record matches the hash anchored on the topic"
+	} else {
+		verification.Detail = "record was altered after it was anchored"
+	}
+	return verification, nil
+}
+
+// load reads an audited row and returns its canonical record and audit entry
+func (t *Trail) load(recordType string, recordID int) (Record, models.AuditEntry, error) {
+	switch recordType {
+	case RecordTypeTip:
+		transfer, err := models.GetTransferByID(t.db, recordID)
+		if err != nil {
+			return Record{}, models.AuditEntry{}, err
+		}
+		if transfer == nil || (transfer.PostID == nil && transfer.CommentID == nil) {
+			return Record{}, models.AuditEntry{}, ErrRecordNotFound
+		}
+		if transfer.Status != models.TransferStatusCompleted {
+			return Record{}, models.AuditEntry{}, ErrNotAuditable
+		}
+		return TipRecord(transfer), transfer.AuditEntry, nil
+
+	case RecordTypeRewardPayout:
+		allocation, err := models.GetRewardAllocationByID(t.db, recordID)
+		if err != nil {
+			return Record{}, models.AuditEntry{}, err
+		}
+		if allocation == nil {
+			return Record{}, models.AuditEntry{}, ErrRecordNotFound
+		}
+		if allocation.Status != models.RewardAllocationCompleted {
+			return Record{}, models.AuditEntry{}, ErrNotAuditable
+		}
+		return RewardPayoutRecord(allocation), allocation.AuditEntry, nil
+
+	case RecordTypeBadgeMint:
+		badge, err := models.GetUserBadgeByID(t.db, recordID)
+		if err != nil {
+			return Record{}, models.AuditEntry{}, err
+		}
+		if badge == nil {
+			return Record{}, models.AuditEntry{}, ErrRecordNotFound
+		}
+		if badge.Status != models.BadgeStatusMinted {
+			return Record{}, models.AuditEntry{}, ErrNotAuditable
+		}
+		return BadgeMintRecord(badge), badge.AuditEntry, nil
+	}
+	return Record{}, models.AuditEntry{}, ErrUnknownRecordType
+}
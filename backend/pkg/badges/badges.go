@@ -0,0 +1,192 @@
+package badges
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	wallet "github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+// supportiveCommentLength is the shortest comment that counts as supportive
+const supportiveCommentLength = 20
+
+// Rule reports whether a user has met the requirements of a badge
+type Rule func(database *sql.DB, userID int) (bool, error)
+
+// Definition describes a badge type and the rule that awards it
+type Definition struct {
+	Type        string
+	Name        string
+	Description string
+	Rule        Rule
+}
+
+// Definitions lists every badge type in the order they are evaluated
+var Definitions = []Definition{
+	{
+		Type:        "first_post",
+		Name:        "First Post",
+		Description: "Shared a first post with the community",
+		Rule:        atLeast(1, models.CountUserPosts),
+	},
+	{
+		Type:        "supportive_commenter",
+		Name:        "100 Supportive Comments",
+		Description: "Left 100 thoughtful comments on other members' posts",
+		Rule:        atLeast(100, countSupportiveComments),
+	},
+	{
+		Type:        "community_hero",
+		Name:        "Community Hero",
+		Description: "Appreciated by 50 members and a regular source of support",
+		Rule: all(
+			atLeast(50, models.CountDistinctLikers),
+			atLeast(25, countSupportiveComments),
+		),
+	},
+}
+
+// Lookup returns the definition of a badge type
+func Lookup(badgeType string) (Definition, bool) {
+	for _, definition := range Definitions {
+		if definition.Type == badgeType {
+			return definition, true
+		}
+	}
+	return Definition{}, false
+}
+
+// Describe fills in the name and description of each badge from its definition
+func Describe(badges []models.UserBadge) {
+	for i := range badges {
+		if definition, ok := Lookup(badges[i].BadgeType); ok {
+			badges[i].Name = definition.Name
+			badges[i].Description = definition.Description
+		}
+	}
+}
+
+// atLeast builds a rule that passes once count reaches min
+func atLeast(min int, count func(*sql.DB, int) (int, error)) Rule {
+	return func(database *sql.DB, userID int) (bool, error) {
+		n, err := count(database, userID)
+		return n >= min, err
+	}
+}
+
+// all builds a rule that passes when every rule passes
+func all(rules ...Rule) Rule {
+	return func(database *sql.DB, userID int) (bool, error) {
+		for _, rule := range rules {
+			ok, err := rule(database, userID)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+}
+
+// countSupportiveComments counts a user's comments on other members' posts
+func countSupportiveComments(database *sql.DB, userID int) (int, error) {
+	return models.CountSupportiveComments(database, userID, supportiveCommentLength)
+}
+
+// Awarder evaluates badge rules against users' activity, records newly
+// earned badges and has their NFTs minted to the users' wallets
+type Awarder struct {
+	db        *sql.DB
+	transfers *wallet.TransferService
+}
+
+// NewAwarder creates a badge awarder
+func NewAwarder(db *sql.DB, transfers *wallet.TransferService) *Awarder {
+	return &Awarder{db: db, transfers: transfers}
+}
+
+// Evaluate awards every badge whose rule the user now meets and returns the
+// newly earned badges
+func (a *Awarder) Evaluate(userID int) ([]models.UserBadge, error) {
+	earned, err := models.GetUserBadges(a.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	has := make(map[string]bool, len(earned))
+	for _, badge := range earned {
+		has[badge.BadgeType] = true
+	}
+
+	var awarded []models.UserBadge
+	for _, definition := range Definitions {
+		if has[definition.Type] {
+			continue
+		}
+		ok, err := definition.Rule(a.db, userID)
+		if err != nil {
+			return awarded, fmt.Errorf("badge %s: %v", definition.Type, err)
+		}
+		if !ok {
+			continue
+		}
+
+		badge, created, err := models.AwardBadge(a.db, userID, definition.Type)
+		if err != nil {
+			return awarded, fmt.Errorf("badge %s: %v", definition.Type, err)
+		}
+		if !created {
+			continue
+		}
+
+		message := fmt.Sprintf("You earned the %s badge", definition.Name)
+		if _, err := models.CreateNotification(a.db, userID, "badge_earned", message, badge.ID); err != nil {
+			log.Printf("Failed to create badge notification for badge %d: %v", badge.ID, err)
+		}
+		awarded = append(awarded, *badge)
+	}
+	return awarded, nil
+}
+
+// DeliverPending mints and transfers the NFTs of badges not yet delivered
+func (a *Awarder) DeliverPending() {
+	pending, err := models.GetPendingBadges(a.db, 100)
+	if err != nil {
+		log.Printf("Failed to list pending badges: %v", err)
+		return
+	}
+	for i := range pending {
+		if err := a.transfers.DeliverBadge(&pending[i]); err != nil {
+			log.Printf("Failed to deliver badge %d: %v", pending[i].ID, err)
+		}
+	}
+}
+
+// Run evaluates users with new activity and delivers pending badges every
+// interval. The first pass covers all recorded activity so badges earned
+// before a restart are not missed.
+func (a *Awarder) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var since time.Time
+	for {
+		passStarted := time.Now()
+		userIDs, err := models.GetUsersActiveSince(a.db, since)
+		if err != nil {
+			log.Printf("Failed to list active users for badges: %v", err)
+		} else {
+			for _, userID := range userIDs {
+				if _, err := a.Evaluate(userID); err != nil {
+					log.Printf("Failed to evaluate badges for user %d: %v", userID, err)
+				}
+			}
+			// Overlap passes slightly since activity timestamps have second precision
+			since = passStarted.Add(-time.Minute)
+		}
+		a.DeliverPending()
+
+		<-ticker.C
+	}
+}
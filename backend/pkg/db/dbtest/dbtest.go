@@ -0,0 +1,141 @@
+// Package dbtest sets up a migrated SQLite database for package tests. The
+// SQLite connection is shared by the whole process, so a test binary opens
+// one database from TestMain with Main and its tests create the users and
+// wallets they need.
+package dbtest
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/On-cure/Oncure/pkg/db/sqlite"
+	"github.com/On-cure/Oncure/pkg/keys"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+// Password is the password of the users CreateUser creates
+const Password = "password"
+
+var userCount atomic.Int64
+
+// DB is the database Main opened for the test binary
+var DB *sql.DB
+
+// Ledger is the part of a ledger the wallet helpers use. The in-memory
+// ledger of the accounts package implements it.
+type Ledger interface {
+	CreateAccount(initialBalance money.Tinybars) (string, string, error)
+	GetAccountBalance(accountID string) (money.Tinybars, error)
+}
+
+// Main opens a database in a temporary directory, runs the tests and exits.
+// Call it from TestMain.
+func Main(m *testing.M) {
+	dir, err := os.MkdirTemp("", "oncure-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	DB, err = Open(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// Open creates a database in dir, applies the migrations and seals wallet
+// keys with a throwaway master key
+func Open(dir string) (*sql.DB, error) {
+	_, file, _, _ := runtime.Caller(0)
+	migrations := filepath.Join(filepath.Dir(file), "..", "migrations", "sqlite")
+
+	os.Unsetenv("DATABASE_URL")
+	os.Setenv("DB_PATH", filepath.Join(dir, "test.db"))
+	os.Setenv("MIGRATIONS_PATH", "file://"+migrations)
+
+	database, err := sqlite.InitDB()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlite.ApplyMigrations(); err != nil {
+		return nil, err
+	}
+
+	masterKey := make([]byte, keys.DataKeySize)
+	if _, err := rand.Read(masterKey); err != nil {
+		return nil, err
+	}
+	provider, err := keys.NewLocalProvider("test", map[string][]byte{"test": masterKey})
+	if err != nil {
+		return nil, err
+	}
+	models.SetWalletKeyring(keys.NewKeyring(provider))
+	return database, nil
+}
+
+// CreateUser creates a user with a unique email address and a role,
+// verified for that role unless it is "user"
+func CreateUser(database *sql.DB, role string) (*models.User, error) {
+	n := userCount.Add(1)
+	userID, err := models.CreateUser(database, models.User{
+		Email:       fmt.Sprintf("user%d@example.com", n),
+		Password:    Password,
+		FirstName:   "User",
+		LastName:    fmt.Sprint(n),
+		DateOfBirth: "1990-01-01",
+		Role:        role,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if role != "user" {
+		if _, err := database.Exec(
+			`UPDATE users SET verification_status = ?, verified_at = CURRENT_TIMESTAMP WHERE id = ?`,
+			models.VerificationStatusVerified, userID,
+		); err != nil {
+			return nil, err
+		}
+	}
+	return models.GetUserById(database, userID)
+}
+
+// NewWalletUser creates a user with a role, as CreateUser does, whose wallet
+// holds balance on ledger
+func NewWalletUser(t testing.TB, ledger Ledger, role string, balance money.Tinybars) *models.User {
+	t.Helper()
+	user, err := CreateUser(DB, role)
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	accountID, privateKey, err := ledger.CreateAccount(balance)
+	if err != nil {
+		t.Fatalf("create account: %v", err)
+	}
+	if err := models.CreateUserWallet(DB, user.ID, accountID, privateKey); err != nil {
+		t.Fatalf("create wallet: %v", err)
+	}
+	return user
+}
+
+// LedgerBalance returns the ledger balance of a user's wallet
+func LedgerBalance(t testing.TB, ledger Ledger, userID int) money.Tinybars {
+	t.Helper()
+	w, err := models.GetUserWallet(DB, userID)
+	if err != nil || w == nil {
+		t.Fatalf("get wallet of user %d: %v", userID, err)
+	}
+	balance, err := ledger.GetAccountBalance(w.HederaAccountID)
+	if err != nil {
+		t.Fatalf("get balance: %v", err)
+	}
+	return balance
+}
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_transfers_status;
+DROP INDEX IF EXISTS idx_transfers_idempotency_key;
+ALTER TABLE transfers DROP CONSTRAINT IF EXISTS transfers_status_check;
+ALTER TABLE transfers DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE transfers DROP COLUMN IF EXISTS failure_reason;
+ALTER TABLE transfers DROP COLUMN IF EXISTS idempotency_key;
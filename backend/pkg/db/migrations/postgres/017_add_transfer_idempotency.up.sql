@@ -0,0 +1,11 @@
+-- Track two-phase transfer state and client idempotency keys
+ALTER TABLE transfers ADD COLUMN idempotency_key TEXT;
+ALTER TABLE transfers ADD COLUMN failure_reason TEXT;
+ALTER TABLE transfers ADD COLUMN updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE transfers SET updated_at = created_at;
+
+ALTER TABLE transfers ADD CONSTRAINT transfers_status_check CHECK (status IN ('pending', 'completed', 'failed'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_idempotency_key ON transfers(from_user_id, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_transfers_status ON transfers(status);
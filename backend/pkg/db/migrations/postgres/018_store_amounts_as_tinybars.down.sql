@@ -0,0 +1,7 @@
+ALTER TABLE user_wallets RENAME COLUMN balance_tinybars TO token_balance;
+ALTER TABLE user_wallets ALTER COLUMN token_balance DROP DEFAULT;
+ALTER TABLE user_wallets ALTER COLUMN token_balance TYPE DECIMAL(20,8) USING token_balance / 100000000.0;
+ALTER TABLE user_wallets ALTER COLUMN token_balance SET DEFAULT 0.00000000;
+
+ALTER TABLE transfers RENAME COLUMN amount_tinybars TO amount;
+ALTER TABLE transfers ALTER COLUMN amount TYPE DECIMAL(20,8) USING amount / 100000000.0;
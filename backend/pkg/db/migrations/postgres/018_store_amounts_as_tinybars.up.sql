@@ -0,0 +1,8 @@
+-- Store HBAR amounts as integer tinybars (1 HBAR = 100000000 tinybars)
+ALTER TABLE transfers ALTER COLUMN amount TYPE BIGINT USING ROUND(amount * 100000000)::BIGINT;
+ALTER TABLE transfers RENAME COLUMN amount TO amount_tinybars;
+
+ALTER TABLE user_wallets ALTER COLUMN token_balance DROP DEFAULT;
+ALTER TABLE user_wallets ALTER COLUMN token_balance TYPE BIGINT USING ROUND(COALESCE(token_balance, 0) * 100000000)::BIGINT;
+ALTER TABLE user_wallets ALTER COLUMN token_balance SET DEFAULT 0;
+ALTER TABLE user_wallets RENAME COLUMN token_balance TO balance_tinybars;
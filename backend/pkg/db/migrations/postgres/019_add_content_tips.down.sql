@@ -0,0 +1,9 @@
+ALTER TABLE comments DROP COLUMN tip_count;
+ALTER TABLE comments DROP COLUMN tip_total_tinybars;
+ALTER TABLE posts DROP COLUMN tip_count;
+ALTER TABLE posts DROP COLUMN tip_total_tinybars;
+
+DROP INDEX IF EXISTS idx_transfers_comment_id;
+DROP INDEX IF EXISTS idx_transfers_post_id;
+ALTER TABLE transfers DROP COLUMN comment_id;
+ALTER TABLE transfers DROP COLUMN post_id;
@@ -0,0 +1 @@
+ALTER TABLE user_wallets DROP COLUMN token_associated_at;
@@ -0,0 +1,2 @@
+-- Track which wallets are associated with the community token
+ALTER TABLE user_wallets ADD COLUMN token_associated_at TIMESTAMP;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS reward_allocations;
+DROP TABLE IF EXISTS reward_runs;
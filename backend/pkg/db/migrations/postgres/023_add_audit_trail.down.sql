@@ -0,0 +1,9 @@
+ALTER TABLE user_badges DROP COLUMN audit_sequence_number;
+ALTER TABLE user_badges DROP COLUMN audit_topic_id;
+ALTER TABLE user_badges DROP COLUMN audit_hash;
+ALTER TABLE reward_allocations DROP COLUMN audit_sequence_number;
+ALTER TABLE reward_allocations DROP COLUMN audit_topic_id;
+ALTER TABLE reward_allocations DROP COLUMN audit_hash;
+ALTER TABLE transfers DROP COLUMN audit_sequence_number;
+ALTER TABLE transfers DROP COLUMN audit_topic_id;
+ALTER TABLE transfers DROP COLUMN audit_hash;
@@ -0,0 +1,4 @@
+-- Envelope encryption: each private key has its own data key, wrapped by the
+-- master key named in encryption_key_id. Rows with no key ID predate it.
+ALTER TABLE user_wallets ADD COLUMN encryption_key_id TEXT;
+ALTER TABLE user_wallets ADD COLUMN encrypted_data_key TEXT;
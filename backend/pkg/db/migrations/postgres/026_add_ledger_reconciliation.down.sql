@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS ledger_drift;
+
+ALTER TABLE user_wallets DROP COLUMN reconciled_at;
+ALTER TABLE user_wallets DROP COLUMN mirror_cursor;
+
+DROP INDEX IF EXISTS idx_transfers_deposit_transaction;
+DROP INDEX IF EXISTS idx_transfers_transaction_id;
+
+-- Deposits cannot be represented without a sending user and are dropped
+DELETE FROM transfers WHERE kind = 'deposit';
+ALTER TABLE transfers DROP COLUMN external_account_id;
+ALTER TABLE transfers DROP COLUMN kind;
+ALTER TABLE transfers ALTER COLUMN from_user_id SET NOT NULL;
@@ -0,0 +1,29 @@
+-- Deposits from accounts outside the platform have no sending user
+ALTER TABLE transfers ALTER COLUMN from_user_id DROP NOT NULL;
+ALTER TABLE transfers ADD COLUMN kind TEXT NOT NULL DEFAULT 'transfer' CHECK (kind IN ('transfer', 'deposit'));
+ALTER TABLE transfers ADD COLUMN external_account_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_transfers_transaction_id ON transfers(transaction_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_deposit_transaction ON transfers(transaction_id) WHERE kind = 'deposit';
+
+-- Consensus timestamp of the last mirror node transaction reconciled per wallet
+ALTER TABLE user_wallets ADD COLUMN mirror_cursor TEXT;
+ALTER TABLE user_wallets ADD COLUMN reconciled_at TIMESTAMP;
+
+-- Differences found between the ledger and the transfers table
+CREATE TABLE IF NOT EXISTS ledger_drift (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    hedera_account_id TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('balance', 'unrecorded_transaction', 'amount_mismatch', 'status_mismatch')),
+    transaction_id TEXT,
+    transfer_id INTEGER,
+    expected_tinybars BIGINT,
+    actual_tinybars BIGINT,
+    detail TEXT,
+    detected_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_drift_user_id ON ledger_drift(user_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_drift_detected_at ON ledger_drift(detected_at);
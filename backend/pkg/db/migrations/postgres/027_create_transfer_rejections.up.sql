@@ -0,0 +1,19 @@
+-- Transfers and tips refused by the transfer policy, kept for review
+CREATE TABLE IF NOT EXISTS transfer_rejections (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    to_user_id INTEGER,
+    amount_tinybars BIGINT,
+    kind TEXT NOT NULL CHECK (kind IN ('transfer', 'tip_post', 'tip_comment')),
+    reason TEXT NOT NULL,
+    detail TEXT,
+    ip_address TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfer_rejections_user_id ON transfer_rejections(user_id);
+CREATE INDEX IF NOT EXISTS idx_transfer_rejections_created_at ON transfer_rejections(created_at);
+
+-- The policy sums and counts a sender's recent transfers
+CREATE INDEX IF NOT EXISTS idx_transfers_from_user_created_at ON transfers(from_user_id, created_at);
@@ -0,0 +1,9 @@
+DELETE FROM transfers WHERE kind NOT IN ('transfer', 'deposit');
+
+DROP INDEX IF EXISTS idx_transfers_escrow_id;
+ALTER TABLE transfers DROP COLUMN IF EXISTS escrow_id;
+ALTER TABLE transfers DROP CONSTRAINT IF EXISTS transfers_kind_check;
+ALTER TABLE transfers ADD CONSTRAINT transfers_kind_check CHECK (kind IN ('transfer', 'deposit'));
+
+DROP TABLE IF EXISTS escrow_events;
+DROP TABLE IF EXISTS escrows;
@@ -0,0 +1,48 @@
+-- Paid mentorship sessions. The patient's payment is held in the platform
+-- escrow account until it is released to the coach or refunded.
+CREATE TABLE IF NOT EXISTS escrows (
+    id SERIAL PRIMARY KEY,
+    patient_id INTEGER NOT NULL,
+    coach_id INTEGER NOT NULL,
+    amount_tinybars BIGINT NOT NULL CHECK (amount_tinybars > 0),
+    status TEXT NOT NULL CHECK (status IN ('funding', 'funded', 'disputed', 'releasing', 'released', 'refunding', 'refunded', 'failed')),
+    description TEXT,
+    scheduled_at TIMESTAMP NOT NULL,
+    auto_complete_at TIMESTAMP NOT NULL,
+    patient_confirmed_at TIMESTAMP,
+    coach_confirmed_at TIMESTAMP,
+    dispute_reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    settled_at TIMESTAMP,
+    FOREIGN KEY (patient_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (coach_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_escrows_patient_id ON escrows(patient_id);
+CREATE INDEX IF NOT EXISTS idx_escrows_coach_id ON escrows(coach_id);
+CREATE INDEX IF NOT EXISTS idx_escrows_status ON escrows(status);
+
+-- Every state transition of an escrow
+CREATE TABLE IF NOT EXISTS escrow_events (
+    id SERIAL PRIMARY KEY,
+    escrow_id INTEGER NOT NULL,
+    from_status TEXT,
+    to_status TEXT NOT NULL,
+    actor_user_id INTEGER,
+    transfer_id INTEGER,
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (escrow_id) REFERENCES escrows(id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_escrow_events_escrow_id ON escrow_events(escrow_id);
+
+-- Escrow payments move through the transfers table
+ALTER TABLE transfers DROP CONSTRAINT IF EXISTS transfers_kind_check;
+ALTER TABLE transfers ADD CONSTRAINT transfers_kind_check
+    CHECK (kind IN ('transfer', 'deposit', 'escrow_fund', 'escrow_release', 'escrow_refund'));
+ALTER TABLE transfers ADD COLUMN escrow_id INTEGER REFERENCES escrows(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_transfers_escrow_id ON transfers(escrow_id);
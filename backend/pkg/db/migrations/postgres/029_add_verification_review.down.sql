@@ -0,0 +1,5 @@
+UPDATE verification_requests SET status = 'pending' WHERE status = 'needs_info';
+ALTER TABLE verification_requests DROP COLUMN IF EXISTS review_note;
+ALTER TABLE verification_requests DROP CONSTRAINT IF EXISTS verification_requests_status_check;
+ALTER TABLE verification_requests ADD CONSTRAINT verification_requests_status_check
+    CHECK (status IN ('pending', 'approved', 'rejected'));
@@ -0,0 +1,6 @@
+-- Admins can ask applicants for more information before deciding, and the
+-- reason given for a rejection or information request is kept on the request
+ALTER TABLE verification_requests DROP CONSTRAINT IF EXISTS verification_requests_status_check;
+ALTER TABLE verification_requests ADD CONSTRAINT verification_requests_status_check
+    CHECK (status IN ('pending', 'needs_info', 'approved', 'rejected'));
+ALTER TABLE verification_requests ADD COLUMN IF NOT EXISTS review_note TEXT;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS admin_actions;
+DROP TABLE IF EXISTS user_suspensions;
+DROP TABLE IF EXISTS staff_roles;
@@ -0,0 +1,44 @@
+-- Platform staff. Staff roles are separate from the community role (user,
+-- coach or mentor) so a coach can also be a moderator.
+CREATE TABLE IF NOT EXISTS staff_roles (
+    user_id INTEGER PRIMARY KEY,
+    role TEXT NOT NULL CHECK (role IN ('admin', 'moderator')),
+    granted_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (granted_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- Account suspensions. A suspension without an end lasts until it is lifted.
+CREATE TABLE IF NOT EXISTS user_suspensions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    suspended_by INTEGER,
+    suspended_until TIMESTAMP,
+    lifted_at TIMESTAMP,
+    lifted_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (suspended_by) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (lifted_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_suspensions_user_id ON user_suspensions(user_id);
+
+-- Every action taken through the admin API. Rows outlive the staff member
+-- and the target so the log stays complete.
+CREATE TABLE IF NOT EXISTS admin_actions (
+    id SERIAL PRIMARY KEY,
+    admin_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id INTEGER,
+    details TEXT,
+    ip_address TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_actions_admin_id ON admin_actions(admin_id);
+CREATE INDEX IF NOT EXISTS idx_admin_actions_target ON admin_actions(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_admin_actions_created_at ON admin_actions(created_at);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS content_reports;
+DROP TABLE IF EXISTS moderation_cases;
+
+ALTER TABLE messages DROP COLUMN hidden_at;
+ALTER TABLE group_post_comments DROP COLUMN hidden_at;
+ALTER TABLE group_posts DROP COLUMN hidden_at;
+ALTER TABLE comments DROP COLUMN hidden_at;
+ALTER TABLE posts DROP COLUMN hidden_at;
@@ -0,0 +1,33 @@
+-- Crisis alerts are raised when new content matches the crisis-language
+-- rules. The content is never blocked; moderators follow up on the alert.
+CREATE TABLE IF NOT EXISTS crisis_alerts (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    content_type TEXT NOT NULL CHECK (content_type IN ('post', 'comment', 'message')),
+    content_id INTEGER NOT NULL,
+    content_snapshot TEXT NOT NULL,
+    category TEXT NOT NULL,
+    severity TEXT NOT NULL CHECK (severity IN ('high', 'medium')),
+    matched_phrases TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'acknowledged')),
+    care_circle_notified BOOLEAN NOT NULL DEFAULT FALSE,
+    acknowledged_by INTEGER,
+    acknowledged_at TIMESTAMP,
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (acknowledged_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_crisis_alerts_status ON crisis_alerts(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_crisis_alerts_user_id ON crisis_alerts(user_id, created_at);
+
+-- People a user trusts to be told when they may need support
+CREATE TABLE IF NOT EXISTS care_circle_contacts (
+    user_id INTEGER NOT NULL,
+    contact_id INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, contact_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (contact_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,8 @@
+-- The device a session was signed in from and when it was last used, so
+-- users can recognize and revoke their sessions
+ALTER TABLE sessions ADD COLUMN user_agent TEXT;
+ALTER TABLE sessions ADD COLUMN ip_address TEXT;
+ALTER TABLE sessions ADD COLUMN last_seen_at TIMESTAMP;
+UPDATE sessions SET last_seen_at = COALESCE(created_at, CURRENT_TIMESTAMP);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
@@ -0,0 +1,15 @@
+-- Sessions are found by the SHA-256 hash of their token, so the table no
+-- longer holds anything that signs a user in. Existing tokens are hashed in
+-- place and stay signed in.
+ALTER TABLE sessions RENAME COLUMN token TO token_hash;
+ALTER TABLE sessions ALTER COLUMN token_hash TYPE TEXT;
+UPDATE sessions SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+-- expires_at now slides forward while a session is used, up to
+-- absolute_expires_at. rotation_due asks for a new token on the next request
+-- after the user's privileges change.
+ALTER TABLE sessions ADD COLUMN absolute_expires_at TIMESTAMP;
+UPDATE sessions SET absolute_expires_at = expires_at;
+ALTER TABLE sessions ADD COLUMN rotation_due BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
@@ -0,0 +1,16 @@
+-- Payments held for review go back to pending or submitted so recovery
+-- checks them again
+UPDATE transfers SET status = 'pending' WHERE status = 'needs_review';
+UPDATE reward_allocations SET status = 'submitted' WHERE status = 'needs_review';
+UPDATE user_badges SET status = 'submitted' WHERE status = 'needs_review';
+
+ALTER TABLE transfers DROP CONSTRAINT IF EXISTS transfers_status_check;
+ALTER TABLE transfers ADD CONSTRAINT transfers_status_check CHECK (status IN ('pending', 'completed', 'failed'));
+
+ALTER TABLE reward_allocations DROP CONSTRAINT IF EXISTS reward_allocations_status_check;
+ALTER TABLE reward_allocations ADD CONSTRAINT reward_allocations_status_check
+    CHECK (status IN ('pending', 'submitted', 'completed', 'failed'));
+
+ALTER TABLE user_badges DROP CONSTRAINT IF EXISTS user_badges_status_check;
+ALTER TABLE user_badges ADD CONSTRAINT user_badges_status_check
+    CHECK (status IN ('pending', 'submitted', 'minted', 'failed'));
@@ -0,0 +1,12 @@
+-- Payments whose outcome neither the ledger receipt nor the mirror node can
+-- confirm are held for review instead of being failed
+ALTER TABLE transfers DROP CONSTRAINT IF EXISTS transfers_status_check;
+ALTER TABLE transfers ADD CONSTRAINT transfers_status_check CHECK (status IN ('pending', 'completed', 'failed', 'needs_review'));
+
+ALTER TABLE reward_allocations DROP CONSTRAINT IF EXISTS reward_allocations_status_check;
+ALTER TABLE reward_allocations ADD CONSTRAINT reward_allocations_status_check
+    CHECK (status IN ('pending', 'submitted', 'completed', 'failed', 'needs_review'));
+
+ALTER TABLE user_badges DROP CONSTRAINT IF EXISTS user_badges_status_check;
+ALTER TABLE user_badges ADD CONSTRAINT user_badges_status_check
+    CHECK (status IN ('pending', 'submitted', 'minted', 'failed', 'needs_review'));
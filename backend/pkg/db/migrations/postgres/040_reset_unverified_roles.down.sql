@@ -0,0 +1,11 @@
+-- Give the self-assigned roles back to users who still have the user role
+UPDATE users SET role = (
+    SELECT r.previous_role FROM role_resets r
+    WHERE r.user_id = users.id AND r.reason = 'self_assigned_at_registration'
+    ORDER BY r.id DESC LIMIT 1
+), updated_at = CURRENT_TIMESTAMP
+WHERE role = 'user' AND id IN (
+    SELECT user_id FROM role_resets WHERE reason = 'self_assigned_at_registration'
+);
+
+DROP TABLE IF EXISTS role_resets;
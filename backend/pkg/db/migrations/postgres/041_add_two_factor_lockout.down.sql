@@ -0,0 +1,2 @@
+ALTER TABLE user_two_factor DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE user_two_factor DROP COLUMN IF EXISTS failed_attempts;
@@ -0,0 +1,4 @@
+-- Count wrong two-factor codes per user; after too many in a row every code
+-- check is refused until locked_until
+ALTER TABLE user_two_factor ADD COLUMN failed_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE user_two_factor ADD COLUMN locked_until TIMESTAMP;
@@ -0,0 +1,6 @@
+UPDATE transfers SET to_user_id = (SELECT coach_id FROM escrows WHERE escrows.id = transfers.escrow_id)
+WHERE kind = 'escrow_fund' AND to_user_id IS NULL;
+DELETE FROM transfers WHERE to_user_id IS NULL;
+
+DROP INDEX IF EXISTS idx_transfers_escrow_payout_key;
+ALTER TABLE transfers ALTER COLUMN to_user_id SET NOT NULL;
@@ -0,0 +1,8 @@
+-- Session payments go to the escrow account, not to the coach, so escrow
+-- funding rows name no receiving user and to_user_id becomes optional.
+-- Escrow releases and refunds carry an idempotency key per escrow so a
+-- payout cannot be recorded twice.
+ALTER TABLE transfers ALTER COLUMN to_user_id DROP NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_escrow_payout_key ON transfers(escrow_id, idempotency_key) WHERE from_user_id IS NULL AND escrow_id IS NOT NULL;
+
+UPDATE transfers SET to_user_id = NULL WHERE kind = 'escrow_fund';
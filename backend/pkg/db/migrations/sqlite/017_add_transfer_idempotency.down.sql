@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_transfers_status;
+DROP INDEX IF EXISTS idx_transfers_idempotency_key;
+ALTER TABLE transfers DROP COLUMN updated_at;
+ALTER TABLE transfers DROP COLUMN failure_reason;
+ALTER TABLE transfers DROP COLUMN idempotency_key;
@@ -0,0 +1,9 @@
+-- Track two-phase transfer state and client idempotency keys
+ALTER TABLE transfers ADD COLUMN idempotency_key TEXT;
+ALTER TABLE transfers ADD COLUMN failure_reason TEXT;
+ALTER TABLE transfers ADD COLUMN updated_at TIMESTAMP;
+
+UPDATE transfers SET updated_at = created_at WHERE updated_at IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_idempotency_key ON transfers(from_user_id, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_transfers_status ON transfers(status);
@@ -0,0 +1,7 @@
+ALTER TABLE user_wallets ADD COLUMN token_balance DECIMAL(20,8) DEFAULT 0.00000000;
+UPDATE user_wallets SET token_balance = COALESCE(balance_tinybars, 0) / 100000000.0;
+ALTER TABLE user_wallets DROP COLUMN balance_tinybars;
+
+ALTER TABLE transfers ADD COLUMN amount DECIMAL(20,8) NOT NULL DEFAULT 0;
+UPDATE transfers SET amount = amount_tinybars / 100000000.0;
+ALTER TABLE transfers DROP COLUMN amount_tinybars;
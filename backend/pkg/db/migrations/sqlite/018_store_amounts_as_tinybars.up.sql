@@ -0,0 +1,8 @@
+-- Store HBAR amounts as integer tinybars (1 HBAR = 100000000 tinybars)
+ALTER TABLE transfers ADD COLUMN amount_tinybars INTEGER NOT NULL DEFAULT 0;
+UPDATE transfers SET amount_tinybars = CAST(ROUND(amount * 100000000) AS INTEGER);
+ALTER TABLE transfers DROP COLUMN amount;
+
+ALTER TABLE user_wallets ADD COLUMN balance_tinybars INTEGER DEFAULT 0;
+UPDATE user_wallets SET balance_tinybars = CAST(ROUND(COALESCE(token_balance, 0) * 100000000) AS INTEGER);
+ALTER TABLE user_wallets DROP COLUMN token_balance;
@@ -0,0 +1,33 @@
+ALTER TABLE comments DROP COLUMN tip_count;
+ALTER TABLE comments DROP COLUMN tip_total_tinybars;
+ALTER TABLE posts DROP COLUMN tip_count;
+ALTER TABLE posts DROP COLUMN tip_total_tinybars;
+
+-- SQLite cannot drop columns with a REFERENCES clause, so rebuild transfers
+CREATE TABLE transfers_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_user_id INTEGER NOT NULL,
+    to_user_id INTEGER NOT NULL,
+    transaction_id TEXT,
+    status TEXT DEFAULT 'completed' CHECK (status IN ('pending', 'completed', 'failed')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    idempotency_key TEXT,
+    failure_reason TEXT,
+    updated_at TIMESTAMP,
+    amount_tinybars INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (from_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (to_user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO transfers_old (id, from_user_id, to_user_id, transaction_id, status, created_at, idempotency_key, failure_reason, updated_at, amount_tinybars)
+SELECT id, from_user_id, to_user_id, transaction_id, status, created_at, idempotency_key, failure_reason, updated_at, amount_tinybars
+FROM transfers;
+
+DROP TABLE transfers;
+ALTER TABLE transfers_old RENAME TO transfers;
+
+CREATE INDEX IF NOT EXISTS idx_transfers_from_user ON transfers(from_user_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_to_user ON transfers(to_user_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_created_at ON transfers(created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_idempotency_key ON transfers(from_user_id, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_transfers_status ON transfers(status);
@@ -0,0 +1,12 @@
+-- Link tips to the post or comment they rewarded
+ALTER TABLE transfers ADD COLUMN post_id INTEGER REFERENCES posts(id) ON DELETE SET NULL;
+ALTER TABLE transfers ADD COLUMN comment_id INTEGER REFERENCES comments(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_transfers_post_id ON transfers(post_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_comment_id ON transfers(comment_id);
+
+-- Aggregated totals of completed tips, maintained like the reaction counts
+ALTER TABLE posts ADD COLUMN tip_total_tinybars INTEGER DEFAULT 0;
+ALTER TABLE posts ADD COLUMN tip_count INTEGER DEFAULT 0;
+ALTER TABLE comments ADD COLUMN tip_total_tinybars INTEGER DEFAULT 0;
+ALTER TABLE comments ADD COLUMN tip_count INTEGER DEFAULT 0;
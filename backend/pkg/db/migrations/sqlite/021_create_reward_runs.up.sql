@@ -0,0 +1,36 @@
+-- Weekly contributor reward runs and their per-user allocations
+CREATE TABLE IF NOT EXISTS reward_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    period_start TIMESTAMP NOT NULL,
+    period_end TIMESTAMP NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending_approval' CHECK (status IN ('pending_approval', 'approved', 'completed', 'cancelled')),
+    pool_units INTEGER NOT NULL,
+    total_units INTEGER NOT NULL DEFAULT 0,
+    approved_by INTEGER,
+    approved_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (approved_by) REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE(period_start, period_end)
+);
+
+CREATE TABLE IF NOT EXISTS reward_allocations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    score INTEGER NOT NULL,
+    breakdown TEXT,
+    amount_units INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'submitted', 'completed', 'failed')),
+    transaction_id TEXT,
+    failure_reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (run_id) REFERENCES reward_runs(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE(run_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reward_runs_status ON reward_runs(status);
+CREATE INDEX IF NOT EXISTS idx_reward_allocations_run_id ON reward_allocations(run_id);
+CREATE INDEX IF NOT EXISTS idx_reward_allocations_status ON reward_allocations(status);
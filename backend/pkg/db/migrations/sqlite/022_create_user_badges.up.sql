@@ -0,0 +1,19 @@
+-- Achievement badges earned by users and the NFTs minted for them
+CREATE TABLE IF NOT EXISTS user_badges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    badge_type TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'submitted', 'minted', 'failed')),
+    token_id TEXT,
+    serial_number INTEGER,
+    transaction_id TEXT,
+    failure_reason TEXT,
+    earned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    minted_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE(user_id, badge_type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_badges_user_id ON user_badges(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_badges_status ON user_badges(status);
@@ -0,0 +1,10 @@
+-- Hash and HCS topic position anchoring tips, reward payouts and badge mints
+ALTER TABLE transfers ADD COLUMN audit_hash TEXT;
+ALTER TABLE transfers ADD COLUMN audit_topic_id TEXT;
+ALTER TABLE transfers ADD COLUMN audit_sequence_number INTEGER;
+ALTER TABLE reward_allocations ADD COLUMN audit_hash TEXT;
+ALTER TABLE reward_allocations ADD COLUMN audit_topic_id TEXT;
+ALTER TABLE reward_allocations ADD COLUMN audit_sequence_number INTEGER;
+ALTER TABLE user_badges ADD COLUMN audit_hash TEXT;
+ALTER TABLE user_badges ADD COLUMN audit_topic_id TEXT;
+ALTER TABLE user_badges ADD COLUMN audit_sequence_number INTEGER;
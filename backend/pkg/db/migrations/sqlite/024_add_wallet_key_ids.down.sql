@@ -0,0 +1,2 @@
+ALTER TABLE user_wallets DROP COLUMN encrypted_data_key;
+ALTER TABLE user_wallets DROP COLUMN encryption_key_id;
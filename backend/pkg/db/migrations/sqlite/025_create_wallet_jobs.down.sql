@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS wallet_jobs;
+ALTER TABLE users DROP COLUMN wallet_status;
@@ -0,0 +1,20 @@
+-- Wallets are provisioned by a background queue instead of during registration
+ALTER TABLE users ADD COLUMN wallet_status TEXT NOT NULL DEFAULT 'active';
+UPDATE users SET wallet_status = 'provisioning' WHERE id NOT IN (SELECT user_id FROM user_wallets);
+
+CREATE TABLE IF NOT EXISTS wallet_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL UNIQUE,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_jobs_status ON wallet_jobs(status, next_attempt_at);
+
+-- Existing users without a wallet are queued as well
+INSERT INTO wallet_jobs (user_id) SELECT id FROM users WHERE wallet_status = 'provisioning';
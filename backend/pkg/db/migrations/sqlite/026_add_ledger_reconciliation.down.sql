@@ -0,0 +1,42 @@
+DROP TABLE IF EXISTS ledger_drift;
+
+ALTER TABLE user_wallets DROP COLUMN reconciled_at;
+ALTER TABLE user_wallets DROP COLUMN mirror_cursor;
+
+-- Deposits cannot be represented without a sending user and are dropped
+CREATE TABLE transfers_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_user_id INTEGER NOT NULL,
+    to_user_id INTEGER NOT NULL,
+    transaction_id TEXT,
+    status TEXT DEFAULT 'completed' CHECK (status IN ('pending', 'completed', 'failed')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    idempotency_key TEXT,
+    failure_reason TEXT,
+    updated_at TIMESTAMP,
+    amount_tinybars INTEGER NOT NULL DEFAULT 0,
+    post_id INTEGER REFERENCES posts(id) ON DELETE SET NULL,
+    comment_id INTEGER REFERENCES comments(id) ON DELETE SET NULL,
+    audit_hash TEXT,
+    audit_topic_id TEXT,
+    audit_sequence_number INTEGER,
+    FOREIGN KEY (from_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (to_user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO transfers_old (id, from_user_id, to_user_id, transaction_id, status, created_at, idempotency_key,
+    failure_reason, updated_at, amount_tinybars, post_id, comment_id, audit_hash, audit_topic_id, audit_sequence_number)
+SELECT id, from_user_id, to_user_id, transaction_id, status, created_at, idempotency_key,
+    failure_reason, updated_at, amount_tinybars, post_id, comment_id, audit_hash, audit_topic_id, audit_sequence_number
+FROM transfers WHERE kind = 'transfer';
+
+DROP TABLE transfers;
+ALTER TABLE transfers_old RENAME TO transfers;
+
+CREATE INDEX IF NOT EXISTS idx_transfers_from_user ON transfers(from_user_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_to_user ON transfers(to_user_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_created_at ON transfers(created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_idempotency_key ON transfers(from_user_id, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_transfers_status ON transfers(status);
+CREATE INDEX IF NOT EXISTS idx_transfers_post_id ON transfers(post_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_comment_id ON transfers(comment_id);
@@ -0,0 +1,64 @@
+-- Deposits from accounts outside the platform have no sending user, so the
+-- transfers table is rebuilt with a nullable from_user_id
+CREATE TABLE transfers_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_user_id INTEGER,
+    to_user_id INTEGER NOT NULL,
+    transaction_id TEXT,
+    status TEXT DEFAULT 'completed' CHECK (status IN ('pending', 'completed', 'failed')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    idempotency_key TEXT,
+    failure_reason TEXT,
+    updated_at TIMESTAMP,
+    amount_tinybars INTEGER NOT NULL DEFAULT 0,
+    post_id INTEGER REFERENCES posts(id) ON DELETE SET NULL,
+    comment_id INTEGER REFERENCES comments(id) ON DELETE SET NULL,
+    audit_hash TEXT,
+    audit_topic_id TEXT,
+    audit_sequence_number INTEGER,
+    kind TEXT NOT NULL DEFAULT 'transfer' CHECK (kind IN ('transfer', 'deposit')),
+    external_account_id TEXT,
+    FOREIGN KEY (from_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (to_user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO transfers_new (id, from_user_id, to_user_id, transaction_id, status, created_at, idempotency_key,
+    failure_reason, updated_at, amount_tinybars, post_id, comment_id, audit_hash, audit_topic_id, audit_sequence_number)
+SELECT id, from_user_id, to_user_id, transaction_id, status, created_at, idempotency_key,
+    failure_reason, updated_at, amount_tinybars, post_id, comment_id, audit_hash, audit_topic_id, audit_sequence_number
+FROM transfers;
+
+DROP TABLE transfers;
+ALTER TABLE transfers_new RENAME TO transfers;
+
+CREATE INDEX IF NOT EXISTS idx_transfers_from_user ON transfers(from_user_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_to_user ON transfers(to_user_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_created_at ON transfers(created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_idempotency_key ON transfers(from_user_id, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_transfers_status ON transfers(status);
+CREATE INDEX IF NOT EXISTS idx_transfers_post_id ON transfers(post_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_comment_id ON transfers(comment_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_transaction_id ON transfers(transaction_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_deposit_transaction ON transfers(transaction_id) WHERE kind = 'deposit';
+
+-- Consensus timestamp of the last mirror node transaction reconciled per wallet
+ALTER TABLE user_wallets ADD COLUMN mirror_cursor TEXT;
+ALTER TABLE user_wallets ADD COLUMN reconciled_at TIMESTAMP;
+
+-- Differences found between the ledger and the transfers table
+CREATE TABLE IF NOT EXISTS ledger_drift (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    hedera_account_id TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('balance', 'unrecorded_transaction', 'amount_mismatch', 'status_mismatch')),
+    transaction_id TEXT,
+    transfer_id INTEGER,
+    expected_tinybars INTEGER,
+    actual_tinybars INTEGER,
+    detail TEXT,
+    detected_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_drift_user_id ON ledger_drift(user_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_drift_detected_at ON ledger_drift(detected_at);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_transfers_from_user_created_at;
+DROP INDEX IF EXISTS idx_transfer_rejections_created_at;
+DROP INDEX IF EXISTS idx_transfer_rejections_user_id;
+DROP TABLE IF EXISTS transfer_rejections;
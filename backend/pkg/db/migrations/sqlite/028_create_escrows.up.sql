@@ -0,0 +1,88 @@
+-- Paid mentorship sessions. The patient's payment is held in the platform
+-- escrow account until it is released to the coach or refunded.
+CREATE TABLE IF NOT EXISTS escrows (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    patient_id INTEGER NOT NULL,
+    coach_id INTEGER NOT NULL,
+    amount_tinybars INTEGER NOT NULL CHECK (amount_tinybars > 0),
+    status TEXT NOT NULL CHECK (status IN ('funding', 'funded', 'disputed', 'releasing', 'released', 'refunding', 'refunded', 'failed')),
+    description TEXT,
+    scheduled_at TIMESTAMP NOT NULL,
+    auto_complete_at TIMESTAMP NOT NULL,
+    patient_confirmed_at TIMESTAMP,
+    coach_confirmed_at TIMESTAMP,
+    dispute_reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    settled_at TIMESTAMP,
+    FOREIGN KEY (patient_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (coach_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_escrows_patient_id ON escrows(patient_id);
+CREATE INDEX IF NOT EXISTS idx_escrows_coach_id ON escrows(coach_id);
+CREATE INDEX IF NOT EXISTS idx_escrows_status ON escrows(status);
+
+-- Every state transition of an escrow
+CREATE TABLE IF NOT EXISTS escrow_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    escrow_id INTEGER NOT NULL,
+    from_status TEXT,
+    to_status TEXT NOT NULL,
+    actor_user_id INTEGER,
+    transfer_id INTEGER,
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (escrow_id) REFERENCES escrows(id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_escrow_events_escrow_id ON escrow_events(escrow_id);
+
+-- Escrow payments move through the transfers table, so it is rebuilt to
+-- allow their kinds and link them to their escrow
+CREATE TABLE transfers_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_user_id INTEGER,
+    to_user_id INTEGER NOT NULL,
+    transaction_id TEXT,
+    status TEXT DEFAULT 'completed' CHECK (status IN ('pending', 'completed', 'failed')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    idempotency_key TEXT,
+    failure_reason TEXT,
+    updated_at TIMESTAMP,
+    amount_tinybars INTEGER NOT NULL DEFAULT 0,
+    post_id INTEGER REFERENCES posts(id) ON DELETE SET NULL,
+    comment_id INTEGER REFERENCES comments(id) ON DELETE SET NULL,
+    audit_hash TEXT,
+    audit_topic_id TEXT,
+    audit_sequence_number INTEGER,
+    kind TEXT NOT NULL DEFAULT 'transfer' CHECK (kind IN ('transfer', 'deposit', 'escrow_fund', 'escrow_release', 'escrow_refund')),
+    external_account_id TEXT,
+    escrow_id INTEGER REFERENCES escrows(id) ON DELETE SET NULL,
+    FOREIGN KEY (from_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (to_user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO transfers_new (id, from_user_id, to_user_id, transaction_id, status, created_at, idempotency_key,
+    failure_reason, updated_at, amount_tinybars, post_id, comment_id, audit_hash, audit_topic_id, audit_sequence_number,
+    kind, external_account_id)
+SELECT id, from_user_id, to_user_id, transaction_id, status, created_at, idempotency_key,
+    failure_reason, updated_at, amount_tinybars, post_id, comment_id, audit_hash, audit_topic_id, audit_sequence_number,
+    kind, external_account_id
+FROM transfers;
+
+DROP TABLE transfers;
+ALTER TABLE transfers_new RENAME TO transfers;
+
+CREATE INDEX IF NOT EXISTS idx_transfers_from_user ON transfers(from_user_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_to_user ON transfers(to_user_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_created_at ON transfers(created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_idempotency_key ON transfers(from_user_id, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_transfers_status ON transfers(status);
+CREATE INDEX IF NOT EXISTS idx_transfers_post_id ON transfers(post_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_comment_id ON transfers(comment_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_transaction_id ON transfers(transaction_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_deposit_transaction ON transfers(transaction_id) WHERE kind = 'deposit';
+CREATE INDEX IF NOT EXISTS idx_transfers_from_user_created_at ON transfers(from_user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_transfers_escrow_id ON transfers(escrow_id);
@@ -0,0 +1,26 @@
+CREATE TABLE verification_requests_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    requested_role TEXT NOT NULL CHECK (requested_role IN ('coach', 'mentor')),
+    status TEXT DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    documents TEXT, -- JSON array of document URLs
+    notes TEXT,
+    reviewed_by INTEGER NULL,
+    reviewed_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (reviewed_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- Requests waiting for more information go back to pending
+INSERT INTO verification_requests_old (id, user_id, requested_role, status, documents, notes, reviewed_by, reviewed_at, created_at, updated_at)
+SELECT id, user_id, requested_role, CASE WHEN status = 'needs_info' THEN 'pending' ELSE status END,
+    documents, notes, reviewed_by, reviewed_at, created_at, updated_at
+FROM verification_requests;
+
+DROP TABLE verification_requests;
+ALTER TABLE verification_requests_old RENAME TO verification_requests;
+
+CREATE INDEX IF NOT EXISTS idx_verification_requests_user_id ON verification_requests(user_id);
+CREATE INDEX IF NOT EXISTS idx_verification_requests_status ON verification_requests(status);
@@ -0,0 +1,46 @@
+-- Content hidden by a moderator stays in place but is left out of listings
+ALTER TABLE posts ADD COLUMN hidden_at TIMESTAMP;
+ALTER TABLE comments ADD COLUMN hidden_at TIMESTAMP;
+ALTER TABLE group_posts ADD COLUMN hidden_at TIMESTAMP;
+ALTER TABLE group_post_comments ADD COLUMN hidden_at TIMESTAMP;
+ALTER TABLE messages ADD COLUMN hidden_at TIMESTAMP;
+
+-- Moderation queue. Reports on the same content share one open case, which
+-- keeps a copy of the content so it can be reviewed after it is deleted.
+CREATE TABLE IF NOT EXISTS moderation_cases (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    content_type TEXT NOT NULL CHECK (content_type IN ('post', 'comment', 'group_post', 'group_comment', 'message')),
+    content_id INTEGER NOT NULL,
+    author_id INTEGER,
+    content_snapshot TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'actioned', 'dismissed')),
+    priority INTEGER NOT NULL DEFAULT 0,
+    report_count INTEGER NOT NULL DEFAULT 0,
+    action TEXT CHECK (action IN ('hide', 'delete', 'warn', 'suspend', 'dismiss')),
+    resolution_note TEXT,
+    resolved_by INTEGER,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_moderation_cases_open_content ON moderation_cases(content_type, content_id) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_moderation_cases_status_priority ON moderation_cases(status, priority);
+
+-- Each user reports a piece of content once per case
+CREATE TABLE IF NOT EXISTS content_reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    case_id INTEGER NOT NULL,
+    reporter_id INTEGER NOT NULL,
+    reason TEXT NOT NULL CHECK (reason IN ('harassment', 'hate', 'self_harm', 'misinformation', 'spam', 'privacy', 'other')),
+    details TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (case_id) REFERENCES moderation_cases(id) ON DELETE CASCADE,
+    FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (case_id, reporter_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_reports_reporter_id ON content_reports(reporter_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS care_circle_contacts;
+DROP TABLE IF EXISTS crisis_alerts;
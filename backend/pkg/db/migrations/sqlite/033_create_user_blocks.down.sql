@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_mutes;
+DROP TABLE IF EXISTS user_blocks;
@@ -0,0 +1,21 @@
+-- Blocking hides two users from each other and stops them interacting
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker_id INTEGER NOT NULL,
+    blocked_id INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks(blocked_id);
+
+-- Muting only hides the muted user's content from the user who muted them
+CREATE TABLE IF NOT EXISTS user_mutes (
+    muter_id INTEGER NOT NULL,
+    muted_id INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (muter_id, muted_id),
+    FOREIGN KEY (muter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (muted_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,17 @@
+-- When the user confirmed they own their email address. Accounts created
+-- before verification existed are treated as verified.
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+UPDATE users SET email_verified_at = COALESCE(created_at, CURRENT_TIMESTAMP);
+
+-- Single-use email verification tokens, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_user_id ON email_verification_tokens(user_id, created_at);
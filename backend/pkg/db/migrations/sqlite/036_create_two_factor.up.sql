@@ -0,0 +1,39 @@
+-- TOTP secrets, sealed like wallet private keys with a data key wrapped by
+-- the master key named in encryption_key_id. enabled_at stays NULL until the
+-- user confirms enrollment with a code; last_used_step keeps a code from
+-- being used twice.
+CREATE TABLE IF NOT EXISTS user_two_factor (
+    user_id INTEGER PRIMARY KEY,
+    encrypted_secret TEXT NOT NULL,
+    encryption_key_id TEXT NOT NULL,
+    encrypted_data_key TEXT NOT NULL,
+    enabled_at TIMESTAMP,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- One-time recovery codes, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS two_factor_recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_two_factor_recovery_codes_user_id ON two_factor_recovery_codes(user_id);
+
+-- Sign-ins waiting for the second factor, identified by a hashed token
+CREATE TABLE IF NOT EXISTS login_challenges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_sessions_user_id;
+ALTER TABLE sessions DROP COLUMN last_seen_at;
+ALTER TABLE sessions DROP COLUMN ip_address;
+ALTER TABLE sessions DROP COLUMN user_agent;
@@ -0,0 +1,6 @@
+-- Hashes cannot be turned back into tokens, so every session is signed out
+DELETE FROM sessions;
+DROP INDEX IF EXISTS idx_sessions_expires_at;
+ALTER TABLE sessions DROP COLUMN rotation_due;
+ALTER TABLE sessions DROP COLUMN absolute_expires_at;
+ALTER TABLE sessions RENAME COLUMN token_hash TO token;
@@ -0,0 +1,13 @@
+-- Sessions are found by the SHA-256 hash of their token, so the table no
+-- longer holds anything that signs a user in. Existing raw tokens cannot be
+-- hashed in SQLite, so their sessions are signed out.
+DELETE FROM sessions;
+ALTER TABLE sessions RENAME COLUMN token TO token_hash;
+
+-- expires_at now slides forward while a session is used, up to
+-- absolute_expires_at. rotation_due asks for a new token on the next request
+-- after the user's privileges change.
+ALTER TABLE sessions ADD COLUMN absolute_expires_at TIMESTAMP;
+ALTER TABLE sessions ADD COLUMN rotation_due BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
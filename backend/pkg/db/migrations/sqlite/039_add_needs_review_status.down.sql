@@ -0,0 +1,110 @@
+-- Payments held for review go back to pending or submitted so recovery
+-- checks them again
+CREATE TABLE transfers_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_user_id INTEGER,
+    to_user_id INTEGER NOT NULL,
+    transaction_id TEXT,
+    status TEXT DEFAULT 'completed' CHECK (status IN ('pending', 'completed', 'failed')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    idempotency_key TEXT,
+    failure_reason TEXT,
+    updated_at TIMESTAMP,
+    amount_tinybars INTEGER NOT NULL DEFAULT 0,
+    post_id INTEGER REFERENCES posts(id) ON DELETE SET NULL,
+    comment_id INTEGER REFERENCES comments(id) ON DELETE SET NULL,
+    audit_hash TEXT,
+    audit_topic_id TEXT,
+    audit_sequence_number INTEGER,
+    kind TEXT NOT NULL DEFAULT 'transfer' CHECK (kind IN ('transfer', 'deposit', 'escrow_fund', 'escrow_release', 'escrow_refund')),
+    external_account_id TEXT,
+    escrow_id INTEGER REFERENCES escrows(id) ON DELETE SET NULL,
+    FOREIGN KEY (from_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (to_user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO transfers_old (id, from_user_id, to_user_id, transaction_id, status, created_at, idempotency_key,
+    failure_reason, updated_at, amount_tinybars, post_id, comment_id, audit_hash, audit_topic_id, audit_sequence_number,
+    kind, external_account_id, escrow_id)
+SELECT id, from_user_id, to_user_id, transaction_id, CASE WHEN status = 'needs_review' THEN 'pending' ELSE status END, created_at, idempotency_key,
+    failure_reason, updated_at, amount_tinybars, post_id, comment_id, audit_hash, audit_topic_id, audit_sequence_number,
+    kind, external_account_id, escrow_id
+FROM transfers;
+
+DROP TABLE transfers;
+ALTER TABLE transfers_old RENAME TO transfers;
+
+CREATE INDEX IF NOT EXISTS idx_transfers_from_user ON transfers(from_user_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_to_user ON transfers(to_user_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_created_at ON transfers(created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_idempotency_key ON transfers(from_user_id, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_transfers_status ON transfers(status);
+CREATE INDEX IF NOT EXISTS idx_transfers_post_id ON transfers(post_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_comment_id ON transfers(comment_id);
+CREATE INDEX IF NOT EXISTS idx_transfers_transaction_id ON transfers(transaction_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_deposit_transaction ON transfers(transaction_id) WHERE kind = 'deposit';
+CREATE INDEX IF NOT EXISTS idx_transfers_from_user_created_at ON transfers(from_user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_transfers_escrow_id ON transfers(escrow_id);
+
+CREATE TABLE reward_allocations_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    score INTEGER NOT NULL,
+    breakdown TEXT,
+    amount_units INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'submitted', 'completed', 'failed')),
+    transaction_id TEXT,
+    failure_reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    audit_hash TEXT,
+    audit_topic_id TEXT,
+    audit_sequence_number INTEGER,
+    FOREIGN KEY (run_id) REFERENCES reward_runs(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE(run_id, user_id)
+);
+
+INSERT INTO reward_allocations_old (id, run_id, user_id, score, breakdown, amount_units, status, transaction_id, failure_reason,
+    created_at, updated_at, audit_hash, audit_topic_id, audit_sequence_number)
+SELECT id, run_id, user_id, score, breakdown, amount_units, CASE WHEN status = 'needs_review' THEN 'submitted' ELSE status END, transaction_id, failure_reason,
+    created_at, updated_at, audit_hash, audit_topic_id, audit_sequence_number
+FROM reward_allocations;
+
+DROP TABLE reward_allocations;
+ALTER TABLE reward_allocations_old RENAME TO reward_allocations;
+
+CREATE INDEX IF NOT EXISTS idx_reward_allocations_run_id ON reward_allocations(run_id);
+CREATE INDEX IF NOT EXISTS idx_reward_allocations_status ON reward_allocations(status);
+
+CREATE TABLE user_badges_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    badge_type TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'submitted', 'minted', 'failed')),
+    token_id TEXT,
+    serial_number INTEGER,
+    transaction_id TEXT,
+    failure_reason TEXT,
+    earned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    minted_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    audit_hash TEXT,
+    audit_topic_id TEXT,
+    audit_sequence_number INTEGER,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE(user_id, badge_type)
+);
+
+INSERT INTO user_badges_old (id, user_id, badge_type, status, token_id, serial_number, transaction_id, failure_reason,
+    earned_at, minted_at, updated_at, audit_hash, audit_topic_id, audit_sequence_number)
+SELECT id, user_id, badge_type, CASE WHEN status = 'needs_review' THEN 'submitted' ELSE status END, token_id, serial_number, transaction_id, failure_reason,
+    earned_at, minted_at, updated_at, audit_hash, audit_topic_id, audit_sequence_number
+FROM user_badges;
+
+DROP TABLE user_badges;
+ALTER TABLE user_badges_old RENAME TO user_badges;
+
+CREATE INDEX IF NOT EXISTS idx_user_badges_user_id ON user_badges(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_badges_status ON user_badges(status);
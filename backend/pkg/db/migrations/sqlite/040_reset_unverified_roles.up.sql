@@ -0,0 +1,20 @@
+-- Roles taken away from users, kept so a reset can be undone
+CREATE TABLE IF NOT EXISTS role_resets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    previous_role TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_resets_user_id ON role_resets(user_id);
+
+-- Registration used to accept any role, so coach and mentor roles that were
+-- never granted by an approved verification request go back to user
+INSERT INTO role_resets (user_id, previous_role, reason)
+SELECT id, role, 'self_assigned_at_registration' FROM users
+WHERE role IN ('coach', 'mentor') AND COALESCE(verification_status, 'unverified') <> 'verified';
+
+UPDATE users SET role = 'user', updated_at = CURRENT_TIMESTAMP
+WHERE role IN ('coach', 'mentor') AND COALESCE(verification_status, 'unverified') <> 'verified';
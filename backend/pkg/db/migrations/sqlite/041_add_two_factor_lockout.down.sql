@@ -0,0 +1,2 @@
+ALTER TABLE user_two_factor DROP COLUMN locked_until;
+ALTER TABLE user_two_factor DROP COLUMN failed_attempts;
@@ -0,0 +1,199 @@
+// Package emailverification confirms that users own the email address they
+// registered with. A link carrying a random single-use token is emailed at
+// registration and can be sent again a limited number of times; only a
+// hash of the token is stored. Until the address is confirmed the policy
+// keeps the account from the actions it restricts, such as messaging.
+package emailverification
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/mail"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+const (
+	// DefaultTokenTTL is how long a verification link works when
+	// EMAIL_VERIFICATION_TTL_HOURS is not set
+	DefaultTokenTTL = 48 * time.Hour
+
+	// DefaultVerifyURL is the page verification links point to when
+	// EMAIL_VERIFICATION_URL is not set
+	DefaultVerifyURL = "http://localhost:3000/verify-email"
+
+	// resendInterval is the least time between two verification emails
+	resendInterval = time.Minute
+
+	// maxEmailsPerDay bounds the verification emails sent to one account
+	maxEmailsPerDay = 5
+
+	// sendTimeout bounds the delivery of one email
+	sendTimeout = 30 * time.Second
+)
+
+var (
+	ErrInvalidToken    = errors.New("the verification link is invalid or has expired")
+	ErrAlreadyVerified = errors.New("your email address is already verified")
+)
+
+// ThrottledError is returned when a verification email is requested too
+// soon after the previous ones
+type ThrottledError struct {
+	RetryAfter time.Time
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("a verification email was sent recently; you can ask for another after %s", e.RetryAfter.UTC().Format(time.RFC3339))
+}
+
+// Config holds the verification link settings
+type Config struct {
+	TokenTTL  time.Duration
+	VerifyURL string
+}
+
+// ConfigFromEnv reads EMAIL_VERIFICATION_TTL_HOURS and
+// EMAIL_VERIFICATION_URL, the frontend page that receives the token as its
+// token query parameter
+func ConfigFromEnv() (Config, error) {
+	config := Config{TokenTTL: DefaultTokenTTL, VerifyURL: DefaultVerifyURL}
+
+	if value := os.Getenv("EMAIL_VERIFICATION_TTL_HOURS"); value != "" {
+		hours, err := strconv.Atoi(value)
+		if err != nil || hours <= 0 {
+			return Config{}, fmt.Errorf("invalid EMAIL_VERIFICATION_TTL_HOURS %q", value)
+		}
+		config.TokenTTL = time.Duration(hours) * time.Hour
+	}
+	if value := os.Getenv("EMAIL_VERIFICATION_URL"); value != "" {
+		parsed, err := url.Parse(value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return Config{}, fmt.Errorf("invalid EMAIL_VERIFICATION_URL %q", value)
+		}
+		config.VerifyURL = value
+	}
+	return config, nil
+}
+
+// Service sends verification links and confirms email addresses
+type Service struct {
+	db     *sql.DB
+	mailer mail.Mailer
+	config Config
+}
+
+// NewService creates an email verification service
+func NewService(db *sql.DB, mailer mail.Mailer, config Config) *Service {
+	return &Service{db: db, mailer: mailer, config: config}
+}
+
+// Send emails a verification link to a new user. The email is sent in the
+// background.
+func (s *Service) Send(user *models.User) error {
+	confirmed, err := s.confirmed(user)
+	if err != nil {
+		return err
+	}
+	if confirmed {
+		return ErrAlreadyVerified
+	}
+
+	token, err := utils.NewToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(s.config.TokenTTL)
+	if err := models.CreateEmailVerificationToken(s.db, user.ID, utils.HashToken(token), expiresAt); err != nil {
+		return err
+	}
+
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Verify your Oncure email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm this is your email address to finish setting up your Oncure account:\n\n%s\n\n"+
+			"The link expires in %s. If you did not create an account you can ignore this email.\n",
+			user.FirstName, utils.TokenLink(s.config.VerifyURL, token), utils.DescribeDuration(s.config.TokenTTL)),
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// Resend emails a new verification link, at most once a minute and
+// maxEmailsPerDay times a day
+func (s *Service) Resend(user *models.User) error {
+	confirmed, err := s.confirmed(user)
+	if err != nil {
+		return err
+	}
+	if confirmed {
+		return ErrAlreadyVerified
+	}
+
+	sent, err := models.GetEmailVerificationTokenTimes(s.db, user.ID, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	if len(sent) >= maxEmailsPerDay {
+		return &ThrottledError{RetryAfter: sent[len(sent)-maxEmailsPerDay].Add(24 * time.Hour)}
+	}
+	if len(sent) > 0 {
+		if next := sent[len(sent)-1].Add(resendInterval); time.Now().Before(next) {
+			return &ThrottledError{RetryAfter: next}
+		}
+	}
+	return s.Send(user)
+}
+
+// confirmed reports whether a user has nothing left to verify. Accounts
+// marked verified by migration 035 can still ask for a link, which
+// ADMIN_EMAILS requires before granting admin.
+func (s *Service) confirmed(user *models.User) (bool, error) {
+	if user.EmailVerifiedAt == nil {
+		return false, nil
+	}
+	return models.HasConfirmedEmail(s.db, user.ID)
+}
+
+// Confirm marks the email address of the account a token was sent to
+// verified and returns the account's user ID
+func (s *Service) Confirm(token string) (int, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return 0, ErrInvalidToken
+	}
+
+	verificationToken, err := models.GetEmailVerificationToken(s.db, utils.HashToken(token))
+	if err != nil {
+		return 0, err
+	}
+	if verificationToken == nil || verificationToken.UsedAt != nil || time.Now().After(verificationToken.ExpiresAt) {
+		return 0, ErrInvalidToken
+	}
+
+	confirmed, err := models.ConfirmEmail(s.db, verificationToken)
+	if err != nil {
+		return 0, err
+	}
+	if !confirmed {
+		return 0, ErrInvalidToken
+	}
+	log.Printf("Email address of user %d verified", verificationToken.UserID)
+	return verificationToken.UserID, nil
+}
@@ -0,0 +1,86 @@
+package emailverification
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+// Actions the policy can keep from accounts without a verified email
+const (
+	ActionMessaging = "messaging" // private and group messages
+	ActionTipping   = "tipping"   // tips and HBAR transfers
+	ActionSessions  = "sessions"  // booking paid sessions
+	ActionPosting   = "posting"   // posts, comments and group posts
+)
+
+var actions = map[string]bool{
+	ActionMessaging: true,
+	ActionTipping:   true,
+	ActionSessions:  true,
+	ActionPosting:   true,
+}
+
+// DefaultRestrictedActions are restricted when
+// EMAIL_VERIFICATION_REQUIRED_FOR is not set
+var DefaultRestrictedActions = []string{ActionMessaging, ActionTipping}
+
+// Policy lists the actions that need a verified email address. The zero
+// Policy restricts nothing.
+type Policy struct {
+	restricted map[string]bool
+}
+
+// NewPolicy creates a policy restricting actions
+func NewPolicy(restricted ...string) (Policy, error) {
+	policy := Policy{restricted: map[string]bool{}}
+	for _, action := range restricted {
+		if !actions[action] {
+			return Policy{}, fmt.Errorf("unknown email verification action %q", action)
+		}
+		policy.restricted[action] = true
+	}
+	return policy, nil
+}
+
+// PolicyFromEnv reads EMAIL_VERIFICATION_REQUIRED_FOR, a comma-separated
+// list of actions or "none"
+func PolicyFromEnv() (Policy, error) {
+	value, ok := os.LookupEnv("EMAIL_VERIFICATION_REQUIRED_FOR")
+	if !ok || strings.TrimSpace(value) == "" {
+		return NewPolicy(DefaultRestrictedActions...)
+	}
+	if strings.TrimSpace(value) == "none" {
+		return NewPolicy()
+	}
+
+	var restricted []string
+	for _, action := range strings.Split(value, ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			restricted = append(restricted, action)
+		}
+	}
+	policy, err := NewPolicy(restricted...)
+	if err != nil {
+		return Policy{}, fmt.Errorf("invalid EMAIL_VERIFICATION_REQUIRED_FOR: %w", err)
+	}
+	return policy, nil
+}
+
+// Allows reports whether a user may take an action
+func (p Policy) Allows(user *models.User, action string) bool {
+	return user.EmailVerifiedAt != nil || !p.restricted[action]
+}
+
+// Restricted returns the restricted actions in alphabetical order
+func (p Policy) Restricted() []string {
+	restricted := []string{}
+	for action := range p.restricted {
+		restricted = append(restricted, action)
+	}
+	sort.Strings(restricted)
+	return restricted
+}
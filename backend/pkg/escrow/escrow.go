@@ -0,0 +1,500 @@
+// Package escrow runs paid mentorship sessions. The patient's payment is
+// moved into the platform escrow account when the session is booked and held
+// there until both parties confirm the session, it auto-completes after a
+// timeout, or it is cancelled or disputed. Every payment goes through the
+// transfer service and every state change is recorded as an escrow event.
+package escrow
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	wallet "github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+// DefaultAutoCompleteAfter is how long after the scheduled time a session
+// completes on its own when ESCROW_AUTO_COMPLETE_HOURS is not set
+const DefaultAutoCompleteAfter = 72 * time.Hour
+
+// abandonedFundingAfter is how long an escrow may stay funding without a
+// funding transfer, e.g. after a crash, before it is marked failed
+const abandonedFundingAfter = 10 * time.Minute
+
+const batchSize = 50
+
+var (
+	ErrNotFound        = errors.New("escrow not found")
+	ErrInvalidCoach    = errors.New("sessions can only be booked with a coach or mentor")
+	ErrCoachUnverified = errors.New("sessions can only be booked with a verified coach or mentor")
+	ErrOwnSession      = errors.New("you cannot book a session with yourself")
+	ErrInvalidSchedule = errors.New("scheduled_at must be in the future")
+	ErrInvalidAmount   = errors.New("amount must be greater than zero")
+	ErrWrongStatus     = errors.New("the session cannot be changed in its current status")
+	ErrTooLateToCancel = errors.New("the session has started; open a dispute instead of cancelling")
+	ErrInvalidOutcome  = errors.New("outcome must be release or refund")
+)
+
+// Summary counts what a pass of the worker did
+type Summary struct {
+	Completed int `json:"completed"`
+	Settled   int `json:"settled"`
+	Errors    int `json:"errors"`
+}
+
+// Service books, settles and refunds paid sessions
+type Service struct {
+	db                *sql.DB
+	transfers         *wallet.TransferService
+	account           *wallet.EscrowAccount
+	autoCompleteAfter time.Duration
+
+	// mu keeps worker passes from overlapping; settleMu keeps a payout from
+	// being submitted twice by a request and the worker at once
+	mu       sync.Mutex
+	settleMu sync.Mutex
+}
+
+// NewService creates an escrow service. account may be nil, which disables paid sessions.
+func NewService(db *sql.DB, transfers *wallet.TransferService, account *wallet.EscrowAccount, autoCompleteAfter time.Duration) *Service {
+	return &Service{db: db, transfers: transfers, account: account, autoCompleteAfter: autoCompleteAfter}
+}
+
+// AutoCompleteAfterFromEnv reads ESCROW_AUTO_COMPLETE_HOURS
+func AutoCompleteAfterFromEnv() (time.Duration, error) {
+	value := os.Getenv("ESCROW_AUTO_COMPLETE_HOURS")
+	if value == "" {
+		return DefaultAutoCompleteAfter, nil
+	}
+	hours, err := strconv.Atoi(value)
+	if err != nil || hours <= 0 {
+		return 0, fmt.Errorf("invalid ESCROW_AUTO_COMPLETE_HOURS %q", value)
+	}
+	return time.Duration(hours) * time.Hour, nil
+}
+
+// Enabled reports whether paid sessions can be booked
+func (s *Service) Enabled() bool {
+	return s.account.Enabled()
+}
+
+// Book creates an escrow for a session with a verified coach or mentor and
+// funds it from the patient's wallet. Retrying with the same idempotency key
+// returns the original escrow and funding transfer.
+func (s *Service) Book(patient *models.User, coachID int, amount money.Tinybars, scheduledAt time.Time, description, idempotencyKey string) (*models.Escrow, *models.Transfer, error) {
+	if !s.Enabled() {
+		return nil, nil, wallet.ErrEscrowDisabled
+	}
+
+	existing, err := models.GetTransferByIdempotencyKey(s.db, patient.ID, idempotencyKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if existing != nil {
+		if existing.Kind != models.TransferKindEscrowFund || existing.EscrowID == nil {
+			return nil, nil, wallet.ErrIdempotencyKeyReused
+		}
+		escrow, err := models.GetEscrowByID(s.db, *existing.EscrowID)
+		if err != nil || escrow == nil {
+			return nil, nil, err
+		}
+		return escrow, existing, nil
+	}
+
+	if amount <= 0 {
+		return nil, nil, ErrInvalidAmount
+	}
+	if coachID == patient.ID {
+		return nil, nil, ErrOwnSession
+	}
+	coach, err := models.GetUserById(s.db, coachID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if coach == nil || (coach.Role != "coach" && coach.Role != "mentor") {
+		return nil, nil, ErrInvalidCoach
+	}
+	if coach.VerificationStatus != models.VerificationStatusVerified {
+		return nil, nil, ErrCoachUnverified
+	}
+	if !scheduledAt.After(time.Now()) {
+		return nil, nil, ErrInvalidSchedule
+	}
+
+	escrow, err := models.CreateEscrow(s.db, models.Escrow{
+		PatientID:      patient.ID,
+		CoachID:        coachID,
+		Amount:         amount,
+		Description:    description,
+		ScheduledAt:    scheduledAt,
+		AutoCompleteAt: scheduledAt.Add(s.autoCompleteAfter),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transfer, err := s.transfers.FundEscrow(escrow, idempotencyKey)
+	if err != nil {
+		s.transition(escrow, models.EscrowStatusFailed, nil, nil, err.Error())
+		return escrow, nil, err
+	}
+	if err := s.settle(escrow); err != nil {
+		log.Printf("Failed to settle escrow %d: %v", escrow.ID, err)
+	}
+	return escrow, transfer, nil
+}
+
+// Get returns an escrow a user takes part in
+func (s *Service) Get(escrowID int, user *models.User) (*models.Escrow, error) {
+	escrow, err := models.GetEscrowByID(s.db, escrowID)
+	if err != nil {
+		return nil, err
+	}
+	if escrow == nil {
+		return nil, ErrNotFound
+	}
+	if user != nil && user.ID != escrow.PatientID && user.ID != escrow.CoachID {
+		return nil, ErrNotFound
+	}
+	return escrow, nil
+}
+
+// Complete records that a participant confirms the session took place. The
+// payment is released once both have confirmed.
+func (s *Service) Complete(escrowID int, user *models.User) (*models.Escrow, error) {
+	escrow, err := s.Get(escrowID, user)
+	if err != nil {
+		return nil, err
+	}
+	if escrow.Status != models.EscrowStatusFunded {
+		return nil, ErrWrongStatus
+	}
+
+	confirmed, err := models.ConfirmEscrow(s.db, escrow, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		return nil, ErrWrongStatus
+	}
+
+	escrow, err = models.GetEscrowByID(s.db, escrowID)
+	if err != nil {
+		return nil, err
+	}
+	if escrow.PatientConfirmedAt == nil || escrow.CoachConfirmedAt == nil {
+		s.notify(s.counterpart(escrow, user.ID), "escrow_confirmed", escrow.ID,
+			"%s %s confirmed your session took place", user.FirstName, user.LastName)
+		return escrow, nil
+	}
+
+	if err := s.release(escrow, &user.ID, "confirmed by both participants"); err != nil {
+		if errors.Is(err, ErrWrongStatus) {
+			// The other participant's confirmation released it first
+			return models.GetEscrowByID(s.db, escrowID)
+		}
+		return nil, err
+	}
+	return escrow, nil
+}
+
+// Cancel refunds a held payment. The coach can cancel at any time while the
+// payment is held; the patient only before the session is scheduled to start.
+func (s *Service) Cancel(escrowID int, user *models.User) (*models.Escrow, error) {
+	escrow, err := s.Get(escrowID, user)
+	if err != nil {
+		return nil, err
+	}
+	if escrow.Status != models.EscrowStatusFunded {
+		return nil, ErrWrongStatus
+	}
+	if user.ID == escrow.PatientID && !time.Now().Before(escrow.ScheduledAt) {
+		return nil, ErrTooLateToCancel
+	}
+
+	if err := s.refund(escrow, &user.ID, "cancelled"); err != nil {
+		return nil, err
+	}
+	s.notify(s.counterpart(escrow, user.ID), "escrow_cancelled", escrow.ID,
+		"%s %s cancelled your paid session; the payment is being refunded", user.FirstName, user.LastName)
+	return escrow, nil
+}
+
+// Dispute holds a payment for an admin to resolve, stopping auto-completion
+func (s *Service) Dispute(escrowID int, user *models.User, reason string) (*models.Escrow, error) {
+	escrow, err := s.Get(escrowID, user)
+	if err != nil {
+		return nil, err
+	}
+	if escrow.Status != models.EscrowStatusFunded {
+		return nil, ErrWrongStatus
+	}
+
+	moved, err := models.TransitionEscrow(s.db, escrow, models.EscrowEvent{
+		ToStatus:    models.EscrowStatusDisputed,
+		ActorUserID: &user.ID,
+		Note:        reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !moved {
+		return nil, ErrWrongStatus
+	}
+	s.notify(s.counterpart(escrow, user.ID), "escrow_disputed", escrow.ID,
+		"%s %s disputed your paid session; the payment is held until an admin reviews it", user.FirstName, user.LastName)
+	return escrow, nil
+}
+
+// Resolve settles a disputed escrow by releasing it to the coach or
+// refunding the patient
+func (s *Service) Resolve(escrowID int, admin *models.User, outcome, note string) (*models.Escrow, error) {
+	escrow, err := s.Get(escrowID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if escrow.Status != models.EscrowStatusDisputed {
+		return nil, ErrWrongStatus
+	}
+
+	switch outcome {
+	case "release":
+		err = s.release(escrow, &admin.ID, note)
+	case "refund":
+		err = s.refund(escrow, &admin.ID, note)
+	default:
+		return nil, ErrInvalidOutcome
+	}
+	if err != nil {
+		return nil, err
+	}
+	return escrow, nil
+}
+
+// Events returns the recorded transitions of an escrow
+func (s *Service) Events(escrowID int) ([]models.EscrowEvent, error) {
+	return models.GetEscrowEvents(s.db, escrowID)
+}
+
+// Run auto-completes due sessions and settles escrows waiting on a
+// transfer every interval
+func (s *Service) Run(interval time.Duration) {
+	if !s.Enabled() {
+		log.Printf("Paid sessions disabled: no escrow account")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		summary := s.ProcessDue()
+		if summary.Completed > 0 || summary.Errors > 0 {
+			log.Printf("Escrow worker completed %d sessions, settled %d escrows, %d errors",
+				summary.Completed, summary.Settled, summary.Errors)
+		}
+	}
+}
+
+// ProcessDue releases escrows both parties confirmed or whose auto-completion
+// time passed, and moves escrows whose funding, release or refund transfer
+// has since settled
+func (s *Service) ProcessDue() Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var summary Summary
+	completable, err := models.GetCompletableEscrows(s.db, time.Now(), batchSize)
+	if err != nil {
+		log.Printf("Failed to list completable escrows: %v", err)
+		summary.Errors++
+	}
+	for i := range completable {
+		escrow := &completable[i]
+		note := "auto-completed after the session"
+		if escrow.PatientConfirmedAt != nil && escrow.CoachConfirmedAt != nil {
+			note = "confirmed by both participants"
+		}
+		if err := s.release(escrow, nil, note); err != nil {
+			if errors.Is(err, ErrWrongStatus) {
+				// Disputed or cancelled since it was listed
+				continue
+			}
+			log.Printf("Failed to release escrow %d: %v", escrow.ID, err)
+			summary.Errors++
+			continue
+		}
+		summary.Completed++
+	}
+
+	for _, status := range []string{models.EscrowStatusFunding, models.EscrowStatusReleasing, models.EscrowStatusRefunding} {
+		escrows, err := models.GetEscrowsByStatus(s.db, status, batchSize)
+		if err != nil {
+			log.Printf("Failed to list %s escrows: %v", status, err)
+			summary.Errors++
+			continue
+		}
+		for i := range escrows {
+			if err := s.settle(&escrows[i]); err != nil {
+				log.Printf("Failed to settle escrow %d: %v", escrows[i].ID, err)
+				summary.Errors++
+				continue
+			}
+			if escrows[i].Status != status {
+				summary.Settled++
+			}
+		}
+	}
+	return summary
+}
+
+// release moves a held escrow to releasing and pays the coach
+func (s *Service) release(escrow *models.Escrow, actorID *int, note string) error {
+	return s.payOut(escrow, models.EscrowStatusReleasing, actorID, note)
+}
+
+// refund moves a held escrow to refunding and pays the patient back
+func (s *Service) refund(escrow *models.Escrow, actorID *int, note string) error {
+	return s.payOut(escrow, models.EscrowStatusRefunding, actorID, note)
+}
+
+// payOut claims a held escrow for a payout, so only one is sent, and then
+// submits the payout transfer
+func (s *Service) payOut(escrow *models.Escrow, status string, actorID *int, note string) error {
+	moved, err := models.TransitionEscrow(s.db, escrow, models.EscrowEvent{
+		ToStatus:    status,
+		ActorUserID: actorID,
+		Note:        note,
+	})
+	if err != nil {
+		return err
+	}
+	if !moved {
+		return ErrWrongStatus
+	}
+	return s.settle(escrow)
+}
+
+// settle advances an escrow waiting on a transfer. Funding escrows become
+// funded or failed with their funding transfer. Releasing and refunding
+// escrows submit their payout if none is in flight, including after a failed
+// one, and become released or refunded once it completes. A transfer is only
+// failed once the ledger confirms it never reached consensus; one held for
+// review keeps the escrow waiting so its payout is never sent twice.
+func (s *Service) settle(escrow *models.Escrow) error {
+	s.settleMu.Lock()
+	defer s.settleMu.Unlock()
+
+	switch escrow.Status {
+	case models.EscrowStatusFunding:
+		transfer, err := models.GetLatestEscrowTransfer(s.db, escrow.ID, models.TransferKindEscrowFund)
+		if err != nil {
+			return err
+		}
+		switch {
+		case transfer == nil:
+			if time.Since(escrow.CreatedAt) > abandonedFundingAfter {
+				s.transition(escrow, models.EscrowStatusFailed, nil, nil, "the payment was never submitted")
+			}
+		case transfer.Status == models.TransferStatusCompleted:
+			if s.transition(escrow, models.EscrowStatusFunded, nil, &transfer.ID, "") {
+				s.announceBooking(escrow)
+			}
+		case transfer.Status == models.TransferStatusFailed:
+			s.transition(escrow, models.EscrowStatusFailed, nil, &transfer.ID, transfer.FailureReason)
+		}
+		return nil
+
+	case models.EscrowStatusReleasing, models.EscrowStatusRefunding:
+		kind, final := models.TransferKindEscrowRelease, models.EscrowStatusReleased
+		if escrow.Status == models.EscrowStatusRefunding {
+			kind, final = models.TransferKindEscrowRefund, models.EscrowStatusRefunded
+		}
+
+		transfer, err := models.GetLatestEscrowTransfer(s.db, escrow.ID, kind)
+		if err != nil {
+			return err
+		}
+		if transfer == nil || transfer.Status == models.TransferStatusFailed {
+			if kind == models.TransferKindEscrowRelease {
+				transfer, err = s.transfers.ReleaseEscrow(escrow)
+			} else {
+				transfer, err = s.transfers.RefundEscrow(escrow)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if transfer.Status != models.TransferStatusCompleted {
+			// Pending transfers are resolved by the transfer recovery worker,
+			// failed ones are retried on the next pass and ones held for
+			// review wait for an admin
+			return nil
+		}
+
+		if s.transition(escrow, final, nil, &transfer.ID, "") {
+			s.announceSettlement(escrow)
+		}
+		return nil
+	}
+	return nil
+}
+
+// transition moves an escrow and logs failures, reporting whether it moved
+func (s *Service) transition(escrow *models.Escrow, status string, actorID, transferID *int, note string) bool {
+	moved, err := models.TransitionEscrow(s.db, escrow, models.EscrowEvent{
+		ToStatus:    status,
+		ActorUserID: actorID,
+		TransferID:  transferID,
+		Note:        note,
+	})
+	if err != nil {
+		log.Printf("Failed to move escrow %d to %s: %v", escrow.ID, status, err)
+		return false
+	}
+	return moved
+}
+
+// announceBooking tells the coach a session was booked and paid for
+func (s *Service) announceBooking(escrow *models.Escrow) {
+	patient, err := models.GetUserById(s.db, escrow.PatientID)
+	if err != nil || patient == nil {
+		log.Printf("Failed to load patient of escrow %d: %v", escrow.ID, err)
+		return
+	}
+	s.notify(escrow.CoachID, "escrow_booked", escrow.ID, "%s %s booked a paid session with you; %s HBAR is held in escrow",
+		patient.FirstName, patient.LastName, escrow.Amount)
+}
+
+// announceSettlement tells both participants where the payment went
+func (s *Service) announceSettlement(escrow *models.Escrow) {
+	if escrow.Status == models.EscrowStatusReleased {
+		s.notify(escrow.CoachID, "escrow_released", escrow.ID, "%s HBAR for your session was released to your wallet", escrow.Amount)
+		s.notify(escrow.PatientID, "escrow_released", escrow.ID, "Your payment of %s HBAR was released to your coach", escrow.Amount)
+		return
+	}
+	s.notify(escrow.PatientID, "escrow_refunded", escrow.ID, "Your payment of %s HBAR was refunded to your wallet", escrow.Amount)
+	s.notify(escrow.CoachID, "escrow_refunded", escrow.ID, "The payment of %s HBAR for your session was refunded", escrow.Amount)
+}
+
+// notify creates a notification about an escrow, logging failures
+func (s *Service) notify(userID int, notificationType string, escrowID int, format string, args ...interface{}) {
+	if _, err := models.CreateNotification(s.db, userID, notificationType, fmt.Sprintf(format, args...), escrowID); err != nil {
+		log.Printf("Failed to create %s notification for escrow %d: %v", notificationType, escrowID, err)
+	}
+}
+
+// counterpart returns the other participant of an escrow
+func (s *Service) counterpart(escrow *models.Escrow, userID int) int {
+	if userID == escrow.PatientID {
+		return escrow.CoachID
+	}
+	return escrow.PatientID
+}
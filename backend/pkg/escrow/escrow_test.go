@@ -0,0 +1,194 @@
+package escrow
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	wallet "github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/db/dbtest"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+// testSetup is a memory ledger with an escrow account, a patient holding
+// 100 HBAR and a verified coach holding 100 HBAR
+type testSetup struct {
+	ledger    *wallet.MemoryLedger
+	service   *Service
+	patient   *models.User
+	coach     *models.User
+	accountID string
+}
+
+func newSetup(t *testing.T) *testSetup {
+	t.Helper()
+	ledger := wallet.NewMemoryLedger()
+	accountID, privateKey, err := ledger.CreateAccount(money.Hbar(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := wallet.NewEscrowAccount(accountID, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transfers := wallet.NewTransferService(dbtest.DB, ledger, nil, nil, nil, account)
+	return &testSetup{
+		ledger:    ledger,
+		service:   NewService(dbtest.DB, transfers, account, DefaultAutoCompleteAfter),
+		patient:   dbtest.NewWalletUser(t, ledger, "user", money.Hbar(100)),
+		coach:     dbtest.NewWalletUser(t, ledger, "coach", money.Hbar(100)),
+		accountID: accountID,
+	}
+}
+
+// book books a 10 HBAR session starting in an hour
+func (s *testSetup) book(t *testing.T, key string) *models.Escrow {
+	t.Helper()
+	escrow, transfer, err := s.service.Book(s.patient, s.coach.ID, money.Hbar(10), time.Now().Add(time.Hour), "", key)
+	if err != nil {
+		t.Fatalf("Book: %v", err)
+	}
+	if escrow.Status != models.EscrowStatusFunded {
+		t.Fatalf("escrow status = %q, want funded", escrow.Status)
+	}
+	if transfer.ExternalAccountID != s.accountID || transfer.ToUserID != 0 {
+		t.Errorf("funding transfer goes to account %q, user %d; want the escrow account", transfer.ExternalAccountID, transfer.ToUserID)
+	}
+	return escrow
+}
+
+func (s *testSetup) escrowBalance(t *testing.T) money.Tinybars {
+	t.Helper()
+	balance, err := s.ledger.GetAccountBalance(s.accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance
+}
+
+func TestBookHoldsThePaymentUntilBothConfirm(t *testing.T) {
+	s := newSetup(t)
+	escrow := s.book(t, "book-release")
+
+	if got := dbtest.LedgerBalance(t, s.ledger, s.patient.ID); got != money.Hbar(90) {
+		t.Errorf("patient balance = %s, want 90", got)
+	}
+	if got := s.escrowBalance(t); got != money.Hbar(20) {
+		t.Errorf("escrow account balance = %s, want 20", got)
+	}
+
+	// Booking again with the same key returns the same escrow
+	again, _, err := s.service.Book(s.patient, s.coach.ID, money.Hbar(10), time.Now().Add(time.Hour), "", "book-release")
+	if err != nil || again.ID != escrow.ID {
+		t.Fatalf("retried Book = escrow %v, %v; want escrow %d", again, err, escrow.ID)
+	}
+
+	escrow, err = s.service.Complete(escrow.ID, s.patient)
+	if err != nil {
+		t.Fatalf("patient Complete: %v", err)
+	}
+	if escrow.Status != models.EscrowStatusFunded {
+		t.Fatalf("status after one confirmation = %q, want funded", escrow.Status)
+	}
+	if _, err := s.service.Complete(escrow.ID, s.coach); err != nil {
+		t.Fatalf("coach Complete: %v", err)
+	}
+
+	escrow, err = models.GetEscrowByID(dbtest.DB, escrow.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if escrow.Status != models.EscrowStatusReleased {
+		t.Fatalf("status = %q, want released", escrow.Status)
+	}
+	if got := dbtest.LedgerBalance(t, s.ledger, s.coach.ID); got != money.Hbar(110) {
+		t.Errorf("coach balance = %s, want 110", got)
+	}
+	release, err := models.GetLatestEscrowTransfer(dbtest.DB, escrow.ID, models.TransferKindEscrowRelease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("escrow-%d-escrow_release-1", escrow.ID); release.IdempotencyKey != want {
+		t.Errorf("release idempotency key = %q, want %q", release.IdempotencyKey, want)
+	}
+}
+
+func TestCancelRefundsThePatient(t *testing.T) {
+	s := newSetup(t)
+	escrow := s.book(t, "book-cancel")
+
+	if _, err := s.service.Cancel(escrow.ID, s.coach); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	escrow, err := models.GetEscrowByID(dbtest.DB, escrow.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if escrow.Status != models.EscrowStatusRefunded {
+		t.Fatalf("status = %q, want refunded", escrow.Status)
+	}
+	if got := dbtest.LedgerBalance(t, s.ledger, s.patient.ID); got != money.Hbar(100) {
+		t.Errorf("patient balance = %s, want 100", got)
+	}
+	if _, err := s.service.Cancel(escrow.ID, s.coach); !errors.Is(err, ErrWrongStatus) {
+		t.Errorf("second Cancel error = %v, want ErrWrongStatus", err)
+	}
+}
+
+func TestFailedPayoutIsRetriedUnderANewKey(t *testing.T) {
+	s := newSetup(t)
+	escrow := s.book(t, "book-retry")
+
+	// Releases signed with the wrong key fail on the ledger
+	badAccount, err := wallet.NewEscrowAccount(s.accountID, "wrong-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken := NewService(dbtest.DB, wallet.NewTransferService(dbtest.DB, s.ledger, nil, nil, nil, badAccount), badAccount, DefaultAutoCompleteAfter)
+	if _, err := broken.Cancel(escrow.ID, s.coach); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	escrow, err = models.GetEscrowByID(dbtest.DB, escrow.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if escrow.Status != models.EscrowStatusRefunding {
+		t.Fatalf("status after a failed refund = %q, want refunding", escrow.Status)
+	}
+
+	s.service.ProcessDue()
+	escrow, err = models.GetEscrowByID(dbtest.DB, escrow.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if escrow.Status != models.EscrowStatusRefunded {
+		t.Fatalf("status after the retry = %q, want refunded", escrow.Status)
+	}
+	refund, err := models.GetLatestEscrowTransfer(dbtest.DB, escrow.ID, models.TransferKindEscrowRefund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("escrow-%d-escrow_refund-2", escrow.ID); refund.IdempotencyKey != want {
+		t.Errorf("retried refund idempotency key = %q, want %q", refund.IdempotencyKey, want)
+	}
+	if got := dbtest.LedgerBalance(t, s.ledger, s.patient.ID); got != money.Hbar(100) {
+		t.Errorf("patient balance = %s, want 100", got)
+	}
+}
+
+func TestBookRequiresAVerifiedCoach(t *testing.T) {
+	s := newSetup(t)
+	if _, err := dbtest.DB.Exec(`UPDATE users SET verification_status = ? WHERE id = ?`, models.VerificationStatusPending, s.coach.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := s.service.Book(s.patient, s.coach.ID, money.Hbar(10), time.Now().Add(time.Hour), "", "book-unverified")
+	if !errors.Is(err, ErrCoachUnverified) {
+		t.Fatalf("Book error = %v, want ErrCoachUnverified", err)
+	}
+	if got := dbtest.LedgerBalance(t, s.ledger, s.patient.ID); got != money.Hbar(100) {
+		t.Errorf("patient balance = %s, want 100", got)
+	}
+}
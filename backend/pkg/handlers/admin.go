@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/middleware"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/moderation"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+// takedownPreviewLength bounds the content copied into the audit log when
+// content is taken down
+const takedownPreviewLength = 500
+
+const (
+	defaultAdminPageSize = 50
+	maxAdminPageSize     = 200
+)
+
+type AdminHandler struct {
+	db         *sql.DB
+	moderation *moderation.Service
+}
+
+func NewAdminHandler(db *sql.DB, moderation *moderation.Service) *AdminHandler {
+	return &AdminHandler{db: db, moderation: moderation}
+}
+
+// SearchUsers lists users matching ?q= (email, name or nickname), ?role=,
+// ?staff_role= and ?suspended=, newest first. Pass the returned next_cursor
+// as ?cursor= to load the next page.
+func (h *AdminHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	search := models.UserSearch{
+		Query:     strings.TrimSpace(query.Get("q")),
+		Role:      query.Get("role"),
+		StaffRole: query.Get("staff_role"),
+	}
+	if value := query.Get("suspended"); value != "" {
+		suspended, err := strconv.ParseBool(value)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "suspended must be true or false")
+			return
+		}
+		search.Suspended = &suspended
+	}
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := strconv.Atoi(value)
+		if err != nil || cursor <= 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		search.BeforeID = cursor
+	}
+	limit := defaultAdminPageSize
+	if value := query.Get("limit"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 && parsed <= maxAdminPageSize {
+			limit = parsed
+		}
+	}
+
+	users, err := models.SearchUsers(h.db, search, limit+1)
+	if err != nil {
+		log.Printf("Failed to search users: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to search users")
+		return
+	}
+
+	nextCursor := ""
+	if len(users) > limit {
+		users = users[:limit]
+		nextCursor = strconv.Itoa(users[limit-1].ID)
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"users":       users,
+		"next_cursor": nextCursor,
+	})
+}
+
+// GetUser returns a user's account with their staff role, suspensions,
+// latest verification request and activity counts
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	staffRole, err := models.GetStaffRole(h.db, target.ID)
+	if err != nil {
+		log.Printf("Failed to load staff role of user %d: %v", target.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load user")
+		return
+	}
+	target.StaffRole = staffRole
+
+	suspensions, err := models.GetUserSuspensions(h.db, target.ID)
+	if err != nil {
+		log.Printf("Failed to load suspensions of user %d: %v", target.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load user")
+		return
+	}
+	activeSuspension, err := models.GetActiveSuspension(h.db, target.ID)
+	if err != nil {
+		log.Printf("Failed to load suspension of user %d: %v", target.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load user")
+		return
+	}
+	verification, err := models.GetLatestVerificationRequest(h.db, target.ID)
+	if err != nil {
+		log.Printf("Failed to load verification request of user %d: %v", target.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load user")
+		return
+	}
+	activity, err := models.GetUserActivityCounts(h.db, target.ID)
+	if err != nil {
+		log.Printf("Failed to count activity of user %d: %v", target.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load user")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"user":                 target,
+		"active_suspension":    activeSuspension,
+		"suspensions":          suspensions,
+		"verification_request": verification,
+		"activity":             activity,
+	})
+}
+
+// SuspendUser suspends a user, optionally until a time, and signs them out
+func (h *AdminHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	admin, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	target, ok := h.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Reason string     `json:"reason"`
+		Until  *time.Time `json:"until"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "A reason is required")
+		return
+	}
+
+	suspension, err := h.moderation.Suspend(admin, target.ID, req.Reason, req.Until)
+	if err != nil {
+		respondWithModerationError(w, err)
+		return
+	}
+
+	details := map[string]interface{}{"reason": req.Reason}
+	if req.Until != nil {
+		details["until"] = req.Until.UTC()
+	}
+	recordAdminAction(h.db, r, "user.suspend", "user", target.ID, details)
+	utils.RespondWithJSON(w, http.StatusOK, suspension)
+}
+
+// UnsuspendUser lifts a user's suspension
+func (h *AdminHandler) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	admin, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	target, ok := h.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	lifted, err := models.LiftSuspension(h.db, target.ID, admin.ID)
+	if err != nil {
+		log.Printf("Failed to lift suspension of user %d: %v", target.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to lift suspension")
+		return
+	}
+	if !lifted {
+		utils.RespondWithError(w, http.StatusConflict, "The user is not suspended")
+		return
+	}
+
+	recordAdminAction(h.db, r, "user.unsuspend", "user", target.ID, nil)
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Suspension lifted"})
+}
+
+// SetStaffRole grants a user the admin or moderator role, or takes their
+// role away with an empty role. Admins cannot change their own role, and
+// users listed in ADMIN_EMAILS keep theirs until they are unlisted.
+func (h *AdminHandler) SetStaffRole(w http.ResponseWriter, r *http.Request) {
+	admin, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	target, ok := h.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Role != "" && req.Role != models.StaffRoleAdmin && req.Role != models.StaffRoleModerator {
+		utils.RespondWithError(w, http.StatusBadRequest, "role must be admin, moderator or empty")
+		return
+	}
+	if target.ID == admin.ID {
+		utils.RespondWithError(w, http.StatusBadRequest, "You cannot change your own staff role")
+		return
+	}
+	if req.Role != models.StaffRoleAdmin && middleware.IsAdminEmail(target.Email) {
+		utils.RespondWithError(w, http.StatusConflict, "The user is listed in ADMIN_EMAILS; remove them there first")
+		return
+	}
+
+	previous, err := models.GetStaffRole(h.db, target.ID)
+	if err == nil {
+		if req.Role == "" {
+			_, err = models.RemoveStaffRole(h.db, target.ID)
+		} else {
+			err = models.SetStaffRole(h.db, target.ID, req.Role, &admin.ID)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to set staff role of user %d: %v", target.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update staff role")
+		return
+	}
+
+	recordAdminAction(h.db, r, "user.staff_role", "user", target.ID, map[string]interface{}{
+		"from": previous,
+		"to":   req.Role,
+	})
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"user_id":    target.ID,
+		"staff_role": req.Role,
+	})
+}
+
+// TakeDownContent deletes a post, comment, group post, group comment or
+// message and tells its author why. A copy of the content is kept in the
+// audit log.
+func (h *AdminHandler) TakeDownContent(w http.ResponseWriter, r *http.Request) {
+	contentType := middleware.GetURLParam(r, "contentType")
+	if !models.IsContentType(contentType) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid content type")
+		return
+	}
+	contentID, err := strconv.Atoi(middleware.GetURLParam(r, "contentID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid content ID")
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "A reason is required")
+		return
+	}
+
+	item, err := models.GetContentItem(h.db, contentType, contentID)
+	if err == nil && item != nil {
+		var deleted bool
+		deleted, err = models.DeleteContentItem(h.db, contentType, contentID)
+		if !deleted {
+			item = nil
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to take down %s %d: %v", contentType, contentID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to take down content")
+		return
+	}
+	if item == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Content not found")
+		return
+	}
+
+	preview := item.Content
+	if len(preview) > takedownPreviewLength {
+		preview = preview[:takedownPreviewLength]
+	}
+	recordAdminAction(h.db, r, "content.takedown", contentType, contentID, map[string]interface{}{
+		"reason":    req.Reason,
+		"author_id": item.AuthorID,
+		"content":   preview,
+	})
+
+	label := strings.ReplaceAll(contentType, "_", " ")
+	if _, err := models.CreateNotification(h.db, item.AuthorID, "content_removed",
+		fmt.Sprintf("Your %s was removed by a moderator: %s", label, req.Reason), 0); err != nil {
+		log.Printf("Failed to notify user %d of removed %s %d: %v", item.AuthorID, contentType, contentID, err)
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Content taken down"})
+}
+
+// HideContent hides a piece of content from listings without deleting it
+func (h *AdminHandler) HideContent(w http.ResponseWriter, r *http.Request) {
+	h.setContentHidden(w, r, true)
+}
+
+// UnhideContent shows hidden content again
+func (h *AdminHandler) UnhideContent(w http.ResponseWriter, r *http.Request) {
+	h.setContentHidden(w, r, false)
+}
+
+// setContentHidden hides or shows the content in the URL
+func (h *AdminHandler) setContentHidden(w http.ResponseWriter, r *http.Request, hidden bool) {
+	contentType := middleware.GetURLParam(r, "contentType")
+	if !models.IsContentType(contentType) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid content type")
+		return
+	}
+	contentID, err := strconv.Atoi(middleware.GetURLParam(r, "contentID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid content ID")
+		return
+	}
+
+	found, err := models.SetContentHidden(h.db, contentType, contentID, hidden)
+	if err != nil {
+		log.Printf("Failed to change visibility of %s %d: %v", contentType, contentID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update content")
+		return
+	}
+	if !found {
+		utils.RespondWithError(w, http.StatusNotFound, "Content not found")
+		return
+	}
+
+	action, message := "content.unhide", "Content is visible again"
+	if hidden {
+		action, message = "content.hide", "Content hidden"
+	}
+	recordAdminAction(h.db, r, action, contentType, contentID, nil)
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": message})
+}
+
+// GetStats returns platform statistics
+func (h *AdminHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := models.GetPlatformStats(h.db, time.Now())
+	if err != nil {
+		log.Printf("Failed to compute platform stats: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load statistics")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, stats)
+}
+
+// GetAuditLog lists admin actions newest first, filtered by ?admin_id=,
+// ?action=, ?target_type= and ?target_id=. Pass the returned next_cursor as
+// ?cursor= to load the next page.
+func (h *AdminHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AdminActionFilter{
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+	}
+	for param, target := range map[string]*int{
+		"admin_id":  &filter.AdminID,
+		"target_id": &filter.TargetID,
+		"cursor":    &filter.BeforeID,
+	} {
+		if value := query.Get(param); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil || id <= 0 {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid "+param)
+				return
+			}
+			*target = id
+		}
+	}
+	limit := defaultAdminPageSize
+	if value := query.Get("limit"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 && parsed <= maxAdminPageSize {
+			limit = parsed
+		}
+	}
+
+	actions, err := models.GetAdminActions(h.db, filter, limit+1)
+	if err != nil {
+		log.Printf("Failed to load admin actions: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load audit log")
+		return
+	}
+
+	nextCursor := ""
+	if len(actions) > limit {
+		actions = actions[:limit]
+		nextCursor = strconv.Itoa(actions[limit-1].ID)
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"actions":     actions,
+		"next_cursor": nextCursor,
+	})
+}
+
+// targetUser loads the user named in the URL, writing an error response if
+// there is none
+func (h *AdminHandler) targetUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, err := strconv.Atoi(middleware.GetURLParam(r, "userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return nil, false
+	}
+	user, err := models.GetUserById(h.db, userID)
+	if err != nil {
+		log.Printf("Failed to load user %d: %v", userID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load user")
+		return nil, false
+	}
+	if user == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// recordAdminAction writes an action of the signed-in staff member to the
+// admin audit log. The action has already happened, so failures are logged
+// rather than returned.
+func recordAdminAction(db *sql.DB, r *http.Request, action, targetType string, targetID int, details map[string]interface{}) {
+	admin, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		return
+	}
+	entry := models.AdminAction{
+		AdminID:    admin.ID,
+		Action:     action,
+		TargetType: targetType,
+		Details:    details,
+		IPAddress:  utils.ClientIP(r),
+	}
+	if targetID > 0 {
+		entry.TargetID = &targetID
+	}
+	if err := models.RecordAdminAction(db, entry); err != nil {
+		log.Printf("Failed to record admin action %s on %s %d by user %d: %v", action, targetType, targetID, admin.ID, err)
+	}
+}
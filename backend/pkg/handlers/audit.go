@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/On-cure/Oncure/pkg/audit"
+	"github.com/On-cure/Oncure/pkg/middleware"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+type AuditHandler struct {
+	trail *audit.Trail
+}
+
+func NewAuditHandler(trail *audit.Trail) *AuditHandler {
+	return &AuditHandler{trail: trail}
+}
+
+// VerifyRecord recomputes the hash of a tip, reward payout or badge mint and
+// checks it against the hash anchored on the audit topic
+func (h *AuditHandler) VerifyRecord(w http.ResponseWriter, r *http.Request) {
+	recordID, err := strconv.Atoi(middleware.GetURLParam(r, "recordID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid record ID")
+		return
+	}
+
+	verification, err := h.trail.Verify(middleware.GetURLParam(r, "recordType"), recordID)
+	if err != nil {
+		switch {
+		case errors.Is(err, audit.ErrUnknownRecordType):
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, audit.ErrRecordNotFound):
+			utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, audit.ErrNotAuditable):
+			utils.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			log.Printf("Audit verification error: %v", err)
+			utils.RespondWithError(w, http.StatusBadGateway, "Failed to read the audit trail")
+		}
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, verification)
+}
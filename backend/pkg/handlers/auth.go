@@ -5,22 +5,30 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-	"os"
-	"time"
 
-	"github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/emailverification"
+	"github.com/On-cure/Oncure/pkg/middleware"
 	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/passwordreset"
+	"github.com/On-cure/Oncure/pkg/provisioning"
+	"github.com/On-cure/Oncure/pkg/sessions"
+	"github.com/On-cure/Oncure/pkg/twofactor"
 	"github.com/On-cure/Oncure/pkg/utils"
-
-	"github.com/google/uuid"
+	"github.com/On-cure/Oncure/pkg/websocket"
 )
 
 type AuthHandler struct {
-	db *sql.DB
+	db        *sql.DB
+	wallets   *provisioning.Worker
+	passwords *passwordreset.Service
+	emails    *emailverification.Service
+	twoFactor *twofactor.Service
+	hub       *websocket.Hub
+	sessions  *sessions.Service
 }
 
-func NewAuthHandler(db *sql.DB) *AuthHandler {
-	return &AuthHandler{db: db}
+func NewAuthHandler(db *sql.DB, wallets *provisioning.Worker, passwords *passwordreset.Service, emails *emailverification.Service, twoFactor *twofactor.Service, hub *websocket.Hub, sessionService *sessions.Service) *AuthHandler {
+	return &AuthHandler{db: db, wallets: wallets, passwords: passwords, emails: emails, twoFactor: twoFactor, hub: hub, sessions: sessionService}
 }
 
 // Register handles user registration
@@ -49,9 +57,10 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set default role if not provided
-	if req.Role == "" {
-		req.Role = "user"
+	// Coach and mentor roles are only granted by approving a verification request
+	if req.Role != "" && req.Role != "user" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Coach and mentor roles are granted by verification after registration")
+		return
 	}
 
 	// Create user
@@ -64,7 +73,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		Avatar:      req.Avatar,
 		Nickname:    req.Nickname,
 		AboutMe:     req.AboutMe,
-		Role:        req.Role,
+		Role:        "user",
 	}
 
 	userId, err := models.CreateUser(h.db, user)
@@ -73,25 +82,20 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create Hedera wallet for the user
-	accountID, privateKey, err := wallet.CreateUserWallet()
-	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create wallet: "+err.Error())
-		return
-	}
+	// The user's wallet was queued with the account; create it right away
+	h.wallets.Wake()
 
-	// Store wallet information and update balance after successful deposit
-	err = wallet.CreateUserWalletWithDeposit(h.db, userId, accountID, privateKey)
-	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to store wallet: "+err.Error())
-		return
+	// Ask the user to confirm their email address
+	if created, err := models.GetUserById(h.db, userId); err != nil || created == nil {
+		log.Printf("Failed to load user %d to verify their email: %v", userId, err)
+	} else if err := h.emails.Send(created); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", userId, err)
 	}
 
 	utils.RespondWithJSON(w, http.StatusCreated, map[string]interface{}{
-		"message":           "User registered successfully",
-		"user_id":           userId,
-		"hedera_account_id": accountID,
-		"initial_balance":   "5 HBAR",
+		"message":       "User registered successfully. Check your email to verify your address.",
+		"user_id":       userId,
+		"wallet_status": models.WalletStatusProvisioning,
 	})
 }
 
@@ -121,44 +125,61 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create session
-	sessionToken := uuid.New().String()
-	err = models.CreateSession(h.db, user.ID, sessionToken)
+	suspension, err := models.GetActiveSuspension(h.db, user.ID)
 	if err != nil {
-		log.Printf("Failed to create session for user %d: %v", user.ID, err)
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create session")
+		log.Printf("Failed to check suspension of user %d: %v", user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Authentication error")
+		return
+	}
+	if suspension != nil {
+		middleware.RespondSuspended(w, suspension)
 		return
 	}
 
-	// Set session cookie with proper cross-origin settings
-	isProduction := os.Getenv("DATABASE_URL") != ""
-	cookie := &http.Cookie{
-		Name:     "session_token",
-		Value:    sessionToken,
-		Path:     "/",
-		Expires:  time.Now().Add(7 * 24 * time.Hour),
-		HttpOnly: true,
-		SameSite: func() http.SameSite {
-			if isProduction {
-				return http.SameSiteNoneMode
-			}
-			return http.SameSiteLaxMode
-		}(),
-		Secure: isProduction,
-	}
-	http.SetCookie(w, cookie)
-	
-	// Also set in response header for debugging
-	w.Header().Set("X-Session-Token", sessionToken)
+	// Users with two-factor authentication finish signing in with a code
+	twoFactor, err := h.twoFactor.Enabled(user)
+	if err != nil {
+		log.Printf("Failed to check two-factor authentication of user %d: %v", user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Authentication error")
+		return
+	}
+	if twoFactor {
+		challenge, err := h.twoFactor.StartLogin(user)
+		if err != nil {
+			log.Printf("Failed to start two-factor sign-in for user %d: %v", user.ID, err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Authentication error")
+			return
+		}
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"two_factor_required": true,
+			"challenge":           challenge.Token,
+			"expires_at":          challenge.ExpiresAt,
+		})
+		return
+	}
 
+	if !h.startSession(w, r, user) {
+		return
+	}
 	utils.RespondWithJSON(w, http.StatusOK, user)
 }
 
+// startSession creates a session for a signed-in user on the device making
+// the request and sets its cookie. It responds with an error and returns
+// false when that fails.
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, user *models.User) bool {
+	if err := h.sessions.Start(w, r, user.ID); err != nil {
+		log.Printf("Failed to create session for user %d: %v", user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create session")
+		return false
+	}
+	return true
+}
+
 // Logout handles user logout
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	// Get session token from cookie
-	cookie, err := r.Cookie("session_token")
-	if err != nil {
+	if _, err := r.Cookie(sessions.CookieName); err != nil {
 		if err == http.ErrNoCookie {
 			utils.RespondWithError(w, http.StatusUnauthorized, "No session token provided")
 			return
@@ -166,31 +187,23 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request")
 		return
 	}
-	sessionToken := cookie.Value
 
-	// Delete session
-	err = models.DeleteSessionByToken(h.db, sessionToken)
+	session, err := h.sessions.FromRequest(r)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete session")
 		return
 	}
 
-	// Clear cookie
-	isProduction := os.Getenv("DATABASE_URL") != ""
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Path:     "/",
-		Expires:  time.Now().Add(-time.Hour),
-		HttpOnly: true,
-		SameSite: func() http.SameSite {
-			if isProduction {
-				return http.SameSiteNoneMode
-			}
-			return http.SameSiteLaxMode
-		}(),
-		Secure: isProduction,
-	})
+	// Delete session
+	if session != nil {
+		if err := models.DeleteSession(h.db, session.ID); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete session")
+			return
+		}
+		h.hub.CloseSessions(session.ID)
+	}
+
+	sessions.ClearCookie(w)
 
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Logged out successfully"})
 }
@@ -198,8 +211,7 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 // GetSession retrieves the current user session
 func (h *AuthHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 	// Get session token from cookie
-	cookie, err := r.Cookie("session_token")
-	if err != nil {
+	if _, err := r.Cookie(sessions.CookieName); err != nil {
 		if err == http.ErrNoCookie {
 			utils.RespondWithError(w, http.StatusUnauthorized, "No session token provided")
 			return
@@ -207,10 +219,9 @@ func (h *AuthHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request")
 		return
 	}
-	sessionToken := cookie.Value
 
 	// Get session
-	session, err := models.GetSessionByToken(h.db, sessionToken)
+	session, err := h.sessions.FromRequest(r)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve session")
 		return
@@ -231,5 +242,11 @@ func (h *AuthHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Let the client show staff tools
+	if err := middleware.LoadStaffRole(h.db, user); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user")
+		return
+	}
+
 	utils.RespondWithJSON(w, http.StatusOK, user)
 }
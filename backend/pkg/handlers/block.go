@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	md "github.com/On-cure/Oncure/pkg/middleware"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+// BlockUser blocks a user. Blocked users cannot see each other's posts and
+// comments, message or follow each other, or invite each other to groups.
+func (h *UserHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
+	h.restrictUser(w, r, models.BlockUser, "User blocked")
+}
+
+// UnblockUser lifts a block
+func (h *UserHandler) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	h.liftRestriction(w, r, models.UnblockUser, "User unblocked", "User is not blocked")
+}
+
+// MuteUser mutes a user, hiding their posts and comments from the current
+// user only. The muted user is not told and can still interact.
+func (h *UserHandler) MuteUser(w http.ResponseWriter, r *http.Request) {
+	h.restrictUser(w, r, models.MuteUser, "User muted")
+}
+
+// UnmuteUser lifts a mute
+func (h *UserHandler) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	h.liftRestriction(w, r, models.UnmuteUser, "User unmuted", "User is not muted")
+}
+
+// GetBlockingSettings lists the users the current user blocked and muted
+func (h *UserHandler) GetBlockingSettings(w http.ResponseWriter, r *http.Request) {
+	user, ok := md.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	blocked, err := models.GetBlockedUsers(h.db, user.ID)
+	if err != nil {
+		log.Printf("Failed to load users blocked by user %d: %v", user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load blocked users")
+		return
+	}
+	muted, err := models.GetMutedUsers(h.db, user.ID)
+	if err != nil {
+		log.Printf("Failed to load users muted by user %d: %v", user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load muted users")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"blocked": blocked,
+		"muted":   muted,
+	})
+}
+
+// UpdateBlockingSettings replaces the lists of blocked and muted users. A
+// list left out of the request is kept as it is.
+func (h *UserHandler) UpdateBlockingSettings(w http.ResponseWriter, r *http.Request) {
+	user, ok := md.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Blocked *[]int `json:"blocked"`
+		Muted   *[]int `json:"muted"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Check every user before changing anything
+	for _, list := range []*[]int{req.Blocked, req.Muted} {
+		if list == nil {
+			continue
+		}
+		for _, targetID := range *list {
+			if status, message := h.checkRestrictionTarget(user, targetID); status != 0 {
+				utils.RespondWithError(w, status, message)
+				return
+			}
+		}
+	}
+
+	if req.Blocked != nil {
+		current, err := models.GetBlockedUsers(h.db, user.ID)
+		if err == nil {
+			err = h.applyRestrictions(user.ID, current, *req.Blocked, models.BlockUser, models.UnblockUser)
+		}
+		if err != nil {
+			log.Printf("Failed to update users blocked by user %d: %v", user.ID, err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update blocked users")
+			return
+		}
+	}
+	if req.Muted != nil {
+		current, err := models.GetMutedUsers(h.db, user.ID)
+		if err == nil {
+			err = h.applyRestrictions(user.ID, current, *req.Muted, models.MuteUser, models.UnmuteUser)
+		}
+		if err != nil {
+			log.Printf("Failed to update users muted by user %d: %v", user.ID, err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update muted users")
+			return
+		}
+	}
+
+	h.GetBlockingSettings(w, r)
+}
+
+// restrictionFunc adds or lifts a block or mute between two users
+type restrictionFunc func(database *sql.DB, userID, targetID int) (bool, error)
+
+// applyRestrictions adds and lifts restrictions until exactly the wanted users are restricted
+func (h *UserHandler) applyRestrictions(userID int, current []models.RestrictedUser, wanted []int, add, lift restrictionFunc) error {
+	keep := map[int]bool{}
+	for _, targetID := range wanted {
+		keep[targetID] = true
+	}
+	existing := map[int]bool{}
+	for _, target := range current {
+		existing[target.ID] = true
+		if !keep[target.ID] {
+			if _, err := lift(h.db, userID, target.ID); err != nil {
+				return err
+			}
+		}
+	}
+	for targetID := range keep {
+		if !existing[targetID] {
+			if _, err := add(h.db, userID, targetID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// restrictUser blocks or mutes the user in the URL
+func (h *UserHandler) restrictUser(w http.ResponseWriter, r *http.Request, add restrictionFunc, message string) {
+	user, ok := md.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	targetID, err := strconv.Atoi(md.GetURLParam(r, "userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if status, message := h.checkRestrictionTarget(user, targetID); status != 0 {
+		utils.RespondWithError(w, status, message)
+		return
+	}
+
+	if _, err := add(h.db, user.ID, targetID); err != nil {
+		log.Printf("Failed to restrict user %d for user %d: %v", targetID, user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": message})
+}
+
+// liftRestriction unblocks or unmutes the user in the URL
+func (h *UserHandler) liftRestriction(w http.ResponseWriter, r *http.Request, lift restrictionFunc, message, notFound string) {
+	user, ok := md.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	targetID, err := strconv.Atoi(md.GetURLParam(r, "userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	lifted, err := lift(h.db, user.ID, targetID)
+	if err != nil {
+		log.Printf("Failed to lift restriction on user %d for user %d: %v", targetID, user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+	if !lifted {
+		utils.RespondWithError(w, http.StatusNotFound, notFound)
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": message})
+}
+
+// checkRestrictionTarget returns the error status and message when a user
+// cannot be blocked or muted, or 0 when they can
+func (h *UserHandler) checkRestrictionTarget(user *models.User, targetID int) (int, string) {
+	if targetID == user.ID {
+		return http.StatusBadRequest, "You cannot block or mute yourself"
+	}
+	target, err := models.GetUserById(h.db, targetID)
+	if err != nil {
+		log.Printf("Failed to load user %d: %v", targetID, err)
+		return http.StatusInternalServerError, "Failed to load user"
+	}
+	if target == nil {
+		return http.StatusNotFound, "User not found"
+	}
+	return 0, ""
+}
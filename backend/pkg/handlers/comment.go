@@ -9,25 +9,27 @@ import (
 
 	md "github.com/On-cure/Oncure/pkg/middleware"
 	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/safety"
 	"github.com/On-cure/Oncure/pkg/utils"
 )
 
 type CommentHandler struct {
-	db *sql.DB
+	db     *sql.DB
+	safety *safety.Service
 }
 
-func NewCommentHandler(db *sql.DB) *CommentHandler {
-	return &CommentHandler{db: db}
+func NewCommentHandler(db *sql.DB, safety *safety.Service) *CommentHandler {
+	return &CommentHandler{db: db, safety: safety}
 }
 
 // GetPostComments retrieves comments for a post
 func (h *CommentHandler) GetPostComments(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	// user, ok := md.GetUserFromContext(r.Context())
-	// if !ok {
-	// 	utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
-	// 	return
-	// }
+	viewer, ok := md.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
 
 	// Get post ID from URL
 	postIdStr := md.GetURLParam(r, "postID")
@@ -70,6 +72,7 @@ func (h *CommentHandler) GetPostComments(w http.ResponseWriter, r *http.Request)
 		"page":     page,
 		"limit":    limit,
 		"parentId": parentId,
+		"viewerId": viewer.ID,
 	}
 
 	comments, err := models.GetPostComments(h.db, postId, options)
@@ -132,6 +135,24 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Blocked users cannot comment on each other's posts or reply to each other
+	authorIDs, err := h.commentTargetAuthors(postId, req.ParentID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create comment")
+		return
+	}
+	for _, authorID := range authorIDs {
+		blocked, err := models.IsBlockedBetween(h.db, user.ID, authorID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create comment")
+			return
+		}
+		if blocked {
+			utils.RespondWithError(w, http.StatusForbidden, "You cannot comment here")
+			return
+		}
+	}
+
 	// Create comment
 	comment := models.Comment{
 		PostID:   postId,
@@ -156,6 +177,9 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Look for crisis language; the comment is published either way
+	support := h.safety.Check(user, models.ContentTypeComment, commentId, req.Content, r.Header.Get("Accept-Language"))
+
 	// Get created comment
 	createdComment, err := models.GetCommentById(h.db, commentId)
 	if err != nil {
@@ -173,7 +197,33 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		createdComment.User = commentUser
 	}
 
-	utils.RespondWithJSON(w, http.StatusCreated, createdComment)
+	utils.RespondWithJSON(w, http.StatusCreated, struct {
+		*models.Comment
+		CrisisSupport *safety.Support `json:"crisis_support,omitempty"`
+	}{createdComment, support})
+}
+
+// commentTargetAuthors returns the authors of the post and of the comment
+// being replied to
+func (h *CommentHandler) commentTargetAuthors(postId int, parentId *int) ([]int, error) {
+	authorIDs := []int{}
+	post, err := models.GetContentItem(h.db, models.ContentTypePost, postId)
+	if err != nil {
+		return nil, err
+	}
+	if post != nil {
+		authorIDs = append(authorIDs, post.AuthorID)
+	}
+	if parentId != nil {
+		parent, err := models.GetContentItem(h.db, models.ContentTypeComment, *parentId)
+		if err != nil {
+			return nil, err
+		}
+		if parent != nil {
+			authorIDs = append(authorIDs, parent.AuthorID)
+		}
+	}
+	return authorIDs, nil
 }
 
 // GetComment retrieves a comment by ID
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/On-cure/Oncure/pkg/emailverification"
+	"github.com/On-cure/Oncure/pkg/middleware"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+// ConfirmEmail verifies an email address with the token from a verification
+// link. It needs no session so the link works on any device.
+func (h *AuthHandler) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if _, err := h.emails.Confirm(req.Token); err != nil {
+		respondWithEmailVerificationError(w, err)
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Email address verified"})
+}
+
+// ResendVerificationEmail sends the current user a new verification link
+func (h *AuthHandler) ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.emails.Resend(user); err != nil {
+		respondWithEmailVerificationError(w, err)
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusAccepted, map[string]string{"message": "Verification email sent"})
+}
+
+// respondWithEmailVerificationError maps email verification errors to responses
+func respondWithEmailVerificationError(w http.ResponseWriter, err error) {
+	var throttled *emailverification.ThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		utils.RespondWithJSON(w, http.StatusTooManyRequests, map[string]interface{}{
+			"error":       err.Error(),
+			"retry_after": throttled.RetryAfter,
+		})
+	case errors.Is(err, emailverification.ErrInvalidToken):
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, emailverification.ErrAlreadyVerified):
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		log.Printf("Email verification request failed: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	wallet "github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/escrow"
+	"github.com/On-cure/Oncure/pkg/middleware"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/money"
+	"github.com/On-cure/Oncure/pkg/transferpolicy"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+type EscrowHandler struct {
+	db       *sql.DB
+	sessions *escrow.Service
+	policy   *transferpolicy.Policy
+}
+
+func NewEscrowHandler(db *sql.DB, sessions *escrow.Service, policy *transferpolicy.Policy) *EscrowHandler {
+	return &EscrowHandler{db: db, sessions: sessions, policy: policy}
+}
+
+// BookSession books a paid session with a coach or mentor and moves the
+// payment into escrow. The payment passes the transfer policy like any
+// other transfer, so large payments need the user's password.
+func (h *EscrowHandler) BookSession(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	var req struct {
+		CoachID        int            `json:"coach_id"`
+		Amount         money.Tinybars `json:"amount"`
+		ScheduledAt    time.Time      `json:"scheduled_at"`
+		Description    string         `json:"description"`
+		IdempotencyKey string         `json:"idempotency_key"`
+		Password       string         `json:"password"`
+		TwoFactorCode  string         `json:"two_factor_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	attempt := transferpolicy.Attempt{
+		User:           user,
+		Kind:           models.TransferAttemptTransfer,
+		ToUserID:       req.CoachID,
+		Amount:         req.Amount,
+		IdempotencyKey: idempotencyKeyFor(r, req.IdempotencyKey),
+		Password:       req.Password,
+		TwoFactorCode:  req.TwoFactorCode,
+		IPAddress:      utils.ClientIP(r),
+	}
+	var booked *models.Escrow
+	transfer, err := h.policy.Send(attempt, func() (*models.Transfer, error) {
+		var transfer *models.Transfer
+		var err error
+		booked, transfer, err = h.sessions.Book(user, req.CoachID, req.Amount, req.ScheduledAt, strings.TrimSpace(req.Description), attempt.IdempotencyKey)
+		return transfer, err
+	})
+	if err != nil {
+		respondWithEscrowError(w, err)
+		return
+	}
+
+	switch booked.Status {
+	case models.EscrowStatusFailed:
+		reason := "Payment failed"
+		if transfer != nil && transfer.FailureReason != "" {
+			reason += ": " + transfer.FailureReason
+		}
+		utils.RespondWithJSON(w, http.StatusBadGateway, map[string]interface{}{
+			"error":    reason,
+			"escrow":   booked,
+			"transfer": transfer,
+		})
+	case models.EscrowStatusFunding:
+		utils.RespondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+			"message":  "Payment submitted and awaiting confirmation",
+			"escrow":   booked,
+			"transfer": transfer,
+		})
+	default:
+		utils.RespondWithJSON(w, http.StatusCreated, map[string]interface{}{
+			"message":  "Session booked; the payment is held in escrow",
+			"escrow":   booked,
+			"transfer": transfer,
+		})
+	}
+}
+
+// GetSessions lists the paid sessions the current user pays for or is paid
+// for, optionally filtered by ?status=
+func (h *EscrowHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	escrows, err := models.GetUserEscrows(h.db, user.ID, r.URL.Query().Get("status"), 100)
+	if err != nil {
+		log.Printf("Failed to load escrows of user %d: %v", user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load sessions")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, escrows)
+}
+
+// GetSession returns a paid session of the current user with its history
+func (h *EscrowHandler) GetSession(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	escrowID, err := strconv.Atoi(middleware.GetURLParam(r, "escrowID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	session, err := h.sessions.Get(escrowID, user)
+	if err != nil {
+		respondWithEscrowError(w, err)
+		return
+	}
+	h.respondWithSession(w, session)
+}
+
+// CompleteSession confirms the session took place. The payment is released
+// to the coach once both participants have confirmed.
+func (h *EscrowHandler) CompleteSession(w http.ResponseWriter, r *http.Request) {
+	h.changeSession(w, r, func(escrowID int, user *models.User) (*models.Escrow, error) {
+		return h.sessions.Complete(escrowID, user)
+	})
+}
+
+// CancelSession cancels a session and refunds the payment
+func (h *EscrowHandler) CancelSession(w http.ResponseWriter, r *http.Request) {
+	h.changeSession(w, r, func(escrowID int, user *models.User) (*models.Escrow, error) {
+		return h.sessions.Cancel(escrowID, user)
+	})
+}
+
+// DisputeSession holds the payment until an admin resolves the dispute
+func (h *EscrowHandler) DisputeSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "A reason is required")
+		return
+	}
+
+	h.changeSession(w, r, func(escrowID int, user *models.User) (*models.Escrow, error) {
+		return h.sessions.Dispute(escrowID, user, req.Reason)
+	})
+}
+
+// GetAdminSessions lists paid sessions with a status, disputed by default
+func (h *EscrowHandler) GetAdminSessions(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.EscrowStatusDisputed
+	}
+
+	escrows, err := models.GetEscrowsByStatus(h.db, status, 200)
+	if err != nil {
+		log.Printf("Failed to load %s escrows: %v", status, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load sessions")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, escrows)
+}
+
+// GetAdminSession returns any paid session with its history
+func (h *EscrowHandler) GetAdminSession(w http.ResponseWriter, r *http.Request) {
+	escrowID, err := strconv.Atoi(middleware.GetURLParam(r, "escrowID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	session, err := h.sessions.Get(escrowID, nil)
+	if err != nil {
+		respondWithEscrowError(w, err)
+		return
+	}
+	h.respondWithSession(w, session)
+}
+
+// ResolveSession settles a disputed session by releasing the payment to the
+// coach or refunding the patient
+func (h *EscrowHandler) ResolveSession(w http.ResponseWriter, r *http.Request) {
+	admin, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	escrowID, err := strconv.Atoi(middleware.GetURLParam(r, "escrowID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	var req struct {
+		Outcome string `json:"outcome"`
+		Note    string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	session, err := h.sessions.Resolve(escrowID, admin, req.Outcome, strings.TrimSpace(req.Note))
+	if err != nil {
+		respondWithEscrowError(w, err)
+		return
+	}
+	recordAdminAction(h.db, r, "session.resolve", "session", escrowID, map[string]interface{}{
+		"outcome": req.Outcome,
+		"note":    strings.TrimSpace(req.Note),
+	})
+	h.respondWithSession(w, session)
+}
+
+// changeSession runs a participant's action on the session in the URL
+func (h *EscrowHandler) changeSession(w http.ResponseWriter, r *http.Request, action func(int, *models.User) (*models.Escrow, error)) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	escrowID, err := strconv.Atoi(middleware.GetURLParam(r, "escrowID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	session, err := action(escrowID, user)
+	if err != nil {
+		respondWithEscrowError(w, err)
+		return
+	}
+	h.respondWithSession(w, session)
+}
+
+// respondWithSession writes the current state of a session with its recorded transitions
+func (h *EscrowHandler) respondWithSession(w http.ResponseWriter, session *models.Escrow) {
+	session, err := models.GetEscrowByID(h.db, session.ID)
+	if err != nil || session == nil {
+		log.Printf("Failed to reload escrow: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load session")
+		return
+	}
+	events, err := h.sessions.Events(session.ID)
+	if err != nil {
+		log.Printf("Failed to load events of escrow %d: %v", session.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load session")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"escrow": session,
+		"events": events,
+	})
+}
+
+// respondWithEscrowError maps escrow, transfer policy and transfer errors to responses
+func respondWithEscrowError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, escrow.ErrNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, "Session not found")
+	case errors.Is(err, escrow.ErrWrongStatus), errors.Is(err, escrow.ErrTooLateToCancel):
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, escrow.ErrInvalidCoach), errors.Is(err, escrow.ErrCoachUnverified), errors.Is(err, escrow.ErrOwnSession),
+		errors.Is(err, escrow.ErrInvalidSchedule), errors.Is(err, escrow.ErrInvalidAmount),
+		errors.Is(err, escrow.ErrInvalidOutcome):
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, wallet.ErrEscrowDisabled):
+		utils.RespondWithError(w, http.StatusServiceUnavailable, "Paid sessions are not available")
+	default:
+		respondWithTransferError(w, err)
+	}
+}
@@ -345,6 +345,17 @@ func (h *GroupHandler) InviteToGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Blocked users cannot invite each other
+	blocked, err := models.IsBlockedBetween(h.db, user.ID, req.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to invite user")
+		return
+	}
+	if blocked {
+		utils.RespondWithError(w, http.StatusForbidden, "You cannot invite this user")
+		return
+	}
+
 	// Invite user to group
 	err = models.InviteToGroup(h.db, groupId, req.UserID, user.ID)
 	if err != nil {
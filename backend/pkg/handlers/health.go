@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+// ledgerCheckInterval is how long a ledger check result is reused, so
+// frequent readiness probes do not each query the network
+const ledgerCheckInterval = 15 * time.Second
+
+type HealthHandler struct {
+	db     *sql.DB
+	ledger wallet.Ledger
+
+	mu            sync.Mutex
+	ledgerErr     error
+	ledgerChecked time.Time
+}
+
+func NewHealthHandler(db *sql.DB, ledger wallet.Ledger) *HealthHandler {
+	return &HealthHandler{db: db, ledger: ledger}
+}
+
+// GetHealth reports that the server is running
+func (h *HealthHandler) GetHealth(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// GetReadiness reports whether the database and the ledger can be reached,
+// answering 503 when either cannot
+func (h *HealthHandler) GetReadiness(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{"database": "ok", "ledger": "ok"}
+	status, code := "ready", http.StatusOK
+
+	if err := h.db.PingContext(r.Context()); err != nil {
+		log.Printf("Readiness check: database unreachable: %v", err)
+		checks["database"] = err.Error()
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	if err := h.checkLedger(); err != nil {
+		checks["ledger"] = err.Error()
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	utils.RespondWithJSON(w, code, map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+// checkLedger pings the ledger, reusing a recent result
+func (h *HealthHandler) checkLedger() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if time.Since(h.ledgerChecked) < ledgerCheckInterval {
+		return h.ledgerErr
+	}
+	h.ledgerErr = h.ledger.Ping()
+	h.ledgerChecked = time.Now()
+	if h.ledgerErr != nil {
+		log.Printf("Readiness check: ledger unreachable: %v", h.ledgerErr)
+	}
+	return h.ledgerErr
+}
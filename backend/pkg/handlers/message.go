@@ -10,17 +10,19 @@ import (
 	"github.com/On-cure/Oncure/pkg/db"
 	md "github.com/On-cure/Oncure/pkg/middleware"
 	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/safety"
 	"github.com/On-cure/Oncure/pkg/utils"
 	"github.com/On-cure/Oncure/pkg/websocket"
 )
 
 type MessageHandler struct {
-	db  *sql.DB
-	hub *websocket.Hub
+	db     *sql.DB
+	hub    *websocket.Hub
+	safety *safety.Service
 }
 
-func NewMessageHandler(db *sql.DB, hub *websocket.Hub) *MessageHandler {
-	return &MessageHandler{db: db, hub: hub}
+func NewMessageHandler(db *sql.DB, hub *websocket.Hub, safety *safety.Service) *MessageHandler {
+	return &MessageHandler{db: db, hub: hub, safety: safety}
 }
 
 // SendPrivateMessage handles sending a private message
@@ -54,6 +56,17 @@ func (h *MessageHandler) SendPrivateMessage(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Blocked users cannot message each other
+	blocked, err := models.IsBlockedBetween(h.db, user.ID, recipientID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create message")
+		return
+	}
+	if blocked {
+		utils.RespondWithError(w, http.StatusForbidden, "You cannot message this user")
+		return
+	}
+
 	// Create message
 	message, err := models.CreatePrivateMessage(h.db, user.ID, recipientID, req.Content)
 	if err != nil {
@@ -64,7 +77,13 @@ func (h *MessageHandler) SendPrivateMessage(w http.ResponseWriter, r *http.Reque
 	// Broadcast message via WebSocket for real-time delivery
 	h.broadcastMessage(message)
 
-	utils.RespondWithJSON(w, http.StatusCreated, message)
+	// Look for crisis language; only the sender is shown the support
+	support := h.safety.Check(user, models.ContentTypeMessage, message.ID, req.Content, r.Header.Get("Accept-Language"))
+
+	utils.RespondWithJSON(w, http.StatusCreated, struct {
+		*models.Message
+		CrisisSupport *safety.Support `json:"crisis_support,omitempty"`
+	}{message, support})
 }
 
 // GetPrivateMessages retrieves messages between current user and another user
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/middleware"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/moderation"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+type ModerationHandler struct {
+	db         *sql.DB
+	moderation *moderation.Service
+}
+
+func NewModerationHandler(db *sql.DB, moderation *moderation.Service) *ModerationHandler {
+	return &ModerationHandler{db: db, moderation: moderation}
+}
+
+// ReportContent reports a post, comment, group post, group comment or
+// message to the moderators
+func (h *ModerationHandler) ReportContent(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	var req struct {
+		ContentType string `json:"content_type"`
+		ContentID   int    `json:"content_id"`
+		Reason      string `json:"reason"`
+		Details     string `json:"details"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	_, created, err := h.moderation.Report(user, req.ContentType, req.ContentID, req.Reason, req.Details)
+	if err != nil {
+		respondWithModerationError(w, err)
+		return
+	}
+
+	// Reporters do not see the case, only that their report was received
+	if !created {
+		utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "You have already reported this content"})
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, map[string]string{"message": "Thank you, the moderators will review your report"})
+}
+
+// GetQueue lists moderation cases with a status, open by default and most
+// urgent first
+func (h *ModerationHandler) GetQueue(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.ModerationCaseOpen
+	}
+
+	cases, err := h.moderation.Queue(status, 200)
+	if err != nil {
+		log.Printf("Failed to load %s moderation cases: %v", status, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load moderation queue")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, cases)
+}
+
+// GetCase returns a moderation case with its reports
+func (h *ModerationHandler) GetCase(w http.ResponseWriter, r *http.Request) {
+	caseID, err := strconv.Atoi(middleware.GetURLParam(r, "caseID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid case ID")
+		return
+	}
+
+	moderationCase, reports, err := h.moderation.Get(caseID)
+	if err != nil {
+		respondWithModerationError(w, err)
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"case":    moderationCase,
+		"reports": reports,
+	})
+}
+
+// ResolveCase hides or deletes the reported content, warns or suspends its
+// author, or dismisses the reports
+func (h *ModerationHandler) ResolveCase(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	caseID, err := strconv.Atoi(middleware.GetURLParam(r, "caseID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid case ID")
+		return
+	}
+
+	var req struct {
+		Action string     `json:"action"`
+		Note   string     `json:"note"`
+		Until  *time.Time `json:"until"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+
+	moderationCase, err := h.moderation.Resolve(caseID, moderator, req.Action, req.Note, req.Until)
+	if err != nil {
+		respondWithModerationError(w, err)
+		return
+	}
+
+	details := map[string]interface{}{
+		"content_type": moderationCase.ContentType,
+		"content_id":   moderationCase.ContentID,
+		"author_id":    moderationCase.AuthorID,
+		"note":         req.Note,
+	}
+	if req.Until != nil && req.Action == models.ModerationActionSuspend {
+		details["until"] = req.Until.UTC()
+	}
+	recordAdminAction(h.db, r, "moderation."+req.Action, "moderation_case", moderationCase.ID, details)
+	utils.RespondWithJSON(w, http.StatusOK, moderationCase)
+}
+
+// respondWithModerationError maps moderation errors to responses
+func respondWithModerationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, moderation.ErrNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, "Moderation case not found")
+	case errors.Is(err, moderation.ErrContentNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, "Content not found")
+	case errors.Is(err, moderation.ErrWrongStatus):
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, moderation.ErrSuspendAdmin), errors.Is(err, moderation.ErrSuspendModerator):
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, moderation.ErrInvalidContentType), errors.Is(err, moderation.ErrInvalidReason),
+		errors.Is(err, moderation.ErrDetailsTooLong), errors.Is(err, moderation.ErrOwnContent),
+		errors.Is(err, moderation.ErrInvalidAction), errors.Is(err, moderation.ErrNoteRequired),
+		errors.Is(err, moderation.ErrInvalidUntil), errors.Is(err, moderation.ErrNoAuthor),
+		errors.Is(err, moderation.ErrSuspendSelf):
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		log.Printf("Moderation request failed: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to process moderation request")
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/On-cure/Oncure/pkg/passwordreset"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+// ForgotPassword emails a password reset link. The response is the same
+// whether or not the email belongs to an account.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Email == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	if err := h.passwords.Request(req.Email, utils.ClientIP(r)); err != nil {
+		log.Printf("Failed to issue password reset: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to process request")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusAccepted, map[string]string{
+		"message": "If an account exists for that email, a password reset link has been sent to it",
+	})
+}
+
+// ResetPassword sets a new password with a reset token and signs the account
+// out of every session
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	err := h.passwords.Reset(req.Token, req.Password)
+	switch {
+	case err == nil:
+		utils.RespondWithJSON(w, http.StatusOK, map[string]string{
+			"message": "Password updated. Please sign in with your new password.",
+		})
+	case errors.Is(err, passwordreset.ErrInvalidToken), errors.Is(err, passwordreset.ErrWeakPassword):
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		log.Printf("Failed to reset password: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reset password")
+	}
+}
@@ -8,15 +8,17 @@ import (
 
 	md "github.com/On-cure/Oncure/pkg/middleware"
 	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/safety"
 	"github.com/On-cure/Oncure/pkg/utils"
 )
 
 type PostHandler struct {
-	db *sql.DB
+	db     *sql.DB
+	safety *safety.Service
 }
 
-func NewPostHandler(db *sql.DB) *PostHandler {
-	return &PostHandler{db: db}
+func NewPostHandler(db *sql.DB, safety *safety.Service) *PostHandler {
+	return &PostHandler{db: db, safety: safety}
 }
 
 // GetPosts retrieves posts for the feed
@@ -109,6 +111,9 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		// In production, you might want to use a proper logger
 	}
 
+	// Look for crisis language; the post is published either way
+	support := h.safety.Check(user, models.ContentTypePost, postId, req.Content, r.Header.Get("Accept-Language"))
+
 	// Get created post
 	createdPost, err := models.GetPostById(h.db, postId, user.ID)
 	if err != nil {
@@ -116,7 +121,10 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusCreated, createdPost)
+	utils.RespondWithJSON(w, http.StatusCreated, struct {
+		*models.Post
+		CrisisSupport *safety.Support `json:"crisis_support,omitempty"`
+	}{createdPost, support})
 }
 
 // UpdatePost updates an existing post
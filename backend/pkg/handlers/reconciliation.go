@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/reconciliation"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+type ReconciliationHandler struct {
+	db         *sql.DB
+	reconciler *reconciliation.Reconciler
+}
+
+func NewReconciliationHandler(db *sql.DB, reconciler *reconciliation.Reconciler) *ReconciliationHandler {
+	return &ReconciliationHandler{db: db, reconciler: reconciler}
+}
+
+// GetLedgerDrift lists recent differences found between the ledger and the
+// transfers table, optionally filtered by user_id
+func (h *ReconciliationHandler) GetLedgerDrift(w http.ResponseWriter, r *http.Request) {
+	userID := 0
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+		userID = id
+	}
+
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	drift, err := models.GetLedgerDrift(h.db, userID, limit)
+	if err != nil {
+		log.Printf("Failed to load ledger drift: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load ledger drift")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, drift)
+}
+
+// Reconcile runs a reconciliation pass now instead of waiting for the worker
+func (h *ReconciliationHandler) Reconcile(w http.ResponseWriter, r *http.Request) {
+	if !h.reconciler.Enabled() {
+		utils.RespondWithError(w, http.StatusServiceUnavailable, "Reconciliation is not configured")
+		return
+	}
+
+	summary := h.reconciler.ReconcileAll()
+	recordAdminAction(h.db, r, "ledger.reconcile", "ledger", 0, nil)
+	utils.RespondWithJSON(w, http.StatusOK, summary)
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	wallet "github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/middleware"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/rewards"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+// rewardDateLayout is the format of period dates in reward requests
+const rewardDateLayout = "2006-01-02"
+
+type RewardHandler struct {
+	db  *sql.DB
+	job *rewards.Job
+}
+
+func NewRewardHandler(db *sql.DB, job *rewards.Job) *RewardHandler {
+	return &RewardHandler{db: db, job: job}
+}
+
+// PreviewRewards scores a period and shows the allocations a run would make
+// without storing it. The period defaults to the last completed window.
+func (h *RewardHandler) PreviewRewards(w http.ResponseWriter, r *http.Request) {
+	start, end, err := h.rewardPeriod(r.URL.Query().Get("start"), r.URL.Query().Get("end"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	run, err := h.job.Preview(start, end)
+	if err != nil {
+		respondWithRewardError(w, err)
+		return
+	}
+
+	h.attachUsers(run)
+	utils.RespondWithJSON(w, http.StatusOK, run)
+}
+
+// CreateRewardRun stores a run for a period so it can be approved
+func (h *RewardHandler) CreateRewardRun(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Start string `json:"start"`
+		End   string `json:"end"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+
+	start, end, err := h.rewardPeriod(req.Start, req.End)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	run, err := h.job.CreateRun(start, end)
+	if err != nil {
+		respondWithRewardError(w, err)
+		return
+	}
+
+	recordAdminAction(h.db, r, "rewards.create_run", "reward_run", run.ID, map[string]interface{}{
+		"period_start": run.PeriodStart,
+		"period_end":   run.PeriodEnd,
+	})
+	h.attachUsers(run)
+	utils.RespondWithJSON(w, http.StatusCreated, run)
+}
+
+// GetRewardRuns lists reward runs, optionally filtered by ?status=
+func (h *RewardHandler) GetRewardRuns(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	runs, err := models.GetRewardRuns(h.db, r.URL.Query().Get("status"), limit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get reward runs")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, runs)
+}
+
+// GetRewardRun returns a run with its allocations and their payment status
+func (h *RewardHandler) GetRewardRun(w http.ResponseWriter, r *http.Request) {
+	runID, err := strconv.Atoi(middleware.GetURLParam(r, "runID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid run ID")
+		return
+	}
+
+	run, err := models.GetRewardRun(h.db, runID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get reward run")
+		return
+	}
+	if run == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Reward run not found")
+		return
+	}
+
+	h.attachUsers(run)
+	utils.RespondWithJSON(w, http.StatusOK, run)
+}
+
+// ApproveRewardRun approves a run awaiting approval and starts its payout
+func (h *RewardHandler) ApproveRewardRun(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	runID, err := strconv.Atoi(middleware.GetURLParam(r, "runID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid run ID")
+		return
+	}
+
+	run, err := h.job.Approve(runID, user.ID)
+	if err != nil {
+		respondWithRewardError(w, err)
+		return
+	}
+
+	recordAdminAction(h.db, r, "rewards.approve_run", "reward_run", run.ID, map[string]interface{}{
+		"pool_units": run.PoolUnits,
+	})
+	h.attachUsers(run)
+	utils.RespondWithJSON(w, http.StatusAccepted, run)
+}
+
+// rewardPeriod parses start and end dates, defaulting to the last completed window
+func (h *RewardHandler) rewardPeriod(startParam, endParam string) (time.Time, time.Time, error) {
+	if startParam == "" && endParam == "" {
+		start, end := h.job.LastPeriod(time.Now())
+		return start, end, nil
+	}
+
+	start, err := time.Parse(rewardDateLayout, startParam)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("start must be a date in YYYY-MM-DD format")
+	}
+	end, err := time.Parse(rewardDateLayout, endParam)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("end must be a date in YYYY-MM-DD format")
+	}
+	return start, end, nil
+}
+
+// attachUsers fills in the users receiving a run's allocations
+func (h *RewardHandler) attachUsers(run *models.RewardRun) {
+	if len(run.Allocations) == 0 {
+		return
+	}
+
+	userIDs := make([]int, len(run.Allocations))
+	for i, allocation := range run.Allocations {
+		userIDs[i] = allocation.UserID
+	}
+	users, err := models.GetUsersByIDs(h.db, userIDs)
+	if err != nil {
+		log.Printf("Failed to load reward recipients: %v", err)
+		return
+	}
+
+	byID := make(map[int]*models.User, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+	for i := range run.Allocations {
+		run.Allocations[i].User = byID[run.Allocations[i].UserID]
+	}
+}
+
+// respondWithRewardError maps reward job errors to HTTP responses
+func respondWithRewardError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, rewards.ErrRunNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, rewards.ErrRunExists), errors.Is(err, rewards.ErrRunNotApproved):
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, rewards.ErrInvalidPeriod):
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, wallet.ErrCommunityTokenDisabled):
+		utils.RespondWithError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		log.Printf("Reward run error: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to process reward run")
+	}
+}
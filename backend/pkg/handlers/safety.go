@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/On-cure/Oncure/pkg/middleware"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/safety"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+type SafetyHandler struct {
+	db     *sql.DB
+	safety *safety.Service
+}
+
+func NewSafetyHandler(db *sql.DB, safety *safety.Service) *SafetyHandler {
+	return &SafetyHandler{db: db, safety: safety}
+}
+
+// GetResources returns the crisis resources for the caller's locale
+func (h *SafetyHandler) GetResources(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(w, http.StatusOK, h.safety.Resources(r.Header.Get("Accept-Language")))
+}
+
+// GetCareCircle lists the contacts in the current user's care circle
+func (h *SafetyHandler) GetCareCircle(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	contacts, err := h.safety.CareCircle(user)
+	if err != nil {
+		log.Printf("Failed to load care circle of user %d: %v", user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load care circle")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, contacts)
+}
+
+// AddCareCircleContact adds a user to the current user's care circle
+func (h *SafetyHandler) AddCareCircleContact(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	var req struct {
+		UserID int `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := h.safety.AddContact(user, req.UserID); err != nil {
+		respondWithSafetyError(w, err)
+		return
+	}
+	h.GetCareCircle(w, r)
+}
+
+// RemoveCareCircleContact removes a user from the current user's care circle
+func (h *SafetyHandler) RemoveCareCircleContact(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	contactID, err := strconv.Atoi(middleware.GetURLParam(r, "userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	removed, err := h.safety.RemoveContact(user, contactID)
+	if err != nil {
+		respondWithSafetyError(w, err)
+		return
+	}
+	if !removed {
+		utils.RespondWithError(w, http.StatusNotFound, "User is not in your care circle")
+		return
+	}
+	h.GetCareCircle(w, r)
+}
+
+// GetAlerts lists crisis alerts with a status, open by default and most
+// severe first
+func (h *SafetyHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.CrisisAlertOpen
+	}
+
+	alerts, err := h.safety.Alerts(status, 200)
+	if err != nil {
+		log.Printf("Failed to load %s crisis alerts: %v", status, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load crisis alerts")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, alerts)
+}
+
+// AcknowledgeAlert records that a moderator followed up on a crisis alert
+func (h *SafetyHandler) AcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	staff, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	alertID, err := strconv.Atoi(middleware.GetURLParam(r, "alertID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid alert ID")
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+	req.Note = strings.TrimSpace(req.Note)
+
+	alert, err := h.safety.Acknowledge(alertID, staff, req.Note)
+	if err != nil {
+		respondWithSafetyError(w, err)
+		return
+	}
+	recordAdminAction(h.db, r, "crisis_alert.acknowledge", "crisis_alert", alert.ID, map[string]interface{}{
+		"user_id": alert.UserID,
+		"note":    req.Note,
+	})
+	utils.RespondWithJSON(w, http.StatusOK, alert)
+}
+
+// respondWithSafetyError maps care circle and crisis alert errors to responses
+func respondWithSafetyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, safety.ErrContactNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+	case errors.Is(err, safety.ErrAlertNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, "Crisis alert not found")
+	case errors.Is(err, safety.ErrAlreadyHandled):
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, safety.ErrOwnContact), errors.Is(err, safety.ErrCareCircleFull):
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		log.Printf("Safety request failed: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
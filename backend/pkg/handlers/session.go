@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/On-cure/Oncure/pkg/middleware"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/sessions"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+// deviceSession is a session as listed to its user
+type deviceSession struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// ListSessions lists the devices the current user is signed in on, marking
+// the session of this request
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	current, _ := middleware.GetSessionFromContext(r.Context())
+
+	sessions, err := models.GetUserSessions(h.db, user.ID)
+	if err != nil {
+		log.Printf("Failed to load sessions of user %d: %v", user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load sessions")
+		return
+	}
+
+	listed := make([]deviceSession, 0, len(sessions))
+	for _, session := range sessions {
+		listed = append(listed, deviceSession{Session: session, Current: current != nil && session.ID == current.ID})
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"sessions": listed})
+}
+
+// RevokeSession signs the current user out of one of their sessions and
+// closes its live connections. Revoking the current session signs out this
+// device.
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	sessionID, err := strconv.Atoi(middleware.GetURLParam(r, "sessionID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	revoked, err := models.DeleteUserSession(h.db, user.ID, sessionID)
+	if err != nil {
+		log.Printf("Failed to revoke session %d of user %d: %v", sessionID, user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+	if !revoked {
+		utils.RespondWithError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	h.hub.CloseSessions(sessionID)
+
+	if current, ok := middleware.GetSessionFromContext(r.Context()); ok && current.ID == sessionID {
+		sessions.ClearCookie(w)
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Session revoked"})
+}
+
+// LogoutEverywhere signs the current user out of every session, including
+// this one, and closes their live connections
+func (h *AuthHandler) LogoutEverywhere(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	sessionIDs, err := models.DeleteUserSessions(h.db, user.ID)
+	if err != nil {
+		log.Printf("Failed to revoke sessions of user %d: %v", user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+	h.hub.CloseSessions(sessionIDs...)
+	log.Printf("User %d logged out of %d sessions", user.ID, len(sessionIDs))
+
+	sessions.ClearCookie(w)
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":  "Logged out everywhere",
+		"sessions": len(sessionIDs),
+	})
+}
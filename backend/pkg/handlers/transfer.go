@@ -3,28 +3,40 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/On-cure/Oncure/accounts"
 	"github.com/On-cure/Oncure/pkg/middleware"
 	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/money"
+	"github.com/On-cure/Oncure/pkg/transferpolicy"
 	"github.com/On-cure/Oncure/pkg/utils"
+
+	"github.com/google/uuid"
 )
 
 type TransferHandler struct {
-	db *sql.DB
+	db        *sql.DB
+	ledger    wallet.Ledger
+	transfers *wallet.TransferService
+	token     *wallet.CommunityToken
+	policy    *transferpolicy.Policy
 }
 
-func NewTransferHandler(db *sql.DB) *TransferHandler {
-	return &TransferHandler{db: db}
+func NewTransferHandler(db *sql.DB, ledger wallet.Ledger, transfers *wallet.TransferService, token *wallet.CommunityToken, policy *transferpolicy.Policy) *TransferHandler {
+	return &TransferHandler{db: db, ledger: ledger, transfers: transfers, token: token, policy: policy}
 }
 
 // TransferHbar handles HBAR transfers between users
 func (h *TransferHandler) TransferHbar(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		ToUserID int     `json:"to_user_id"`
-		Amount   float64 `json:"amount"`
+		ToUserID       int            `json:"to_user_id"`
+		Amount         money.Tinybars `json:"amount"`
+		IdempotencyKey string         `json:"idempotency_key"`
+		Password       string         `json:"password"`
+		TwoFactorCode  string         `json:"two_factor_code"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -40,57 +52,216 @@ func (h *TransferHandler) TransferHbar(w http.ResponseWriter, r *http.Request) {
 	}
 	userID := user.ID
 
-	// Get sender wallet
-	senderWallet, err := models.GetUserWallet(h.db, userID)
-	if err != nil || senderWallet == nil {
-		utils.RespondWithError(w, http.StatusNotFound, "Sender wallet not found")
+	attempt := transferpolicy.Attempt{
+		User:           user,
+		Kind:           models.TransferAttemptTransfer,
+		ToUserID:       req.ToUserID,
+		Amount:         req.Amount,
+		IdempotencyKey: idempotencyKeyFor(r, req.IdempotencyKey),
+		Password:       req.Password,
+		TwoFactorCode:  req.TwoFactorCode,
+		IPAddress:      utils.ClientIP(r),
+	}
+	transfer, err := h.policy.Send(attempt, func() (*models.Transfer, error) {
+		return h.transfers.Transfer(userID, attempt.ToUserID, attempt.Amount, attempt.IdempotencyKey)
+	})
+	if err != nil {
+		respondWithTransferError(w, err)
 		return
 	}
 
-	// Get receiver wallet
-	receiverWallet, err := models.GetUserWallet(h.db, req.ToUserID)
-	if err != nil || receiverWallet == nil {
-		utils.RespondWithError(w, http.StatusNotFound, "Receiver wallet not found")
+	respondWithTransfer(w, transfer)
+}
+
+// tipRequest is the body accepted by the post and comment tip endpoints.
+// Password, or TwoFactorCode for users with two-factor authentication, is
+// only needed for tips above the step-up threshold.
+type tipRequest struct {
+	Amount         money.Tinybars `json:"amount"`
+	IdempotencyKey string         `json:"idempotency_key"`
+	Password       string         `json:"password"`
+	TwoFactorCode  string         `json:"two_factor_code"`
+}
+
+// tipAttempt describes a tip for the transfer policy
+func tipAttempt(r *http.Request, user *models.User, kind string, toUserID int, req tipRequest) transferpolicy.Attempt {
+	return transferpolicy.Attempt{
+		User:           user,
+		Kind:           kind,
+		ToUserID:       toUserID,
+		Amount:         req.Amount,
+		IdempotencyKey: idempotencyKeyFor(r, req.IdempotencyKey),
+		Password:       req.Password,
+		TwoFactorCode:  req.TwoFactorCode,
+		IPAddress:      utils.ClientIP(r),
+	}
+}
+
+// TipPost sends HBAR to the author of a post
+func (h *TransferHandler) TipPost(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
 		return
 	}
 
-	// Decrypt sender's private key
-	privateKey, err := senderWallet.DecryptPrivateKey()
+	postID, err := strconv.Atoi(middleware.GetURLParam(r, "postID"))
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to decrypt private key")
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	var req tipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Posts the user cannot see are reported as missing
+	post, err := models.GetPostById(h.db, postID, user.ID)
+	if err != nil || post == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Post not found")
 		return
 	}
 
-	// Perform transfer
-	transactionID, err := wallet.TransferHbar(
-		senderWallet.HederaAccountID,
-		receiverWallet.HederaAccountID,
-		privateKey,
-		req.Amount,
-	)
+	attempt := tipAttempt(r, user, models.TransferAttemptTipPost, post.UserID, req)
+	transfer, err := h.policy.Send(attempt, func() (*models.Transfer, error) {
+		return h.transfers.TipPost(user.ID, post, attempt.Amount, attempt.IdempotencyKey)
+	})
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Transfer failed: "+err.Error())
+		respondWithTransferError(w, err)
+		return
+	}
+
+	respondWithTransfer(w, transfer)
+}
+
+// TipComment sends HBAR to the author of a comment
+func (h *TransferHandler) TipComment(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
 		return
 	}
 
-	// Record transfer in database
-	err = models.CreateTransfer(h.db, userID, req.ToUserID, req.Amount, transactionID)
+	commentID, err := strconv.Atoi(middleware.GetURLParam(r, "commentID"))
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to record transfer: "+err.Error())
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	var req tipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 
-	// Update balances in database
-	senderBalance, _ := wallet.GetAccountBalance(senderWallet.HederaAccountID)
-	receiverBalance, _ := wallet.GetAccountBalance(receiverWallet.HederaAccountID)
-	models.SyncWalletBalance(h.db, userID, senderBalance)
-	models.SyncWalletBalance(h.db, req.ToUserID, receiverBalance)
+	// Comments hidden by moderation are reported as missing, as in TipPost
+	comment, err := models.GetVisibleCommentById(h.db, commentID, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve comment")
+		return
+	}
+	if comment == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+	// Comments on posts the user cannot see are reported as missing
+	if post, err := models.GetPostById(h.db, comment.PostID, user.ID); err != nil || post == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
 
-	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"message": "Transfer successful",
-		"amount":  req.Amount,
-		"to":      receiverWallet.HederaAccountID,
+	attempt := tipAttempt(r, user, models.TransferAttemptTipComment, comment.UserID, req)
+	transfer, err := h.policy.Send(attempt, func() (*models.Transfer, error) {
+		return h.transfers.TipComment(user.ID, comment, attempt.Amount, attempt.IdempotencyKey)
 	})
+	if err != nil {
+		respondWithTransferError(w, err)
+		return
+	}
+
+	respondWithTransfer(w, transfer)
+}
+
+// idempotencyKeyFor returns the client's idempotency key from the
+// Idempotency-Key header or the request body, generating one if neither is set.
+// Retries carrying the same key resolve to the original transfer.
+func idempotencyKeyFor(r *http.Request, bodyKey string) string {
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		return key
+	}
+	if bodyKey != "" {
+		return bodyKey
+	}
+	return uuid.New().String()
+}
+
+// respondWithTransferError maps transfer policy and transfer service errors to responses
+func respondWithTransferError(w http.ResponseWriter, err error) {
+	var rejection *transferpolicy.Rejection
+	if errors.As(err, &rejection) {
+		respondWithRejection(w, rejection)
+		return
+	}
+
+	switch {
+	case errors.Is(err, wallet.ErrSenderWalletNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, "Sender wallet not found")
+	case errors.Is(err, wallet.ErrReceiverWalletNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, "Receiver wallet not found")
+	case errors.Is(err, wallet.ErrIdempotencyKeyReused):
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, "Transfer failed: "+err.Error())
+	}
+}
+
+// respondWithRejection explains why the transfer policy refused a transfer.
+// Clients prompt for the password, or a two-factor code when the user has one
+// set up, when reason is step_up_required.
+func respondWithRejection(w http.ResponseWriter, rejection *transferpolicy.Rejection) {
+	status := http.StatusForbidden
+	switch rejection.Reason {
+	case transferpolicy.ReasonInvalidAmount, transferpolicy.ReasonInvalidReceiver, transferpolicy.ReasonSelfTransfer:
+		status = http.StatusBadRequest
+	case transferpolicy.ReasonVelocityLimit, transferpolicy.ReasonStepUpLocked:
+		status = http.StatusTooManyRequests
+	}
+	utils.RespondWithJSON(w, status, map[string]interface{}{
+		"error":  rejection.Message,
+		"reason": rejection.Reason,
+		"limits": rejection.Limits,
+	})
+}
+
+// respondWithTransfer writes a transfer with the status code matching its state
+func respondWithTransfer(w http.ResponseWriter, transfer *models.Transfer) {
+	switch transfer.Status {
+	case models.TransferStatusFailed:
+		utils.RespondWithJSON(w, http.StatusBadGateway, map[string]interface{}{
+			"error":    "Transfer failed: " + transfer.FailureReason,
+			"transfer": transfer,
+		})
+	case models.TransferStatusPending:
+		utils.RespondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+			"message":  "Transfer submitted and awaiting confirmation",
+			"amount":   transfer.Amount,
+			"transfer": transfer,
+		})
+	case models.TransferStatusNeedsReview:
+		utils.RespondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+			"message":  "Transfer submitted but could not be confirmed; it is held for review",
+			"amount":   transfer.Amount,
+			"transfer": transfer,
+		})
+	default:
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"message":  "Transfer successful",
+			"amount":   transfer.Amount,
+			"transfer": transfer,
+		})
+	}
 }
 
 // GetBalance returns the HBAR balance for the current user
@@ -109,17 +280,13 @@ func (h *TransferHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get balance from Hedera
-	balance, err := wallet.GetAccountBalance(userWallet.HederaAccountID)
+	balances, err := h.walletBalances(userWallet)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get balance: "+err.Error())
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"account_id": userWallet.HederaAccountID,
-		"balance":    balance,
-	})
+	utils.RespondWithJSON(w, http.StatusOK, balances)
 }
 
 // GetUserBalance returns the HBAR balance for a specific user (by user ID)
@@ -143,37 +310,97 @@ func (h *TransferHandler) GetUserBalance(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Get balance from Hedera
-	balance, err := wallet.GetAccountBalance(userWallet.HederaAccountID)
+	balances, err := h.walletBalances(userWallet)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get balance: "+err.Error())
 		return
 	}
+	balances["user_id"] = userID
 
-	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"user_id":    userID,
+	utils.RespondWithJSON(w, http.StatusOK, balances)
+}
+
+// walletBalances reports a wallet's HBAR and community token balances
+// separately. "balance" repeats the HBAR balance for older clients.
+func (h *TransferHandler) walletBalances(userWallet *models.UserWallet) (map[string]interface{}, error) {
+	hbar, err := h.ledger.GetAccountBalance(userWallet.HederaAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := map[string]interface{}{
 		"account_id": userWallet.HederaAccountID,
-		"balance":    balance,
-	})
+		"balance":    hbar,
+		"hbar":       hbar,
+		"token":      nil,
+	}
+
+	if h.token.Enabled() {
+		token := map[string]interface{}{
+			"token_id":   h.token.TokenID(),
+			"symbol":     h.token.Symbol(),
+			"decimals":   h.token.Decimals(),
+			"associated": userWallet.TokenAssociatedAt != nil,
+			"balance":    h.token.Format(0),
+			"units":      int64(0),
+		}
+		if userWallet.TokenAssociatedAt != nil {
+			units, err := h.token.Balance(userWallet.HederaAccountID)
+			if err != nil {
+				return nil, err
+			}
+			token["balance"] = h.token.Format(units)
+			token["units"] = units
+		}
+		balances["token"] = token
+	}
+
+	return balances, nil
 }
 
-// GetTransferHistory returns transfer history for the current user
-func (h *TransferHandler) GetTransferHistory(w http.ResponseWriter, r *http.Request) {
+// GetTransferLimits returns the current user's transfer limits and how much
+// of them they have used
+func (h *TransferHandler) GetTransferLimits(w http.ResponseWriter, r *http.Request) {
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
 		return
 	}
-	userID := user.ID
 
-	// Get transfer history
-	transfers, err := models.GetTransferHistory(h.db, userID, 50)
+	usage, err := h.policy.Usage(user)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get transfer history: "+err.Error())
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get transfer limits")
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"transfers": transfers,
-	})
-}
\ No newline at end of file
+	utils.RespondWithJSON(w, http.StatusOK, usage)
+}
+
+// GetTransferRejections lists recent transfers refused by the transfer
+// policy, optionally filtered by user_id
+func (h *TransferHandler) GetTransferRejections(w http.ResponseWriter, r *http.Request) {
+	userID := 0
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+		userID = id
+	}
+
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	rejections, err := models.GetTransferRejections(h.db, userID, limit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load transfer rejections")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, rejections)
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/middleware"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+const (
+	defaultHistoryPageSize = 50
+	maxHistoryPageSize     = 100
+	exportPageSize         = 500
+)
+
+// GetTransferHistory returns a page of the current user's transfers, newest
+// first, with the other user of each transfer and a link to the transaction
+// on the network explorer. Pass the returned next_cursor as ?cursor= to get
+// the following page.
+func (h *TransferHandler) GetTransferHistory(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	filter, err := parseTransferHistoryFilter(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		beforeID, err := strconv.Atoi(cursor)
+		if err != nil || beforeID <= 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		filter.BeforeID = beforeID
+	}
+	limit := defaultHistoryPageSize
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 && parsed <= maxHistoryPageSize {
+			limit = parsed
+		}
+	}
+
+	transfers, nextCursor, err := models.GetTransferHistory(h.db, user.ID, filter, limit)
+	if err != nil {
+		log.Printf("Failed to load transfer history of user %d: %v", user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get transfer history")
+		return
+	}
+	addExplorerURLs(transfers)
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"transfers":   transfers,
+		"next_cursor": nextCursor,
+	})
+}
+
+// ExportTransferHistory downloads all of the current user's transfers that
+// match the history filters as CSV (the default) or JSON, e.g. for tax
+// records or donation receipts
+func (h *TransferHandler) ExportTransferHistory(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Format must be csv or json")
+		return
+	}
+	filter, err := parseTransferHistoryFilter(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	transfers := []models.TransferHistoryEntry{}
+	for {
+		page, nextCursor, err := models.GetTransferHistory(h.db, user.ID, filter, exportPageSize)
+		if err != nil {
+			log.Printf("Failed to export transfer history of user %d: %v", user.ID, err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to export transfer history")
+			return
+		}
+		transfers = append(transfers, page...)
+		if nextCursor == "" {
+			break
+		}
+		filter.BeforeID = page[len(page)-1].ID
+	}
+	addExplorerURLs(transfers)
+
+	filename := fmt.Sprintf("oncure-transfers-%s.%s", time.Now().UTC().Format("2006-01-02"), format)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(transfers)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"id", "date", "kind", "direction", "status", "amount_hbar", "counterparty", "counterparty_user_id",
+		"external_account_id", "post_id", "comment_id", "session_id", "transaction_id", "explorer_url", "failure_reason",
+	})
+	for _, t := range transfers {
+		counterparty, counterpartyID := "", ""
+		if t.Counterparty != nil {
+			counterparty = t.Counterparty.DisplayName()
+			counterpartyID = strconv.Itoa(t.Counterparty.ID)
+		}
+		out.Write([]string{
+			strconv.Itoa(t.ID), t.CreatedAt.UTC().Format(time.RFC3339), t.Kind, t.Direction, t.Status, t.Amount.String(),
+			counterparty, counterpartyID, t.ExternalAccountID, optionalID(t.PostID), optionalID(t.CommentID),
+			optionalID(t.EscrowID), t.TransactionID, t.ExplorerURL, t.FailureReason,
+		})
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Printf("Failed to write transfer export of user %d: %v", user.ID, err)
+	}
+}
+
+// parseTransferHistoryFilter reads the history filters from the query string:
+// direction (in, out), status, kind, from and to (dates or RFC 3339 times; a
+// date in "to" includes that whole day), linked (post, comment, session),
+// post_id, comment_id and session_id
+func parseTransferHistoryFilter(r *http.Request) (models.TransferHistoryFilter, error) {
+	query := r.URL.Query()
+	filter := models.TransferHistoryFilter{
+		Direction: query.Get("direction"),
+		Status:    query.Get("status"),
+		Kind:      query.Get("kind"),
+		Linked:    query.Get("linked"),
+	}
+
+	switch filter.Direction {
+	case "", models.TransferDirectionIn, models.TransferDirectionOut:
+	default:
+		return filter, fmt.Errorf("Direction must be in or out")
+	}
+	switch filter.Status {
+	case "", models.TransferStatusPending, models.TransferStatusCompleted, models.TransferStatusFailed,
+		models.TransferStatusNeedsReview:
+	default:
+		return filter, fmt.Errorf("Invalid status")
+	}
+	switch filter.Kind {
+	case "", models.TransferKindTransfer, models.TransferKindDeposit, models.TransferKindEscrowFund,
+		models.TransferKindEscrowRelease, models.TransferKindEscrowRefund:
+	default:
+		return filter, fmt.Errorf("Invalid kind")
+	}
+	switch filter.Linked {
+	case "", "post", "comment", "session":
+	default:
+		return filter, fmt.Errorf("Linked must be post, comment or session")
+	}
+
+	if value := query.Get("from"); value != "" {
+		since, _, err := parseHistoryTime(value)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid from date")
+		}
+		filter.Since = &since
+	}
+	if value := query.Get("to"); value != "" {
+		until, dateOnly, err := parseHistoryTime(value)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid to date")
+		}
+		if dateOnly {
+			until = until.AddDate(0, 0, 1)
+		}
+		filter.Until = &until
+	}
+
+	for name, target := range map[string]*int{"post_id": &filter.PostID, "comment_id": &filter.CommentID, "session_id": &filter.EscrowID} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return filter, fmt.Errorf("Invalid %s", name)
+		}
+		*target = id
+	}
+	return filter, nil
+}
+
+// parseHistoryTime parses a YYYY-MM-DD date (UTC) or an RFC 3339 time and
+// reports whether it was a date
+func parseHistoryTime(value string) (time.Time, bool, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// addExplorerURLs links every transfer that reached the ledger to the network explorer
+func addExplorerURLs(transfers []models.TransferHistoryEntry) {
+	for i := range transfers {
+		transfers[i].ExplorerURL = wallet.TransactionURL(transfers[i].TransactionID)
+	}
+}
+
+// optionalID formats an optional ID for CSV, empty when unset
+func optionalID(id *int) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.Itoa(*id)
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/On-cure/Oncure/pkg/middleware"
+	"github.com/On-cure/Oncure/pkg/twofactor"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+// CompleteTwoFactorLogin finishes a sign-in with the challenge returned by
+// Login and a code from the authenticator app or a recovery code
+func (h *AuthHandler) CompleteTwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	user, err := h.twoFactor.CompleteLogin(req.Challenge, req.Code)
+	if err != nil {
+		respondWithTwoFactorError(w, err)
+		return
+	}
+	if !h.startSession(w, r, user) {
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, user)
+}
+
+// GetTwoFactorStatus reports whether the current user has two-factor
+// authentication on and whether they must
+func (h *AuthHandler) GetTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	status, err := h.twoFactor.Status(user)
+	if err != nil {
+		respondWithTwoFactorError(w, err)
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, status)
+}
+
+// SetupTwoFactor returns a new secret for the current user to add to their
+// authenticator app. It takes effect once confirmed with EnableTwoFactor.
+func (h *AuthHandler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	enrollment, err := h.twoFactor.Setup(user)
+	if err != nil {
+		respondWithTwoFactorError(w, err)
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, enrollment)
+}
+
+// EnableTwoFactor turns two-factor authentication on with a first code from
+// the authenticator app and returns the recovery codes
+func (h *AuthHandler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	codes, err := h.twoFactor.Enable(user, req.Code)
+	if err != nil {
+		respondWithTwoFactorError(w, err)
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+}
+
+// DisableTwoFactor turns two-factor authentication off after checking the
+// password and a code
+func (h *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := h.twoFactor.Disable(user, req.Password, req.Code); err != nil {
+		respondWithTwoFactorError(w, err)
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes after
+// checking a code
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	codes, err := h.twoFactor.RegenerateRecoveryCodes(user, req.Code)
+	if err != nil {
+		respondWithTwoFactorError(w, err)
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+}
+
+// respondWithTwoFactorError maps two-factor authentication errors to responses
+func respondWithTwoFactorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, twofactor.ErrInvalidCode), errors.Is(err, twofactor.ErrInvalidPassword),
+		errors.Is(err, twofactor.ErrInvalidChallenge):
+		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, twofactor.ErrAlreadyEnabled), errors.Is(err, twofactor.ErrNotEnabled),
+		errors.Is(err, twofactor.ErrNotSetUp):
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, twofactor.ErrRequired):
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, twofactor.ErrLocked):
+		utils.RespondWithError(w, http.StatusTooManyRequests, err.Error())
+	default:
+		log.Printf("Two-factor authentication request failed: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
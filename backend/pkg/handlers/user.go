@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/On-cure/Oncure/pkg/badges"
 	"github.com/On-cure/Oncure/pkg/db"
 	md "github.com/On-cure/Oncure/pkg/middleware"
 	"github.com/On-cure/Oncure/pkg/models"
@@ -77,6 +78,10 @@ func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if profile == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
 
 	// If requesting another user's profile, check privacy
 	if targetUserID != user.ID && !profile.IsPublic {
@@ -84,6 +89,14 @@ func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Include earned achievement badges
+	profile.Badges, err = models.GetUserBadges(h.db, targetUserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve badges")
+		return
+	}
+	badges.Describe(profile.Badges)
+
 	utils.RespondWithJSON(w, http.StatusOK, profile)
 }
 
@@ -331,6 +344,17 @@ func (h *UserHandler) FollowUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Blocked users cannot follow each other
+	blocked, err := models.IsBlockedBetween(h.db, user.ID, targetUserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to follow user")
+		return
+	}
+	if blocked {
+		utils.RespondWithError(w, http.StatusForbidden, "You cannot follow this user")
+		return
+	}
+
 	// Follow the user
 	err = models.FollowUser(h.db, user.ID, targetUserID)
 	if err != nil {
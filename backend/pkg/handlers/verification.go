@@ -3,24 +3,36 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/On-cure/Oncure/pkg/middleware"
 	"github.com/On-cure/Oncure/pkg/models"
 	"github.com/On-cure/Oncure/pkg/utils"
+	"github.com/On-cure/Oncure/pkg/verification"
 )
 
 type VerificationHandler struct {
-	db *sql.DB
+	db       *sql.DB
+	requests *verification.Service
 }
 
-func NewVerificationHandler(db *sql.DB) *VerificationHandler {
-	return &VerificationHandler{db: db}
+func NewVerificationHandler(db *sql.DB, requests *verification.Service) *VerificationHandler {
+	return &VerificationHandler{db: db, requests: requests}
 }
 
-// RequestVerification handles verification requests
+// RequestVerification handles verification requests. Submitting while the
+// open request waits for more information resubmits that request.
 func (h *VerificationHandler) RequestVerification(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("user_id").(int)
-	
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
 	var req struct {
 		RequestedRole string   `json:"requested_role"`
 		Documents     []string `json:"documents"`
@@ -32,72 +44,173 @@ func (h *VerificationHandler) RequestVerification(w http.ResponseWriter, r *http
 		return
 	}
 
-	if req.RequestedRole != "coach" && req.RequestedRole != "mentor" {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid role requested")
+	request, err := h.requests.Submit(user, req.RequestedRole, req.Documents, req.Notes)
+	if err != nil {
+		respondWithVerificationError(w, err)
 		return
 	}
 
-	// Check if user already has pending request
-	var exists bool
-	err := h.db.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM verification_requests WHERE user_id = ? AND status = 'pending')",
-		userID,
-	).Scan(&exists)
+	utils.RespondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"message": "Verification request submitted successfully",
+		"request": request,
+	})
+}
+
+// GetVerificationStatus gets user's verification status
+func (h *VerificationHandler) GetVerificationStatus(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	request, err := h.requests.Latest(user.ID)
 	if err != nil {
+		log.Printf("Failed to load verification request of user %d: %v", user.ID, err)
 		utils.RespondWithError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
-	if exists {
-		utils.RespondWithError(w, http.StatusConflict, "You already have a pending verification request")
+	if request == nil {
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"has_request":         false,
+			"verification_status": user.VerificationStatus,
+		})
 		return
 	}
 
-	documentsJSON, _ := json.Marshal(req.Documents)
-	
-	_, err = h.db.Exec(
-		`INSERT INTO verification_requests (user_id, requested_role, documents, notes) 
-		 VALUES (?, ?, ?, ?)`,
-		userID, req.RequestedRole, string(documentsJSON), req.Notes,
-	)
+	response := map[string]interface{}{
+		"has_request":         true,
+		"request":             request,
+		"verification_status": user.VerificationStatus,
+	}
+	if reapplyAt := h.requests.ReapplyAt(request); reapplyAt != nil {
+		response["can_reapply_at"] = reapplyAt
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// GetAdminRequests lists verification requests with a status, pending by
+// default, oldest first with their applicants and documents
+func (h *VerificationHandler) GetAdminRequests(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.VerificationRequestPending
+	}
+
+	requests, err := h.requests.List(status, 200)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create verification request")
+		log.Printf("Failed to load %s verification requests: %v", status, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to load verification requests")
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusCreated, map[string]string{
-		"message": "Verification request submitted successfully",
+	utils.RespondWithJSON(w, http.StatusOK, requests)
+}
+
+// GetAdminRequest returns a verification request with its applicant
+func (h *VerificationHandler) GetAdminRequest(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.Atoi(middleware.GetURLParam(r, "requestID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request ID")
+		return
+	}
+
+	request, err := h.requests.Get(requestID)
+	if err != nil {
+		respondWithVerificationError(w, err)
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, request)
+}
+
+// ApproveRequest grants the applicant the requested role
+func (h *VerificationHandler) ApproveRequest(w http.ResponseWriter, r *http.Request) {
+	h.reviewRequest(w, r, "verification.approve", func(requestID int, admin *models.User, note string) (*models.VerificationRequest, error) {
+		return h.requests.Approve(requestID, admin, note)
 	})
 }
 
-// GetVerificationStatus gets user's verification status
-func (h *VerificationHandler) GetVerificationStatus(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("user_id").(int)
-	
-	var request models.VerificationRequest
-	err := h.db.QueryRow(
-		`SELECT id, user_id, requested_role, status, documents, notes, 
-		 reviewed_by, reviewed_at, created_at, updated_at 
-		 FROM verification_requests WHERE user_id = ? ORDER BY created_at DESC LIMIT 1`,
-		userID,
-	).Scan(
-		&request.ID, &request.UserID, &request.RequestedRole, &request.Status,
-		&request.Documents, &request.Notes, &request.ReviewedBy, &request.ReviewedAt,
-		&request.CreatedAt, &request.UpdatedAt,
-	)
-	
+// RejectRequest declines a request; the note is the reason shown to the applicant
+func (h *VerificationHandler) RejectRequest(w http.ResponseWriter, r *http.Request) {
+	h.reviewRequest(w, r, "verification.reject", func(requestID int, admin *models.User, note string) (*models.VerificationRequest, error) {
+		return h.requests.Reject(requestID, admin, note)
+	})
+}
+
+// RequestMoreInfo sends a request back to the applicant asking for more information
+func (h *VerificationHandler) RequestMoreInfo(w http.ResponseWriter, r *http.Request) {
+	h.reviewRequest(w, r, "verification.request_info", func(requestID int, admin *models.User, note string) (*models.VerificationRequest, error) {
+		return h.requests.RequestInfo(requestID, admin, note)
+	})
+}
+
+// reviewRequest runs an admin's review of the request in the URL and
+// records it in the audit log as action. The note is read from "note", or
+// "reason" and "message" for rejections and information requests.
+func (h *VerificationHandler) reviewRequest(w http.ResponseWriter, r *http.Request, action string, review func(int, *models.User, string) (*models.VerificationRequest, error)) {
+	admin, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	requestID, err := strconv.Atoi(middleware.GetURLParam(r, "requestID"))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-				"has_request": false,
-			})
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request ID")
+		return
+	}
+
+	var req struct {
+		Note    string `json:"note"`
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
 			return
 		}
-		utils.RespondWithError(w, http.StatusInternalServerError, "Database error")
-		return
+	}
+	note := req.Note
+	if note == "" {
+		note = req.Reason
+	}
+	if note == "" {
+		note = req.Message
 	}
 
-	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"has_request": true,
-		"request":     request,
+	request, err := review(requestID, admin, strings.TrimSpace(note))
+	if err != nil {
+		respondWithVerificationError(w, err)
+		return
+	}
+	recordAdminAction(h.db, r, action, "verification_request", request.ID, map[string]interface{}{
+		"user_id":        request.UserID,
+		"requested_role": request.RequestedRole,
+		"note":           request.ReviewNote,
 	})
-}
\ No newline at end of file
+	utils.RespondWithJSON(w, http.StatusOK, request)
+}
+
+// respondWithVerificationError maps verification errors to responses
+func respondWithVerificationError(w http.ResponseWriter, err error) {
+	var reapply *verification.ReapplyError
+	switch {
+	case errors.Is(err, verification.ErrNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, "Verification request not found")
+	case errors.Is(err, verification.ErrAlreadyOpen), errors.Is(err, verification.ErrAlreadyVerified),
+		errors.Is(err, verification.ErrWrongStatus):
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	case errors.As(err, &reapply):
+		utils.RespondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":          err.Error(),
+			"can_reapply_at": reapply.After,
+		})
+	case errors.Is(err, verification.ErrInvalidRole), errors.Is(err, verification.ErrNoDocuments),
+		errors.Is(err, verification.ErrTooManyDocuments), errors.Is(err, verification.ErrReasonRequired),
+		errors.Is(err, verification.ErrMessageRequired):
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		log.Printf("Verification request failed: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to process verification request")
+	}
+}
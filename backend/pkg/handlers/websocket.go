@@ -6,18 +6,20 @@ import (
 	"net/http"
 
 	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/sessions"
 	"github.com/On-cure/Oncure/pkg/websocket"
 
 	websocketG "github.com/gorilla/websocket"
 )
 
 type WebSocketHandler struct {
-	hub *websocket.Hub
-	db  *sql.DB
+	hub      *websocket.Hub
+	db       *sql.DB
+	sessions *sessions.Service
 }
 
-func NewWebSocketHandler(hub *websocket.Hub, db *sql.DB) *WebSocketHandler {
-	return &WebSocketHandler{hub: hub, db: db}
+func NewWebSocketHandler(hub *websocket.Hub, db *sql.DB, sessionService *sessions.Service) *WebSocketHandler {
+	return &WebSocketHandler{hub: hub, db: db, sessions: sessionService}
 }
 
 // ServeWS handles WebSocket connections
@@ -25,22 +27,25 @@ func (h *WebSocketHandler) ServeWS(w http.ResponseWriter, r *http.Request) {
 	log.Printf("WebSocket connection attempt from %s", r.RemoteAddr)
 
 	// Validate session manually since WebSocket doesn't use middleware
-	sessionCookie, err := r.Cookie("session_token")
-	if err != nil {
+	if _, err := r.Cookie(sessions.CookieName); err != nil {
 		log.Printf("WebSocket: No session cookie found: %v", err)
 		http.Error(w, "Unauthorized: No session cookie", http.StatusUnauthorized)
 		return
 	}
 
-	log.Printf("WebSocket: Found session cookie: %s", sessionCookie.Value[:10]+"...")
-
 	// Get user from session
-	user, err := models.GetUserBySessionToken(h.db, sessionCookie.Value)
-	if err != nil {
+	session, err := h.sessions.FromRequest(r)
+	if err != nil || session == nil {
 		log.Printf("WebSocket: Invalid session token: %v", err)
 		http.Error(w, "Unauthorized: Invalid session", http.StatusUnauthorized)
 		return
 	}
+	user, err := models.GetUserById(h.db, session.UserID)
+	if err != nil || user == nil {
+		log.Printf("WebSocket: Failed to load user %d: %v", session.UserID, err)
+		http.Error(w, "Unauthorized: Invalid session", http.StatusUnauthorized)
+		return
+	}
 
 	log.Printf("WebSocket: User authenticated: %d (%s)", user.ID, user.FirstName)
 
@@ -61,11 +66,12 @@ func (h *WebSocketHandler) ServeWS(w http.ResponseWriter, r *http.Request) {
 
 	// Create client
 	client := &websocket.Client{
-		Hub:      h.hub,
-		Conn:     conn,
-		Send:     make(chan []byte, 256),
-		UserID:   user.ID,
-		Username: user.FirstName + " " + user.LastName,
+		Hub:       h.hub,
+		Conn:      conn,
+		Send:      make(chan []byte, 256),
+		UserID:    user.ID,
+		SessionID: session.ID,
+		Username:  user.FirstName + " " + user.LastName,
 	}
 
 	// Register client
@@ -0,0 +1,107 @@
+package keys
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// HTTPProvider wraps data keys through a KMS-compatible HTTP service, so
+// master keys never leave it. The service exposes
+//
+//	POST {base}/v1/keys/{keyID}/wrap   {"plaintext": "<base64>"}  -> {"ciphertext": "<base64>"}
+//	POST {base}/v1/keys/{keyID}/unwrap {"ciphertext": "<base64>"} -> {"plaintext": "<base64>"}
+//
+// and authenticates requests with a bearer token.
+type HTTPProvider struct {
+	baseURL string
+	keyID   string
+	token   string
+	client  *http.Client
+}
+
+// NewHTTPProvider creates a provider for the service at baseURL whose
+// current master key is keyID
+func NewHTTPProvider(baseURL, keyID, token string) (*HTTPProvider, error) {
+	if baseURL == "" || keyID == "" {
+		return nil, fmt.Errorf("KMS URL and key ID are required: %w", ErrMissingKey)
+	}
+	return &HTTPProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		keyID:   keyID,
+		token:   token,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// NewHTTPProviderFromEnv reads WALLET_KMS_URL, WALLET_KMS_KEY_ID and WALLET_KMS_TOKEN
+func NewHTTPProviderFromEnv() (*HTTPProvider, error) {
+	return NewHTTPProvider(os.Getenv("WALLET_KMS_URL"), os.Getenv("WALLET_KMS_KEY_ID"), os.Getenv("WALLET_KMS_TOKEN"))
+}
+
+// CurrentKeyID returns the ID of the current master key
+func (p *HTTPProvider) CurrentKeyID() string {
+	return p.keyID
+}
+
+// Wrap asks the service to encrypt a data key
+func (p *HTTPProvider) Wrap(keyID string, dataKey []byte) ([]byte, error) {
+	var response struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	request := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}
+	if err := p.call(keyID, "wrap", request, &response); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(response.Ciphertext)
+}
+
+// Unwrap asks the service to decrypt a data key
+func (p *HTTPProvider) Unwrap(keyID string, wrappedKey []byte) ([]byte, error) {
+	var response struct {
+		Plaintext string `json:"plaintext"`
+	}
+	request := map[string]string{"ciphertext": base64.StdEncoding.EncodeToString(wrappedKey)}
+	if err := p.call(keyID, "unwrap", request, &response); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(response.Plaintext)
+}
+
+// call posts a JSON request to a key operation and decodes the response
+func (p *HTTPProvider) call(keyID, operation string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/keys/%s/%s", p.baseURL, url.PathEscape(keyID), operation)
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("KMS request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrUnknownKey
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("KMS %s returned status %d", operation, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
@@ -0,0 +1,202 @@
+package keys
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// DataKeySize is the size of the AES-256 keys used for data and master keys
+const DataKeySize = 32
+
+var (
+	ErrMissingKey = errors.New("wallet encryption key is not configured")
+	ErrWeakKey    = errors.New("wallet encryption key is too weak")
+	ErrUnknownKey = errors.New("unknown wallet encryption key ID")
+)
+
+// Provider holds the master keys that wrap per-record data keys. Master
+// keys are identified by ID so data wrapped by an older key can still be
+// unwrapped after a new key becomes current.
+type Provider interface {
+	// CurrentKeyID returns the ID of the master key new data keys are wrapped with
+	CurrentKeyID() string
+
+	// Wrap encrypts a data key with the master key keyID
+	Wrap(keyID string, dataKey []byte) ([]byte, error)
+
+	// Unwrap decrypts a data key previously wrapped with the master key keyID
+	Unwrap(keyID string, wrappedKey []byte) ([]byte, error)
+}
+
+// Envelope is a secret encrypted with its own data key, stored together
+// with the data key wrapped by a master key and that master key's ID
+type Envelope struct {
+	KeyID            string
+	EncryptedDataKey string
+	Ciphertext       string
+}
+
+// Keyring seals and opens envelopes using a provider's master keys
+type Keyring struct {
+	provider Provider
+}
+
+// NewKeyring creates a keyring backed by provider
+func NewKeyring(provider Provider) *Keyring {
+	return &Keyring{provider: provider}
+}
+
+// NewKeyringFromEnv builds the keyring selected by WALLET_KEY_PROVIDER
+// ("env", "file" or "http"). In production a missing or weak master key is
+// an error; elsewhere it is logged and a development key is used.
+func NewKeyringFromEnv() (*Keyring, error) {
+	var provider Provider
+	var err error
+	switch name := os.Getenv("WALLET_KEY_PROVIDER"); name {
+	case "", "env":
+		provider, err = NewEnvProvider()
+	case "file":
+		provider, err = NewFileProvider(os.Getenv("WALLET_KEY_FILE"))
+	case "http":
+		provider, err = NewHTTPProviderFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown WALLET_KEY_PROVIDER %q", name)
+	}
+
+	if errors.Is(err, ErrMissingKey) || errors.Is(err, ErrWeakKey) {
+		if IsProduction() {
+			return nil, err
+		}
+		log.Printf("WARNING: %v; using the development wallet key, never use it in production", err)
+		return NewKeyring(developmentProvider()), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyring(provider), nil
+}
+
+// IsProduction reports whether the server runs in production, which like
+// the session cookie settings is signalled by a PostgreSQL DATABASE_URL
+func IsProduction() bool {
+	return os.Getenv("DATABASE_URL") != ""
+}
+
+// CurrentKeyID returns the ID of the master key new envelopes are sealed with
+func (k *Keyring) CurrentKeyID() string {
+	return k.provider.CurrentKeyID()
+}
+
+// Seal encrypts plaintext with a fresh data key wrapped by the current master key
+func (k *Keyring) Seal(plaintext []byte) (Envelope, error) {
+	dataKey := make([]byte, DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return Envelope{}, err
+	}
+
+	ciphertext, err := seal(dataKey, plaintext)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	keyID := k.provider.CurrentKeyID()
+	wrapped, err := k.provider.Wrap(keyID, dataKey)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	return Envelope{
+		KeyID:            keyID,
+		EncryptedDataKey: base64.StdEncoding.EncodeToString(wrapped),
+		Ciphertext:       base64.StdEncoding.EncodeToString(ciphertext),
+	}, nil
+}
+
+// Open decrypts an envelope
+func (k *Keyring) Open(envelope Envelope) ([]byte, error) {
+	dataKey, err := k.unwrap(envelope)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(envelope.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return open(dataKey, ciphertext)
+}
+
+// Rewrap re-wraps an envelope's data key with the current master key. The
+// ciphertext is unchanged, so rotating master keys never re-encrypts secrets.
+func (k *Keyring) Rewrap(envelope Envelope) (Envelope, error) {
+	dataKey, err := k.unwrap(envelope)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	keyID := k.provider.CurrentKeyID()
+	wrapped, err := k.provider.Wrap(keyID, dataKey)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	envelope.KeyID = keyID
+	envelope.EncryptedDataKey = base64.StdEncoding.EncodeToString(wrapped)
+	return envelope, nil
+}
+
+// unwrap recovers the data key of an envelope
+func (k *Keyring) unwrap(envelope Envelope) ([]byte, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(envelope.EncryptedDataKey)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := k.provider.Unwrap(envelope.KeyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with key %s: %w", envelope.KeyID, err)
+	}
+	return dataKey, nil
+}
+
+// seal encrypts plaintext with AES-GCM, prefixing the random nonce
+func seal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts a nonce-prefixed AES-GCM ciphertext
+func open(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,32 @@
+package keys
+
+import (
+	"encoding/base64"
+	"os"
+)
+
+// OpenLegacy decrypts a secret stored before envelope encryption, when every
+// wallet was encrypted directly with WALLET_ENCRYPTION_KEY padded or
+// truncated to 32 bytes. It exists only so such rows can be read and rotated.
+func OpenLegacy(ciphertext string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return open(legacyKey(), data)
+}
+
+// legacyKey derives the key the original implementation used
+func legacyKey() []byte {
+	key := os.Getenv("WALLET_ENCRYPTION_KEY")
+	if key == "" {
+		key = "your-32-byte-encryption-key-here"
+	}
+
+	if len(key) < 32 {
+		padded := make([]byte, 32)
+		copy(padded, key)
+		return padded
+	}
+	return []byte(key[:32])
+}
@@ -0,0 +1,185 @@
+package keys
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// placeholderKeys are sample values from the docs that must never be used as keys
+var placeholderKeys = []string{
+	"your-32-byte-encryption-key-here",
+	"your-32-byte-encryption-key-change-this-in-production",
+}
+
+// developmentKeyID identifies the key used when none is configured outside production
+const developmentKeyID = "dev"
+
+// LocalProvider wraps data keys with master keys held in process memory
+type LocalProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewLocalProvider creates a provider from master keys by ID. Every key must
+// be 32 random bytes; current must be one of them.
+func NewLocalProvider(current string, masterKeys map[string][]byte) (*LocalProvider, error) {
+	if len(masterKeys) == 0 {
+		return nil, ErrMissingKey
+	}
+	if _, ok := masterKeys[current]; !ok {
+		return nil, fmt.Errorf("current key %q: %w", current, ErrUnknownKey)
+	}
+	for keyID, key := range masterKeys {
+		if err := checkStrength(key); err != nil {
+			return nil, fmt.Errorf("key %q: %w", keyID, err)
+		}
+	}
+	return &LocalProvider{current: current, keys: masterKeys}, nil
+}
+
+// NewEnvProvider reads master keys from WALLET_ENCRYPTION_KEYS, a comma
+// separated list of id:base64key pairs, with WALLET_ENCRYPTION_KEY_ID naming
+// the current one (the first pair by default). A lone WALLET_ENCRYPTION_KEY,
+// either base64 encoded or 32 raw characters, is accepted as key "v1".
+func NewEnvProvider() (*LocalProvider, error) {
+	masterKeys := make(map[string][]byte)
+	current := os.Getenv("WALLET_ENCRYPTION_KEY_ID")
+
+	if list := os.Getenv("WALLET_ENCRYPTION_KEYS"); list != "" {
+		for _, pair := range strings.Split(list, ",") {
+			keyID, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok || keyID == "" {
+				return nil, fmt.Errorf("invalid WALLET_ENCRYPTION_KEYS entry %q, expected id:base64key", pair)
+			}
+			key, err := decodeKey(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", keyID, err)
+			}
+			masterKeys[keyID] = key
+			if current == "" {
+				current = keyID
+			}
+		}
+	} else if single := os.Getenv("WALLET_ENCRYPTION_KEY"); single != "" {
+		key, err := decodeKey(single)
+		if err != nil || len(key) != DataKeySize {
+			// Older deployments configured the key as a plain string
+			key, err = []byte(single), nil
+			if isPlaceholder(single) {
+				err = fmt.Errorf("placeholder value: %w", ErrWeakKey)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("WALLET_ENCRYPTION_KEY: %w", err)
+		}
+		if current == "" {
+			current = "v1"
+		}
+		masterKeys[current] = key
+	}
+
+	return NewLocalProvider(current, masterKeys)
+}
+
+// keyFile is the layout of the file read by NewFileProvider
+type keyFile struct {
+	Current string            `json:"current"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// NewFileProvider reads master keys from a JSON file of the form
+// {"current": "k2", "keys": {"k1": "<base64>", "k2": "<base64>"}}
+func NewFileProvider(path string) (*LocalProvider, error) {
+	if path == "" {
+		return nil, fmt.Errorf("WALLET_KEY_FILE is not set: %w", ErrMissingKey)
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(contents, &file); err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %v", path, err)
+	}
+
+	masterKeys := make(map[string][]byte, len(file.Keys))
+	for keyID, encoded := range file.Keys {
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", keyID, err)
+		}
+		masterKeys[keyID] = key
+	}
+	return NewLocalProvider(file.Current, masterKeys)
+}
+
+// developmentProvider returns a provider with a fixed, publicly known key
+func developmentProvider() *LocalProvider {
+	key := sha256.Sum256([]byte("oncure development wallet key"))
+	return &LocalProvider{current: developmentKeyID, keys: map[string][]byte{developmentKeyID: key[:]}}
+}
+
+// CurrentKeyID returns the ID of the current master key
+func (p *LocalProvider) CurrentKeyID() string {
+	return p.current
+}
+
+// Wrap encrypts a data key with a master key
+func (p *LocalProvider) Wrap(keyID string, dataKey []byte) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return seal(key, dataKey)
+}
+
+// Unwrap decrypts a data key with a master key
+func (p *LocalProvider) Unwrap(keyID string, wrappedKey []byte) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return open(key, wrappedKey)
+}
+
+// decodeKey decodes a base64 master key
+func decodeKey(encoded string) ([]byte, error) {
+	if isPlaceholder(encoded) {
+		return nil, fmt.Errorf("placeholder value: %w", ErrWeakKey)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("not valid base64: %w", ErrWeakKey)
+	}
+	return key, nil
+}
+
+// isPlaceholder reports whether value is one of the documented sample keys
+func isPlaceholder(value string) bool {
+	for _, placeholder := range placeholderKeys {
+		if value == placeholder {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStrength rejects keys that are not 32 bytes or are visibly not random
+func checkStrength(key []byte) error {
+	if len(key) != DataKeySize {
+		return fmt.Errorf("must be %d bytes, got %d: %w", DataKeySize, len(key), ErrWeakKey)
+	}
+	distinct := make(map[byte]bool)
+	for _, b := range key {
+		distinct[b] = true
+	}
+	if len(distinct) < 16 {
+		return fmt.Errorf("too few distinct bytes: %w", ErrWeakKey)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogMailer writes messages to a file, or to the server log without one,
+// instead of sending them
+type LogMailer struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewLogMailer creates a mailer appending messages to path, or logging them
+// when path is empty
+func NewLogMailer(path string) *LogMailer {
+	return &LogMailer{path: path}
+}
+
+// Send records a message
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	entry := fmt.Sprintf("Date: %s\nTo: %s\nSubject: %s\n\n%s\n", time.Now().UTC().Format(time.RFC1123Z), msg.To, msg.Subject, msg.Body)
+	if m.path == "" {
+		log.Printf("Email not sent (MAIL_BACKEND=log):\n%s", entry)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(entry + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
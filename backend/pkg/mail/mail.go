@@ -0,0 +1,40 @@
+// Package mail sends email to users, such as password reset links. The
+// mailer is picked by MAIL_BACKEND: "smtp" relays through an SMTP server and
+// "log", the default, writes messages to a file or the server log so links
+// can be followed during local development.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewMailerFromEnv builds the mailer selected by MAIL_BACKEND ("log" or
+// "smtp")
+func NewMailerFromEnv() (Mailer, error) {
+	switch backend := os.Getenv("MAIL_BACKEND"); backend {
+	case "", "log":
+		if os.Getenv("DATABASE_URL") != "" {
+			log.Printf("WARNING: MAIL_BACKEND is log; email is written to the log instead of being sent")
+		}
+		return NewLogMailer(os.Getenv("MAIL_LOG_FILE")), nil
+	case "smtp":
+		return NewSMTPMailerFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown MAIL_BACKEND %q", backend)
+	}
+}
@@ -0,0 +1,120 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// SMTPMailer relays messages through an SMTP server, upgrading the
+// connection with STARTTLS when the server offers it
+type SMTPMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer creates a mailer relaying through host:port. Without a
+// username messages are sent unauthenticated.
+func NewSMTPMailer(host, port, username, password, from string) (*SMTPMailer, error) {
+	if host == "" {
+		return nil, errors.New("SMTP_HOST is required with MAIL_BACKEND=smtp")
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM %q: %w", from, err)
+	}
+	if port == "" {
+		port = "587"
+	}
+	return &SMTPMailer{host: host, port: port, username: username, password: password, from: from}, nil
+}
+
+// NewSMTPMailerFromEnv reads SMTP_HOST, SMTP_PORT, SMTP_USERNAME,
+// SMTP_PASSWORD and MAIL_FROM
+func NewSMTPMailerFromEnv() (*SMTPMailer, error) {
+	return NewSMTPMailer(
+		os.Getenv("SMTP_HOST"),
+		os.Getenv("SMTP_PORT"),
+		os.Getenv("SMTP_USERNAME"),
+		os.Getenv("SMTP_PASSWORD"),
+		os.Getenv("MAIL_FROM"),
+	)
+}
+
+// Send delivers a message, giving up when ctx is done
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", msg.To)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.host, m.port))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+
+	from, _ := mail.ParseAddress(m.from)
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(m.format(msg)); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// format renders the headers and body of a message
+func (m *SMTPMailer) format(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+// IsAdminEmail reports whether email is listed in the comma-separated
+// ADMIN_EMAILS environment variable. Listed users are made admins when they
+// first sign in with a verified address, which bootstraps the staff of a new
+// installation.
+func IsAdminEmail(email string) bool {
+	for _, admin := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		admin = strings.TrimSpace(admin)
+		if admin != "" && strings.EqualFold(admin, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireRole only lets staff with one of the roles through. It must run after Auth.
+func RequireRole(roles ...string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			user, ok := GetUserFromContext(r.Context())
+			if !ok {
+				utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			for _, role := range roles {
+				if user.StaffRole == role {
+					next(w, r)
+					return
+				}
+			}
+			if len(roles) == 1 && roles[0] == models.StaffRoleAdmin {
+				utils.RespondWithError(w, http.StatusForbidden, "Admin access required")
+				return
+			}
+			utils.RespondWithError(w, http.StatusForbidden, "Staff access required")
+		}
+	}
+}
+
+// RequireAdmin only lets administrators through. It must run after Auth.
+func RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return RequireRole(models.StaffRoleAdmin)(next)
+}
+
+// RequireStaff lets administrators and moderators through. It must run after Auth.
+func RequireStaff(next http.HandlerFunc) http.HandlerFunc {
+	return RequireRole(models.StaffRoleAdmin, models.StaffRoleModerator)(next)
+}
@@ -3,33 +3,26 @@ package middleware
 import (
 	"context"
 	"database/sql"
+	"log"
 	"net/http"
 
 	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/sessions"
 	"github.com/On-cure/Oncure/pkg/utils"
 )
 
 // Create a specific key for user context
 const userContextKey contextKey = "user"
 
+// sessionContextKey stores the session the request was authenticated with
+const sessionContextKey contextKey = "session"
+
 // Auth middleware to check if user is authenticated
-func Auth(db *sql.DB) func(http.Handler) http.Handler {
+func Auth(db *sql.DB, sessionService *sessions.Service) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get session token from cookie
-			cookie, err := r.Cookie("session_token")
-			if err != nil {
-				if err == http.ErrNoCookie {
-					utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
-					return
-				}
-				utils.RespondWithError(w, http.StatusBadRequest, "Bad Request")
-				return
-			}
-			sessionToken := cookie.Value
-
-			// Get session
-			session, err := models.GetSessionByToken(db, sessionToken)
+			// Get session from the cookie
+			session, err := sessionService.FromRequest(r)
 			if err != nil {
 				utils.RespondWithError(w, http.StatusInternalServerError, "Internal server error")
 				return
@@ -52,8 +45,34 @@ func Auth(db *sql.DB) func(http.Handler) http.Handler {
 				return
 			}
 
+			// Suspended users are signed out when suspended; refuse any session left over
+			suspension, err := models.GetActiveSuspension(db, user.ID)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+			if suspension != nil {
+				RespondSuspended(w, suspension)
+				return
+			}
+
+			if err := LoadStaffRole(db, user); err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+			if !checkTwoFactorSetup(db, w, r, user) {
+				return
+			}
+
+			// Keep the device list and expiry current and rotate the token after
+			// a privilege change; a failed update does not fail the request
+			if err := sessionService.Refresh(w, r, session); err != nil {
+				log.Printf("Failed to update session %d: %v", session.ID, err)
+			}
+
 			// Add user to context using custom key type
 			ctx := context.WithValue(r.Context(), userContextKey, user)
+			ctx = context.WithValue(ctx, sessionContextKey, session)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -64,3 +83,45 @@ func GetUserFromContext(ctx context.Context) (*models.User, bool) {
 	user, ok := ctx.Value(userContextKey).(*models.User)
 	return user, ok
 }
+
+// GetSessionFromContext returns the session the request was authenticated with
+func GetSessionFromContext(ctx context.Context) (*models.Session, bool) {
+	session, ok := ctx.Value(sessionContextKey).(*models.Session)
+	return session, ok
+}
+
+// LoadStaffRole sets the user's staff role, making users listed in
+// ADMIN_EMAILS admins if they have no role yet. Only addresses confirmed
+// with a verification link count, so registering a listed address does not
+// grant the role by itself, nor does an account that was only marked
+// verified when email verification was introduced.
+func LoadStaffRole(db *sql.DB, user *models.User) error {
+	role, err := models.GetStaffRole(db, user.ID)
+	if err != nil {
+		return err
+	}
+	if role == "" && user.EmailVerifiedAt != nil && IsAdminEmail(user.Email) {
+		confirmed, err := models.HasConfirmedEmail(db, user.ID)
+		if err != nil {
+			return err
+		}
+		if confirmed {
+			if err := models.SetStaffRole(db, user.ID, models.StaffRoleAdmin, nil); err != nil {
+				return err
+			}
+			log.Printf("Granted admin role to user %d from ADMIN_EMAILS", user.ID)
+			role = models.StaffRoleAdmin
+		}
+	}
+	user.StaffRole = role
+	return nil
+}
+
+// RespondSuspended refuses a request from a suspended user
+func RespondSuspended(w http.ResponseWriter, suspension *models.UserSuspension) {
+	utils.RespondWithJSON(w, http.StatusForbidden, map[string]interface{}{
+		"error":           "Your account is suspended",
+		"reason":          suspension.Reason,
+		"suspended_until": suspension.SuspendedUntil,
+	})
+}
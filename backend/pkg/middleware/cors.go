@@ -13,7 +13,7 @@ func CORSMiddleware(next http.Handler) http.Handler {
 		// Always set credentials first
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Cookie")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Cookie, Idempotency-Key")
 		w.Header().Set("Access-Control-Max-Age", "86400")
 		
 		// Handle origin
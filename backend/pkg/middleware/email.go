@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/On-cure/Oncure/pkg/emailverification"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+// emailPolicy is the email verification policy RequireVerifiedEmail enforces
+var emailPolicy emailverification.Policy
+
+// SetEmailVerificationPolicy sets the policy RequireVerifiedEmail enforces.
+// Until it is called nothing is restricted.
+func SetEmailVerificationPolicy(policy emailverification.Policy) {
+	emailPolicy = policy
+}
+
+// RequireVerifiedEmail refuses an action to users whose email address is not
+// verified when the email verification policy restricts it. It must run
+// after Auth.
+func RequireVerifiedEmail(action string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			user, ok := GetUserFromContext(r.Context())
+			if !ok {
+				utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			if !emailPolicy.Allows(user, action) {
+				utils.RespondWithJSON(w, http.StatusForbidden, map[string]interface{}{
+					"error":                       "Please verify your email address first",
+					"email_verification_required": true,
+					"action":                      action,
+				})
+				return
+			}
+			next(w, r)
+		}
+	}
+}
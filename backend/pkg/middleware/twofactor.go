@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/twofactor"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+// twoFactorPolicy is the policy Auth enforces on the roles that must use
+// two-factor authentication
+var twoFactorPolicy twofactor.Policy
+
+// SetTwoFactorPolicy sets the roles Auth requires two-factor authentication
+// of. Until it is called nobody is required to use it.
+func SetTwoFactorPolicy(policy twofactor.Policy) {
+	twoFactorPolicy = policy
+}
+
+// checkTwoFactorSetup refuses a request from a user who must use two-factor
+// authentication but has not set it up, except to the auth endpoints they
+// need to set it up. It reports whether the request may continue.
+func checkTwoFactorSetup(db *sql.DB, w http.ResponseWriter, r *http.Request, user *models.User) bool {
+	if !twoFactorPolicy.Requires(user) || strings.HasPrefix(r.URL.Path, "/api/auth/") {
+		return true
+	}
+	enabled, err := models.IsTwoFactorEnabled(db, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return false
+	}
+	if !enabled {
+		utils.RespondWithJSON(w, http.StatusForbidden, map[string]interface{}{
+			"error":                     "Set up two-factor authentication to continue",
+			"two_factor_setup_required": true,
+		})
+		return false
+	}
+	return true
+}
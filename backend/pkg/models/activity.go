@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/On-cure/Oncure/pkg/db"
+	"github.com/On-cure/Oncure/pkg/money"
 )
 
 type Activity struct {
@@ -144,12 +145,12 @@ func GetUserPosts(database *sql.DB, userID int, page, limit int) ([]Post, error)
 
 	query := `
 		SELECT p.id, p.user_id, p.content, p.image_url, p.privacy, 
-		       COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, 
+		       COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, COALESCE(p.tip_total_tinybars, 0) as tip_total, COALESCE(p.tip_count, 0) as tip_count, 
 		       p.created_at, p.updated_at,
 		       u.id, u.first_name, u.last_name, u.nickname, u.avatar
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
-		WHERE p.user_id = ?
+		WHERE p.user_id = ? AND p.hidden_at IS NULL
 		ORDER BY p.created_at DESC
 		LIMIT ? OFFSET ?
 	`
@@ -166,7 +167,7 @@ func GetUserPosts(database *sql.DB, userID int, page, limit int) ([]Post, error)
 
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
-			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt,
+			&post.LikeCount, &post.DislikeCount, &post.TipTotal, &post.TipCount, &post.CreatedAt, &post.UpdatedAt,
 			&user.ID, &user.FirstName, &user.LastName, &user.Nickname, &user.Avatar,
 		)
 		if err != nil {
@@ -405,6 +406,21 @@ func CreateReactionActivity(database *sql.DB, userID int, targetType string, tar
 	})
 }
 
+// CreateTipActivity records a tip received on a post or comment
+func CreateTipActivity(database *sql.DB, userID int, targetType string, targetID int, tipperID int, transferID int, amount money.Tinybars) error {
+	return CreateActivity(database, Activity{
+		UserID:       userID,
+		ActivityType: "tip_received",
+		TargetType:   targetType,
+		TargetID:     targetID,
+		TargetUserID: &tipperID,
+		Metadata: map[string]interface{}{
+			"amount":      amount.String(),
+			"transfer_id": transferID,
+		},
+	})
+}
+
 // Helper to truncate strings for previews
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
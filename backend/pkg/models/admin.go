@@ -0,0 +1,295 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+// Content types staff can act on
+const (
+	ContentTypePost         = "post"
+	ContentTypeComment      = "comment"
+	ContentTypeGroupPost    = "group_post"
+	ContentTypeGroupComment = "group_comment"
+	ContentTypeMessage      = "message"
+)
+
+// contentTables maps content types to their table and author column
+var contentTables = map[string]struct{ table, authorColumn string }{
+	ContentTypePost:         {"posts", "user_id"},
+	ContentTypeComment:      {"comments", "user_id"},
+	ContentTypeGroupPost:    {"group_posts", "user_id"},
+	ContentTypeGroupComment: {"group_post_comments", "user_id"},
+	ContentTypeMessage:      {"messages", "sender_id"},
+}
+
+// IsContentType reports whether contentType names content staff can act on
+func IsContentType(contentType string) bool {
+	_, ok := contentTables[contentType]
+	return ok
+}
+
+// ContentItem is a piece of user content as staff see it
+type ContentItem struct {
+	Type      string    `json:"type"`
+	ID        int       `json:"id"`
+	AuthorID  int       `json:"author_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetContentItem returns a post, comment, group post, group comment or
+// message, or nil if it does not exist
+func GetContentItem(database *sql.DB, contentType string, contentID int) (*ContentItem, error) {
+	source, ok := contentTables[contentType]
+	if !ok {
+		return nil, nil
+	}
+
+	item := &ContentItem{Type: contentType}
+	err := db.QueryRow(database,
+		`SELECT id, `+source.authorColumn+`, content, created_at FROM `+source.table+` WHERE id = ?`,
+		contentID,
+	).Scan(&item.ID, &item.AuthorID, &item.Content, &item.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
+// DeleteContentItem removes a piece of content regardless of its author.
+// Replies, reactions and comments go with it. It reports false when the
+// content no longer exists.
+func DeleteContentItem(database *sql.DB, contentType string, contentID int) (bool, error) {
+	source, ok := contentTables[contentType]
+	if !ok {
+		return false, nil
+	}
+
+	result, err := db.Exec(database, `DELETE FROM `+source.table+` WHERE id = ?`, contentID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// AdminUserSummary is a user as listed by the admin user search
+type AdminUserSummary struct {
+	ID                 int        `json:"id"`
+	Email              string     `json:"email"`
+	FirstName          string     `json:"first_name"`
+	LastName           string     `json:"last_name"`
+	Nickname           string     `json:"nickname,omitempty"`
+	Avatar             string     `json:"avatar,omitempty"`
+	Role               string     `json:"role"`
+	VerificationStatus string     `json:"verification_status"`
+	StaffRole          string     `json:"staff_role,omitempty"`
+	Suspended          bool       `json:"suspended"`
+	SuspendedUntil     *time.Time `json:"suspended_until,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+// UserSearch narrows the admin user search; zero values match everything
+type UserSearch struct {
+	// Query matches the email, names or nickname
+	Query     string
+	Role      string
+	StaffRole string
+	Suspended *bool
+	BeforeID  int
+}
+
+// SearchUsers returns the users matching a search, newest first
+func SearchUsers(database *sql.DB, search UserSearch, limit int) ([]AdminUserSummary, error) {
+	now := time.Now().UTC()
+	query := `SELECT u.id, u.email, u.first_name, u.last_name, COALESCE(u.nickname, ''), COALESCE(u.avatar, ''),
+		COALESCE(u.role, 'user'), COALESCE(u.verification_status, 'unverified'), COALESCE(sr.role, ''),
+		s.id, s.suspended_until, u.created_at
+		FROM users u
+		LEFT JOIN staff_roles sr ON sr.user_id = u.id
+		LEFT JOIN user_suspensions s ON s.id = (
+			SELECT MAX(id) FROM user_suspensions
+			WHERE user_id = u.id AND lifted_at IS NULL AND (suspended_until IS NULL OR suspended_until > ?)
+		)
+		WHERE 1 = 1`
+	args := []interface{}{now}
+	if search.Query != "" {
+		pattern := "%" + strings.ToLower(search.Query) + "%"
+		query += ` AND (LOWER(u.email) LIKE ? OR LOWER(u.first_name) LIKE ? OR LOWER(u.last_name) LIKE ?
+			OR LOWER(COALESCE(u.nickname, '')) LIKE ? OR LOWER(u.first_name || ' ' || u.last_name) LIKE ?)`
+		args = append(args, pattern, pattern, pattern, pattern, pattern)
+	}
+	if search.Role != "" {
+		query += ` AND COALESCE(u.role, 'user') = ?`
+		args = append(args, search.Role)
+	}
+	if search.StaffRole != "" {
+		query += ` AND sr.role = ?`
+		args = append(args, search.StaffRole)
+	}
+	if search.Suspended != nil {
+		if *search.Suspended {
+			query += ` AND s.id IS NOT NULL`
+		} else {
+			query += ` AND s.id IS NULL`
+		}
+	}
+	if search.BeforeID > 0 {
+		query += ` AND u.id < ?`
+		args = append(args, search.BeforeID)
+	}
+	query += ` ORDER BY u.id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(database, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []AdminUserSummary{}
+	for rows.Next() {
+		var user AdminUserSummary
+		var suspensionID *int
+		if err := rows.Scan(&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Nickname, &user.Avatar,
+			&user.Role, &user.VerificationStatus, &user.StaffRole, &suspensionID, &user.SuspendedUntil, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		user.Suspended = suspensionID != nil
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// UserActivityCounts counts what a user has contributed
+type UserActivityCounts struct {
+	Posts         int `json:"posts"`
+	Comments      int `json:"comments"`
+	GroupPosts    int `json:"group_posts"`
+	GroupComments int `json:"group_comments"`
+	Messages      int `json:"messages"`
+}
+
+// GetUserActivityCounts counts a user's posts, comments and messages
+func GetUserActivityCounts(database *sql.DB, userID int) (*UserActivityCounts, error) {
+	counts := &UserActivityCounts{}
+	err := db.QueryRow(database,
+		`SELECT
+			(SELECT COUNT(*) FROM posts WHERE user_id = ?),
+			(SELECT COUNT(*) FROM comments WHERE user_id = ?),
+			(SELECT COUNT(*) FROM group_posts WHERE user_id = ?),
+			(SELECT COUNT(*) FROM group_post_comments WHERE user_id = ?),
+			(SELECT COUNT(*) FROM messages WHERE sender_id = ?)`,
+		userID, userID, userID, userID, userID,
+	).Scan(&counts.Posts, &counts.Comments, &counts.GroupPosts, &counts.GroupComments, &counts.Messages)
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// PlatformStats summarises the platform for the admin dashboard
+type PlatformStats struct {
+	Users struct {
+		Total     int `json:"total"`
+		Coaches   int `json:"coaches"`
+		Mentors   int `json:"mentors"`
+		New7Days  int `json:"new_7_days"`
+		New30Days int `json:"new_30_days"`
+		Suspended int `json:"suspended"`
+	} `json:"users"`
+	Content struct {
+		Posts         int `json:"posts"`
+		Posts7Days    int `json:"posts_7_days"`
+		Comments      int `json:"comments"`
+		Groups        int `json:"groups"`
+		GroupPosts    int `json:"group_posts"`
+		GroupComments int `json:"group_comments"`
+		Messages      int `json:"messages"`
+	} `json:"content"`
+	Queues struct {
+		PendingVerifications  int `json:"pending_verifications"`
+		DisputedSessions      int `json:"disputed_sessions"`
+		PaymentsNeedingReview int `json:"payments_needing_review"`
+	} `json:"queues"`
+	Transfers struct {
+		Completed30Days int            `json:"completed_30_days"`
+		Volume30Days    money.Tinybars `json:"volume_30_days"`
+		Failed30Days    int            `json:"failed_30_days"`
+	} `json:"transfers"`
+}
+
+// GetPlatformStats counts users, content, review queues and recent transfers
+func GetPlatformStats(database *sql.DB, now time.Time) (*PlatformStats, error) {
+	now = now.UTC()
+	weekAgo := now.AddDate(0, 0, -7)
+	monthAgo := now.AddDate(0, 0, -30)
+	stats := &PlatformStats{}
+
+	err := db.QueryRow(database,
+		`SELECT
+			(SELECT COUNT(*) FROM users),
+			(SELECT COUNT(*) FROM users WHERE role = 'coach'),
+			(SELECT COUNT(*) FROM users WHERE role = 'mentor'),
+			(SELECT COUNT(*) FROM users WHERE created_at >= ?),
+			(SELECT COUNT(*) FROM users WHERE created_at >= ?),
+			(SELECT COUNT(DISTINCT user_id) FROM user_suspensions
+				WHERE lifted_at IS NULL AND (suspended_until IS NULL OR suspended_until > ?))`,
+		weekAgo, monthAgo, now,
+	).Scan(&stats.Users.Total, &stats.Users.Coaches, &stats.Users.Mentors, &stats.Users.New7Days,
+		&stats.Users.New30Days, &stats.Users.Suspended)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.QueryRow(database,
+		`SELECT
+			(SELECT COUNT(*) FROM posts),
+			(SELECT COUNT(*) FROM posts WHERE created_at >= ?),
+			(SELECT COUNT(*) FROM comments),
+			(SELECT COUNT(*) FROM groups),
+			(SELECT COUNT(*) FROM group_posts),
+			(SELECT COUNT(*) FROM group_post_comments),
+			(SELECT COUNT(*) FROM messages)`,
+		weekAgo,
+	).Scan(&stats.Content.Posts, &stats.Content.Posts7Days, &stats.Content.Comments, &stats.Content.Groups,
+		&stats.Content.GroupPosts, &stats.Content.GroupComments, &stats.Content.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.QueryRow(database,
+		`SELECT
+			(SELECT COUNT(*) FROM verification_requests WHERE status = ?),
+			(SELECT COUNT(*) FROM escrows WHERE status = ?),
+			(SELECT COUNT(*) FROM transfers WHERE status = ?) +
+				(SELECT COUNT(*) FROM reward_allocations WHERE status = ?) +
+				(SELECT COUNT(*) FROM user_badges WHERE status = ?)`,
+		VerificationRequestPending, EscrowStatusDisputed,
+		TransferStatusNeedsReview, RewardAllocationNeedsReview, BadgeStatusNeedsReview,
+	).Scan(&stats.Queues.PendingVerifications, &stats.Queues.DisputedSessions, &stats.Queues.PaymentsNeedingReview)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.QueryRow(database,
+		`SELECT
+			COUNT(CASE WHEN status = ? THEN 1 END),
+			COALESCE(SUM(CASE WHEN status = ? THEN amount_tinybars END), 0),
+			COUNT(CASE WHEN status = ? THEN 1 END)
+		FROM transfers WHERE kind = ? AND created_at >= ?`,
+		TransferStatusCompleted, TransferStatusCompleted, TransferStatusFailed, TransferKindTransfer, monthAgo,
+	).Scan(&stats.Transfers.Completed30Days, &stats.Transfers.Volume30Days, &stats.Transfers.Failed30Days)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+)
+
+// AdminAction is one entry of the admin audit log: a change a staff member
+// made through the admin API
+type AdminAction struct {
+	ID         int                    `json:"id"`
+	AdminID    int                    `json:"admin_id"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"target_type"`
+	TargetID   *int                   `json:"target_id,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	IPAddress  string                 `json:"ip_address,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// AdminActionFilter narrows the audit log; zero values match everything
+type AdminActionFilter struct {
+	AdminID    int
+	Action     string
+	TargetType string
+	TargetID   int
+	BeforeID   int
+}
+
+// RecordAdminAction appends an action to the admin audit log
+func RecordAdminAction(database *sql.DB, action AdminAction) error {
+	var details interface{}
+	if len(action.Details) > 0 {
+		encoded, err := json.Marshal(action.Details)
+		if err != nil {
+			return err
+		}
+		details = string(encoded)
+	}
+
+	_, err := db.Exec(database,
+		`INSERT INTO admin_actions (admin_id, action, target_type, target_id, details, ip_address)
+		VALUES (?, ?, ?, ?, ?, NULLIF(?, ''))`,
+		action.AdminID, action.Action, action.TargetType, action.TargetID, details, action.IPAddress,
+	)
+	return err
+}
+
+// GetAdminActions returns audit log entries matching the filter, newest first
+func GetAdminActions(database *sql.DB, filter AdminActionFilter, limit int) ([]AdminAction, error) {
+	query := `SELECT id, admin_id, action, target_type, target_id, COALESCE(details, ''), COALESCE(ip_address, ''), created_at
+		FROM admin_actions WHERE 1 = 1`
+	args := []interface{}{}
+	if filter.AdminID > 0 {
+		query += ` AND admin_id = ?`
+		args = append(args, filter.AdminID)
+	}
+	if filter.Action != "" {
+		query += ` AND action = ?`
+		args = append(args, filter.Action)
+	}
+	if filter.TargetType != "" {
+		query += ` AND target_type = ?`
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID > 0 {
+		query += ` AND target_id = ?`
+		args = append(args, filter.TargetID)
+	}
+	if filter.BeforeID > 0 {
+		query += ` AND id < ?`
+		args = append(args, filter.BeforeID)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(database, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []AdminAction{}
+	for rows.Next() {
+		var action AdminAction
+		var details string
+		if err := rows.Scan(&action.ID, &action.AdminID, &action.Action, &action.TargetType, &action.TargetID,
+			&details, &action.IPAddress, &action.CreatedAt); err != nil {
+			return nil, err
+		}
+		if details != "" {
+			if err := json.Unmarshal([]byte(details), &action.Details); err != nil {
+				action.Details = map[string]interface{}{"raw": details}
+			}
+		}
+		actions = append(actions, action)
+	}
+	return actions, rows.Err()
+}
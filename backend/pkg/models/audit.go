@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/On-cure/Oncure/pkg/db"
+)
+
+// Tables whose rows are anchored to the HCS audit trail
+const (
+	AuditTableTransfers         = "transfers"
+	AuditTableRewardAllocations = "reward_allocations"
+	AuditTableUserBadges        = "user_badges"
+)
+
+// AuditEntry records where a row's hash was anchored on the audit topic. It
+// is empty until the audit worker has submitted the row.
+type AuditEntry struct {
+	AuditHash           string `json:"audit_hash,omitempty"`
+	AuditTopicID        string `json:"audit_topic_id,omitempty"`
+	AuditSequenceNumber *int64 `json:"audit_sequence_number,omitempty"`
+}
+
+const auditColumns = `COALESCE(audit_hash, ''), COALESCE(audit_topic_id, ''), audit_sequence_number`
+
+// SetAuditEntry stores the hash of a row and the topic position it was
+// anchored at. Rows that were already anchored are left unchanged.
+func SetAuditEntry(database *sql.DB, table string, recordID int, hash, topicID string, sequenceNumber int64) error {
+	switch table {
+	case AuditTableTransfers, AuditTableRewardAllocations, AuditTableUserBadges:
+	default:
+		return fmt.Errorf("table %s is not audited", table)
+	}
+
+	_, err := db.Exec(database,
+		`UPDATE `+table+` SET audit_hash = ?, audit_topic_id = ?, audit_sequence_number = ?
+		WHERE id = ? AND audit_sequence_number IS NULL`,
+		hash, topicID, sequenceNumber, recordID,
+	)
+	return err
+}
+
+// GetUnauditedTips returns completed tips that have not been anchored yet
+func GetUnauditedTips(database *sql.DB, limit int) ([]Transfer, error) {
+	rows, err := db.Query(database,
+		`SELECT `+transferColumns+` FROM transfers
+		WHERE status = ? AND (post_id IS NOT NULL OR comment_id IS NOT NULL) AND audit_sequence_number IS NULL
+		ORDER BY id ASC
+		LIMIT ?`,
+		TransferStatusCompleted, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []Transfer
+	for rows.Next() {
+		var t Transfer
+		if err := scanTransfer(rows, &t); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, rows.Err()
+}
+
+// GetUnauditedRewardAllocations returns paid reward allocations that have not been anchored yet
+func GetUnauditedRewardAllocations(database *sql.DB, limit int) ([]RewardAllocation, error) {
+	rows, err := db.Query(database,
+		`SELECT `+rewardAllocationColumns+` FROM reward_allocations
+		WHERE status = ? AND audit_sequence_number IS NULL
+		ORDER BY id ASC
+		LIMIT ?`,
+		RewardAllocationCompleted, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allocations []RewardAllocation
+	for rows.Next() {
+		var allocation RewardAllocation
+		if err := scanRewardAllocation(rows, &allocation); err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, allocation)
+	}
+	return allocations, rows.Err()
+}
+
+// GetUnauditedBadges returns minted badges that have not been anchored yet
+func GetUnauditedBadges(database *sql.DB, limit int) ([]UserBadge, error) {
+	return queryUserBadges(database,
+		`SELECT `+userBadgeColumns+` FROM user_badges
+		WHERE status = ? AND audit_sequence_number IS NULL
+		ORDER BY id ASC
+		LIMIT ?`,
+		BadgeStatusMinted, limit,
+	)
+}
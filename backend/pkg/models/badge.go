@@ -0,0 +1,261 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+)
+
+// Badge statuses. A badge is pending until its NFT has been minted and
+// submitted to the user's wallet, and minted once that transfer succeeded.
+// It needs review when the outcome of that transfer cannot be confirmed.
+const (
+	BadgeStatusPending     = "pending"
+	BadgeStatusSubmitted   = "submitted"
+	BadgeStatusMinted      = "minted"
+	BadgeStatusFailed      = "failed"
+	BadgeStatusNeedsReview = "needs_review"
+)
+
+// UserBadge is an achievement badge earned by a user. Name and Description
+// come from the badge definition and are not stored.
+type UserBadge struct {
+	ID            int        `json:"id"`
+	UserID        int        `json:"user_id"`
+	BadgeType     string     `json:"badge_type"`
+	Name          string     `json:"name,omitempty"`
+	Description   string     `json:"description,omitempty"`
+	Status        string     `json:"status"`
+	TokenID       string     `json:"token_id,omitempty"`
+	SerialNumber  *int64     `json:"serial_number,omitempty"`
+	TransactionID string     `json:"transaction_id,omitempty"`
+	FailureReason string     `json:"failure_reason,omitempty"`
+	EarnedAt      time.Time  `json:"earned_at"`
+	MintedAt      *time.Time `json:"minted_at,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	AuditEntry
+}
+
+const userBadgeColumns = `id, user_id, badge_type, status, COALESCE(token_id, ''), serial_number,
+	COALESCE(transaction_id, ''), COALESCE(failure_reason, ''), earned_at, minted_at, updated_at,
+	` + auditColumns
+
+// scanUserBadge scans a row selected with userBadgeColumns
+func scanUserBadge(row interface{ Scan(...interface{}) error }, badge *UserBadge) error {
+	return row.Scan(
+		&badge.ID, &badge.UserID, &badge.BadgeType, &badge.Status, &badge.TokenID, &badge.SerialNumber,
+		&badge.TransactionID, &badge.FailureReason, &badge.EarnedAt, &badge.MintedAt, &badge.UpdatedAt,
+		&badge.AuditHash, &badge.AuditTopicID, &badge.AuditSequenceNumber,
+	)
+}
+
+// AwardBadge records that a user earned a badge. It reports false when the
+// user already had the badge.
+func AwardBadge(database *sql.DB, userID int, badgeType string) (*UserBadge, bool, error) {
+	result, err := db.Exec(database,
+		`INSERT INTO user_badges (user_id, badge_type, status) VALUES (?, ?, ?)
+		ON CONFLICT (user_id, badge_type) DO NOTHING`,
+		userID, badgeType, BadgeStatusPending,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	badge := &UserBadge{}
+	err = scanUserBadge(db.QueryRow(database,
+		`SELECT `+userBadgeColumns+` FROM user_badges WHERE user_id = ? AND badge_type = ?`,
+		userID, badgeType,
+	), badge)
+	if err != nil {
+		return nil, false, err
+	}
+	return badge, affected > 0, nil
+}
+
+// GetUserBadgeByID retrieves a badge by ID
+func GetUserBadgeByID(database *sql.DB, badgeID int) (*UserBadge, error) {
+	badge := &UserBadge{}
+	err := scanUserBadge(db.QueryRow(database, `SELECT `+userBadgeColumns+` FROM user_badges WHERE id = ?`, badgeID), badge)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return badge, nil
+}
+
+// GetUserBadges lists the badges a user earned, oldest first
+func GetUserBadges(database *sql.DB, userID int) ([]UserBadge, error) {
+	return queryUserBadges(database,
+		`SELECT `+userBadgeColumns+` FROM user_badges WHERE user_id = ? ORDER BY earned_at ASC, id ASC`,
+		userID,
+	)
+}
+
+// GetPendingBadges returns badges whose NFT has not been delivered yet
+func GetPendingBadges(database *sql.DB, limit int) ([]UserBadge, error) {
+	return queryUserBadges(database,
+		`SELECT `+userBadgeColumns+` FROM user_badges WHERE status = ? ORDER BY id ASC LIMIT ?`,
+		BadgeStatusPending, limit,
+	)
+}
+
+// GetStaleSubmittedBadges returns submitted badges last updated before the cutoff
+func GetStaleSubmittedBadges(database *sql.DB, updatedBefore time.Time, limit int) ([]UserBadge, error) {
+	return queryUserBadges(database,
+		`SELECT `+userBadgeColumns+` FROM user_badges
+		WHERE status = ? AND updated_at < ?
+		ORDER BY updated_at ASC
+		LIMIT ?`,
+		BadgeStatusSubmitted, updatedBefore.UTC(), limit,
+	)
+}
+
+// queryUserBadges runs a query selecting userBadgeColumns
+func queryUserBadges(database *sql.DB, query string, args ...interface{}) ([]UserBadge, error) {
+	rows, err := db.Query(database, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	badges := []UserBadge{}
+	for rows.Next() {
+		var badge UserBadge
+		if err := scanUserBadge(rows, &badge); err != nil {
+			return nil, err
+		}
+		badges = append(badges, badge)
+	}
+	return badges, rows.Err()
+}
+
+// SetBadgeSerial records the NFT minted for a pending badge so a retried
+// delivery transfers it instead of minting another one
+func SetBadgeSerial(database *sql.DB, badgeID int, tokenID string, serialNumber int64) error {
+	_, err := db.Exec(database,
+		`UPDATE user_badges SET token_id = ?, serial_number = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ? AND serial_number IS NULL`,
+		tokenID, serialNumber, badgeID, BadgeStatusPending,
+	)
+	return err
+}
+
+// MarkBadgeSubmitted claims a pending badge for delivery under a reserved
+// transaction ID. It reports false when another worker claimed it first.
+func MarkBadgeSubmitted(database *sql.DB, badgeID int, transactionID string) (bool, error) {
+	result, err := db.Exec(database,
+		`UPDATE user_badges SET status = ?, transaction_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		BadgeStatusSubmitted, transactionID, badgeID, BadgeStatusPending,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// MarkBadgeMinted moves a submitted badge to minted. It reports false when
+// the badge was no longer submitted.
+func MarkBadgeMinted(database *sql.DB, badgeID int) (bool, error) {
+	result, err := db.Exec(database,
+		`UPDATE user_badges SET status = ?, failure_reason = NULL, minted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		BadgeStatusMinted, badgeID, BadgeStatusSubmitted,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// MarkBadgeFailed moves a pending or submitted badge to failed with a reason
+func MarkBadgeFailed(database *sql.DB, badgeID int, reason string) error {
+	_, err := db.Exec(database,
+		`UPDATE user_badges SET status = ?, failure_reason = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status IN (?, ?)`,
+		BadgeStatusFailed, reason, badgeID, BadgeStatusPending, BadgeStatusSubmitted,
+	)
+	return err
+}
+
+// MarkBadgeNeedsReview moves a submitted badge to needs_review with the
+// reason its delivery's outcome is unknown
+func MarkBadgeNeedsReview(database *sql.DB, badgeID int, reason string) error {
+	_, err := db.Exec(database,
+		`UPDATE user_badges SET status = ?, failure_reason = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		BadgeStatusNeedsReview, reason, badgeID, BadgeStatusSubmitted,
+	)
+	return err
+}
+
+// GetUsersActiveSince returns the users with activity recorded at or after since
+func GetUsersActiveSince(database *sql.DB, since time.Time) ([]int, error) {
+	rows, err := db.Query(database,
+		`SELECT DISTINCT user_id FROM user_activities WHERE created_at >= ?`,
+		since.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// CountUserPosts counts the posts a user has written
+func CountUserPosts(database *sql.DB, userID int) (int, error) {
+	var count int
+	err := db.QueryRow(database, `SELECT COUNT(*) FROM posts WHERE user_id = ?`, userID).Scan(&count)
+	return count, err
+}
+
+// CountSupportiveComments counts the comments of at least minLength
+// characters a user has left on other users' posts
+func CountSupportiveComments(database *sql.DB, userID int, minLength int) (int, error) {
+	var count int
+	err := db.QueryRow(database,
+		`SELECT COUNT(*) FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		WHERE c.user_id = ? AND p.user_id <> c.user_id AND LENGTH(TRIM(c.content)) >= ?`,
+		userID, minLength,
+	).Scan(&count)
+	return count, err
+}
+
+// CountDistinctLikers counts the other users who have liked a user's posts or comments
+func CountDistinctLikers(database *sql.DB, userID int) (int, error) {
+	var count int
+	err := db.QueryRow(database,
+		`SELECT COUNT(DISTINCT likes.liker_id) FROM (
+			SELECT r.user_id AS liker_id
+			FROM post_reactions r
+			JOIN posts p ON p.id = r.post_id
+			WHERE p.user_id = ? AND r.reaction_type = 'like' AND r.user_id <> p.user_id
+			UNION ALL
+			SELECT r.user_id AS liker_id
+			FROM comment_reactions r
+			JOIN comments c ON c.id = r.comment_id
+			WHERE c.user_id = ? AND r.reaction_type = 'like' AND r.user_id <> c.user_id
+		) likes`,
+		userID, userID,
+	).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+)
+
+// RestrictedUser is a user someone blocked or muted
+type RestrictedUser struct {
+	ID        int       `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Nickname  string    `json:"nickname,omitempty"`
+	Avatar    string    `json:"avatar,omitempty"`
+	Since     time.Time `json:"since"`
+}
+
+// blockedUsersFilter returns a condition leaving out rows whose user, in
+// column, blocked the viewer or was blocked by them. It takes the viewer's
+// ID twice; see blockedUsersArgs.
+func blockedUsersFilter(column string) string {
+	return column + ` NOT IN (
+		SELECT blocked_id FROM user_blocks WHERE blocker_id = ?
+		UNION SELECT blocker_id FROM user_blocks WHERE blocked_id = ?)`
+}
+
+// blockedUsersArgs returns the arguments of blockedUsersFilter
+func blockedUsersArgs(viewerID int) []interface{} {
+	return []interface{}{viewerID, viewerID}
+}
+
+// hiddenAuthorsFilter is blockedUsersFilter that also leaves out the users
+// the viewer muted. It takes the viewer's ID three times; see
+// hiddenAuthorsArgs.
+func hiddenAuthorsFilter(column string) string {
+	return column + ` NOT IN (
+		SELECT blocked_id FROM user_blocks WHERE blocker_id = ?
+		UNION SELECT blocker_id FROM user_blocks WHERE blocked_id = ?
+		UNION SELECT muted_id FROM user_mutes WHERE muter_id = ?)`
+}
+
+// hiddenAuthorsArgs returns the arguments of hiddenAuthorsFilter
+func hiddenAuthorsArgs(viewerID int) []interface{} {
+	return []interface{}{viewerID, viewerID, viewerID}
+}
+
+// BlockUser blocks a user. The two users stop following each other and
+// leave each other's care circles. It reports false when the user was
+// already blocked.
+func BlockUser(database *sql.DB, blockerID, blockedID int) (bool, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := db.TxExec(tx,
+		`INSERT INTO user_blocks (blocker_id, blocked_id) VALUES (?, ?) ON CONFLICT (blocker_id, blocked_id) DO NOTHING`,
+		blockerID, blockedID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	for _, query := range []string{
+		`DELETE FROM follows WHERE (follower_id = ? AND following_id = ?) OR (follower_id = ? AND following_id = ?)`,
+		`DELETE FROM care_circle_contacts WHERE (user_id = ? AND contact_id = ?) OR (user_id = ? AND contact_id = ?)`,
+	} {
+		if _, err := db.TxExec(tx, query, blockerID, blockedID, blockedID, blockerID); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// UnblockUser lifts a block. It reports false when the user was not blocked.
+func UnblockUser(database *sql.DB, blockerID, blockedID int) (bool, error) {
+	result, err := db.Exec(database, `DELETE FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?`, blockerID, blockedID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// MuteUser mutes a user. It reports false when the user was already muted.
+func MuteUser(database *sql.DB, muterID, mutedID int) (bool, error) {
+	result, err := db.Exec(database,
+		`INSERT INTO user_mutes (muter_id, muted_id) VALUES (?, ?) ON CONFLICT (muter_id, muted_id) DO NOTHING`,
+		muterID, mutedID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// UnmuteUser lifts a mute. It reports false when the user was not muted.
+func UnmuteUser(database *sql.DB, muterID, mutedID int) (bool, error) {
+	result, err := db.Exec(database, `DELETE FROM user_mutes WHERE muter_id = ? AND muted_id = ?`, muterID, mutedID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// IsBlockedBetween reports whether either user blocked the other
+func IsBlockedBetween(database *sql.DB, userID, otherUserID int) (bool, error) {
+	var exists bool
+	err := db.QueryRow(database,
+		`SELECT EXISTS(
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)
+		)`,
+		userID, otherUserID, otherUserID, userID,
+	).Scan(&exists)
+	return exists, err
+}
+
+// GetBlockedUsers returns the users a user blocked, most recent first
+func GetBlockedUsers(database *sql.DB, userID int) ([]RestrictedUser, error) {
+	return queryRestrictedUsers(database,
+		`SELECT u.id, u.first_name, u.last_name, COALESCE(u.nickname, ''), COALESCE(u.avatar, ''), b.created_at
+		FROM user_blocks b
+		JOIN users u ON b.blocked_id = u.id
+		WHERE b.blocker_id = ?
+		ORDER BY b.created_at DESC, u.id DESC`,
+		userID,
+	)
+}
+
+// GetMutedUsers returns the users a user muted, most recent first
+func GetMutedUsers(database *sql.DB, userID int) ([]RestrictedUser, error) {
+	return queryRestrictedUsers(database,
+		`SELECT u.id, u.first_name, u.last_name, COALESCE(u.nickname, ''), COALESCE(u.avatar, ''), m.created_at
+		FROM user_mutes m
+		JOIN users u ON m.muted_id = u.id
+		WHERE m.muter_id = ?
+		ORDER BY m.created_at DESC, u.id DESC`,
+		userID,
+	)
+}
+
+// queryRestrictedUsers runs a query selecting blocked or muted users
+func queryRestrictedUsers(database *sql.DB, query string, args ...interface{}) ([]RestrictedUser, error) {
+	rows, err := db.Query(database, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []RestrictedUser{}
+	for rows.Next() {
+		var user RestrictedUser
+		if err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Nickname, &user.Avatar, &user.Since); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
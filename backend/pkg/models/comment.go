@@ -6,21 +6,24 @@ import (
 	"time"
 
 	dbpkg "github.com/On-cure/Oncure/pkg/db"
+	"github.com/On-cure/Oncure/pkg/money"
 )
 
 type Comment struct {
-	ID           int       `json:"id"`
-	PostID       int       `json:"post_id"`
-	UserID       int       `json:"user_id"`
-	ParentID     *int      `json:"parent_id"`
-	Content      string    `json:"content"`
-	ImageURL     string    `json:"image_url,omitempty"`
-	LikeCount    int       `json:"like_count"`
-	DislikeCount int       `json:"dislike_count"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	User         *User     `json:"user,omitempty"`
-	Replies      []Comment `json:"replies,omitempty"`
+	ID           int            `json:"id"`
+	PostID       int            `json:"post_id"`
+	UserID       int            `json:"user_id"`
+	ParentID     *int           `json:"parent_id"`
+	Content      string         `json:"content"`
+	ImageURL     string         `json:"image_url,omitempty"`
+	LikeCount    int            `json:"like_count"`
+	DislikeCount int            `json:"dislike_count"`
+	TipTotal     money.Tinybars `json:"tip_total"`
+	TipCount     int            `json:"tip_count"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	User         *User          `json:"user,omitempty"`
+	Replies      []Comment      `json:"replies,omitempty"`
 }
 
 // CreateComment creates a new comment
@@ -60,14 +63,14 @@ func GetCommentById(database *sql.DB, commentId int) (*Comment, error) {
 	// Get comment data
 	err := database.QueryRow(
 		`SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.image_url, 
-		COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, 
+		COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, COALESCE(c.tip_total_tinybars, 0) as tip_total, COALESCE(c.tip_count, 0) as tip_count, 
 		c.created_at, c.updated_at
 		FROM comments c
 		WHERE c.id = ?`,
 		commentId,
 	).Scan(
 		&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentID, &comment.Content, &comment.ImageURL,
-		&comment.LikeCount, &comment.DislikeCount, &comment.CreatedAt, &comment.UpdatedAt,
+		&comment.LikeCount, &comment.DislikeCount, &comment.TipTotal, &comment.TipCount, &comment.CreatedAt, &comment.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -85,6 +88,23 @@ func GetCommentById(database *sql.DB, commentId int) (*Comment, error) {
 	return comment, nil
 }
 
+// GetVisibleCommentById retrieves a comment by ID, treating comments hidden
+// by moderation as missing for everyone but their author
+func GetVisibleCommentById(database *sql.DB, commentId int, currentUserId int) (*Comment, error) {
+	var visible int
+	err := database.QueryRow(
+		dbpkg.Placeholder(`SELECT COUNT(*) FROM comments WHERE id = ? AND (hidden_at IS NULL OR user_id = ?)`),
+		commentId, currentUserId,
+	).Scan(&visible)
+	if err != nil {
+		return nil, err
+	}
+	if visible == 0 {
+		return nil, nil
+	}
+	return GetCommentById(database, commentId)
+}
+
 // GetPostComments retrieves comments for a post
 func GetPostComments(database *sql.DB, postId int, options map[string]interface{}) ([]Comment, error) {
 	comments := []Comment{}
@@ -93,6 +113,7 @@ func GetPostComments(database *sql.DB, postId int, options map[string]interface{
 	page := 1
 	limit := 20
 	var parentId *int = nil
+	viewerId := 0
 
 	// Override with options if provided
 	if p, ok := options["page"].(int); ok {
@@ -104,6 +125,9 @@ func GetPostComments(database *sql.DB, postId int, options map[string]interface{
 	if p, ok := options["parentId"].(*int); ok {
 		parentId = p
 	}
+	if v, ok := options["viewerId"].(int); ok {
+		viewerId = v
+	}
 
 	offset := (page - 1) * limit
 
@@ -115,43 +139,45 @@ func GetPostComments(database *sql.DB, postId int, options map[string]interface{
 		// Get top-level comments
 		query = `
 			SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.image_url, 
-			COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, 
+			COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, COALESCE(c.tip_total_tinybars, 0) as tip_total, COALESCE(c.tip_count, 0) as tip_count, 
 			c.created_at, c.updated_at,
 			u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 			FROM comments c
 			JOIN users u ON c.user_id = u.id
-			WHERE c.post_id = ? AND c.parent_id IS NULL
+			WHERE c.post_id = ? AND c.parent_id IS NULL AND c.hidden_at IS NULL AND ` + hiddenAuthorsFilter("c.user_id") + `
 			ORDER BY c.created_at DESC
 			LIMIT ? OFFSET ?
 		`
-		args = []interface{}{postId, limit, offset}
+		args = append([]interface{}{postId}, hiddenAuthorsArgs(viewerId)...)
+		args = append(args, limit, offset)
 	} else if *parentId == -1 {
 		// Special case: get all replies for the post (no pagination)
 		query = `
 			SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.image_url, 
-			COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, 
+			COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, COALESCE(c.tip_total_tinybars, 0) as tip_total, COALESCE(c.tip_count, 0) as tip_count, 
 			c.created_at, c.updated_at,
 			u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 			FROM comments c
 			JOIN users u ON c.user_id = u.id
-			WHERE c.post_id = ? AND c.parent_id IS NOT NULL
+			WHERE c.post_id = ? AND c.parent_id IS NOT NULL AND c.hidden_at IS NULL AND ` + hiddenAuthorsFilter("c.user_id") + `
 			ORDER BY c.created_at DESC
 		`
-		args = []interface{}{postId}
+		args = append([]interface{}{postId}, hiddenAuthorsArgs(viewerId)...)
 	} else {
 		// Get replies to a specific comment
 		query = `
 			SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.image_url, 
-			COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, 
+			COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, COALESCE(c.tip_total_tinybars, 0) as tip_total, COALESCE(c.tip_count, 0) as tip_count, 
 			c.created_at, c.updated_at,
 			u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 			FROM comments c
 			JOIN users u ON c.user_id = u.id
-			WHERE c.parent_id = ?
+			WHERE c.parent_id = ? AND c.hidden_at IS NULL AND ` + hiddenAuthorsFilter("c.user_id") + `
 			ORDER BY c.created_at DESC
 			LIMIT ? OFFSET ?
 		`
-		args = []interface{}{*parentId, limit, offset}
+		args = append([]interface{}{*parentId}, hiddenAuthorsArgs(viewerId)...)
+		args = append(args, limit, offset)
 	}
 
 	rows, err := database.Query(dbpkg.Placeholder(query), args...)
@@ -166,7 +192,7 @@ func GetPostComments(database *sql.DB, postId int, options map[string]interface{
 
 		err := rows.Scan(
 			&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentID, &comment.Content, &comment.ImageURL,
-			&comment.LikeCount, &comment.DislikeCount, &comment.CreatedAt, &comment.UpdatedAt,
+			&comment.LikeCount, &comment.DislikeCount, &comment.TipTotal, &comment.TipCount, &comment.CreatedAt, &comment.UpdatedAt,
 			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname,
 		)
 		if err != nil {
@@ -194,6 +220,7 @@ func GetPostComments(database *sql.DB, postId int, options map[string]interface{
 					"parentId": &comment.ID,
 					"limit":    replyLimit,
 					"page":     1,
+					"viewerId": viewerId,
 				})
 				if err != nil {
 					return nil, err
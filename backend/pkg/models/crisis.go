@@ -0,0 +1,252 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+)
+
+// Crisis alert severities
+const (
+	CrisisSeverityHigh   = "high"
+	CrisisSeverityMedium = "medium"
+)
+
+// Crisis alert statuses
+const (
+	CrisisAlertOpen         = "open"
+	CrisisAlertAcknowledged = "acknowledged"
+)
+
+// CrisisAlert is raised for moderators when new content matches the
+// crisis-language rules
+type CrisisAlert struct {
+	ID                 int        `json:"id"`
+	UserID             int        `json:"user_id"`
+	ContentType        string     `json:"content_type"`
+	ContentID          int        `json:"content_id"`
+	ContentSnapshot    string     `json:"content_snapshot"`
+	Category           string     `json:"category"`
+	Severity           string     `json:"severity"`
+	MatchedPhrases     []string   `json:"matched_phrases"`
+	Status             string     `json:"status"`
+	CareCircleNotified bool       `json:"care_circle_notified"`
+	AcknowledgedBy     *int       `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt     *time.Time `json:"acknowledged_at,omitempty"`
+	Note               string     `json:"note,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+const crisisAlertColumns = `id, user_id, content_type, content_id, content_snapshot, category, severity, matched_phrases, status,
+	care_circle_notified, acknowledged_by, acknowledged_at, COALESCE(note, ''), created_at`
+
+// scanCrisisAlert scans a row selected with crisisAlertColumns
+func scanCrisisAlert(row interface{ Scan(...interface{}) error }, a *CrisisAlert) error {
+	var matched string
+	err := row.Scan(
+		&a.ID, &a.UserID, &a.ContentType, &a.ContentID, &a.ContentSnapshot, &a.Category, &a.Severity, &matched, &a.Status,
+		&a.CareCircleNotified, &a.AcknowledgedBy, &a.AcknowledgedAt, &a.Note, &a.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	a.MatchedPhrases = []string{}
+	return json.Unmarshal([]byte(matched), &a.MatchedPhrases)
+}
+
+// CreateCrisisAlert records a new open crisis alert
+func CreateCrisisAlert(database *sql.DB, alert CrisisAlert) (*CrisisAlert, error) {
+	matched, err := json.Marshal(alert.MatchedPhrases)
+	if err != nil {
+		return nil, err
+	}
+
+	var alertID int64
+	if db.IsPostgreSQL() {
+		err = database.QueryRow(
+			`INSERT INTO crisis_alerts (user_id, content_type, content_id, content_snapshot, category, severity, matched_phrases)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+			alert.UserID, alert.ContentType, alert.ContentID, alert.ContentSnapshot, alert.Category, alert.Severity, string(matched),
+		).Scan(&alertID)
+	} else {
+		var result sql.Result
+		result, err = database.Exec(
+			`INSERT INTO crisis_alerts (user_id, content_type, content_id, content_snapshot, category, severity, matched_phrases)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			alert.UserID, alert.ContentType, alert.ContentID, alert.ContentSnapshot, alert.Category, alert.Severity, string(matched),
+		)
+		if err == nil {
+			alertID, err = result.LastInsertId()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetCrisisAlert(database, int(alertID))
+}
+
+// GetCrisisAlert retrieves a crisis alert by ID
+func GetCrisisAlert(database *sql.DB, alertID int) (*CrisisAlert, error) {
+	alert := &CrisisAlert{}
+	err := scanCrisisAlert(db.QueryRow(database, `SELECT `+crisisAlertColumns+` FROM crisis_alerts WHERE id = ?`, alertID), alert)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return alert, nil
+}
+
+// GetCrisisAlerts returns alerts with a status. Open alerts come high
+// severity first and then oldest first; acknowledged ones most recently
+// acknowledged first.
+func GetCrisisAlerts(database *sql.DB, status string, limit int) ([]CrisisAlert, error) {
+	order := `acknowledged_at DESC, id DESC`
+	if status == CrisisAlertOpen {
+		order = `CASE severity WHEN 'high' THEN 0 ELSE 1 END, created_at ASC, id ASC`
+	}
+
+	rows, err := db.Query(database,
+		`SELECT `+crisisAlertColumns+` FROM crisis_alerts WHERE status = ? ORDER BY `+order+` LIMIT ?`,
+		status, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []CrisisAlert{}
+	for rows.Next() {
+		var alert CrisisAlert
+		if err := scanCrisisAlert(rows, &alert); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// AcknowledgeCrisisAlert records that a moderator followed up on an open
+// alert. It reports false when the alert was no longer open.
+func AcknowledgeCrisisAlert(database *sql.DB, alert *CrisisAlert, staffID int, note string) (bool, error) {
+	result, err := db.Exec(database,
+		`UPDATE crisis_alerts SET status = ?, acknowledged_by = ?, acknowledged_at = CURRENT_TIMESTAMP, note = NULLIF(?, '')
+		WHERE id = ? AND status = ?`,
+		CrisisAlertAcknowledged, staffID, note, alert.ID, CrisisAlertOpen,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	alert.Status = CrisisAlertAcknowledged
+	alert.AcknowledgedBy = &staffID
+	alert.Note = note
+	return true, nil
+}
+
+// MarkCareCircleNotified records that the author's care circle was told about an alert
+func MarkCareCircleNotified(database *sql.DB, alertID int) error {
+	_, err := db.Exec(database, `UPDATE crisis_alerts SET care_circle_notified = ? WHERE id = ?`, true, alertID)
+	return err
+}
+
+// CareCircleNotifiedSince reports whether a user's care circle was told
+// about an alert raised after since
+func CareCircleNotifiedSince(database *sql.DB, userID int, since time.Time) (bool, error) {
+	var count int
+	err := db.QueryRow(database,
+		`SELECT COUNT(*) FROM crisis_alerts WHERE user_id = ? AND care_circle_notified = ? AND created_at >= ?`,
+		userID, true, since.UTC(),
+	).Scan(&count)
+	return count > 0, err
+}
+
+// CareCircleContact is a user someone added to their care circle
+type CareCircleContact struct {
+	ID        int       `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Nickname  string    `json:"nickname,omitempty"`
+	Avatar    string    `json:"avatar,omitempty"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+// GetCareCircle returns the contacts a user added to their care circle
+func GetCareCircle(database *sql.DB, userID int) ([]CareCircleContact, error) {
+	rows, err := db.Query(database,
+		`SELECT u.id, u.first_name, u.last_name, COALESCE(u.nickname, ''), COALESCE(u.avatar, ''), c.created_at
+		FROM care_circle_contacts c
+		JOIN users u ON c.contact_id = u.id
+		WHERE c.user_id = ?
+		ORDER BY c.created_at ASC, u.id ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := []CareCircleContact{}
+	for rows.Next() {
+		var contact CareCircleContact
+		if err := rows.Scan(&contact.ID, &contact.FirstName, &contact.LastName, &contact.Nickname, &contact.Avatar, &contact.AddedAt); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	}
+	return contacts, rows.Err()
+}
+
+// GetCareCircleContactIDs returns the IDs of a user's care circle contacts
+func GetCareCircleContactIDs(database *sql.DB, userID int) ([]int, error) {
+	rows, err := db.Query(database, `SELECT contact_id FROM care_circle_contacts WHERE user_id = ? ORDER BY contact_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contactIDs := []int{}
+	for rows.Next() {
+		var contactID int
+		if err := rows.Scan(&contactID); err != nil {
+			return nil, err
+		}
+		contactIDs = append(contactIDs, contactID)
+	}
+	return contactIDs, rows.Err()
+}
+
+// AddCareCircleContact adds a contact to a user's care circle. It reports
+// false when the contact was already in it.
+func AddCareCircleContact(database *sql.DB, userID, contactID int) (bool, error) {
+	result, err := db.Exec(database,
+		`INSERT INTO care_circle_contacts (user_id, contact_id) VALUES (?, ?) ON CONFLICT (user_id, contact_id) DO NOTHING`,
+		userID, contactID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RemoveCareCircleContact removes a contact from a user's care circle. It
+// reports false when the contact was not in it.
+func RemoveCareCircleContact(database *sql.DB, userID, contactID int) (bool, error) {
+	result, err := db.Exec(database, `DELETE FROM care_circle_contacts WHERE user_id = ? AND contact_id = ?`, userID, contactID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
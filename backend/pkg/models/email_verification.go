@@ -0,0 +1,124 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+)
+
+// EmailVerificationToken is a single-use token confirming a user owns their
+// email address. Only the SHA-256 hash of the token is stored.
+type EmailVerificationToken struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// CreateEmailVerificationToken stores the hash of a new verification token
+func CreateEmailVerificationToken(database *sql.DB, userID int, tokenHash string, expiresAt time.Time) error {
+	_, err := db.Exec(database,
+		`INSERT INTO email_verification_tokens (user_id, token_hash, expires_at) VALUES (?, ?, ?)`,
+		userID, tokenHash, expiresAt.UTC(),
+	)
+	return err
+}
+
+// GetEmailVerificationToken retrieves a verification token by its hash
+func GetEmailVerificationToken(database *sql.DB, tokenHash string) (*EmailVerificationToken, error) {
+	token := &EmailVerificationToken{}
+	err := db.QueryRow(database,
+		`SELECT id, user_id, token_hash, expires_at, used_at, created_at FROM email_verification_tokens WHERE token_hash = ?`,
+		tokenHash,
+	).Scan(&token.ID, &token.UserID, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// GetEmailVerificationTokenTimes returns when the verification tokens a
+// user was sent since a time were created, oldest first
+func GetEmailVerificationTokenTimes(database *sql.DB, userID int, since time.Time) ([]time.Time, error) {
+	rows, err := db.Query(database,
+		`SELECT created_at FROM email_verification_tokens WHERE user_id = ? AND created_at >= ? ORDER BY created_at ASC`,
+		userID, since.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	times := []time.Time{}
+	for rows.Next() {
+		var createdAt time.Time
+		if err := rows.Scan(&createdAt); err != nil {
+			return nil, err
+		}
+		times = append(times, createdAt)
+	}
+	return times, rows.Err()
+}
+
+// HasConfirmedEmail reports whether a user confirmed their email address
+// with a verification link. Accounts that migration 035 marked verified
+// have not, until they use a link.
+func HasConfirmedEmail(database *sql.DB, userID int) (bool, error) {
+	var confirmed bool
+	err := db.QueryRow(database,
+		`SELECT EXISTS(SELECT 1 FROM email_verification_tokens WHERE user_id = ? AND used_at IS NOT NULL)`,
+		userID,
+	).Scan(&confirmed)
+	return confirmed, err
+}
+
+// ConfirmEmail uses a verification token to mark its user's email address
+// verified and spends the user's other open tokens. It reports false when
+// the token was already used.
+func ConfirmEmail(database *sql.DB, token *EmailVerificationToken) (bool, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	result, err := db.TxExec(tx,
+		`UPDATE email_verification_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`,
+		now, token.ID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	if _, err := db.TxExec(tx,
+		`UPDATE users SET email_verified_at = ? WHERE id = ? AND email_verified_at IS NULL`,
+		now, token.UserID,
+	); err != nil {
+		return false, err
+	}
+	if _, err := db.TxExec(tx,
+		`UPDATE email_verification_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL`,
+		now, token.UserID,
+	); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
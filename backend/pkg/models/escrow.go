@@ -0,0 +1,290 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+// Escrow statuses. A payment is funding until the patient's transfer into the
+// escrow account settles and funded while it is held. Releasing and refunding
+// last until the payout transfer settles; released, refunded and failed are
+// final. Disputed payments are held until an admin resolves them.
+const (
+	EscrowStatusFunding   = "funding"
+	EscrowStatusFunded    = "funded"
+	EscrowStatusDisputed  = "disputed"
+	EscrowStatusReleasing = "releasing"
+	EscrowStatusReleased  = "released"
+	EscrowStatusRefunding = "refunding"
+	EscrowStatusRefunded  = "refunded"
+	EscrowStatusFailed    = "failed"
+)
+
+// Escrow is a paid mentorship session whose payment is held by the platform
+type Escrow struct {
+	ID                 int            `json:"id"`
+	PatientID          int            `json:"patient_id"`
+	CoachID            int            `json:"coach_id"`
+	Amount             money.Tinybars `json:"amount"`
+	Status             string         `json:"status"`
+	Description        string         `json:"description,omitempty"`
+	ScheduledAt        time.Time      `json:"scheduled_at"`
+	AutoCompleteAt     time.Time      `json:"auto_complete_at"`
+	PatientConfirmedAt *time.Time     `json:"patient_confirmed_at,omitempty"`
+	CoachConfirmedAt   *time.Time     `json:"coach_confirmed_at,omitempty"`
+	DisputeReason      string         `json:"dispute_reason,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	SettledAt          *time.Time     `json:"settled_at,omitempty"`
+}
+
+// EscrowEvent records one state transition of an escrow
+type EscrowEvent struct {
+	ID          int       `json:"id"`
+	EscrowID    int       `json:"escrow_id"`
+	FromStatus  string    `json:"from_status,omitempty"`
+	ToStatus    string    `json:"to_status"`
+	ActorUserID *int      `json:"actor_user_id,omitempty"`
+	TransferID  *int      `json:"transfer_id,omitempty"`
+	Note        string    `json:"note,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const escrowColumns = `id, patient_id, coach_id, amount_tinybars, status, COALESCE(description, ''), scheduled_at, auto_complete_at,
+	patient_confirmed_at, coach_confirmed_at, COALESCE(dispute_reason, ''), created_at, updated_at, settled_at`
+
+// scanEscrow scans a row selected with escrowColumns
+func scanEscrow(row interface{ Scan(...interface{}) error }, e *Escrow) error {
+	return row.Scan(
+		&e.ID, &e.PatientID, &e.CoachID, &e.Amount, &e.Status, &e.Description, &e.ScheduledAt, &e.AutoCompleteAt,
+		&e.PatientConfirmedAt, &e.CoachConfirmedAt, &e.DisputeReason, &e.CreatedAt, &e.UpdatedAt, &e.SettledAt,
+	)
+}
+
+// IsFinal reports whether the escrow can no longer change
+func (e *Escrow) IsFinal() bool {
+	switch e.Status {
+	case EscrowStatusReleased, EscrowStatusRefunded, EscrowStatusFailed:
+		return true
+	}
+	return false
+}
+
+// CreateEscrow records a new escrow in the funding status together with its first event
+func CreateEscrow(database *sql.DB, escrow Escrow) (*Escrow, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var escrowID int64
+	if db.IsPostgreSQL() {
+		err = tx.QueryRow(
+			`INSERT INTO escrows (patient_id, coach_id, amount_tinybars, status, description, scheduled_at, auto_complete_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7) RETURNING id`,
+			escrow.PatientID, escrow.CoachID, escrow.Amount, EscrowStatusFunding, escrow.Description,
+			escrow.ScheduledAt.UTC(), escrow.AutoCompleteAt.UTC(),
+		).Scan(&escrowID)
+	} else {
+		var result sql.Result
+		result, err = tx.Exec(
+			`INSERT INTO escrows (patient_id, coach_id, amount_tinybars, status, description, scheduled_at, auto_complete_at)
+			VALUES (?, ?, ?, ?, NULLIF(?, ''), ?, ?)`,
+			escrow.PatientID, escrow.CoachID, escrow.Amount, EscrowStatusFunding, escrow.Description,
+			escrow.ScheduledAt.UTC(), escrow.AutoCompleteAt.UTC(),
+		)
+		if err == nil {
+			escrowID, err = result.LastInsertId()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertEscrowEvent(tx, EscrowEvent{
+		EscrowID:    int(escrowID),
+		ToStatus:    EscrowStatusFunding,
+		ActorUserID: &escrow.PatientID,
+	}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetEscrowByID(database, int(escrowID))
+}
+
+// GetEscrowByID retrieves an escrow by ID
+func GetEscrowByID(database *sql.DB, escrowID int) (*Escrow, error) {
+	escrow := &Escrow{}
+	err := scanEscrow(db.QueryRow(database, `SELECT `+escrowColumns+` FROM escrows WHERE id = ?`, escrowID), escrow)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return escrow, nil
+}
+
+// GetUserEscrows returns the escrows a user pays or is paid by, newest
+// first, optionally with one status
+func GetUserEscrows(database *sql.DB, userID int, status string, limit int) ([]Escrow, error) {
+	query := `SELECT ` + escrowColumns + ` FROM escrows WHERE (patient_id = ? OR coach_id = ?)`
+	args := []interface{}{userID, userID}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+	return queryEscrows(database, query, args...)
+}
+
+// GetEscrowsByStatus returns escrows with a status, oldest first
+func GetEscrowsByStatus(database *sql.DB, status string, limit int) ([]Escrow, error) {
+	return queryEscrows(database,
+		`SELECT `+escrowColumns+` FROM escrows WHERE status = ? ORDER BY updated_at ASC, id ASC LIMIT ?`,
+		status, limit,
+	)
+}
+
+// GetCompletableEscrows returns funded escrows both parties confirmed or
+// whose auto-completion time has passed
+func GetCompletableEscrows(database *sql.DB, now time.Time, limit int) ([]Escrow, error) {
+	return queryEscrows(database,
+		`SELECT `+escrowColumns+` FROM escrows
+		WHERE status = ? AND ((patient_confirmed_at IS NOT NULL AND coach_confirmed_at IS NOT NULL) OR auto_complete_at <= ?)
+		ORDER BY auto_complete_at ASC, id ASC
+		LIMIT ?`,
+		EscrowStatusFunded, now.UTC(), limit,
+	)
+}
+
+// queryEscrows runs a query selecting escrowColumns
+func queryEscrows(database *sql.DB, query string, args ...interface{}) ([]Escrow, error) {
+	rows, err := db.Query(database, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	escrows := []Escrow{}
+	for rows.Next() {
+		var e Escrow
+		if err := scanEscrow(rows, &e); err != nil {
+			return nil, err
+		}
+		escrows = append(escrows, e)
+	}
+	return escrows, rows.Err()
+}
+
+// TransitionEscrow moves an escrow from its current status to another and
+// records the event. Confirmations, the dispute reason and the settlement
+// time are stored with the statuses they belong to. It reports false when
+// the escrow was no longer in the status it was loaded with, e.g. because a
+// concurrent request moved it first.
+func TransitionEscrow(database *sql.DB, escrow *Escrow, event EscrowEvent) (bool, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE escrows SET status = ?, updated_at = CURRENT_TIMESTAMP`
+	args := []interface{}{event.ToStatus}
+	switch event.ToStatus {
+	case EscrowStatusDisputed:
+		query += `, dispute_reason = ?`
+		args = append(args, event.Note)
+	case EscrowStatusReleased, EscrowStatusRefunded, EscrowStatusFailed:
+		query += `, settled_at = CURRENT_TIMESTAMP`
+	}
+	query += ` WHERE id = ? AND status = ?`
+	args = append(args, escrow.ID, escrow.Status)
+
+	result, err := db.TxExec(tx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	event.EscrowID = escrow.ID
+	event.FromStatus = escrow.Status
+	if err := insertEscrowEvent(tx, event); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	escrow.Status = event.ToStatus
+	if event.ToStatus == EscrowStatusDisputed {
+		escrow.DisputeReason = event.Note
+	}
+	return true, nil
+}
+
+// ConfirmEscrow records that a participant confirmed the session took place.
+// It reports false when the escrow is no longer funded.
+func ConfirmEscrow(database *sql.DB, escrow *Escrow, userID int) (bool, error) {
+	column := "patient_confirmed_at"
+	if userID == escrow.CoachID {
+		column = "coach_confirmed_at"
+	}
+
+	result, err := db.Exec(database,
+		`UPDATE escrows SET `+column+` = COALESCE(`+column+`, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		escrow.ID, EscrowStatusFunded,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetEscrowEvents returns the transitions of an escrow, oldest first
+func GetEscrowEvents(database *sql.DB, escrowID int) ([]EscrowEvent, error) {
+	rows, err := db.Query(database,
+		`SELECT id, escrow_id, COALESCE(from_status, ''), to_status, actor_user_id, transfer_id, COALESCE(note, ''), created_at
+		FROM escrow_events WHERE escrow_id = ? ORDER BY id ASC`,
+		escrowID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []EscrowEvent{}
+	for rows.Next() {
+		var e EscrowEvent
+		if err := rows.Scan(&e.ID, &e.EscrowID, &e.FromStatus, &e.ToStatus, &e.ActorUserID, &e.TransferID, &e.Note, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// insertEscrowEvent records a transition inside a transaction
+func insertEscrowEvent(tx *sql.Tx, event EscrowEvent) error {
+	_, err := db.TxExec(tx,
+		`INSERT INTO escrow_events (escrow_id, from_status, to_status, actor_user_id, transfer_id, note)
+		VALUES (?, NULLIF(?, ''), ?, ?, ?, NULLIF(?, ''))`,
+		event.EscrowID, event.FromStatus, event.ToStatus, event.ActorUserID, event.TransferID, event.Note,
+	)
+	return err
+}
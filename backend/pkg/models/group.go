@@ -475,15 +475,17 @@ func GetGroupPosts(db *sql.DB, groupId int, userId int, page int, limit int) ([]
 	offset := (page - 1) * limit
 	posts := []Post{}
 
+	args := append([]interface{}{groupId}, hiddenAuthorsArgs(userId)...)
+	args = append(args, limit, offset)
 	rows, err := db.Query(`
 		SELECT gp.id, gp.user_id, gp.content, gp.image_url, gp.created_at, gp.updated_at,
 		u.id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 		FROM group_posts gp
 		JOIN users u ON gp.user_id = u.id
-		WHERE gp.group_id = ?
+		WHERE gp.group_id = ? AND gp.hidden_at IS NULL AND `+hiddenAuthorsFilter("gp.user_id")+`
 		ORDER BY gp.created_at DESC
 		LIMIT ? OFFSET ?
-	`, groupId, limit, offset)
+	`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -166,11 +166,12 @@ func GetGroupPostComments(db *sql.DB, groupPostId int, userId int, options map[s
 			u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 			FROM group_post_comments c
 			JOIN users u ON c.user_id = u.id
-			WHERE c.group_post_id = ? AND c.parent_id IS NULL
+			WHERE c.group_post_id = ? AND c.parent_id IS NULL AND c.hidden_at IS NULL AND ` + hiddenAuthorsFilter("c.user_id") + `
 			ORDER BY c.created_at DESC
 			LIMIT ? OFFSET ?
 		`
-		args = []interface{}{groupPostId, limit, offset}
+		args = append([]interface{}{groupPostId}, hiddenAuthorsArgs(userId)...)
+		args = append(args, limit, offset)
 	} else if *parentId == -1 {
 		// Special case: get all replies for the post (no pagination)
 		query = `
@@ -180,10 +181,10 @@ func GetGroupPostComments(db *sql.DB, groupPostId int, userId int, options map[s
 			u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 			FROM group_post_comments c
 			JOIN users u ON c.user_id = u.id
-			WHERE c.group_post_id = ? AND c.parent_id IS NOT NULL
+			WHERE c.group_post_id = ? AND c.parent_id IS NOT NULL AND c.hidden_at IS NULL AND ` + hiddenAuthorsFilter("c.user_id") + `
 			ORDER BY c.created_at DESC
 		`
-		args = []interface{}{groupPostId}
+		args = append([]interface{}{groupPostId}, hiddenAuthorsArgs(userId)...)
 	} else {
 		// Get replies to a specific comment
 		query = `
@@ -193,11 +194,12 @@ func GetGroupPostComments(db *sql.DB, groupPostId int, userId int, options map[s
 			u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 			FROM group_post_comments c
 			JOIN users u ON c.user_id = u.id
-			WHERE c.parent_id = ?
+			WHERE c.parent_id = ? AND c.hidden_at IS NULL AND ` + hiddenAuthorsFilter("c.user_id") + `
 			ORDER BY c.created_at DESC
 			LIMIT ? OFFSET ?
 		`
-		args = []interface{}{*parentId, limit, offset}
+		args = append([]interface{}{*parentId}, hiddenAuthorsArgs(userId)...)
+		args = append(args, limit, offset)
 	}
 
 	rows, err := db.Query(query, args...)
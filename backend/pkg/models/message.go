@@ -22,8 +22,9 @@ type Message struct {
 
 // CreatePrivateMessage creates a new private message between users
 func CreatePrivateMessage(database *sql.DB, senderId int, receiverId int, content string) (*Message, error) {
-	// Allow sending messages to any user; message requests are handled at the conversation level
-	// (Old restriction removed to support message requests)
+	// Allow sending messages to any user who has not blocked the sender or been
+	// blocked by them; message requests are handled at the conversation level.
+	// Callers check blocks with IsBlockedBetween.
 
 	// Create message
 	result, err := db.Exec(database,
@@ -104,7 +105,8 @@ func GetPrivateMessages(database *sql.DB, userId1 int, userId2 int, page int, li
 		FROM messages m
 		JOIN users s ON m.sender_id = s.id
 		JOIN users r ON m.receiver_id = r.id
-		WHERE (m.sender_id = ? AND m.receiver_id = ?) OR (m.sender_id = ? AND m.receiver_id = ?)
+		WHERE ((m.sender_id = ? AND m.receiver_id = ?) OR (m.sender_id = ? AND m.receiver_id = ?))
+		AND m.hidden_at IS NULL
 		ORDER BY m.created_at ASC
 		LIMIT ? OFFSET ?
 	`, userId1, userId2, userId2, userId1, limit, offset)
@@ -181,7 +183,7 @@ func GetGroupMessages(database *sql.DB, groupId int, userId int, page int, limit
 		s.id, s.email, s.first_name, s.last_name, s.avatar, s.nickname
 		FROM messages m
 		JOIN users s ON m.sender_id = s.id
-		WHERE m.group_id = ?
+		WHERE m.group_id = ? AND m.hidden_at IS NULL
 		ORDER BY m.created_at ASC
 		LIMIT ? OFFSET ?
 	`, groupId, limit, offset)
@@ -0,0 +1,332 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+)
+
+// Report reasons
+const (
+	ReportReasonHarassment     = "harassment"
+	ReportReasonHate           = "hate"
+	ReportReasonSelfHarm       = "self_harm"
+	ReportReasonMisinformation = "misinformation"
+	ReportReasonSpam           = "spam"
+	ReportReasonPrivacy        = "privacy"
+	ReportReasonOther          = "other"
+)
+
+// reportReasonWeights rank report reasons by urgency. A case's priority is
+// the weight of its most urgent reason plus reportWeight per further report.
+var reportReasonWeights = map[string]int{
+	ReportReasonSelfHarm:       100,
+	ReportReasonHarassment:     60,
+	ReportReasonHate:           60,
+	ReportReasonMisinformation: 50,
+	ReportReasonPrivacy:        40,
+	ReportReasonSpam:           20,
+	ReportReasonOther:          10,
+}
+
+const reportWeight = 10
+
+// IsReportReason reports whether reason is a known report reason
+func IsReportReason(reason string) bool {
+	_, ok := reportReasonWeights[reason]
+	return ok
+}
+
+// Moderation case statuses
+const (
+	ModerationCaseOpen      = "open"
+	ModerationCaseActioned  = "actioned"
+	ModerationCaseDismissed = "dismissed"
+)
+
+// Moderation actions
+const (
+	ModerationActionHide    = "hide"
+	ModerationActionDelete  = "delete"
+	ModerationActionWarn    = "warn"
+	ModerationActionSuspend = "suspend"
+	ModerationActionDismiss = "dismiss"
+)
+
+// ModerationCase gathers the reports on one piece of content until a
+// moderator acts on it or dismisses them
+type ModerationCase struct {
+	ID              int        `json:"id"`
+	ContentType     string     `json:"content_type"`
+	ContentID       int        `json:"content_id"`
+	AuthorID        *int       `json:"author_id,omitempty"`
+	ContentSnapshot string     `json:"content_snapshot"`
+	Reason          string     `json:"reason"`
+	Status          string     `json:"status"`
+	Priority        int        `json:"priority"`
+	ReportCount     int        `json:"report_count"`
+	Action          string     `json:"action,omitempty"`
+	ResolutionNote  string     `json:"resolution_note,omitempty"`
+	ResolvedBy      *int       `json:"resolved_by,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// ContentReport is one user's report of a piece of content
+type ContentReport struct {
+	ID         int       `json:"id"`
+	CaseID     int       `json:"case_id"`
+	ReporterID int       `json:"reporter_id"`
+	Reason     string    `json:"reason"`
+	Details    string    `json:"details,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+const moderationCaseColumns = `id, content_type, content_id, author_id, content_snapshot, reason, status, priority, report_count,
+	COALESCE(action, ''), COALESCE(resolution_note, ''), resolved_by, resolved_at, created_at, updated_at`
+
+// scanModerationCase scans a row selected with moderationCaseColumns
+func scanModerationCase(row interface{ Scan(...interface{}) error }, c *ModerationCase) error {
+	return row.Scan(
+		&c.ID, &c.ContentType, &c.ContentID, &c.AuthorID, &c.ContentSnapshot, &c.Reason, &c.Status, &c.Priority, &c.ReportCount,
+		&c.Action, &c.ResolutionNote, &c.ResolvedBy, &c.ResolvedAt, &c.CreatedAt, &c.UpdatedAt,
+	)
+}
+
+// AddContentReport files a report on a piece of content, opening a case for
+// it unless one is open already, and raises the case's priority. It reports
+// false when the reporter already reported the content in the open case.
+func AddContentReport(database *sql.DB, item *ContentItem, reporterID int, reason, details string) (*ModerationCase, bool, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := db.TxExec(tx,
+		`INSERT INTO moderation_cases (content_type, content_id, author_id, content_snapshot, reason, status, priority)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (content_type, content_id) WHERE status = 'open' DO NOTHING`,
+		item.Type, item.ID, item.AuthorID, item.Content, reason, ModerationCaseOpen, reportReasonWeights[reason],
+	); err != nil {
+		return nil, false, err
+	}
+
+	openCase := &ModerationCase{}
+	if err := scanModerationCase(tx.QueryRow(db.Placeholder(
+		`SELECT `+moderationCaseColumns+` FROM moderation_cases WHERE content_type = ? AND content_id = ? AND status = ?`),
+		item.Type, item.ID, ModerationCaseOpen,
+	), openCase); err != nil {
+		return nil, false, err
+	}
+
+	result, err := db.TxExec(tx,
+		`INSERT INTO content_reports (case_id, reporter_id, reason, details) VALUES (?, ?, ?, NULLIF(?, ''))
+		ON CONFLICT (case_id, reporter_id) DO NOTHING`,
+		openCase.ID, reporterID, reason, details,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+	if affected == 0 {
+		return openCase, false, nil
+	}
+
+	if reportReasonWeights[reason] > reportReasonWeights[openCase.Reason] {
+		openCase.Reason = reason
+	}
+	openCase.ReportCount++
+	openCase.Priority = reportReasonWeights[openCase.Reason] + reportWeight*(openCase.ReportCount-1)
+	if _, err := db.TxExec(tx,
+		`UPDATE moderation_cases SET reason = ?, report_count = ?, priority = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		openCase.Reason, openCase.ReportCount, openCase.Priority, openCase.ID,
+	); err != nil {
+		return nil, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return openCase, true, nil
+}
+
+// GetModerationCase retrieves a moderation case by ID
+func GetModerationCase(database *sql.DB, caseID int) (*ModerationCase, error) {
+	moderationCase := &ModerationCase{}
+	err := scanModerationCase(db.QueryRow(database,
+		`SELECT `+moderationCaseColumns+` FROM moderation_cases WHERE id = ?`, caseID,
+	), moderationCase)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return moderationCase, nil
+}
+
+// GetModerationQueue returns cases with a status. Open cases come most
+// urgent first; resolved ones most recently resolved first.
+func GetModerationQueue(database *sql.DB, status string, limit int) ([]ModerationCase, error) {
+	order := `resolved_at DESC, id DESC`
+	if status == ModerationCaseOpen {
+		order = `priority DESC, created_at ASC, id ASC`
+	}
+
+	rows, err := db.Query(database,
+		`SELECT `+moderationCaseColumns+` FROM moderation_cases WHERE status = ? ORDER BY `+order+` LIMIT ?`,
+		status, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cases := []ModerationCase{}
+	for rows.Next() {
+		var moderationCase ModerationCase
+		if err := scanModerationCase(rows, &moderationCase); err != nil {
+			return nil, err
+		}
+		cases = append(cases, moderationCase)
+	}
+	return cases, rows.Err()
+}
+
+// GetContentReports returns the reports of a case, oldest first
+func GetContentReports(database *sql.DB, caseID int) ([]ContentReport, error) {
+	rows, err := db.Query(database,
+		`SELECT id, case_id, reporter_id, reason, COALESCE(details, ''), created_at
+		FROM content_reports WHERE case_id = ? ORDER BY id ASC`,
+		caseID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []ContentReport{}
+	for rows.Next() {
+		var report ContentReport
+		if err := rows.Scan(&report.ID, &report.CaseID, &report.ReporterID, &report.Reason, &report.Details, &report.CreatedAt); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// ResolveModerationCase records a moderator's decision on an open case. It
+// reports false when the case was no longer open.
+func ResolveModerationCase(database *sql.DB, moderationCase *ModerationCase, action, note string, moderatorID int) (bool, error) {
+	status := ModerationCaseActioned
+	if action == ModerationActionDismiss {
+		status = ModerationCaseDismissed
+	}
+
+	result, err := db.Exec(database,
+		`UPDATE moderation_cases
+		SET status = ?, action = ?, resolution_note = NULLIF(?, ''), resolved_by = ?, resolved_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		status, action, note, moderatorID, moderationCase.ID, ModerationCaseOpen,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	moderationCase.Status = status
+	moderationCase.Action = action
+	moderationCase.ResolutionNote = note
+	moderationCase.ResolvedBy = &moderatorID
+	return true, nil
+}
+
+// SetContentHidden hides a piece of content from listings or shows it
+// again. It reports false when the content does not exist.
+func SetContentHidden(database *sql.DB, contentType string, contentID int, hidden bool) (bool, error) {
+	source, ok := contentTables[contentType]
+	if !ok {
+		return false, nil
+	}
+
+	query := `UPDATE ` + source.table + ` SET hidden_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if !hidden {
+		query = `UPDATE ` + source.table + ` SET hidden_at = NULL WHERE id = ?`
+	}
+	result, err := db.Exec(database, query, contentID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CanViewContent reports whether a user can see a piece of content: posts
+// and their comments by the post's privacy, group content by group
+// membership and messages by being their recipient or a group member
+func CanViewContent(database *sql.DB, item *ContentItem, userID int) (bool, error) {
+	if item.AuthorID == userID {
+		return true, nil
+	}
+
+	var err error
+	switch item.Type {
+	case ContentTypePost, ContentTypeComment:
+		postID := item.ID
+		if item.Type == ContentTypeComment {
+			err = db.QueryRow(database, `SELECT post_id FROM comments WHERE id = ?`, item.ID).Scan(&postID)
+			if err != nil {
+				break
+			}
+		}
+		post := &Post{ID: postID}
+		err = db.QueryRow(database, `SELECT user_id, privacy FROM posts WHERE id = ?`, postID).Scan(&post.UserID, &post.Privacy)
+		if err != nil {
+			break
+		}
+		return CanViewPost(database, post, userID)
+
+	case ContentTypeGroupPost, ContentTypeGroupComment:
+		var groupID int
+		if item.Type == ContentTypeGroupPost {
+			err = db.QueryRow(database, `SELECT group_id FROM group_posts WHERE id = ?`, item.ID).Scan(&groupID)
+		} else {
+			err = db.QueryRow(database,
+				`SELECT gp.group_id FROM group_post_comments c JOIN group_posts gp ON gp.id = c.group_post_id WHERE c.id = ?`,
+				item.ID,
+			).Scan(&groupID)
+		}
+		if err != nil {
+			break
+		}
+		return IsGroupMember(database, groupID, userID)
+
+	case ContentTypeMessage:
+		var receiverID, groupID sql.NullInt64
+		err = db.QueryRow(database, `SELECT receiver_id, group_id FROM messages WHERE id = ?`, item.ID).Scan(&receiverID, &groupID)
+		if err != nil {
+			break
+		}
+		if receiverID.Valid {
+			return int(receiverID.Int64) == userID, nil
+		}
+		return IsGroupMember(database, int(groupID.Int64), userID)
+	}
+
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return false, err
+}
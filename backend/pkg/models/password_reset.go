@@ -0,0 +1,107 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+)
+
+// PasswordResetToken is a single-use token letting a user choose a new
+// password. Only the SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	ID          int
+	UserID      int
+	TokenHash   string
+	RequestedIP string
+	ExpiresAt   time.Time
+	UsedAt      *time.Time
+	CreatedAt   time.Time
+}
+
+// CreatePasswordResetToken stores the hash of a new reset token
+func CreatePasswordResetToken(database *sql.DB, userID int, tokenHash, requestedIP string, expiresAt time.Time) error {
+	_, err := db.Exec(database,
+		`INSERT INTO password_reset_tokens (user_id, token_hash, requested_ip, expires_at) VALUES (?, ?, ?, ?)`,
+		userID, tokenHash, requestedIP, expiresAt.UTC(),
+	)
+	return err
+}
+
+// GetPasswordResetToken retrieves a reset token by its hash
+func GetPasswordResetToken(database *sql.DB, tokenHash string) (*PasswordResetToken, error) {
+	token := &PasswordResetToken{}
+	err := db.QueryRow(database,
+		`SELECT id, user_id, token_hash, COALESCE(requested_ip, ''), expires_at, used_at, created_at
+		FROM password_reset_tokens WHERE token_hash = ?`,
+		tokenHash,
+	).Scan(&token.ID, &token.UserID, &token.TokenHash, &token.RequestedIP, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// CountPasswordResetTokensSince counts the reset tokens issued to a user
+// since a time
+func CountPasswordResetTokensSince(database *sql.DB, userID int, since time.Time) (int, error) {
+	var count int
+	err := db.QueryRow(database,
+		`SELECT COUNT(*) FROM password_reset_tokens WHERE user_id = ? AND created_at >= ?`,
+		userID, since.UTC(),
+	).Scan(&count)
+	return count, err
+}
+
+// ResetPassword uses a reset token to replace its user's password. In the
+// same transaction every other unused token of the user is spent and all of
+// their sessions are deleted, signing them out everywhere; the IDs of the
+// deleted sessions are returned. It reports false when the token was already
+// used.
+func ResetPassword(database *sql.DB, token *PasswordResetToken, passwordHash string) (bool, []int, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return false, nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	result, err := db.TxExec(tx,
+		`UPDATE password_reset_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`,
+		now, token.ID,
+	)
+	if err != nil {
+		return false, nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, nil, err
+	}
+	if affected == 0 {
+		return false, nil, nil
+	}
+
+	for _, statement := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE users SET password = ?, updated_at = ? WHERE id = ?`, []interface{}{passwordHash, now, token.UserID}},
+		{`UPDATE password_reset_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL`, []interface{}{now, token.UserID}},
+	} {
+		if _, err := db.TxExec(tx, statement.query, statement.args...); err != nil {
+			return false, nil, err
+		}
+	}
+	sessionIDs, err := deleteUserSessions(tx, token.UserID)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, nil, err
+	}
+	return true, sessionIDs, nil
+}
@@ -6,21 +6,24 @@ import (
 	"time"
 
 	"github.com/On-cure/Oncure/pkg/db"
+	"github.com/On-cure/Oncure/pkg/money"
 )
 
 type Post struct {
-	ID            int       `json:"id"`
-	UserID        int       `json:"user_id"`
-	Content       string    `json:"content"`
-	ImageURL      string    `json:"image_url,omitempty"`
-	Privacy       string    `json:"privacy"`
-	LikeCount     int       `json:"like_count"`
-	DislikeCount  int       `json:"dislike_count"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	User          *User     `json:"user,omitempty"`
-	Comments      []Comment `json:"comments,omitempty"`
-	SelectedUsers []int     `json:"selected_users,omitempty"`
+	ID            int            `json:"id"`
+	UserID        int            `json:"user_id"`
+	Content       string         `json:"content"`
+	ImageURL      string         `json:"image_url,omitempty"`
+	Privacy       string         `json:"privacy"`
+	LikeCount     int            `json:"like_count"`
+	DislikeCount  int            `json:"dislike_count"`
+	TipTotal      money.Tinybars `json:"tip_total"`
+	TipCount      int            `json:"tip_count"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	User          *User          `json:"user,omitempty"`
+	Comments      []Comment      `json:"comments,omitempty"`
+	SelectedUsers []int          `json:"selected_users,omitempty"`
 }
 
 // CreatePost creates a new post
@@ -86,14 +89,14 @@ func GetPostById(database *sql.DB, postId int, currentUserId int) (*Post, error)
 	// Get post data
 	err := db.QueryRow(database,
 		`SELECT p.id, p.user_id, p.content, p.image_url, p.privacy, 
-		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, 
+		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, COALESCE(p.tip_total_tinybars, 0) as tip_total, COALESCE(p.tip_count, 0) as tip_count, 
 		p.created_at, p.updated_at
 		FROM posts p
-		WHERE p.id = ?`,
-		postId,
+		WHERE p.id = ? AND (p.hidden_at IS NULL OR p.user_id = ?)`,
+		postId, currentUserId,
 	).Scan(
 		&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
-		&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt,
+		&post.LikeCount, &post.DislikeCount, &post.TipTotal, &post.TipCount, &post.CreatedAt, &post.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -148,12 +151,12 @@ func GetFeedPosts(database *sql.DB, userId int, page, limit int, privacy []strin
 	// Build query based on privacy settings
 	query := `
 		SELECT p.id, p.user_id, p.content, p.image_url, p.privacy, 
-		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, 
+		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, COALESCE(p.tip_total_tinybars, 0) as tip_total, COALESCE(p.tip_count, 0) as tip_count, 
 		p.created_at, p.updated_at,
 		u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname, u.role, u.verification_status
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
-		WHERE 
+		WHERE p.hidden_at IS NULL AND (
 			(p.privacy = 'public') OR
 			(p.privacy = 'almost_private' AND p.user_id = ?) OR
 			(p.privacy = 'almost_private' AND EXISTS (
@@ -165,11 +168,15 @@ func GetFeedPosts(database *sql.DB, userId int, page, limit int, privacy []strin
 				SELECT 1 FROM post_privacy_users 
 				WHERE post_id = p.id AND user_id = ?
 			))
+		) AND ` + hiddenAuthorsFilter("p.user_id") + `
 		ORDER BY p.created_at DESC
 		LIMIT ? OFFSET ?
 	`
 
-	rows, err := db.Query(database, query, userId, userId, userId, userId, limit, offset)
+	args := []interface{}{userId, userId, userId, userId}
+	args = append(args, hiddenAuthorsArgs(userId)...)
+	args = append(args, limit, offset)
+	rows, err := db.Query(database, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -181,7 +188,7 @@ func GetFeedPosts(database *sql.DB, userId int, page, limit int, privacy []strin
 
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
-			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt,
+			&post.LikeCount, &post.DislikeCount, &post.TipTotal, &post.TipCount, &post.CreatedAt, &post.UpdatedAt,
 			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname, &user.Role, &user.VerificationStatus,
 		)
 		if err != nil {
@@ -281,6 +288,12 @@ func CanViewPost(database *sql.DB, post *Post, userId int) (bool, error) {
 		return true, nil
 	}
 
+	// Blocked users cannot see each other's posts
+	blocked, err := IsBlockedBetween(database, post.UserID, userId)
+	if err != nil || blocked {
+		return false, err
+	}
+
 	// Public posts can be viewed by anyone
 	if post.Privacy == "public" {
 		return true, nil
@@ -437,13 +450,13 @@ func GetCommentedPosts(database *sql.DB, userId int, page, limit int) ([]Post, e
 
 	query := `
 		SELECT DISTINCT p.id, p.user_id, p.content, p.image_url, p.privacy, 
-		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, 
+		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, COALESCE(p.tip_total_tinybars, 0) as tip_total, COALESCE(p.tip_count, 0) as tip_count, 
 		p.created_at, p.updated_at,
 		u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname, u.role, u.verification_status
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
 		JOIN comments c ON p.id = c.post_id
-		WHERE c.user_id = ?
+		WHERE c.user_id = ? AND p.hidden_at IS NULL
 		AND (
 			(p.privacy = 'public') OR
 			(p.privacy = 'almost_private' AND p.user_id = ?) OR
@@ -473,7 +486,7 @@ func GetCommentedPosts(database *sql.DB, userId int, page, limit int) ([]Post, e
 
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
-			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt,
+			&post.LikeCount, &post.DislikeCount, &post.TipTotal, &post.TipCount, &post.CreatedAt, &post.UpdatedAt,
 			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname, &user.Role, &user.VerificationStatus,
 		)
 		if err != nil {
@@ -539,13 +552,13 @@ func GetSavedPosts(database *sql.DB, userID, page, limit int) ([]Post, error) {
 
 	query := `
 		SELECT p.id, p.user_id, p.content, p.image_url, p.privacy, 
-		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, 
+		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, COALESCE(p.tip_total_tinybars, 0) as tip_total, COALESCE(p.tip_count, 0) as tip_count, 
 		p.created_at, p.updated_at,
 		u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname, u.role, u.verification_status
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
 		JOIN saved_posts sp ON p.id = sp.post_id
-		WHERE sp.user_id = ?
+		WHERE sp.user_id = ? AND p.hidden_at IS NULL
 		AND (
 			(p.privacy = 'public') OR
 			(p.privacy = 'almost_private' AND p.user_id = ?) OR
@@ -575,7 +588,7 @@ func GetSavedPosts(database *sql.DB, userID, page, limit int) ([]Post, error) {
 
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
-			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt,
+			&post.LikeCount, &post.DislikeCount, &post.TipTotal, &post.TipCount, &post.CreatedAt, &post.UpdatedAt,
 			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname, &user.Role, &user.VerificationStatus,
 		)
 		if err != nil {
@@ -596,13 +609,13 @@ func GetLikedPosts(database *sql.DB, userId int, page, limit int) ([]Post, error
 
 	query := `
 		SELECT p.id, p.user_id, p.content, p.image_url, p.privacy, 
-		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, 
+		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, COALESCE(p.tip_total_tinybars, 0) as tip_total, COALESCE(p.tip_count, 0) as tip_count, 
 		p.created_at, p.updated_at,
 		u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname, u.role, u.verification_status
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
 		JOIN post_reactions pr ON p.id = pr.post_id
-		WHERE pr.user_id = ? AND pr.reaction_type = 'like'
+		WHERE pr.user_id = ? AND pr.reaction_type = 'like' AND p.hidden_at IS NULL
 		AND (
 			(p.privacy = 'public') OR
 			(p.privacy = 'almost_private' AND p.user_id = ?) OR
@@ -632,7 +645,7 @@ func GetLikedPosts(database *sql.DB, userId int, page, limit int) ([]Post, error
 
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
-			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt,
+			&post.LikeCount, &post.DislikeCount, &post.TipTotal, &post.TipCount, &post.CreatedAt, &post.UpdatedAt,
 			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname, &user.Role, &user.VerificationStatus,
 		)
 		if err != nil {
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+// Kinds of ledger drift found by reconciliation
+const (
+	// DriftBalance is a ledger balance that transfers do not explain
+	DriftBalance = "balance"
+	// DriftUnrecordedTransaction is a ledger transaction with no transfer row
+	DriftUnrecordedTransaction = "unrecorded_transaction"
+	// DriftAmountMismatch is a transfer whose amount differs from the ledger
+	DriftAmountMismatch = "amount_mismatch"
+	// DriftStatusMismatch is a failed transfer that succeeded on the ledger
+	DriftStatusMismatch = "status_mismatch"
+)
+
+// LedgerDrift is a difference found between the ledger and the transfers table
+type LedgerDrift struct {
+	ID              int             `json:"id"`
+	UserID          int             `json:"user_id"`
+	HederaAccountID string          `json:"hedera_account_id"`
+	Kind            string          `json:"kind"`
+	TransactionID   string          `json:"transaction_id,omitempty"`
+	TransferID      *int            `json:"transfer_id,omitempty"`
+	Expected        *money.Tinybars `json:"expected,omitempty"`
+	Actual          *money.Tinybars `json:"actual,omitempty"`
+	Detail          string          `json:"detail,omitempty"`
+	DetectedAt      time.Time       `json:"detected_at"`
+}
+
+// RecordLedgerDrift stores a drift finding. Findings about a transaction are
+// stored once; it reports false when the same finding already exists.
+func RecordLedgerDrift(database *sql.DB, drift LedgerDrift) (bool, error) {
+	if drift.TransactionID != "" {
+		var exists bool
+		err := db.QueryRow(database,
+			`SELECT EXISTS(SELECT 1 FROM ledger_drift WHERE user_id = ? AND kind = ? AND transaction_id = ?)`,
+			drift.UserID, drift.Kind, drift.TransactionID,
+		).Scan(&exists)
+		if err != nil || exists {
+			return false, err
+		}
+	}
+
+	_, err := db.Exec(database,
+		`INSERT INTO ledger_drift (user_id, hedera_account_id, kind, transaction_id, transfer_id, expected_tinybars, actual_tinybars, detail)
+		VALUES (?, ?, ?, NULLIF(?, ''), ?, ?, ?, NULLIF(?, ''))`,
+		drift.UserID, drift.HederaAccountID, drift.Kind, drift.TransactionID, drift.TransferID,
+		drift.Expected, drift.Actual, drift.Detail,
+	)
+	return err == nil, err
+}
+
+// GetLedgerDrift returns the most recent drift findings, optionally for one user
+func GetLedgerDrift(database *sql.DB, userID int, limit int) ([]LedgerDrift, error) {
+	query := `SELECT id, user_id, hedera_account_id, kind, COALESCE(transaction_id, ''), transfer_id,
+		expected_tinybars, actual_tinybars, COALESCE(detail, ''), detected_at
+		FROM ledger_drift`
+	args := []interface{}{}
+	if userID > 0 {
+		query += ` WHERE user_id = ?`
+		args = append(args, userID)
+	}
+	query += ` ORDER BY detected_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(database, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drift := []LedgerDrift{}
+	for rows.Next() {
+		var d LedgerDrift
+		if err := rows.Scan(
+			&d.ID, &d.UserID, &d.HederaAccountID, &d.Kind, &d.TransactionID, &d.TransferID,
+			&d.Expected, &d.Actual, &d.Detail, &d.DetectedAt,
+		); err != nil {
+			return nil, err
+		}
+		drift = append(drift, d)
+	}
+	return drift, rows.Err()
+}
+
+// GetWalletsAfter returns up to limit wallets with an ID greater than afterID, in ID order
+func GetWalletsAfter(database *sql.DB, afterID, limit int) ([]UserWallet, error) {
+	rows, err := db.Query(database,
+		`SELECT `+walletColumns+` FROM user_wallets WHERE id > ? ORDER BY id ASC LIMIT ?`,
+		afterID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var wallets []UserWallet
+	for rows.Next() {
+		var w UserWallet
+		if err := scanWallet(rows, &w); err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, w)
+	}
+	return wallets, rows.Err()
+}
+
+// GetWalletByAccountID retrieves the custodial wallet of a Hedera account
+func GetWalletByAccountID(database *sql.DB, accountID string) (*UserWallet, error) {
+	w := &UserWallet{}
+	err := scanWallet(db.QueryRow(database,
+		`SELECT `+walletColumns+` FROM user_wallets WHERE hedera_account_id = ?`,
+		accountID,
+	), w)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return w, nil
+}
+
+// MarkWalletReconciled stores the consensus timestamp reconciliation has
+// reached for a wallet
+func MarkWalletReconciled(database *sql.DB, walletID int, cursor string) error {
+	_, err := db.Exec(database,
+		`UPDATE user_wallets SET mirror_cursor = NULLIF(?, ''), reconciled_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		cursor, walletID,
+	)
+	return err
+}
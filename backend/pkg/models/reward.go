@@ -0,0 +1,433 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+)
+
+// Reward run statuses
+const (
+	RewardRunPendingApproval = "pending_approval"
+	RewardRunApproved        = "approved"
+	RewardRunCompleted       = "completed"
+	RewardRunCancelled       = "cancelled"
+)
+
+// Reward allocation statuses. An allocation is submitted once a transaction
+// ID has been reserved for its payment and it may have reached the ledger,
+// and needs review when the outcome of that payment cannot be confirmed.
+const (
+	RewardAllocationPending     = "pending"
+	RewardAllocationSubmitted   = "submitted"
+	RewardAllocationCompleted   = "completed"
+	RewardAllocationFailed      = "failed"
+	RewardAllocationNeedsReview = "needs_review"
+)
+
+// RewardRun is one distribution of the community token to top contributors
+type RewardRun struct {
+	ID          int                `json:"id"`
+	PeriodStart time.Time          `json:"period_start"`
+	PeriodEnd   time.Time          `json:"period_end"`
+	Status      string             `json:"status"`
+	PoolUnits   int64              `json:"pool_units"`
+	TotalUnits  int64              `json:"total_units"`
+	ApprovedBy  *int               `json:"approved_by,omitempty"`
+	ApprovedAt  *time.Time         `json:"approved_at,omitempty"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Allocations []RewardAllocation `json:"allocations,omitempty"`
+}
+
+// RewardAllocation is one user's share of a reward run and the state of its payment
+type RewardAllocation struct {
+	ID            int            `json:"id"`
+	RunID         int            `json:"run_id"`
+	UserID        int            `json:"user_id"`
+	Score         int            `json:"score"`
+	Breakdown     map[string]int `json:"breakdown,omitempty"`
+	AmountUnits   int64          `json:"amount_units"`
+	Status        string         `json:"status"`
+	TransactionID string         `json:"transaction_id,omitempty"`
+	FailureReason string         `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	User          *User          `json:"user,omitempty"`
+	AuditEntry
+}
+
+const rewardRunColumns = `id, period_start, period_end, status, pool_units, total_units,
+	approved_by, approved_at, completed_at, created_at`
+
+const rewardAllocationColumns = `id, run_id, user_id, score, COALESCE(breakdown, ''), amount_units, status,
+	COALESCE(transaction_id, ''), COALESCE(failure_reason, ''), created_at, updated_at,
+	` + auditColumns
+
+// scanRewardRun scans a row selected with rewardRunColumns
+func scanRewardRun(row interface{ Scan(...interface{}) error }, run *RewardRun) error {
+	return row.Scan(
+		&run.ID, &run.PeriodStart, &run.PeriodEnd, &run.Status, &run.PoolUnits, &run.TotalUnits,
+		&run.ApprovedBy, &run.ApprovedAt, &run.CompletedAt, &run.CreatedAt,
+	)
+}
+
+// scanRewardAllocation scans a row selected with rewardAllocationColumns
+func scanRewardAllocation(row interface{ Scan(...interface{}) error }, allocation *RewardAllocation) error {
+	var breakdown string
+	err := row.Scan(
+		&allocation.ID, &allocation.RunID, &allocation.UserID, &allocation.Score, &breakdown,
+		&allocation.AmountUnits, &allocation.Status, &allocation.TransactionID, &allocation.FailureReason,
+		&allocation.CreatedAt, &allocation.UpdatedAt,
+		&allocation.AuditHash, &allocation.AuditTopicID, &allocation.AuditSequenceNumber,
+	)
+	if err != nil {
+		return err
+	}
+	if breakdown != "" {
+		json.Unmarshal([]byte(breakdown), &allocation.Breakdown)
+	}
+	return nil
+}
+
+// CreateRewardRun stores a run awaiting approval together with its allocations
+func CreateRewardRun(database *sql.DB, run RewardRun) (int, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var totalUnits int64
+	for _, allocation := range run.Allocations {
+		totalUnits += allocation.AmountUnits
+	}
+
+	var runID int64
+	if db.IsPostgreSQL() {
+		err = tx.QueryRow(
+			`INSERT INTO reward_runs (period_start, period_end, status, pool_units, total_units)
+			VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+			run.PeriodStart.UTC(), run.PeriodEnd.UTC(), RewardRunPendingApproval, run.PoolUnits, totalUnits,
+		).Scan(&runID)
+	} else {
+		var result sql.Result
+		result, err = tx.Exec(
+			`INSERT INTO reward_runs (period_start, period_end, status, pool_units, total_units)
+			VALUES (?, ?, ?, ?, ?)`,
+			run.PeriodStart.UTC(), run.PeriodEnd.UTC(), RewardRunPendingApproval, run.PoolUnits, totalUnits,
+		)
+		if err == nil {
+			runID, err = result.LastInsertId()
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for _, allocation := range run.Allocations {
+		breakdown, err := json.Marshal(allocation.Breakdown)
+		if err != nil {
+			return 0, err
+		}
+		_, err = db.TxExec(tx,
+			`INSERT INTO reward_allocations (run_id, user_id, score, breakdown, amount_units, status)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			runID, allocation.UserID, allocation.Score, string(breakdown), allocation.AmountUnits, RewardAllocationPending,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(runID), nil
+}
+
+// GetRewardRun retrieves a run with its allocations
+func GetRewardRun(database *sql.DB, runID int) (*RewardRun, error) {
+	run := &RewardRun{}
+	err := scanRewardRun(db.QueryRow(database, `SELECT `+rewardRunColumns+` FROM reward_runs WHERE id = ?`, runID), run)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	run.Allocations, err = GetRewardAllocations(database, runID)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// GetRewardRunByPeriod retrieves the run covering exactly the given period, without allocations
+func GetRewardRunByPeriod(database *sql.DB, periodStart, periodEnd time.Time) (*RewardRun, error) {
+	run := &RewardRun{}
+	err := scanRewardRun(db.QueryRow(database,
+		`SELECT `+rewardRunColumns+` FROM reward_runs WHERE period_start = ? AND period_end = ?`,
+		periodStart.UTC(), periodEnd.UTC(),
+	), run)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return run, nil
+}
+
+// GetRewardRuns lists runs, newest first, optionally filtered by status
+func GetRewardRuns(database *sql.DB, status string, limit int) ([]RewardRun, error) {
+	query := `SELECT ` + rewardRunColumns + ` FROM reward_runs`
+	args := []interface{}{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY period_end DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(database, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []RewardRun{}
+	for rows.Next() {
+		var run RewardRun
+		if err := scanRewardRun(rows, &run); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// GetRewardAllocations lists the allocations of a run, largest first
+func GetRewardAllocations(database *sql.DB, runID int) ([]RewardAllocation, error) {
+	rows, err := db.Query(database,
+		`SELECT `+rewardAllocationColumns+` FROM reward_allocations WHERE run_id = ? ORDER BY amount_units DESC, id ASC`,
+		runID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	allocations := []RewardAllocation{}
+	for rows.Next() {
+		var allocation RewardAllocation
+		if err := scanRewardAllocation(rows, &allocation); err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, allocation)
+	}
+	return allocations, rows.Err()
+}
+
+// ApproveRewardRun moves a run awaiting approval to approved. It reports
+// false when the run was not awaiting approval.
+func ApproveRewardRun(database *sql.DB, runID int, approvedBy int) (bool, error) {
+	result, err := db.Exec(database,
+		`UPDATE reward_runs SET status = ?, approved_by = ?, approved_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		RewardRunApproved, approvedBy, runID, RewardRunPendingApproval,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CompleteRewardRunIfSettled marks an approved run completed once none of its
+// allocations are still waiting to be paid, confirmed or reviewed
+func CompleteRewardRunIfSettled(database *sql.DB, runID int) error {
+	_, err := db.Exec(database,
+		`UPDATE reward_runs SET status = ?, completed_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ? AND NOT EXISTS (
+			SELECT 1 FROM reward_allocations WHERE run_id = ? AND status IN (?, ?, ?)
+		)`,
+		RewardRunCompleted, runID, RewardRunApproved, runID,
+		RewardAllocationPending, RewardAllocationSubmitted, RewardAllocationNeedsReview,
+	)
+	return err
+}
+
+// MarkRewardAllocationSubmitted claims a pending allocation for payment under
+// a reserved transaction ID. It reports false when another payer claimed it first.
+func MarkRewardAllocationSubmitted(database *sql.DB, allocationID int, transactionID string) (bool, error) {
+	result, err := db.Exec(database,
+		`UPDATE reward_allocations SET status = ?, transaction_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		RewardAllocationSubmitted, transactionID, allocationID, RewardAllocationPending,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// MarkRewardAllocationCompleted moves a submitted allocation to completed. It
+// reports false when the allocation was no longer submitted.
+func MarkRewardAllocationCompleted(database *sql.DB, allocationID int) (bool, error) {
+	result, err := db.Exec(database,
+		`UPDATE reward_allocations SET status = ?, failure_reason = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		RewardAllocationCompleted, allocationID, RewardAllocationSubmitted,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// MarkRewardAllocationFailed moves a pending or submitted allocation to failed with a reason
+func MarkRewardAllocationFailed(database *sql.DB, allocationID int, reason string) error {
+	_, err := db.Exec(database,
+		`UPDATE reward_allocations SET status = ?, failure_reason = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status IN (?, ?)`,
+		RewardAllocationFailed, reason, allocationID, RewardAllocationPending, RewardAllocationSubmitted,
+	)
+	return err
+}
+
+// MarkRewardAllocationNeedsReview moves a submitted allocation to
+// needs_review with the reason its payment's outcome is unknown
+func MarkRewardAllocationNeedsReview(database *sql.DB, allocationID int, reason string) error {
+	_, err := db.Exec(database,
+		`UPDATE reward_allocations SET status = ?, failure_reason = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		RewardAllocationNeedsReview, reason, allocationID, RewardAllocationSubmitted,
+	)
+	return err
+}
+
+// GetStaleSubmittedRewardAllocations returns submitted allocations last updated before the cutoff
+func GetStaleSubmittedRewardAllocations(database *sql.DB, updatedBefore time.Time, limit int) ([]RewardAllocation, error) {
+	rows, err := db.Query(database,
+		`SELECT `+rewardAllocationColumns+` FROM reward_allocations
+		WHERE status = ? AND updated_at < ?
+		ORDER BY updated_at ASC
+		LIMIT ?`,
+		RewardAllocationSubmitted, updatedBefore.UTC(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allocations []RewardAllocation
+	for rows.Next() {
+		var allocation RewardAllocation
+		if err := scanRewardAllocation(rows, &allocation); err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, allocation)
+	}
+	return allocations, rows.Err()
+}
+
+// RewardActivityCount is the number of qualifying actions a user took. Day is
+// set for actions that are capped per day and empty for per-window totals.
+type RewardActivityCount struct {
+	UserID int
+	Day    string
+	Count  int
+}
+
+// GetDailyPostCounts counts the posts each wallet holder created per day in
+// [start, end), from their post_created activities on posts that still exist
+func GetDailyPostCounts(database *sql.DB, start, end time.Time) ([]RewardActivityCount, error) {
+	return queryRewardActivityCounts(database,
+		`SELECT a.user_id, DATE(a.created_at) AS day, COUNT(*)
+		FROM user_activities a
+		JOIN user_wallets w ON w.user_id = a.user_id
+		JOIN posts p ON p.id = a.target_id AND p.user_id = a.user_id
+		WHERE a.activity_type = 'post_created' AND a.created_at >= ? AND a.created_at < ?
+		GROUP BY a.user_id, DATE(a.created_at)`,
+		start.UTC(), end.UTC(),
+	)
+}
+
+// GetDailyCommentCounts counts the comments of at least minLength characters
+// each wallet holder left on other users' posts per day in [start, end)
+func GetDailyCommentCounts(database *sql.DB, start, end time.Time, minLength int) ([]RewardActivityCount, error) {
+	return queryRewardActivityCounts(database,
+		`SELECT c.user_id, DATE(c.created_at) AS day, COUNT(*)
+		FROM comments c
+		JOIN user_wallets w ON w.user_id = c.user_id
+		JOIN posts p ON p.id = c.post_id
+		WHERE p.user_id <> c.user_id AND LENGTH(TRIM(c.content)) >= ?
+			AND c.created_at >= ? AND c.created_at < ?
+		GROUP BY c.user_id, DATE(c.created_at)`,
+		minLength, start.UTC(), end.UTC(),
+	)
+}
+
+// GetDistinctLikersCounts counts, for each wallet holder, the distinct other
+// users who liked their posts or comments in [start, end)
+func GetDistinctLikersCounts(database *sql.DB, start, end time.Time) ([]RewardActivityCount, error) {
+	return queryRewardActivityCounts(database,
+		`SELECT likes.author_id, '' AS day, COUNT(DISTINCT likes.liker_id)
+		FROM (
+			SELECT p.user_id AS author_id, r.user_id AS liker_id
+			FROM post_reactions r
+			JOIN posts p ON p.id = r.post_id
+			WHERE r.reaction_type = 'like' AND r.user_id <> p.user_id
+				AND r.created_at >= ? AND r.created_at < ?
+			UNION ALL
+			SELECT c.user_id AS author_id, r.user_id AS liker_id
+			FROM comment_reactions r
+			JOIN comments c ON c.id = r.comment_id
+			WHERE r.reaction_type = 'like' AND r.user_id <> c.user_id
+				AND r.created_at >= ? AND r.created_at < ?
+		) likes
+		JOIN user_wallets w ON w.user_id = likes.author_id
+		GROUP BY likes.author_id`,
+		start.UTC(), end.UTC(), start.UTC(), end.UTC(),
+	)
+}
+
+// queryRewardActivityCounts runs a query selecting user ID, day and count
+func queryRewardActivityCounts(database *sql.DB, query string, args ...interface{}) ([]RewardActivityCount, error) {
+	rows, err := db.Query(database, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []RewardActivityCount
+	for rows.Next() {
+		var count RewardActivityCount
+		if err := rows.Scan(&count.UserID, &count.Day, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// GetRewardAllocationByID retrieves a reward allocation by ID
+func GetRewardAllocationByID(database *sql.DB, allocationID int) (*RewardAllocation, error) {
+	allocation := &RewardAllocation{}
+	err := scanRewardAllocation(db.QueryRow(database,
+		`SELECT `+rewardAllocationColumns+` FROM reward_allocations WHERE id = ?`, allocationID,
+	), allocation)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return allocation, nil
+}
@@ -7,41 +7,77 @@ import (
 	"github.com/On-cure/Oncure/pkg/db"
 )
 
+// SessionTouchInterval is how often a session's last-seen time, IP address,
+// user agent and sliding expiry are updated while it is used
+const SessionTouchInterval = time.Minute
+
+// Session is a signed-in device. Only the SHA-256 hash of its token is
+// stored. ExpiresAt slides forward while the session is used, up to
+// AbsoluteExpiresAt.
 type Session struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	Token     string    `json:"token"`
-	CreatedAt time.Time `json:"created_at"`
-	ExpiresAt time.Time `json:"expires_at"`
+	ID                int       `json:"id"`
+	UserID            int       `json:"user_id"`
+	TokenHash         string    `json:"-"`
+	UserAgent         string    `json:"user_agent"`
+	IPAddress         string    `json:"ip_address"`
+	CreatedAt         time.Time `json:"created_at"`
+	LastSeenAt        time.Time `json:"last_seen_at"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	AbsoluteExpiresAt time.Time `json:"absolute_expires_at"`
+	// RotationDue is set when the user's privileges changed; the next
+	// request replaces the token
+	RotationDue bool `json:"-"`
+}
+
+// Expired reports whether a session can no longer be used
+func (s *Session) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt) || !now.Before(s.AbsoluteExpiresAt)
 }
 
-// CreateSession creates a new session for a user
-func CreateSession(database *sql.DB, userId int, token string) error {
-	// Set expiration to 7 days from now
-	expiresAt := time.Now().Add(7 * 24 * time.Hour)
+// sessionColumns are the columns scanned by scanSession
+const sessionColumns = `id, user_id, token_hash, COALESCE(user_agent, ''), COALESCE(ip_address, ''),
+	created_at, last_seen_at, expires_at, absolute_expires_at, rotation_due`
+
+// scanSession scans a row selected with sessionColumns
+func scanSession(row interface{ Scan(...interface{}) error }, session *Session) error {
+	var lastSeenAt, absoluteExpiresAt *time.Time
+	err := row.Scan(&session.ID, &session.UserID, &session.TokenHash, &session.UserAgent, &session.IPAddress,
+		&session.CreatedAt, &lastSeenAt, &session.ExpiresAt, &absoluteExpiresAt, &session.RotationDue)
+	if err != nil {
+		return err
+	}
+	session.LastSeenAt = session.CreatedAt
+	if lastSeenAt != nil {
+		session.LastSeenAt = *lastSeenAt
+	}
+	session.AbsoluteExpiresAt = session.ExpiresAt
+	if absoluteExpiresAt != nil {
+		session.AbsoluteExpiresAt = *absoluteExpiresAt
+	}
+	return nil
+}
 
-	_, err := db.Exec(database, `INSERT INTO sessions (user_id, token, expires_at) VALUES (?, ?, ?)`, userId, token, expiresAt)
+// CreateSession stores a new session for a user signing in from a device
+func CreateSession(database *sql.DB, userID int, tokenHash, userAgent, ipAddress string, expiresAt, absoluteExpiresAt time.Time) error {
+	_, err := db.Exec(database,
+		`INSERT INTO sessions (user_id, token_hash, user_agent, ip_address, last_seen_at, expires_at, absolute_expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID, tokenHash, userAgent, ipAddress, time.Now().UTC(), expiresAt.UTC(), absoluteExpiresAt.UTC(),
+	)
 	return err
 }
 
-// GetSessionByToken retrieves a session by token
-func GetSessionByToken(database *sql.DB, token string) (*Session, error) {
+// GetSessionByTokenHash retrieves a session by the hash of its token,
+// whether or not it has expired
+func GetSessionByTokenHash(database *sql.DB, tokenHash string) (*Session, error) {
 	session := &Session{}
-	err := db.QueryRow(database, `SELECT id, user_id, token, created_at, expires_at FROM sessions WHERE token = ?`, token).Scan(&session.ID, &session.UserID, &session.Token, &session.CreatedAt, &session.ExpiresAt)
+	err := scanSession(db.QueryRow(database, `SELECT `+sessionColumns+` FROM sessions WHERE token_hash = ?`, tokenHash), session)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
-
-	// Check if session is expired
-	if session.ExpiresAt.Before(time.Now()) {
-		// Delete expired session
-		_, _ = db.Exec(database, "DELETE FROM sessions WHERE id = ?", session.ID)
-		return nil, nil
-	}
-
 	return session, nil
 }
 
@@ -51,8 +87,141 @@ func DeleteSession(database *sql.DB, sessionId int) error {
 	return err
 }
 
-// DeleteSessionByToken deletes a session by token
-func DeleteSessionByToken(database *sql.DB, token string) error {
-	_, err := db.Exec(database, "DELETE FROM sessions WHERE token = ?", token)
+// TouchSession records that a session was used from a device and slides its
+// expiry to expiresAt. The update is skipped when the session was already
+// seen within SessionTouchInterval, so busy sessions are written at most once
+// a minute.
+func TouchSession(database *sql.DB, session *Session, userAgent, ipAddress string, expiresAt time.Time) error {
+	now := time.Now().UTC()
+	if now.Sub(session.LastSeenAt) < SessionTouchInterval {
+		return nil
+	}
+	_, err := db.Exec(database,
+		`UPDATE sessions SET last_seen_at = ?, user_agent = ?, ip_address = ?, expires_at = ?
+		WHERE id = ? AND (last_seen_at IS NULL OR last_seen_at < ?)`,
+		now, userAgent, ipAddress, expiresAt.UTC(), session.ID, now.Add(-SessionTouchInterval),
+	)
+	return err
+}
+
+// RotateSessionToken replaces the token of a session and clears its
+// rotation_due flag. It reports false when the token was already replaced.
+func RotateSessionToken(database *sql.DB, sessionID int, oldTokenHash, newTokenHash string) (bool, error) {
+	result, err := db.Exec(database,
+		`UPDATE sessions SET token_hash = ?, rotation_due = ? WHERE id = ? AND token_hash = ?`,
+		newTokenHash, false, sessionID, oldTokenHash,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// requireSessionRotation marks every session of a user for a new token on
+// its next request, in the transaction changing the user's privileges
+func requireSessionRotation(tx *sql.Tx, userID int) error {
+	_, err := db.TxExec(tx, `UPDATE sessions SET rotation_due = ? WHERE user_id = ?`, true, userID)
 	return err
 }
+
+// DeleteExpiredSessions deletes the sessions expired at a time and returns
+// how many were deleted
+func DeleteExpiredSessions(database *sql.DB, now time.Time) (int64, error) {
+	result, err := db.Exec(database,
+		`DELETE FROM sessions WHERE expires_at <= ? OR absolute_expires_at <= ?`,
+		now.UTC(), now.UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetUserSessions lists a user's unexpired sessions, most recently used first
+func GetUserSessions(database *sql.DB, userID int) ([]Session, error) {
+	rows, err := db.Query(database,
+		`SELECT `+sessionColumns+` FROM sessions
+		WHERE user_id = ? AND expires_at > ? AND (absolute_expires_at IS NULL OR absolute_expires_at > ?)
+		ORDER BY COALESCE(last_seen_at, created_at) DESC, id DESC`,
+		userID, time.Now().UTC(), time.Now().UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		if err := scanSession(rows, &session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteUserSession deletes one of a user's sessions. It reports false when
+// the user has no such session.
+func DeleteUserSession(database *sql.DB, userID, sessionID int) (bool, error) {
+	result, err := db.Exec(database, `DELETE FROM sessions WHERE id = ? AND user_id = ?`, sessionID, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// DeleteUserSessions deletes every session of a user, signing them out
+// everywhere, and returns the IDs of the deleted sessions
+func DeleteUserSessions(database *sql.DB, userID int) ([]int, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids, err := deleteUserSessions(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// deleteUserSessions deletes every session of a user within a transaction
+// and returns the IDs of the deleted sessions, so the caller can close their
+// connections once it commits
+func deleteUserSessions(tx *sql.Tx, userID int) ([]int, error) {
+	rows, err := tx.Query(db.Placeholder(`SELECT id FROM sessions WHERE user_id = ?`), userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := db.TxExec(tx, `DELETE FROM sessions WHERE user_id = ?`, userID); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
@@ -0,0 +1,242 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+)
+
+// Staff roles. Admins can do everything on the admin API; moderators can
+// search users, suspend them and take content down.
+const (
+	StaffRoleAdmin     = "admin"
+	StaffRoleModerator = "moderator"
+)
+
+// UserSuspension keeps a user from signing in until it ends or is lifted.
+// A suspension without SuspendedUntil lasts until it is lifted.
+type UserSuspension struct {
+	ID             int        `json:"id"`
+	UserID         int        `json:"user_id"`
+	Reason         string     `json:"reason"`
+	SuspendedBy    *int       `json:"suspended_by,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	LiftedAt       *time.Time `json:"lifted_at,omitempty"`
+	LiftedBy       *int       `json:"lifted_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+const userSuspensionColumns = `id, user_id, reason, suspended_by, suspended_until, lifted_at, lifted_by, created_at`
+
+// scanUserSuspension scans a row selected with userSuspensionColumns
+func scanUserSuspension(row interface{ Scan(...interface{}) error }, s *UserSuspension) error {
+	return row.Scan(&s.ID, &s.UserID, &s.Reason, &s.SuspendedBy, &s.SuspendedUntil, &s.LiftedAt, &s.LiftedBy, &s.CreatedAt)
+}
+
+// GetStaffRole returns a user's staff role, or "" for users who are not staff
+func GetStaffRole(database *sql.DB, userID int) (string, error) {
+	var role string
+	err := db.QueryRow(database, `SELECT role FROM staff_roles WHERE user_id = ?`, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// SetStaffRole grants a user a staff role, replacing any role they had, and
+// has their sessions take new tokens. grantedBy is nil for roles granted
+// from configuration.
+func SetStaffRole(database *sql.DB, userID int, role string, grantedBy *int) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = db.TxExec(tx,
+		`INSERT INTO staff_roles (user_id, role, granted_by) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET role = excluded.role, granted_by = excluded.granted_by, created_at = CURRENT_TIMESTAMP`,
+		userID, role, grantedBy,
+	)
+	if err != nil {
+		return err
+	}
+	if err := requireSessionRotation(tx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveStaffRole takes a user's staff role away and has their sessions
+// take new tokens. It reports false when the user had none.
+func RemoveStaffRole(database *sql.DB, userID int) (bool, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := db.TxExec(tx, `DELETE FROM staff_roles WHERE user_id = ?`, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+	if err := requireSessionRotation(tx, userID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// GetStaffUserIDs returns the IDs of the users with one of the staff roles
+func GetStaffUserIDs(database *sql.DB, roles ...string) ([]int, error) {
+	if len(roles) == 0 {
+		return []int{}, nil
+	}
+	placeholders := make([]string, len(roles))
+	args := make([]interface{}, len(roles))
+	for i, role := range roles {
+		placeholders[i] = "?"
+		args[i] = role
+	}
+
+	rows, err := db.Query(database,
+		`SELECT user_id FROM staff_roles WHERE role IN (`+joinPlaceholders(placeholders)+`) ORDER BY user_id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []int{}
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// SuspendUser suspends a user and signs them out everywhere, returning the
+// IDs of the deleted sessions with the suspension. A suspension that is still
+// in force is lifted by the new one, so a user has at most one active
+// suspension.
+func SuspendUser(database *sql.DB, suspension UserSuspension) (*UserSuspension, []int, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := db.TxExec(tx,
+		`UPDATE user_suspensions SET lifted_at = CURRENT_TIMESTAMP, lifted_by = ?
+		WHERE user_id = ? AND lifted_at IS NULL`,
+		suspension.SuspendedBy, suspension.UserID,
+	); err != nil {
+		return nil, nil, err
+	}
+
+	var until interface{}
+	if suspension.SuspendedUntil != nil {
+		until = suspension.SuspendedUntil.UTC()
+	}
+	var suspensionID int64
+	if db.IsPostgreSQL() {
+		err = tx.QueryRow(
+			`INSERT INTO user_suspensions (user_id, reason, suspended_by, suspended_until)
+			VALUES ($1, $2, $3, $4) RETURNING id`,
+			suspension.UserID, suspension.Reason, suspension.SuspendedBy, until,
+		).Scan(&suspensionID)
+	} else {
+		var result sql.Result
+		result, err = tx.Exec(
+			`INSERT INTO user_suspensions (user_id, reason, suspended_by, suspended_until)
+			VALUES (?, ?, ?, ?)`,
+			suspension.UserID, suspension.Reason, suspension.SuspendedBy, until,
+		)
+		if err == nil {
+			suspensionID, err = result.LastInsertId()
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sessionIDs, err := deleteUserSessions(tx, suspension.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	created := &UserSuspension{}
+	err = scanUserSuspension(db.QueryRow(database,
+		`SELECT `+userSuspensionColumns+` FROM user_suspensions WHERE id = ?`, suspensionID,
+	), created)
+	if err != nil {
+		return nil, nil, err
+	}
+	return created, sessionIDs, nil
+}
+
+// LiftSuspension ends a user's active suspension early. It reports false
+// when the user was not suspended.
+func LiftSuspension(database *sql.DB, userID int, liftedBy int) (bool, error) {
+	result, err := db.Exec(database,
+		`UPDATE user_suspensions SET lifted_at = CURRENT_TIMESTAMP, lifted_by = ?
+		WHERE user_id = ? AND lifted_at IS NULL AND (suspended_until IS NULL OR suspended_until > ?)`,
+		liftedBy, userID, time.Now().UTC(),
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetActiveSuspension returns the suspension a user is under, or nil
+func GetActiveSuspension(database *sql.DB, userID int) (*UserSuspension, error) {
+	suspension := &UserSuspension{}
+	err := scanUserSuspension(db.QueryRow(database,
+		`SELECT `+userSuspensionColumns+` FROM user_suspensions
+		WHERE user_id = ? AND lifted_at IS NULL AND (suspended_until IS NULL OR suspended_until > ?)
+		ORDER BY id DESC LIMIT 1`,
+		userID, time.Now().UTC(),
+	), suspension)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return suspension, nil
+}
+
+// GetUserSuspensions returns a user's suspensions, newest first
+func GetUserSuspensions(database *sql.DB, userID int) ([]UserSuspension, error) {
+	rows, err := db.Query(database,
+		`SELECT `+userSuspensionColumns+` FROM user_suspensions WHERE user_id = ? ORDER BY id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suspensions := []UserSuspension{}
+	for rows.Next() {
+		var suspension UserSuspension
+		if err := scanUserSuspension(rows, &suspension); err != nil {
+			return nil, err
+		}
+		suspensions = append(suspensions, suspension)
+	}
+	return suspensions, rows.Err()
+}
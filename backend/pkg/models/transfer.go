@@ -0,0 +1,534 @@
+package models
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+// Transfer kinds. Deposits come from accounts outside the platform and are
+// found by reconciliation, so they have no sending user. Escrow payments go
+// into the platform escrow account and are released or refunded from it;
+// ExternalAccountID holds the escrow account, as the receiver of escrow
+// funding and the sender of releases and refunds.
+const (
+	TransferKindTransfer      = "transfer"
+	TransferKindDeposit       = "deposit"
+	TransferKindEscrowFund    = "escrow_fund"
+	TransferKindEscrowRelease = "escrow_release"
+	TransferKindEscrowRefund  = "escrow_refund"
+)
+
+// Transfer statuses. A transfer needs review when neither its receipt nor
+// the mirror node can tell whether it reached consensus, so an admin has to
+// check the ledger before it is retried or written off.
+const (
+	TransferStatusPending     = "pending"
+	TransferStatusCompleted   = "completed"
+	TransferStatusFailed      = "failed"
+	TransferStatusNeedsReview = "needs_review"
+)
+
+// Transfer represents a transfer record. FromUserID is 0 for deposits and
+// for escrow releases and refunds; ToUserID is 0 for escrow funding.
+type Transfer struct {
+	ID                int            `json:"id"`
+	Kind              string         `json:"kind"`
+	FromUserID        int            `json:"from_user_id"`
+	ExternalAccountID string         `json:"external_account_id,omitempty"`
+	ToUserID          int            `json:"to_user_id"`
+	Amount            money.Tinybars `json:"amount"`
+	TransactionID     string         `json:"transaction_id,omitempty"`
+	Status            string         `json:"status"`
+	IdempotencyKey    string         `json:"idempotency_key,omitempty"`
+	FailureReason     string         `json:"failure_reason,omitempty"`
+	PostID            *int           `json:"post_id,omitempty"`
+	CommentID         *int           `json:"comment_id,omitempty"`
+	EscrowID          *int           `json:"escrow_id,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	AuditEntry
+}
+
+const transferColumns = `id, kind, COALESCE(from_user_id, 0), COALESCE(external_account_id, ''), COALESCE(to_user_id, 0), amount_tinybars, COALESCE(transaction_id, ''), status,
+	COALESCE(idempotency_key, ''), COALESCE(failure_reason, ''), post_id, comment_id, escrow_id, created_at, updated_at,
+	` + auditColumns
+
+// scanTransfer scans a row selected with transferColumns
+func scanTransfer(row interface{ Scan(...interface{}) error }, t *Transfer) error {
+	return row.Scan(
+		&t.ID, &t.Kind, &t.FromUserID, &t.ExternalAccountID, &t.ToUserID, &t.Amount, &t.TransactionID, &t.Status,
+		&t.IdempotencyKey, &t.FailureReason, &t.PostID, &t.CommentID, &t.EscrowID, &t.CreatedAt, &t.UpdatedAt,
+		&t.AuditHash, &t.AuditTopicID, &t.AuditSequenceNumber,
+	)
+}
+
+// CreatePendingTransfer records a transfer before it is submitted to the ledger.
+// If the sender already used the idempotency key, or for escrow releases and
+// refunds the escrow already has a payout with it, the existing transfer is
+// returned instead and created is false. Deposits are not deduplicated here.
+func CreatePendingTransfer(database *sql.DB, transfer Transfer) (*Transfer, bool, error) {
+	existing, err := getTransferByKey(database, transfer)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+
+	if transfer.Kind == "" {
+		transfer.Kind = TransferKindTransfer
+	}
+
+	var transferID int64
+	if db.IsPostgreSQL() {
+		err = database.QueryRow(
+			`INSERT INTO transfers (kind, from_user_id, external_account_id, to_user_id, amount_tinybars, transaction_id, status, idempotency_key,
+				post_id, comment_id, escrow_id, updated_at)
+			VALUES ($1, NULLIF($2, 0), NULLIF($3, ''), NULLIF($4, 0), $5, $6, $7, $8, $9, $10, $11, CURRENT_TIMESTAMP) RETURNING id`,
+			transfer.Kind, transfer.FromUserID, transfer.ExternalAccountID, transfer.ToUserID, transfer.Amount, transfer.TransactionID,
+			TransferStatusPending, transfer.IdempotencyKey, transfer.PostID, transfer.CommentID, transfer.EscrowID,
+		).Scan(&transferID)
+	} else {
+		var result sql.Result
+		result, err = database.Exec(
+			`INSERT INTO transfers (kind, from_user_id, external_account_id, to_user_id, amount_tinybars, transaction_id, status, idempotency_key,
+				post_id, comment_id, escrow_id, updated_at)
+			VALUES (?, NULLIF(?, 0), NULLIF(?, ''), NULLIF(?, 0), ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+			transfer.Kind, transfer.FromUserID, transfer.ExternalAccountID, transfer.ToUserID, transfer.Amount, transfer.TransactionID,
+			TransferStatusPending, transfer.IdempotencyKey, transfer.PostID, transfer.CommentID, transfer.EscrowID,
+		)
+		if err == nil {
+			transferID, err = result.LastInsertId()
+		}
+	}
+	if err != nil {
+		// A concurrent request may have claimed the key between the lookup and the insert
+		existing, lookupErr := getTransferByKey(database, transfer)
+		if lookupErr == nil && existing != nil {
+			return existing, false, nil
+		}
+		return nil, false, err
+	}
+
+	created, err := GetTransferByID(database, int(transferID))
+	if err != nil {
+		return nil, false, err
+	}
+	return created, true, nil
+}
+
+// GetTransferByID retrieves a transfer by ID
+func GetTransferByID(database *sql.DB, transferID int) (*Transfer, error) {
+	transfer := &Transfer{}
+	err := scanTransfer(db.QueryRow(database, `SELECT `+transferColumns+` FROM transfers WHERE id = ?`, transferID), transfer)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// GetTransferByIdempotencyKey retrieves the transfer a sender created with an idempotency key
+func GetTransferByIdempotencyKey(database *sql.DB, fromUserID int, idempotencyKey string) (*Transfer, error) {
+	transfer := &Transfer{}
+	err := scanTransfer(db.QueryRow(database,
+		`SELECT `+transferColumns+` FROM transfers WHERE from_user_id = ? AND idempotency_key = ?`,
+		fromUserID, idempotencyKey,
+	), transfer)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// getTransferByKey retrieves the transfer that already claimed the
+// idempotency key of a new one: a payout of the same escrow for transfers
+// sent from the escrow account, otherwise one of the same sender
+func getTransferByKey(database *sql.DB, transfer Transfer) (*Transfer, error) {
+	if transfer.FromUserID != 0 || transfer.EscrowID == nil {
+		return GetTransferByIdempotencyKey(database, transfer.FromUserID, transfer.IdempotencyKey)
+	}
+	existing := &Transfer{}
+	err := scanTransfer(db.QueryRow(database,
+		`SELECT `+transferColumns+` FROM transfers WHERE from_user_id IS NULL AND escrow_id = ? AND idempotency_key = ?`,
+		*transfer.EscrowID, transfer.IdempotencyKey,
+	), existing)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return existing, nil
+}
+
+// CountEscrowTransfers counts the transfers of a kind made for an escrow
+func CountEscrowTransfers(database *sql.DB, escrowID int, kind string) (int, error) {
+	var count int
+	err := db.QueryRow(database,
+		`SELECT COUNT(*) FROM transfers WHERE escrow_id = ? AND kind = ?`,
+		escrowID, kind,
+	).Scan(&count)
+	return count, err
+}
+
+// GetLatestEscrowTransfer retrieves the most recent transfer of a kind made
+// for an escrow, or nil if there is none
+func GetLatestEscrowTransfer(database *sql.DB, escrowID int, kind string) (*Transfer, error) {
+	transfer := &Transfer{}
+	err := scanTransfer(db.QueryRow(database,
+		`SELECT `+transferColumns+` FROM transfers WHERE escrow_id = ? AND kind = ? ORDER BY id DESC LIMIT 1`,
+		escrowID, kind,
+	), transfer)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// GetTransferByTransactionID retrieves the transfer recorded for a ledger transaction
+func GetTransferByTransactionID(database *sql.DB, transactionID string) (*Transfer, error) {
+	transfer := &Transfer{}
+	err := scanTransfer(db.QueryRow(database,
+		`SELECT `+transferColumns+` FROM transfers WHERE transaction_id = ? ORDER BY id LIMIT 1`,
+		transactionID,
+	), transfer)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// CreateDeposit records a completed deposit from an external account. It
+// reports false when the transaction was already recorded.
+func CreateDeposit(database *sql.DB, toUserID int, externalAccountID string, amount money.Tinybars, transactionID string) (*Transfer, bool, error) {
+	existing, err := GetTransferByTransactionID(database, transactionID)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+
+	var transferID int64
+	if db.IsPostgreSQL() {
+		err = database.QueryRow(
+			`INSERT INTO transfers (kind, to_user_id, external_account_id, amount_tinybars, transaction_id, status, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP) RETURNING id`,
+			TransferKindDeposit, toUserID, externalAccountID, amount, transactionID, TransferStatusCompleted,
+		).Scan(&transferID)
+	} else {
+		var result sql.Result
+		result, err = database.Exec(
+			`INSERT INTO transfers (kind, to_user_id, external_account_id, amount_tinybars, transaction_id, status, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+			TransferKindDeposit, toUserID, externalAccountID, amount, transactionID, TransferStatusCompleted,
+		)
+		if err == nil {
+			transferID, err = result.LastInsertId()
+		}
+	}
+	if err != nil {
+		// A concurrent reconciliation may have recorded the deposit first
+		existing, lookupErr := GetTransferByTransactionID(database, transactionID)
+		if lookupErr == nil && existing != nil {
+			return existing, false, nil
+		}
+		return nil, false, err
+	}
+
+	created, err := GetTransferByID(database, int(transferID))
+	if err != nil {
+		return nil, false, err
+	}
+	return created, true, nil
+}
+
+// MarkTransferCompleted moves a pending transfer to completed and adds tips
+// to the tip totals of the post or comment they rewarded. It reports false
+// when the transfer was no longer pending, e.g. because a concurrent
+// resolution already completed it.
+func MarkTransferCompleted(database *sql.DB, transfer *Transfer) (bool, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := db.TxExec(tx,
+		`UPDATE transfers SET status = ?, transaction_id = ?, failure_reason = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		TransferStatusCompleted, transfer.TransactionID, transfer.ID, TransferStatusPending,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	if transfer.PostID != nil {
+		_, err = db.TxExec(tx,
+			`UPDATE posts SET tip_total_tinybars = COALESCE(tip_total_tinybars, 0) + ?, tip_count = COALESCE(tip_count, 0) + 1 WHERE id = ?`,
+			transfer.Amount, *transfer.PostID,
+		)
+	} else if transfer.CommentID != nil {
+		_, err = db.TxExec(tx,
+			`UPDATE comments SET tip_total_tinybars = COALESCE(tip_total_tinybars, 0) + ?, tip_count = COALESCE(tip_count, 0) + 1 WHERE id = ?`,
+			transfer.Amount, *transfer.CommentID,
+		)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// MarkTransferFailed moves a pending transfer to failed with a reason
+func MarkTransferFailed(database *sql.DB, transferID int, reason string) error {
+	_, err := db.Exec(database,
+		`UPDATE transfers SET status = ?, failure_reason = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		TransferStatusFailed, reason, transferID, TransferStatusPending,
+	)
+	return err
+}
+
+// MarkTransferNeedsReview moves a pending transfer to needs_review with the
+// reason its outcome is unknown
+func MarkTransferNeedsReview(database *sql.DB, transferID int, reason string) error {
+	_, err := db.Exec(database,
+		`UPDATE transfers SET status = ?, failure_reason = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		TransferStatusNeedsReview, reason, transferID, TransferStatusPending,
+	)
+	return err
+}
+
+// GetStalePendingTransfers returns pending transfers created before the cutoff
+func GetStalePendingTransfers(database *sql.DB, createdBefore time.Time, limit int) ([]Transfer, error) {
+	rows, err := db.Query(database,
+		`SELECT `+transferColumns+` FROM transfers
+		WHERE status = ? AND created_at < ?
+		ORDER BY created_at ASC
+		LIMIT ?`,
+		TransferStatusPending, createdBefore.UTC(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []Transfer
+	for rows.Next() {
+		var t Transfer
+		if err := scanTransfer(rows, &t); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, rows.Err()
+}
+
+// Transfer directions as seen by one user
+const (
+	TransferDirectionIn  = "in"
+	TransferDirectionOut = "out"
+)
+
+// TransferHistoryFilter narrows a user's transfer history. Zero values do not filter.
+type TransferHistoryFilter struct {
+	Direction string // TransferDirectionIn or TransferDirectionOut
+	Status    string
+	Kind      string
+	Since     *time.Time
+	Until     *time.Time // exclusive
+	// Linked is "post", "comment" or "session" to only include transfers
+	// linked to that kind of content
+	Linked    string
+	PostID    int
+	CommentID int
+	EscrowID  int
+	// BeforeID is the pagination cursor; only older transfers are returned
+	BeforeID int
+}
+
+// TransferCounterparty is the public profile summary of the other user of a transfer
+type TransferCounterparty struct {
+	ID        int    `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Nickname  string `json:"nickname,omitempty"`
+	Avatar    string `json:"avatar,omitempty"`
+	Role      string `json:"role"`
+}
+
+// DisplayName is the counterparty's nickname, or their full name without one
+func (c *TransferCounterparty) DisplayName() string {
+	if c.Nickname != "" {
+		return c.Nickname
+	}
+	return strings.TrimSpace(c.FirstName + " " + c.LastName)
+}
+
+// TransferHistoryEntry is a transfer as seen by one side of it. The
+// counterparty is the other user; deposits have none and name the external
+// account instead.
+type TransferHistoryEntry struct {
+	Transfer
+	Direction    string                `json:"direction"`
+	Counterparty *TransferCounterparty `json:"counterparty,omitempty"`
+	ExplorerURL  string                `json:"explorer_url,omitempty"`
+}
+
+// GetTransferHistory returns a page of the transfers a user sent or
+// received, newest first, and the cursor of the next page ("" on the last
+// page). A session payment only shows up for the coach once it is released;
+// while it is held in escrow it belongs to the patient's history alone. For
+// escrow payments the counterparty is the other participant of the session.
+func GetTransferHistory(database *sql.DB, userID int, filter TransferHistoryFilter, limit int) ([]TransferHistoryEntry, string, error) {
+	where := `(t.from_user_id = ? OR t.to_user_id = ?)`
+	args := []interface{}{userID, userID}
+
+	switch filter.Direction {
+	case TransferDirectionOut:
+		where += ` AND t.from_user_id = ?`
+		args = append(args, userID)
+	case TransferDirectionIn:
+		where += ` AND t.to_user_id = ?`
+		args = append(args, userID)
+	}
+	if filter.Status != "" {
+		where += ` AND t.status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.Kind != "" {
+		where += ` AND t.kind = ?`
+		args = append(args, filter.Kind)
+	}
+	if filter.Since != nil {
+		where += ` AND t.created_at >= ?`
+		args = append(args, filter.Since.UTC())
+	}
+	if filter.Until != nil {
+		where += ` AND t.created_at < ?`
+		args = append(args, filter.Until.UTC())
+	}
+	switch filter.Linked {
+	case "post":
+		where += ` AND t.post_id IS NOT NULL`
+	case "comment":
+		where += ` AND t.comment_id IS NOT NULL`
+	case "session":
+		where += ` AND t.escrow_id IS NOT NULL`
+	}
+	if filter.PostID > 0 {
+		where += ` AND t.post_id = ?`
+		args = append(args, filter.PostID)
+	}
+	if filter.CommentID > 0 {
+		where += ` AND t.comment_id = ?`
+		args = append(args, filter.CommentID)
+	}
+	if filter.EscrowID > 0 {
+		where += ` AND t.escrow_id = ?`
+		args = append(args, filter.EscrowID)
+	}
+	if filter.BeforeID > 0 {
+		where += ` AND t.id < ?`
+		args = append(args, filter.BeforeID)
+	}
+
+	// The counterparty is resolved in a subquery so transferColumns stay unambiguous
+	query := `
+		SELECT ` + transferColumns + `, counterparty_id, COALESCE(counterparty_first_name, ''), COALESCE(counterparty_last_name, ''),
+			COALESCE(counterparty_nickname, ''), COALESCE(counterparty_avatar, ''), COALESCE(counterparty_role, '')
+		FROM (
+			SELECT t.*, c.id AS counterparty_id, c.first_name AS counterparty_first_name, c.last_name AS counterparty_last_name,
+				c.nickname AS counterparty_nickname, c.avatar AS counterparty_avatar, c.role AS counterparty_role
+			FROM transfers t
+			LEFT JOIN escrows e ON e.id = t.escrow_id
+			LEFT JOIN users c ON c.id = CASE
+				WHEN t.kind = ? THEN e.coach_id
+				WHEN t.from_user_id = ? THEN t.to_user_id
+				WHEN t.kind = ? THEN e.patient_id
+				WHEN t.kind = ? THEN e.coach_id
+				ELSE t.from_user_id
+			END
+			WHERE ` + where + `
+		) history
+		ORDER BY id DESC
+		LIMIT ?
+	`
+	args = append([]interface{}{TransferKindEscrowFund, userID, TransferKindEscrowRelease, TransferKindEscrowRefund}, args...)
+	args = append(args, limit+1) // Get one extra to check if there are more
+
+	rows, err := db.Query(database, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	entries := []TransferHistoryEntry{}
+	for rows.Next() {
+		var entry TransferHistoryEntry
+		var counterpartyID sql.NullInt64
+		var counterparty TransferCounterparty
+		t := &entry.Transfer
+		err := rows.Scan(
+			&t.ID, &t.Kind, &t.FromUserID, &t.ExternalAccountID, &t.ToUserID, &t.Amount, &t.TransactionID, &t.Status,
+			&t.IdempotencyKey, &t.FailureReason, &t.PostID, &t.CommentID, &t.EscrowID, &t.CreatedAt, &t.UpdatedAt,
+			&t.AuditHash, &t.AuditTopicID, &t.AuditSequenceNumber,
+			&counterpartyID, &counterparty.FirstName, &counterparty.LastName, &counterparty.Nickname, &counterparty.Avatar, &counterparty.Role,
+		)
+		if err != nil {
+			return nil, "", err
+		}
+
+		entry.Direction = TransferDirectionIn
+		if t.FromUserID == userID {
+			entry.Direction = TransferDirectionOut
+		}
+		if counterpartyID.Valid {
+			counterparty.ID = int(counterpartyID.Int64)
+			entry.Counterparty = &counterparty
+		}
+		// Idempotency keys are only meaningful to the sender
+		if entry.Direction == TransferDirectionIn {
+			t.IdempotencyKey = ""
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	// If we got more results than requested, remove the last one and set cursor
+	nextCursor := ""
+	if len(entries) > limit {
+		entries = entries[:limit]
+		nextCursor = strconv.Itoa(entries[limit-1].ID)
+	}
+	return entries, nextCursor, nil
+}
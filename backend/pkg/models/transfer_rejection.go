@@ -0,0 +1,115 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+// Kinds of transfer the transfer policy is asked about
+const (
+	TransferAttemptTransfer   = "transfer"
+	TransferAttemptTipPost    = "tip_post"
+	TransferAttemptTipComment = "tip_comment"
+)
+
+// TransferRejection is a transfer or tip the transfer policy refused
+type TransferRejection struct {
+	ID        int             `json:"id"`
+	UserID    int             `json:"user_id"`
+	ToUserID  *int            `json:"to_user_id,omitempty"`
+	Amount    *money.Tinybars `json:"amount,omitempty"`
+	Kind      string          `json:"kind"`
+	Reason    string          `json:"reason"`
+	Detail    string          `json:"detail,omitempty"`
+	IPAddress string          `json:"ip_address,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// RecordTransferRejection stores a refused transfer attempt
+func RecordTransferRejection(database *sql.DB, rejection TransferRejection) error {
+	_, err := db.Exec(database,
+		`INSERT INTO transfer_rejections (user_id, to_user_id, amount_tinybars, kind, reason, detail, ip_address)
+		VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''))`,
+		rejection.UserID, rejection.ToUserID, rejection.Amount, rejection.Kind, rejection.Reason,
+		rejection.Detail, rejection.IPAddress,
+	)
+	return err
+}
+
+// GetTransferRejections returns the most recent refused attempts, optionally for one user
+func GetTransferRejections(database *sql.DB, userID int, limit int) ([]TransferRejection, error) {
+	query := `SELECT id, user_id, to_user_id, amount_tinybars, kind, reason, COALESCE(detail, ''),
+		COALESCE(ip_address, ''), created_at
+		FROM transfer_rejections`
+	args := []interface{}{}
+	if userID > 0 {
+		query += ` WHERE user_id = ?`
+		args = append(args, userID)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(database, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rejections := []TransferRejection{}
+	for rows.Next() {
+		var r TransferRejection
+		if err := rows.Scan(
+			&r.ID, &r.UserID, &r.ToUserID, &r.Amount, &r.Kind, &r.Reason, &r.Detail,
+			&r.IPAddress, &r.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		rejections = append(rejections, r)
+	}
+	return rejections, rows.Err()
+}
+
+// GetTransferRejectionStats returns how many of a user's attempts were
+// refused for a reason since a time and when the oldest of them was
+func GetTransferRejectionStats(database *sql.DB, userID int, reason string, since time.Time) (int, time.Time, error) {
+	rows, err := db.Query(database,
+		`SELECT created_at FROM transfer_rejections
+		WHERE user_id = ? AND reason = ? AND created_at >= ?
+		ORDER BY created_at ASC`,
+		userID, reason, since.UTC(),
+	)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer rows.Close()
+
+	var count int
+	var oldest time.Time
+	for rows.Next() {
+		var createdAt time.Time
+		if err := rows.Scan(&createdAt); err != nil {
+			return 0, time.Time{}, err
+		}
+		if count == 0 {
+			oldest = createdAt
+		}
+		count++
+	}
+	return count, oldest, rows.Err()
+}
+
+// GetOutgoingTransferTotals returns the amount and number of transfers a user
+// sent since a time. Failed transfers moved nothing and are not counted.
+func GetOutgoingTransferTotals(database *sql.DB, userID int, since time.Time) (money.Tinybars, int, error) {
+	var total money.Tinybars
+	var count int
+	err := db.QueryRow(database,
+		`SELECT COALESCE(SUM(amount_tinybars), 0), COUNT(*) FROM transfers
+		WHERE from_user_id = ? AND status <> ? AND created_at >= ?`,
+		userID, TransferStatusFailed, since.UTC(),
+	).Scan(&total, &count)
+	return total, count, err
+}
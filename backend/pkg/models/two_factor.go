@@ -0,0 +1,407 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+	"github.com/On-cure/Oncure/pkg/keys"
+)
+
+// TwoFactor is a user's TOTP enrollment. It is pending until EnabledAt is set.
+type TwoFactor struct {
+	UserID       int
+	Secret       string
+	EnabledAt    *time.Time
+	LastUsedStep int64
+	// FailedAttempts counts the wrong codes since the last accepted one;
+	// reaching the limit sets LockedUntil
+	FailedAttempts int
+	LockedUntil    *time.Time
+	CreatedAt      time.Time
+
+	encryptedSecret  string
+	encryptionKeyID  string
+	encryptedDataKey string
+}
+
+// Enabled reports whether the user finished enrolling
+func (t *TwoFactor) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+// Locked reports whether code checks are refused at a given time
+func (t *TwoFactor) Locked(now time.Time) bool {
+	return t.LockedUntil != nil && now.Before(*t.LockedUntil)
+}
+
+const twoFactorColumns = `user_id, encrypted_secret, encryption_key_id, encrypted_data_key, enabled_at, last_used_step, failed_attempts, locked_until, created_at`
+
+// scanTwoFactor scans a row selected with twoFactorColumns without opening the secret
+func scanTwoFactor(row interface{ Scan(...interface{}) error }, t *TwoFactor) error {
+	return row.Scan(&t.UserID, &t.encryptedSecret, &t.encryptionKeyID, &t.encryptedDataKey, &t.EnabledAt, &t.LastUsedStep, &t.FailedAttempts, &t.LockedUntil, &t.CreatedAt)
+}
+
+// envelope returns the stored secret as an envelope
+func (t *TwoFactor) envelope() keys.Envelope {
+	return keys.Envelope{
+		KeyID:            t.encryptionKeyID,
+		EncryptedDataKey: t.encryptedDataKey,
+		Ciphertext:       t.encryptedSecret,
+	}
+}
+
+// SaveTwoFactorSecret starts or restarts a pending enrollment with a new
+// secret, sealed with the wallet keyring. It reports false when two-factor
+// authentication is already enabled.
+func SaveTwoFactorSecret(database *sql.DB, userID int, secret string) (bool, error) {
+	keyring, err := walletKeyring()
+	if err != nil {
+		return false, err
+	}
+	envelope, err := keyring.Seal([]byte(secret))
+	if err != nil {
+		return false, err
+	}
+
+	result, err := db.Exec(database,
+		`INSERT INTO user_two_factor (user_id, encrypted_secret, encryption_key_id, encrypted_data_key)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			encrypted_secret = excluded.encrypted_secret,
+			encryption_key_id = excluded.encryption_key_id,
+			encrypted_data_key = excluded.encrypted_data_key,
+			last_used_step = 0,
+			created_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE user_two_factor.enabled_at IS NULL`,
+		userID, envelope.Ciphertext, envelope.KeyID, envelope.EncryptedDataKey,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetTwoFactor retrieves a user's enrollment with its secret opened, or nil
+// when they have none
+func GetTwoFactor(database *sql.DB, userID int) (*TwoFactor, error) {
+	t := &TwoFactor{}
+	err := scanTwoFactor(db.QueryRow(database, `SELECT `+twoFactorColumns+` FROM user_two_factor WHERE user_id = ?`, userID), t)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	keyring, err := walletKeyring()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := keyring.Open(t.envelope())
+	if err != nil {
+		return nil, err
+	}
+	t.Secret = string(secret)
+	return t, nil
+}
+
+// IsTwoFactorEnabled reports whether a user enabled two-factor authentication
+func IsTwoFactorEnabled(database *sql.DB, userID int) (bool, error) {
+	var exists bool
+	err := db.QueryRow(database,
+		`SELECT EXISTS(SELECT 1 FROM user_two_factor WHERE user_id = ? AND enabled_at IS NOT NULL)`,
+		userID,
+	).Scan(&exists)
+	return exists, err
+}
+
+// EnableTwoFactor completes a pending enrollment with the step of the code
+// that confirmed it and replaces the user's recovery codes. It reports false
+// when there was no pending enrollment.
+func EnableTwoFactor(database *sql.DB, userID int, step int64, recoveryCodeHashes []string) (bool, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := db.TxExec(tx,
+		`UPDATE user_two_factor SET enabled_at = ?, last_used_step = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND enabled_at IS NULL`,
+		time.Now().UTC(), step, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UseTwoFactorStep records that the code of a time step was used. It
+// reports false when a code of that step or a later one was already used.
+func UseTwoFactorStep(database *sql.DB, userID int, step int64) (bool, error) {
+	result, err := db.Exec(database,
+		`UPDATE user_two_factor SET last_used_step = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND enabled_at IS NOT NULL AND last_used_step < ?`,
+		step, userID, step,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RecordTwoFactorFailure counts a wrong code of a user. The maxAttempts-th
+// wrong code in a row locks code checks until lockedUntil and starts the
+// count again; it reports whether this one did.
+func RecordTwoFactorFailure(database *sql.DB, userID, maxAttempts int, lockedUntil time.Time) (bool, error) {
+	result, err := db.Exec(database,
+		`UPDATE user_two_factor SET failed_attempts = 0, locked_until = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND failed_attempts + 1 >= ?`,
+		lockedUntil.UTC(), userID, maxAttempts,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected > 0 {
+		return affected > 0, err
+	}
+	_, err = db.Exec(database,
+		`UPDATE user_two_factor SET failed_attempts = failed_attempts + 1, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`,
+		userID,
+	)
+	return false, err
+}
+
+// ResetTwoFactorFailures clears the wrong code count of a user after an
+// accepted code
+func ResetTwoFactorFailures(database *sql.DB, userID int) error {
+	_, err := db.Exec(database,
+		`UPDATE user_two_factor SET failed_attempts = 0, updated_at = CURRENT_TIMESTAMP WHERE user_id = ? AND failed_attempts > 0`,
+		userID,
+	)
+	return err
+}
+
+// DisableTwoFactor removes a user's enrollment and recovery codes
+func DisableTwoFactor(database *sql.DB, userID int) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := db.TxExec(tx, `DELETE FROM two_factor_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := db.TxExec(tx, `DELETE FROM user_two_factor WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes replaces all of a user's recovery codes
+func ReplaceRecoveryCodes(database *sql.DB, userID int, codeHashes []string) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// replaceRecoveryCodes replaces a user's recovery codes within a transaction
+func replaceRecoveryCodes(tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := db.TxExec(tx, `DELETE FROM two_factor_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := db.TxExec(tx,
+			`INSERT INTO two_factor_recovery_codes (user_id, code_hash) VALUES (?, ?)`,
+			userID, hash,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode spends an unused recovery code. It reports false when the
+// user has no such unused code.
+func UseRecoveryCode(database *sql.DB, userID int, codeHash string) (bool, error) {
+	result, err := db.Exec(database,
+		`UPDATE two_factor_recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		time.Now().UTC(), userID, codeHash,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CountRecoveryCodes counts a user's unused recovery codes
+func CountRecoveryCodes(database *sql.DB, userID int) (int, error) {
+	var count int
+	err := db.QueryRow(database,
+		`SELECT COUNT(*) FROM two_factor_recovery_codes WHERE user_id = ? AND used_at IS NULL`,
+		userID,
+	).Scan(&count)
+	return count, err
+}
+
+// RotateTwoFactorSecrets re-wraps the TOTP secrets not yet under the current
+// master key, batchSize at a time, and returns how many were rotated. Like
+// RotateWalletKeys each update only applies if the row is unchanged.
+func RotateTwoFactorSecrets(database *sql.DB, batchSize int) (int, error) {
+	keyring, err := walletKeyring()
+	if err != nil {
+		return 0, err
+	}
+	currentKeyID := keyring.CurrentKeyID()
+
+	rotated, lastUserID := 0, 0
+	for {
+		batch, err := getTwoFactorNotUnderKey(database, currentKeyID, lastUserID, batchSize)
+		if err != nil {
+			return rotated, err
+		}
+		if len(batch) == 0 {
+			return rotated, nil
+		}
+
+		for i := range batch {
+			t := &batch[i]
+			lastUserID = t.UserID
+			envelope, err := keyring.Rewrap(t.envelope())
+			if err == nil {
+				_, err = db.Exec(database,
+					`UPDATE user_two_factor
+					SET encrypted_secret = ?, encryption_key_id = ?, encrypted_data_key = ?, updated_at = CURRENT_TIMESTAMP
+					WHERE user_id = ? AND encrypted_secret = ? AND encryption_key_id = ?`,
+					envelope.Ciphertext, envelope.KeyID, envelope.EncryptedDataKey,
+					t.UserID, t.encryptedSecret, t.encryptionKeyID,
+				)
+			}
+			if err != nil {
+				log.Printf("Failed to rotate two-factor secret of user %d: %v", t.UserID, err)
+				continue
+			}
+			rotated++
+		}
+	}
+}
+
+// getTwoFactorNotUnderKey returns enrollments after afterUserID whose secret
+// is not wrapped by keyID
+func getTwoFactorNotUnderKey(database *sql.DB, keyID string, afterUserID, limit int) ([]TwoFactor, error) {
+	rows, err := db.Query(database,
+		`SELECT `+twoFactorColumns+` FROM user_two_factor
+		WHERE user_id > ? AND encryption_key_id <> ?
+		ORDER BY user_id ASC
+		LIMIT ?`,
+		afterUserID, keyID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []TwoFactor
+	for rows.Next() {
+		var t TwoFactor
+		if err := scanTwoFactor(rows, &t); err != nil {
+			return nil, err
+		}
+		batch = append(batch, t)
+	}
+	return batch, rows.Err()
+}
+
+// LoginChallenge is a sign-in that passed the password check and waits for
+// the second factor
+type LoginChallenge struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	Attempts  int
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// CreateLoginChallenge stores the hash of a new login challenge token
+func CreateLoginChallenge(database *sql.DB, userID int, tokenHash string, expiresAt time.Time) error {
+	_, err := db.Exec(database,
+		`INSERT INTO login_challenges (user_id, token_hash, expires_at) VALUES (?, ?, ?)`,
+		userID, tokenHash, expiresAt.UTC(),
+	)
+	return err
+}
+
+// GetLoginChallenge retrieves a login challenge by its token hash
+func GetLoginChallenge(database *sql.DB, tokenHash string) (*LoginChallenge, error) {
+	c := &LoginChallenge{}
+	err := db.QueryRow(database,
+		`SELECT id, user_id, token_hash, attempts, expires_at, used_at FROM login_challenges WHERE token_hash = ?`,
+		tokenHash,
+	).Scan(&c.ID, &c.UserID, &c.TokenHash, &c.Attempts, &c.ExpiresAt, &c.UsedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// RecordLoginChallengeAttempt counts a wrong code against a challenge that
+// has had fewer than maxAttempts. It reports false when the challenge is
+// used up.
+func RecordLoginChallengeAttempt(database *sql.DB, challengeID, maxAttempts int) (bool, error) {
+	result, err := db.Exec(database,
+		`UPDATE login_challenges SET attempts = attempts + 1 WHERE id = ? AND used_at IS NULL AND attempts < ?`,
+		challengeID, maxAttempts,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CompleteLoginChallenge marks a challenge used. It reports false when it
+// was already used.
+func CompleteLoginChallenge(database *sql.DB, challengeID int) (bool, error) {
+	result, err := db.Exec(database,
+		`UPDATE login_challenges SET used_at = ? WHERE id = ? AND used_at IS NULL`,
+		time.Now().UTC(), challengeID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
@@ -10,34 +10,54 @@ import (
 )
 
 type User struct {
+	ID                 int         `json:"id"`
+	Email              string      `json:"email"`
+	EmailVerifiedAt    *time.Time  `json:"email_verified_at,omitempty"`
+	Password           string      `json:"-"`
+	FirstName          string      `json:"first_name"`
+	LastName           string      `json:"last_name"`
+	DateOfBirth        string      `json:"date_of_birth"`
+	Avatar             string      `json:"avatar,omitempty"`
+	Nickname           string      `json:"nickname,omitempty"`
+	AboutMe            string      `json:"about_me,omitempty"`
+	Role               string      `json:"role"`
+	VerificationStatus string      `json:"verification_status"`
+	VerifiedAt         *time.Time  `json:"verified_at,omitempty"`
+	CreatedAt          time.Time   `json:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at"`
+	IsPublic           bool        `json:"is_public"`
+	WalletStatus       string      `json:"wallet_status,omitempty"`
+	Badges             []UserBadge `json:"badges,omitempty"`
+	StaffRole          string      `json:"staff_role,omitempty"` // only loaded for the signed-in user
+}
+
+type VerificationRequest struct {
+	ID            int                    `json:"id"`
+	UserID        int                    `json:"user_id"`
+	RequestedRole string                 `json:"requested_role"`
+	Status        string                 `json:"status"`
+	Documents     []string               `json:"documents"`
+	Notes         string                 `json:"notes,omitempty"`
+	ReviewNote    string                 `json:"review_note,omitempty"`
+	ReviewedBy    *int                   `json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time             `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+	Applicant     *VerificationApplicant `json:"applicant,omitempty"`
+}
+
+// VerificationApplicant is the account summary shown to admins reviewing a request
+type VerificationApplicant struct {
 	ID                 int        `json:"id"`
 	Email              string     `json:"email"`
-	Password           string     `json:"-"`
 	FirstName          string     `json:"first_name"`
 	LastName           string     `json:"last_name"`
-	DateOfBirth        string     `json:"date_of_birth"`
-	Avatar             string     `json:"avatar,omitempty"`
 	Nickname           string     `json:"nickname,omitempty"`
-	AboutMe            string     `json:"about_me,omitempty"`
+	Avatar             string     `json:"avatar,omitempty"`
 	Role               string     `json:"role"`
 	VerificationStatus string     `json:"verification_status"`
 	VerifiedAt         *time.Time `json:"verified_at,omitempty"`
 	CreatedAt          time.Time  `json:"created_at"`
-	UpdatedAt          time.Time  `json:"updated_at"`
-	IsPublic           bool       `json:"is_public"`
-}
-
-type VerificationRequest struct {
-	ID            int        `json:"id"`
-	UserID        int        `json:"user_id"`
-	RequestedRole string     `json:"requested_role"`
-	Status        string     `json:"status"`
-	Documents     string     `json:"documents,omitempty"`
-	Notes         string     `json:"notes,omitempty"`
-	ReviewedBy    *int       `json:"reviewed_by,omitempty"`
-	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"`
 }
 
 // CreateUser creates a new user in the database
@@ -70,16 +90,18 @@ func CreateUser(database *sql.DB, user User) (int, error) {
 	if db.IsPostgreSQL() {
 		// PostgreSQL with RETURNING
 		err = tx.QueryRow(
-			`INSERT INTO users (email, password, first_name, last_name, date_of_birth, avatar, nickname, about_me, role)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+			`INSERT INTO users (email, password, first_name, last_name, date_of_birth, avatar, nickname, about_me, role, wallet_status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
 			user.Email, string(hashedPassword), user.FirstName, user.LastName, user.DateOfBirth, user.Avatar, user.Nickname, user.AboutMe, user.Role,
+			WalletStatusProvisioning,
 		).Scan(&userID)
 	} else {
 		// SQLite with LastInsertId
 		result, err := tx.Exec(
-			`INSERT INTO users (email, password, first_name, last_name, date_of_birth, avatar, nickname, about_me, role)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			`INSERT INTO users (email, password, first_name, last_name, date_of_birth, avatar, nickname, about_me, role, wallet_status)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 			user.Email, string(hashedPassword), user.FirstName, user.LastName, user.DateOfBirth, user.Avatar, user.Nickname, user.AboutMe, user.Role,
+			WalletStatusProvisioning,
 		)
 		if err == nil {
 			id, _ := result.LastInsertId()
@@ -96,6 +118,11 @@ func CreateUser(database *sql.DB, user User) (int, error) {
 		return 0, err
 	}
 
+	// Queue the user's wallet in the same transaction so it is never forgotten
+	if err := enqueueWalletJob(tx, userID); err != nil {
+		return 0, err
+	}
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return 0, err
@@ -110,13 +137,15 @@ func GetUserByEmail(database *sql.DB, email string) (*User, error) {
 	err := db.QueryRow(database, `SELECT u.id, u.email, u.password, u.first_name, u.last_name, u.date_of_birth, 
 		u.avatar, u.nickname, u.about_me, COALESCE(u.role, 'user') as role, 
 		COALESCE(u.verification_status, 'unverified') as verification_status, u.verified_at,
-		u.created_at, u.updated_at, COALESCE(p.is_public, true) as is_public
+		u.created_at, u.updated_at, COALESCE(p.is_public, true) as is_public, COALESCE(u.wallet_status, 'active'),
+		u.email_verified_at
 		FROM users u
 		LEFT JOIN user_profiles p ON u.id = p.user_id
 		WHERE u.email = ?`, email).Scan(
 		&user.ID, &user.Email, &user.Password, &user.FirstName, &user.LastName, &user.DateOfBirth,
 		&user.Avatar, &user.Nickname, &user.AboutMe, &user.Role, &user.VerificationStatus, &user.VerifiedAt,
-		&user.CreatedAt, &user.UpdatedAt, &user.IsPublic,
+		&user.CreatedAt, &user.UpdatedAt, &user.IsPublic, &user.WalletStatus,
+		&user.EmailVerifiedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -133,13 +162,15 @@ func GetUserById(database *sql.DB, id int) (*User, error) {
 	err := db.QueryRow(database, `SELECT u.id, u.email, u.first_name, u.last_name, u.date_of_birth, 
 		u.avatar, u.nickname, u.about_me, COALESCE(u.role, 'user') as role,
 		COALESCE(u.verification_status, 'unverified') as verification_status, u.verified_at,
-		u.created_at, u.updated_at, COALESCE(p.is_public, true) as is_public
+		u.created_at, u.updated_at, COALESCE(p.is_public, true) as is_public, COALESCE(u.wallet_status, 'active'),
+		u.email_verified_at
 		FROM users u
 		LEFT JOIN user_profiles p ON u.id = p.user_id
 		WHERE u.id = ?`, id).Scan(
 		&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.DateOfBirth,
 		&user.Avatar, &user.Nickname, &user.AboutMe, &user.Role, &user.VerificationStatus, &user.VerifiedAt,
-		&user.CreatedAt, &user.UpdatedAt, &user.IsPublic,
+		&user.CreatedAt, &user.UpdatedAt, &user.IsPublic, &user.WalletStatus,
+		&user.EmailVerifiedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -191,12 +222,12 @@ func GetSuggestedUsers(database *sql.DB, userID int) ([]map[string]interface{},
 		       u.created_at, u.updated_at, COALESCE(p.is_public, true) as is_public
 		FROM users u
 		LEFT JOIN user_profiles p ON u.id = p.user_id
-		WHERE u.id != ?
+		WHERE u.id != ? AND ` + hiddenAuthorsFilter("u.id") + `
 		ORDER BY u.created_at DESC
 		LIMIT 20
 	`
 
-	rows, err := db.Query(database, query, userID)
+	rows, err := db.Query(database, query, append([]interface{}{userID}, hiddenAuthorsArgs(userID)...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -309,34 +340,6 @@ func joinPlaceholders(placeholders []string) string {
 	return result
 }
 
-// GetUserBySessionToken retrieves a user by their session token
-func GetUserBySessionToken(database *sql.DB, sessionToken string) (*User, error) {
-	query := `
-		SELECT u.id, u.email, u.first_name, u.last_name, u.date_of_birth,
-		       u.avatar, u.nickname, u.about_me, u.created_at, u.updated_at,
-		       COALESCE(p.is_public, true) as is_public
-		FROM users u
-		LEFT JOIN user_profiles p ON u.id = p.user_id
-		INNER JOIN sessions s ON u.id = s.user_id
-		WHERE s.token = ? AND s.expires_at > CURRENT_TIMESTAMP
-	`
-
-	var user User
-	err := db.QueryRow(database, query, sessionToken).Scan(
-		&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.DateOfBirth,
-		&user.Avatar, &user.Nickname, &user.AboutMe, &user.CreatedAt, &user.UpdatedAt, &user.IsPublic,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, err
-		}
-		return nil, err
-	}
-
-	return &user, nil
-}
-
 // GetAllUsers returns all users, including private ones, for the sidebar
 func GetAllUsers(database *sql.DB, excludeUserID int) ([]map[string]interface{}, error) {
 	query := `
@@ -346,12 +349,12 @@ func GetAllUsers(database *sql.DB, excludeUserID int) ([]map[string]interface{},
 		       u.created_at, u.updated_at, COALESCE(p.is_public, true) as is_public
 		FROM users u
 		LEFT JOIN user_profiles p ON u.id = p.user_id
-		WHERE u.id != ?
+		WHERE u.id != ? AND ` + blockedUsersFilter("u.id") + `
 		ORDER BY u.created_at DESC
 		LIMIT 100
 	`
 
-	rows, err := db.Query(database, query, excludeUserID)
+	rows, err := db.Query(database, query, append([]interface{}{excludeUserID}, blockedUsersArgs(excludeUserID)...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,309 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/On-cure/Oncure/pkg/db"
+)
+
+// Verification request statuses. An admin may send a pending request back
+// to the applicant as needs_info; resubmitting it makes it pending again.
+const (
+	VerificationRequestPending   = "pending"
+	VerificationRequestNeedsInfo = "needs_info"
+	VerificationRequestApproved  = "approved"
+	VerificationRequestRejected  = "rejected"
+)
+
+// Verification statuses of a user
+const (
+	VerificationStatusUnverified = "unverified"
+	VerificationStatusPending    = "pending"
+	VerificationStatusVerified   = "verified"
+	VerificationStatusRejected   = "rejected"
+)
+
+// verificationRequestColumns are selected from verification_requests aliased as vr
+const verificationRequestColumns = `vr.id, vr.user_id, vr.requested_role, COALESCE(vr.status, 'pending'), COALESCE(vr.documents, ''),
+	COALESCE(vr.notes, ''), COALESCE(vr.review_note, ''), vr.reviewed_by, vr.reviewed_at, vr.created_at, vr.updated_at`
+
+// applicantColumns are selected from the applicant's users row aliased as u
+const applicantColumns = `u.id, u.email, u.first_name, u.last_name, COALESCE(u.avatar, ''), COALESCE(u.nickname, ''),
+	COALESCE(u.role, 'user'), COALESCE(u.verification_status, 'unverified'), u.verified_at, u.created_at`
+
+// scanVerificationRequest scans a row selected with verificationRequestColumns
+// and, when applicant is non-nil, applicantColumns
+func scanVerificationRequest(row interface{ Scan(...interface{}) error }, r *VerificationRequest, applicant *VerificationApplicant) error {
+	var documents string
+	dest := []interface{}{
+		&r.ID, &r.UserID, &r.RequestedRole, &r.Status, &documents,
+		&r.Notes, &r.ReviewNote, &r.ReviewedBy, &r.ReviewedAt, &r.CreatedAt, &r.UpdatedAt,
+	}
+	if applicant != nil {
+		dest = append(dest,
+			&applicant.ID, &applicant.Email, &applicant.FirstName, &applicant.LastName, &applicant.Avatar, &applicant.Nickname,
+			&applicant.Role, &applicant.VerificationStatus, &applicant.VerifiedAt, &applicant.CreatedAt,
+		)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	r.Documents = []string{}
+	if documents != "" {
+		if err := json.Unmarshal([]byte(documents), &r.Documents); err != nil {
+			// Keep requests with malformed documents reviewable
+			r.Documents = []string{documents}
+		}
+	}
+	if applicant != nil {
+		r.Applicant = applicant
+	}
+	return nil
+}
+
+// CreateVerificationRequest records a pending request for a role and marks
+// the applicant's verification pending. Users who are already verified for
+// another role keep their verified status while the request is reviewed.
+func CreateVerificationRequest(database *sql.DB, userID int, role string, documents []string, notes string) (*VerificationRequest, error) {
+	documentsJSON, err := json.Marshal(documents)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var requestID int64
+	if db.IsPostgreSQL() {
+		err = tx.QueryRow(
+			`INSERT INTO verification_requests (user_id, requested_role, status, documents, notes)
+			VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+			userID, role, VerificationRequestPending, string(documentsJSON), notes,
+		).Scan(&requestID)
+	} else {
+		var result sql.Result
+		result, err = tx.Exec(
+			`INSERT INTO verification_requests (user_id, requested_role, status, documents, notes)
+			VALUES (?, ?, ?, ?, ?)`,
+			userID, role, VerificationRequestPending, string(documentsJSON), notes,
+		)
+		if err == nil {
+			requestID, err = result.LastInsertId()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.TxExec(tx,
+		`UPDATE users SET verification_status = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND COALESCE(verification_status, 'unverified') <> ?`,
+		VerificationStatusPending, userID, VerificationStatusVerified,
+	); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetVerificationRequestByID(database, int(requestID))
+}
+
+// ResubmitVerificationRequest adds documents and notes to a request that is
+// waiting for more information and returns it to pending. The documents
+// already attached are kept, so reviewers see everything sent, and the new
+// notes follow the earlier ones. It reports false when the request no longer
+// needs information.
+func ResubmitVerificationRequest(database *sql.DB, requestID int, documents []string, notes string) (bool, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var existingDocuments, existingNotes string
+	err = tx.QueryRow(db.Placeholder(
+		`SELECT COALESCE(documents, ''), COALESCE(notes, '') FROM verification_requests WHERE id = ? AND status = ?`),
+		requestID, VerificationRequestNeedsInfo,
+	).Scan(&existingDocuments, &existingNotes)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	merged := []string{}
+	if existingDocuments != "" {
+		if err := json.Unmarshal([]byte(existingDocuments), &merged); err != nil {
+			merged = []string{existingDocuments}
+		}
+	}
+	merged = appendMissing(merged, documents...)
+	documentsJSON, err := json.Marshal(merged)
+	if err != nil {
+		return false, err
+	}
+	if notes != "" && existingNotes != "" {
+		notes = existingNotes + "\n\n" + notes
+	} else if notes == "" {
+		notes = existingNotes
+	}
+
+	result, err := db.TxExec(tx,
+		`UPDATE verification_requests SET status = ?, documents = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		VerificationRequestPending, string(documentsJSON), notes, requestID, VerificationRequestNeedsInfo,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// appendMissing appends the values not already in a list
+func appendMissing(list []string, values ...string) []string {
+	seen := make(map[string]bool, len(list))
+	for _, value := range list {
+		seen[value] = true
+	}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// GetVerificationRequestByID retrieves a verification request with its applicant
+func GetVerificationRequestByID(database *sql.DB, requestID int) (*VerificationRequest, error) {
+	request := &VerificationRequest{}
+	err := scanVerificationRequest(db.QueryRow(database,
+		`SELECT `+verificationRequestColumns+`, `+applicantColumns+`
+		FROM verification_requests vr JOIN users u ON u.id = vr.user_id
+		WHERE vr.id = ?`,
+		requestID,
+	), request, &VerificationApplicant{})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return request, nil
+}
+
+// GetLatestVerificationRequest retrieves a user's most recent verification request
+func GetLatestVerificationRequest(database *sql.DB, userID int) (*VerificationRequest, error) {
+	request := &VerificationRequest{}
+	err := scanVerificationRequest(db.QueryRow(database,
+		`SELECT `+verificationRequestColumns+` FROM verification_requests vr
+		WHERE vr.user_id = ? ORDER BY vr.created_at DESC, vr.id DESC LIMIT 1`,
+		userID,
+	), request, nil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return request, nil
+}
+
+// GetVerificationRequests returns the requests with a status and their
+// applicants, oldest first so the queue is worked in order
+func GetVerificationRequests(database *sql.DB, status string, limit int) ([]VerificationRequest, error) {
+	rows, err := db.Query(database,
+		`SELECT `+verificationRequestColumns+`, `+applicantColumns+`
+		FROM verification_requests vr JOIN users u ON u.id = vr.user_id
+		WHERE vr.status = ?
+		ORDER BY vr.updated_at ASC, vr.id ASC
+		LIMIT ?`,
+		status, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []VerificationRequest{}
+	for rows.Next() {
+		var request VerificationRequest
+		if err := scanVerificationRequest(rows, &request, &VerificationApplicant{}); err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+// ReviewVerificationRequest records an admin's decision on a pending
+// request. Approving gives the applicant the requested role, marks them
+// verified and has their sessions take new tokens; rejecting marks them
+// rejected and takes away any unverified role unless they are already
+// verified for another role. Asking for
+// more information leaves the applicant pending. The request and the user
+// change in one transaction. It reports false when the request was no
+// longer pending.
+func ReviewVerificationRequest(database *sql.DB, request *VerificationRequest, reviewerID int, status, note string) (bool, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := db.TxExec(tx,
+		`UPDATE verification_requests
+		SET status = ?, review_note = NULLIF(?, ''), reviewed_by = ?, reviewed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		status, note, reviewerID, request.ID, VerificationRequestPending,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	switch status {
+	case VerificationRequestApproved:
+		_, err = db.TxExec(tx,
+			`UPDATE users SET role = ?, verification_status = ?, verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?`,
+			request.RequestedRole, VerificationStatusVerified, request.UserID,
+		)
+		if err == nil {
+			err = requireSessionRotation(tx, request.UserID)
+		}
+	case VerificationRequestRejected:
+		_, err = db.TxExec(tx,
+			`UPDATE users SET role = 'user', verification_status = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND COALESCE(verification_status, 'unverified') <> ?`,
+			VerificationStatusRejected, request.UserID, VerificationStatusVerified,
+		)
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	request.Status = status
+	request.ReviewNote = note
+	request.ReviewedBy = &reviewerID
+	return true, nil
+}
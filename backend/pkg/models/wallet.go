@@ -1,15 +1,13 @@
 package models
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
 	"database/sql"
-	"encoding/base64"
-	"errors"
-	"io"
-	"os"
+	"log"
+	"sync"
 	"time"
+
+	"github.com/On-cure/Oncure/pkg/keys"
+	"github.com/On-cure/Oncure/pkg/money"
 )
 
 type UserWallet struct {
@@ -17,22 +15,41 @@ type UserWallet struct {
 	UserID             int       `json:"user_id"`
 	HederaAccountID    string    `json:"hedera_account_id"`
 	EncryptedPrivateKey string   `json:"-"`
-	TokenBalance       float64   `json:"token_balance"`
+	EncryptionKeyID    string    `json:"-"`
+	EncryptedDataKey   string    `json:"-"`
+	MirrorCursor       string    `json:"-"`
+	TokenBalance       money.Tinybars `json:"token_balance"`
+	TokenAssociatedAt  *time.Time `json:"token_associated_at,omitempty"`
 	CreatedAt          time.Time `json:"created_at"`
 	UpdatedAt          time.Time `json:"updated_at"`
 }
 
+const walletColumns = `id, user_id, hedera_account_id, encrypted_private_key, COALESCE(encryption_key_id, ''),
+	COALESCE(encrypted_data_key, ''), balance_tinybars, token_associated_at, COALESCE(mirror_cursor, ''), created_at, updated_at`
+
+// scanWallet scans a row selected with walletColumns
+func scanWallet(row interface{ Scan(...interface{}) error }, w *UserWallet) error {
+	return row.Scan(
+		&w.ID, &w.UserID, &w.HederaAccountID, &w.EncryptedPrivateKey, &w.EncryptionKeyID,
+		&w.EncryptedDataKey, &w.TokenBalance, &w.TokenAssociatedAt, &w.MirrorCursor, &w.CreatedAt, &w.UpdatedAt,
+	)
+}
+
 // CreateUserWallet creates a new wallet record for a user
 func CreateUserWallet(db *sql.DB, userID int, accountID, privateKey string) error {
-	encryptedKey, err := encryptPrivateKey(privateKey)
+	keyring, err := walletKeyring()
+	if err != nil {
+		return err
+	}
+	envelope, err := keyring.Seal([]byte(privateKey))
 	if err != nil {
 		return err
 	}
 
 	_, err = db.Exec(
-		`INSERT INTO user_wallets (user_id, hedera_account_id, encrypted_private_key, token_balance)
-		VALUES ($1, $2, $3, $4)`,
-		userID, accountID, encryptedKey, 0.0,
+		`INSERT INTO user_wallets (user_id, hedera_account_id, encrypted_private_key, encryption_key_id, encrypted_data_key, balance_tinybars)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		userID, accountID, envelope.Ciphertext, envelope.KeyID, envelope.EncryptedDataKey, 0,
 	)
 	return err
 }
@@ -40,14 +57,7 @@ func CreateUserWallet(db *sql.DB, userID int, accountID, privateKey string) erro
 // GetUserWallet retrieves wallet information for a user
 func GetUserWallet(db *sql.DB, userID int) (*UserWallet, error) {
 	wallet := &UserWallet{}
-	err := db.QueryRow(
-		`SELECT id, user_id, hedera_account_id, encrypted_private_key, token_balance, created_at, updated_at
-		FROM user_wallets WHERE user_id = $1`,
-		userID,
-	).Scan(
-		&wallet.ID, &wallet.UserID, &wallet.HederaAccountID, &wallet.EncryptedPrivateKey,
-		&wallet.TokenBalance, &wallet.CreatedAt, &wallet.UpdatedAt,
-	)
+	err := scanWallet(db.QueryRow(`SELECT `+walletColumns+` FROM user_wallets WHERE user_id = $1`, userID), wallet)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -57,146 +67,178 @@ func GetUserWallet(db *sql.DB, userID int) (*UserWallet, error) {
 	return wallet, nil
 }
 
+// CountUserWallets counts the wallets created for users
+func CountUserWallets(db *sql.DB) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM user_wallets`).Scan(&count)
+	return count, err
+}
+
 // UpdateTokenBalance updates the token balance for a user's wallet
-func UpdateTokenBalance(db *sql.DB, userID int, balance float64) error {
+func UpdateTokenBalance(db *sql.DB, userID int, balance money.Tinybars) error {
 	_, err := db.Exec(
-		`UPDATE user_wallets SET token_balance = $1, updated_at = CURRENT_TIMESTAMP WHERE user_id = $2`,
+		`UPDATE user_wallets SET balance_tinybars = $1, updated_at = CURRENT_TIMESTAMP WHERE user_id = $2`,
 		balance, userID,
 	)
 	return err
 }
 
+// MarkTokenAssociated records that a user's wallet can hold the community token
+func MarkTokenAssociated(db *sql.DB, userID int) error {
+	_, err := db.Exec(
+		`UPDATE user_wallets SET token_associated_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE user_id = $1`,
+		userID,
+	)
+	return err
+}
+
 // DecryptPrivateKey decrypts the stored private key
 func (w *UserWallet) DecryptPrivateKey() (string, error) {
-	return decryptPrivateKey(w.EncryptedPrivateKey)
-}
+	if w.EncryptionKeyID == "" {
+		plaintext, err := keys.OpenLegacy(w.EncryptedPrivateKey)
+		if err != nil {
+			return "", err
+		}
+		return string(plaintext), nil
+	}
 
-// encryptPrivateKey encrypts a private key using AES
-func encryptPrivateKey(privateKey string) (string, error) {
-	key := getEncryptionKey()
-	block, err := aes.NewCipher(key)
+	keyring, err := walletKeyring()
 	if err != nil {
 		return "", err
 	}
-
-	gcm, err := cipher.NewGCM(block)
+	plaintext, err := keyring.Open(w.envelope())
 	if err != nil {
 		return "", err
 	}
+	return string(plaintext), nil
+}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
+// envelope returns the stored private key as an envelope
+func (w *UserWallet) envelope() keys.Envelope {
+	return keys.Envelope{
+		KeyID:            w.EncryptionKeyID,
+		EncryptedDataKey: w.EncryptedDataKey,
+		Ciphertext:       w.EncryptedPrivateKey,
 	}
+}
+
+var (
+	keyringMu sync.Mutex
+	keyring   *keys.Keyring
+)
 
-	ciphertext := gcm.Seal(nonce, nonce, []byte(privateKey), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+// SetWalletKeyring sets the keyring wallet private keys are encrypted with
+func SetWalletKeyring(k *keys.Keyring) {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	keyring = k
 }
 
-// decryptPrivateKey decrypts a private key using AES
-func decryptPrivateKey(encryptedKey string) (string, error) {
-	key := getEncryptionKey()
-	ciphertext, err := base64.StdEncoding.DecodeString(encryptedKey)
-	if err != nil {
-		return "", err
+// walletKeyring returns the configured keyring, building it from the
+// environment on first use when the caller did not set one
+func walletKeyring() (*keys.Keyring, error) {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	if keyring == nil {
+		k, err := keys.NewKeyringFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		keyring = k
 	}
+	return keyring, nil
+}
 
-	block, err := aes.NewCipher(key)
+// RotateWalletKeys re-wraps the private keys of wallets not yet under the
+// current master key, batchSize wallets at a time, and returns how many were
+// rotated. Wallets stored before envelope encryption are re-encrypted. Each
+// update only applies if the row is unchanged, so it is safe to run while the
+// server is serving requests.
+func RotateWalletKeys(db *sql.DB, batchSize int) (int, error) {
+	keyring, err := walletKeyring()
 	if err != nil {
-		return "", err
+		return 0, err
 	}
+	currentKeyID := keyring.CurrentKeyID()
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
+	rotated, lastID := 0, 0
+	for {
+		wallets, err := getWalletsNotUnderKey(db, currentKeyID, lastID, batchSize)
+		if err != nil {
+			return rotated, err
+		}
+		if len(wallets) == 0 {
+			return rotated, nil
+		}
 
-	nonceSize := gcm.NonceSize()
-	if len(ciphertext) < nonceSize {
-		return "", errors.New("ciphertext too short")
+		for i := range wallets {
+			w := &wallets[i]
+			lastID = w.ID
+			if err := rotateWalletKey(db, keyring, w); err != nil {
+				log.Printf("Failed to rotate key for wallet %d: %v", w.ID, err)
+				continue
+			}
+			rotated++
+		}
 	}
+}
 
-	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+// getWalletsNotUnderKey returns wallets after afterID whose key is not wrapped by keyID
+func getWalletsNotUnderKey(db *sql.DB, keyID string, afterID, limit int) ([]UserWallet, error) {
+	rows, err := db.Query(
+		`SELECT `+walletColumns+` FROM user_wallets
+		WHERE id > $1 AND COALESCE(encryption_key_id, '') <> $2
+		ORDER BY id ASC
+		LIMIT $3`,
+		afterID, keyID, limit,
+	)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer rows.Close()
 
-	return string(plaintext), nil
-}
-
-// getEncryptionKey gets the encryption key from environment
-func getEncryptionKey() []byte {
-	key := os.Getenv("WALLET_ENCRYPTION_KEY")
-	if key == "" {
-		// Fallback key - should be set in production
-		key = "your-32-byte-encryption-key-here"
-	}
-	// Ensure key is 32 bytes for AES-256
-	keyBytes := []byte(key)
-	if len(keyBytes) < 32 {
-		// Pad with zeros if too short
-		padded := make([]byte, 32)
-		copy(padded, keyBytes)
-		return padded
+	var wallets []UserWallet
+	for rows.Next() {
+		var w UserWallet
+		if err := scanWallet(rows, &w); err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, w)
 	}
-	return keyBytes[:32]
+	return wallets, rows.Err()
 }
 
-// Transfer represents a transfer record
-type Transfer struct {
-	ID            int       `json:"id"`
-	FromUserID    int       `json:"from_user_id"`
-	ToUserID      int       `json:"to_user_id"`
-	Amount        float64   `json:"amount"`
-	TransactionID string    `json:"transaction_id,omitempty"`
-	Status        string    `json:"status"`
-	CreatedAt     time.Time `json:"created_at"`
-}
+// rotateWalletKey moves one wallet's private key under the current master key
+func rotateWalletKey(db *sql.DB, keyring *keys.Keyring, w *UserWallet) error {
+	var envelope keys.Envelope
+	if w.EncryptionKeyID == "" {
+		privateKey, err := w.DecryptPrivateKey()
+		if err != nil {
+			return err
+		}
+		envelope, err = keyring.Seal([]byte(privateKey))
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		envelope, err = keyring.Rewrap(w.envelope())
+		if err != nil {
+			return err
+		}
+	}
 
-// CreateTransfer records a transfer in the database
-func CreateTransfer(db *sql.DB, fromUserID, toUserID int, amount float64, transactionID string) error {
 	_, err := db.Exec(
-		`INSERT INTO transfers (from_user_id, to_user_id, amount, transaction_id, status)
-		VALUES ($1, $2, $3, $4, $5)`,
-		fromUserID, toUserID, amount, transactionID, "completed",
+		`UPDATE user_wallets
+		SET encrypted_private_key = $1, encryption_key_id = $2, encrypted_data_key = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 AND encrypted_private_key = $5 AND COALESCE(encryption_key_id, '') = $6`,
+		envelope.Ciphertext, envelope.KeyID, envelope.EncryptedDataKey,
+		w.ID, w.EncryptedPrivateKey, w.EncryptionKeyID,
 	)
 	return err
 }
 
 // SyncWalletBalance updates wallet balance from Hedera network
-func SyncWalletBalance(db *sql.DB, userID int, balance float64) error {
+func SyncWalletBalance(db *sql.DB, userID int, balance money.Tinybars) error {
 	return UpdateTokenBalance(db, userID, balance)
 }
-
-// GetTransferHistory gets transfer history for a user
-func GetTransferHistory(db *sql.DB, userID int, limit int) ([]Transfer, error) {
-	query := `
-		SELECT id, from_user_id, to_user_id, amount, transaction_id, status, created_at
-		FROM transfers 
-		WHERE from_user_id = $1 OR to_user_id = $2
-		ORDER BY created_at DESC
-		LIMIT $3
-	`
-
-	rows, err := db.Query(query, userID, userID, limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var transfers []Transfer
-	for rows.Next() {
-		var t Transfer
-		err := rows.Scan(
-			&t.ID, &t.FromUserID, &t.ToUserID, &t.Amount,
-			&t.TransactionID, &t.Status, &t.CreatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		transfers = append(transfers, t)
-	}
-
-	return transfers, nil
-}
\ No newline at end of file
@@ -0,0 +1,195 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db"
+)
+
+// Wallet statuses of a user. New users are provisioning until the wallet
+// queue has created their Hedera account, and failed once it gave up.
+const (
+	WalletStatusProvisioning = "provisioning"
+	WalletStatusActive       = "active"
+	WalletStatusFailed       = "failed"
+)
+
+// Wallet job statuses
+const (
+	WalletJobStatusPending   = "pending"
+	WalletJobStatusRunning   = "running"
+	WalletJobStatusCompleted = "completed"
+	WalletJobStatusFailed    = "failed"
+)
+
+// WalletJob is a queued request to create a user's Hedera wallet
+type WalletJob struct {
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+const walletJobColumns = `id, user_id, status, attempts, next_attempt_at, COALESCE(last_error, ''), created_at, updated_at`
+
+// scanWalletJob scans a row selected with walletJobColumns
+func scanWalletJob(row interface{ Scan(...interface{}) error }, job *WalletJob) error {
+	return row.Scan(
+		&job.ID, &job.UserID, &job.Status, &job.Attempts, &job.NextAttemptAt, &job.LastError,
+		&job.CreatedAt, &job.UpdatedAt,
+	)
+}
+
+// EnqueueWalletProvisioning queues wallet creation for a user who has no
+// wallet. A failed job is reset so the queue tries again from scratch.
+func EnqueueWalletProvisioning(database *sql.DB, userID int) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := enqueueWalletJob(tx, userID); err != nil {
+		return err
+	}
+	_, err = db.TxExec(tx,
+		`UPDATE users SET wallet_status = ? WHERE id = ? AND wallet_status <> ?`,
+		WalletStatusProvisioning, userID, WalletStatusActive,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// enqueueWalletJob inserts a pending wallet job, resetting a failed one
+func enqueueWalletJob(tx *sql.Tx, userID int) error {
+	_, err := db.TxExec(tx,
+		`INSERT INTO wallet_jobs (user_id, status, next_attempt_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET status = ?, attempts = 0, next_attempt_at = ?, last_error = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE wallet_jobs.status = ?`,
+		userID, WalletJobStatusPending, time.Now().UTC(),
+		WalletJobStatusPending, time.Now().UTC(), WalletJobStatusFailed,
+	)
+	return err
+}
+
+// ClaimDueWalletJobs moves up to limit pending jobs that are due to running,
+// counting the attempt, and returns them. Jobs claimed concurrently by
+// another worker are skipped.
+func ClaimDueWalletJobs(database *sql.DB, now time.Time, limit int) ([]WalletJob, error) {
+	rows, err := db.Query(database,
+		`SELECT `+walletJobColumns+` FROM wallet_jobs
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at ASC
+		LIMIT ?`,
+		WalletJobStatusPending, now.UTC(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var due []WalletJob
+	for rows.Next() {
+		var job WalletJob
+		if err := scanWalletJob(rows, &job); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, job)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var claimed []WalletJob
+	for _, job := range due {
+		result, err := db.Exec(database,
+			`UPDATE wallet_jobs SET status = ?, attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND status = ?`,
+			WalletJobStatusRunning, job.ID, WalletJobStatusPending,
+		)
+		if err != nil {
+			return claimed, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return claimed, err
+		}
+		if affected == 0 {
+			continue
+		}
+		job.Status = WalletJobStatusRunning
+		job.Attempts++
+		claimed = append(claimed, job)
+	}
+	return claimed, nil
+}
+
+// CompleteWalletJob marks a job completed and the user's wallet active
+func CompleteWalletJob(database *sql.DB, job *WalletJob) error {
+	return finishWalletJob(database, job, WalletJobStatusCompleted, "", WalletStatusActive)
+}
+
+// FailWalletJob marks a job failed for good and the user's wallet failed
+func FailWalletJob(database *sql.DB, job *WalletJob, reason string) error {
+	return finishWalletJob(database, job, WalletJobStatusFailed, reason, WalletStatusFailed)
+}
+
+// finishWalletJob settles a running job and the user's wallet status together
+func finishWalletJob(database *sql.DB, job *WalletJob, status, reason, walletStatus string) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = db.TxExec(tx,
+		`UPDATE wallet_jobs SET status = ?, last_error = NULLIF(?, ''), updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		status, reason, job.ID,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = db.TxExec(tx, `UPDATE users SET wallet_status = ? WHERE id = ?`, walletStatus, job.UserID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	job.Status = status
+	job.LastError = reason
+	return nil
+}
+
+// RetryWalletJob puts a running job back in the queue until nextAttemptAt
+func RetryWalletJob(database *sql.DB, job *WalletJob, nextAttemptAt time.Time, reason string) error {
+	_, err := db.Exec(database,
+		`UPDATE wallet_jobs SET status = ?, next_attempt_at = ?, last_error = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`,
+		WalletJobStatusPending, nextAttemptAt.UTC(), reason, job.ID, WalletJobStatusRunning,
+	)
+	return err
+}
+
+// ReleaseStaleWalletJobs returns jobs left running since before the cutoff,
+// e.g. by a crash, to the queue and reports how many were released
+func ReleaseStaleWalletJobs(database *sql.DB, updatedBefore time.Time) (int, error) {
+	result, err := db.Exec(database,
+		`UPDATE wallet_jobs SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE status = ? AND updated_at < ?`,
+		WalletJobStatusPending, WalletJobStatusRunning, updatedBefore.UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
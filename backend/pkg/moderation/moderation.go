@@ -0,0 +1,248 @@
+// Package moderation runs the moderation queue. Users report content with a
+// reason; reports on the same content are gathered into one case whose
+// priority grows with the urgency of the reasons and the number of
+// reporters. Moderators hide or delete the content, warn or suspend its
+// author, or dismiss the reports, and the reporters are told the outcome.
+package moderation
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/websocket"
+)
+
+// maxDetailsLength bounds the free text attached to a report
+const maxDetailsLength = 1000
+
+var (
+	ErrInvalidContentType = errors.New("content_type must be post, comment, group_post, group_comment or message")
+	ErrInvalidReason      = errors.New("reason must be harassment, hate, self_harm, misinformation, spam, privacy or other")
+	ErrDetailsTooLong     = fmt.Errorf("details must be at most %d characters", maxDetailsLength)
+	ErrContentNotFound    = errors.New("content not found")
+	ErrOwnContent         = errors.New("you cannot report your own content")
+	ErrNotFound           = errors.New("moderation case not found")
+	ErrWrongStatus        = errors.New("the case has already been resolved")
+	ErrInvalidAction      = errors.New("action must be hide, delete, warn, suspend or dismiss")
+	ErrNoteRequired       = errors.New("a note for the author is required to warn or suspend")
+	ErrInvalidUntil       = errors.New("until must be in the future")
+	ErrNoAuthor           = errors.New("the author of the content no longer exists")
+	ErrSuspendSelf        = errors.New("you cannot suspend yourself")
+	ErrSuspendAdmin       = errors.New("admins cannot be suspended; remove their admin role first")
+	ErrSuspendModerator   = errors.New("only an admin can suspend a moderator")
+)
+
+// Service files reports and resolves moderation cases
+type Service struct {
+	db  *sql.DB
+	hub *websocket.Hub
+}
+
+// NewService creates a moderation service. hub closes the live connections
+// of suspended users and may be nil.
+func NewService(db *sql.DB, hub *websocket.Hub) *Service {
+	return &Service{db: db, hub: hub}
+}
+
+// Report files a user's report on content they can see. It reports false
+// when the user had already reported the content and the case is still open.
+func (s *Service) Report(reporter *models.User, contentType string, contentID int, reason, details string) (*models.ModerationCase, bool, error) {
+	if !models.IsContentType(contentType) {
+		return nil, false, ErrInvalidContentType
+	}
+	if !models.IsReportReason(reason) {
+		return nil, false, ErrInvalidReason
+	}
+	details = strings.TrimSpace(details)
+	if len(details) > maxDetailsLength {
+		return nil, false, ErrDetailsTooLong
+	}
+
+	item, err := models.GetContentItem(s.db, contentType, contentID)
+	if err != nil {
+		return nil, false, err
+	}
+	if item == nil {
+		return nil, false, ErrContentNotFound
+	}
+	if item.AuthorID == reporter.ID {
+		return nil, false, ErrOwnContent
+	}
+	canView, err := models.CanViewContent(s.db, item, reporter.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !canView {
+		return nil, false, ErrContentNotFound
+	}
+
+	moderationCase, created, err := models.AddContentReport(s.db, item, reporter.ID, reason, details)
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		log.Printf("User %d reported %s %d for %s; case %d has %d reports", reporter.ID, contentType, contentID, reason, moderationCase.ID, moderationCase.ReportCount)
+	}
+	return moderationCase, created, nil
+}
+
+// Queue returns the cases with a status, open cases most urgent first
+func (s *Service) Queue(status string, limit int) ([]models.ModerationCase, error) {
+	return models.GetModerationQueue(s.db, status, limit)
+}
+
+// Get returns a case with its reports
+func (s *Service) Get(caseID int) (*models.ModerationCase, []models.ContentReport, error) {
+	moderationCase, err := models.GetModerationCase(s.db, caseID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if moderationCase == nil {
+		return nil, nil, ErrNotFound
+	}
+	reports, err := models.GetContentReports(s.db, caseID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return moderationCase, reports, nil
+}
+
+// Resolve acts on an open case. Hiding keeps the content but leaves it out
+// of listings; deleting removes it. Warning and suspending need a note,
+// which is shown to the author; until optionally ends a suspension. The
+// author and every reporter are notified.
+func (s *Service) Resolve(caseID int, moderator *models.User, action, note string, until *time.Time) (*models.ModerationCase, error) {
+	moderationCase, reports, err := s.Get(caseID)
+	if err != nil {
+		return nil, err
+	}
+	if moderationCase.Status != models.ModerationCaseOpen {
+		return nil, ErrWrongStatus
+	}
+
+	switch action {
+	case models.ModerationActionHide:
+		_, err = models.SetContentHidden(s.db, moderationCase.ContentType, moderationCase.ContentID, true)
+	case models.ModerationActionDelete:
+		_, err = models.DeleteContentItem(s.db, moderationCase.ContentType, moderationCase.ContentID)
+	case models.ModerationActionWarn, models.ModerationActionSuspend:
+		if note == "" {
+			return nil, ErrNoteRequired
+		}
+		if moderationCase.AuthorID == nil {
+			return nil, ErrNoAuthor
+		}
+		if action == models.ModerationActionSuspend {
+			_, err = s.Suspend(moderator, *moderationCase.AuthorID, note, until)
+		}
+	case models.ModerationActionDismiss:
+	default:
+		return nil, ErrInvalidAction
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := models.ResolveModerationCase(s.db, moderationCase, action, note, moderator.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !resolved {
+		return nil, ErrWrongStatus
+	}
+	log.Printf("Moderation case %d resolved with %s by user %d", moderationCase.ID, action, moderator.ID)
+
+	s.notifyAuthor(moderationCase, action, note)
+	s.notifyReporters(moderationCase, reports)
+	return moderationCase, nil
+}
+
+// Suspend suspends a user, optionally until a time, and signs them out.
+// Only admins can suspend moderators, and admins must lose their role first.
+func (s *Service) Suspend(actor *models.User, userID int, reason string, until *time.Time) (*models.UserSuspension, error) {
+	if userID == actor.ID {
+		return nil, ErrSuspendSelf
+	}
+	if until != nil && !until.After(time.Now()) {
+		return nil, ErrInvalidUntil
+	}
+	role, err := models.GetStaffRole(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	if role == models.StaffRoleAdmin {
+		return nil, ErrSuspendAdmin
+	}
+	if role == models.StaffRoleModerator && actor.StaffRole != models.StaffRoleAdmin {
+		return nil, ErrSuspendModerator
+	}
+
+	suspension, sessionIDs, err := models.SuspendUser(s.db, models.UserSuspension{
+		UserID:         userID,
+		Reason:         reason,
+		SuspendedBy:    &actor.ID,
+		SuspendedUntil: until,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.hub != nil {
+		s.hub.CloseSessions(sessionIDs...)
+	}
+	log.Printf("User %d suspended by user %d", userID, actor.ID)
+	return suspension, nil
+}
+
+// notifyAuthor tells the author what happened to their content. Suspended
+// authors learn the reason when they next try to sign in.
+func (s *Service) notifyAuthor(moderationCase *models.ModerationCase, action, note string) {
+	if moderationCase.AuthorID == nil {
+		return
+	}
+	label := contentLabel(moderationCase.ContentType)
+
+	var message string
+	switch action {
+	case models.ModerationActionHide:
+		message = fmt.Sprintf("Your %s was hidden by a moderator", label)
+	case models.ModerationActionDelete:
+		message = fmt.Sprintf("Your %s was removed by a moderator", label)
+	case models.ModerationActionWarn:
+		message = fmt.Sprintf("A moderator warned you about your %s", label)
+	default:
+		return
+	}
+	if note != "" {
+		message += ": " + note
+	}
+	s.notify(*moderationCase.AuthorID, "moderation_"+action, moderationCase.ID, "%s", message)
+}
+
+// notifyReporters tells everyone who reported the content the outcome
+func (s *Service) notifyReporters(moderationCase *models.ModerationCase, reports []models.ContentReport) {
+	label := contentLabel(moderationCase.ContentType)
+	message := fmt.Sprintf("Thank you for your report. A moderator reviewed the %s you reported and took action.", label)
+	if moderationCase.Action == models.ModerationActionDismiss {
+		message = fmt.Sprintf("Thank you for your report. A moderator reviewed the %s you reported and found it within the community guidelines.", label)
+	}
+	for _, report := range reports {
+		s.notify(report.ReporterID, "report_resolved", moderationCase.ID, "%s", message)
+	}
+}
+
+// notify creates a notification about a moderation case, logging failures
+func (s *Service) notify(userID int, notificationType string, caseID int, format string, args ...interface{}) {
+	if _, err := models.CreateNotification(s.db, userID, notificationType, fmt.Sprintf(format, args...), caseID); err != nil {
+		log.Printf("Failed to create %s notification for moderation case %d: %v", notificationType, caseID, err)
+	}
+}
+
+// contentLabel names a content type in messages to users
+func contentLabel(contentType string) string {
+	return strings.ReplaceAll(contentType, "_", " ")
+}
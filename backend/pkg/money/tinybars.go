@@ -0,0 +1,71 @@
+// Package money holds the fixed-point amount types used for ledger balances
+// and transfers. Amounts are whole smallest units (tinybars for HBAR) so
+// totals add up exactly and match the ledger; decimal strings are only used
+// at the API edges.
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Tinybars is an HBAR amount counted in tinybars, the smallest HBAR unit
+type Tinybars int64
+
+// HbarDecimals is the number of decimal places in an HBAR amount
+const HbarDecimals = 8
+
+// TinybarsPerHbar is the number of tinybars in one HBAR
+const TinybarsPerHbar Tinybars = 100000000
+
+// Hbar returns a whole number of HBAR in tinybars
+func Hbar(hbars int64) Tinybars {
+	return Tinybars(hbars) * TinybarsPerHbar
+}
+
+// ParseHbar parses a decimal HBAR amount such as "1.5" or "-0.00000001".
+// Amounts with more than eight decimal places are rejected rather than rounded.
+func ParseHbar(s string) (Tinybars, error) {
+	units, err := ParseUnits(s, HbarDecimals)
+	return Tinybars(units), err
+}
+
+// String formats the amount as decimal HBAR with all eight places, e.g. "1.50000000"
+func (t Tinybars) String() string {
+	return FormatUnits(int64(t), HbarDecimals)
+}
+
+// Int64 returns the amount as a plain tinybar count
+func (t Tinybars) Int64() int64 {
+	return int64(t)
+}
+
+// MarshalJSON encodes the amount as a decimal HBAR string so clients never
+// round-trip it through a float
+func (t Tinybars) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON accepts a decimal HBAR string or a JSON number. Numbers are
+// parsed from their literal text, never through float64.
+func (t *Tinybars) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	} else if strings.ContainsAny(text, "eE") {
+		return fmt.Errorf("%w: exponent notation is not supported", ErrInvalidAmount)
+	}
+
+	parsed, err := ParseHbar(text)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
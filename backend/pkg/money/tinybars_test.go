@@ -0,0 +1,64 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestTinybarsUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Tinybars
+		wantErr bool
+	}{
+		{`"1.5"`, 150000000, false},
+		{`1.5`, 150000000, false},
+		{`"0.00000001"`, 1, false},
+		{`0.00000001`, 1, false},
+		{`"-2"`, -Hbar(2), false},
+		{`-2`, -Hbar(2), false},
+		{`92233720368.54775807`, Tinybars(9223372036854775807), false},
+
+		{`0.000000001`, 0, true},
+		{`"0.000000001"`, 0, true},
+		{`92233720368.54775808`, 0, true},
+		{`1e8`, 0, true},
+		{`1E-8`, 0, true},
+		{`"1e8"`, 0, true},
+		{`""`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, test := range tests {
+		var got Tinybars
+		err := json.Unmarshal([]byte(test.input), &got)
+		if test.wantErr {
+			if !errors.Is(err, ErrInvalidAmount) {
+				t.Errorf("unmarshal %s = %d, %v; want ErrInvalidAmount", test.input, got, err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("unmarshal %s = %d, %v; want %d", test.input, got, err, test.want)
+		}
+	}
+
+	// null leaves the amount unchanged
+	amount := Hbar(3)
+	if err := json.Unmarshal([]byte(`null`), &amount); err != nil || amount != Hbar(3) {
+		t.Errorf("unmarshal null = %s, %v; want 3 HBAR unchanged", amount, err)
+	}
+}
+
+func TestTinybarsJSONRoundTrips(t *testing.T) {
+	for _, amount := range []Tinybars{0, 1, -1, Hbar(5), Hbar(-5) + 1, Tinybars(9223372036854775807)} {
+		data, err := json.Marshal(amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Tinybars
+		if err := json.Unmarshal(data, &got); err != nil || got != amount {
+			t.Errorf("round trip of %d through %s = %d, %v", amount, data, got, err)
+		}
+	}
+}
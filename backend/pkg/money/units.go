@@ -0,0 +1,94 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// ParseUnits parses a decimal amount into whole units of an asset with the
+// given number of decimal places, e.g. "1.5" with 2 decimals is 150 units.
+// Amounts with more decimal places than the asset supports are rejected.
+func ParseUnits(s string, decimals int) (int64, error) {
+	s = strings.TrimSpace(s)
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	whole, frac, hasPoint := strings.Cut(s, ".")
+	if whole == "" && (!hasPoint || frac == "") {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if len(frac) > decimals {
+		return 0, fmt.Errorf("%w: more than %d decimal places", ErrInvalidAmount, decimals)
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	scale := pow10(decimals)
+	var wholeUnits, fracUnits int64
+	var err error
+	if whole != "" {
+		wholeUnits, err = strconv.ParseInt(whole, 10, 64)
+		if err != nil || wholeUnits > math.MaxInt64/scale {
+			return 0, fmt.Errorf("%w: out of range", ErrInvalidAmount)
+		}
+	}
+	if frac != "" {
+		fracUnits, _ = strconv.ParseInt(frac+strings.Repeat("0", decimals-len(frac)), 10, 64)
+	}
+
+	total := wholeUnits*scale + fracUnits
+	if total < 0 {
+		return 0, fmt.Errorf("%w: out of range", ErrInvalidAmount)
+	}
+	if negative {
+		total = -total
+	}
+	return total, nil
+}
+
+// FormatUnits formats whole units of an asset with the given number of
+// decimal places, always printing every place, e.g. 150 with 2 decimals is "1.50"
+func FormatUnits(units int64, decimals int) string {
+	sign := ""
+	abs := uint64(units)
+	if units < 0 {
+		sign = "-"
+		abs = uint64(-(units + 1)) + 1
+	}
+	if decimals == 0 {
+		return fmt.Sprintf("%s%d", sign, abs)
+	}
+	scale := uint64(pow10(decimals))
+	return fmt.Sprintf("%s%d.%0*d", sign, abs/scale, decimals, abs%scale)
+}
+
+// pow10 returns 10^n for the small exponents used as asset decimals
+func pow10(n int) int64 {
+	result := int64(1)
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
+
+// isDigits reports whether s contains only ASCII digits
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
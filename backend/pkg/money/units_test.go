@@ -0,0 +1,103 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseUnits(t *testing.T) {
+	tests := []struct {
+		input    string
+		decimals int
+		want     int64
+		wantErr  bool
+	}{
+		{"1.5", 2, 150, false},
+		{"1", 8, 100000000, false},
+		{"0.00000001", 8, 1, false},
+		{".5", 2, 50, false},
+		{"1.", 2, 100, false},
+		{"+2", 0, 2, false},
+		{" 3.25 ", 2, 325, false},
+		{"007", 0, 7, false},
+		{"-1.5", 2, -150, false},
+		{"-0.00000001", 8, -1, false},
+		{"-0", 2, 0, false},
+		{"92233720368.54775807", 8, math.MaxInt64, false},
+		{"9223372036854775807", 0, math.MaxInt64, false},
+
+		// Too many decimal places
+		{"1.001", 2, 0, true},
+		{"0.000000001", 8, 0, true},
+		{"1.5", 0, 0, true},
+
+		// Out of int64 range
+		{"92233720368.54775808", 8, 0, true},
+		{"92233720369", 8, 0, true},
+		{"9223372036854775808", 0, 0, true},
+		{"-92233720369", 8, 0, true},
+
+		// Malformed
+		{"", 8, 0, true},
+		{"   ", 8, 0, true},
+		{".", 8, 0, true},
+		{"-", 8, 0, true},
+		{"--1", 8, 0, true},
+		{"+-1", 8, 0, true},
+		{"1e5", 8, 0, true},
+		{"1.5e2", 8, 0, true},
+		{"0x10", 8, 0, true},
+		{"1,000", 8, 0, true},
+		{"1.2.3", 8, 0, true},
+		{"abc", 8, 0, true},
+		{"1 000", 8, 0, true},
+	}
+	for _, test := range tests {
+		got, err := ParseUnits(test.input, test.decimals)
+		if test.wantErr {
+			if !errors.Is(err, ErrInvalidAmount) {
+				t.Errorf("ParseUnits(%q, %d) = %d, %v; want ErrInvalidAmount", test.input, test.decimals, got, err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("ParseUnits(%q, %d) = %d, %v; want %d", test.input, test.decimals, got, err, test.want)
+		}
+	}
+}
+
+func TestFormatUnits(t *testing.T) {
+	tests := []struct {
+		units    int64
+		decimals int
+		want     string
+	}{
+		{150, 2, "1.50"},
+		{0, 8, "0.00000000"},
+		{1, 8, "0.00000001"},
+		{-1, 8, "-0.00000001"},
+		{-150, 2, "-1.50"},
+		{42, 0, "42"},
+		{-42, 0, "-42"},
+		{math.MaxInt64, 8, "92233720368.54775807"},
+		{math.MinInt64, 8, "-92233720368.54775808"},
+	}
+	for _, test := range tests {
+		if got := FormatUnits(test.units, test.decimals); got != test.want {
+			t.Errorf("FormatUnits(%d, %d) = %q, want %q", test.units, test.decimals, got, test.want)
+		}
+	}
+}
+
+func TestFormatUnitsRoundTrips(t *testing.T) {
+	for _, decimals := range []int{0, 2, 6, 8} {
+		for _, units := range []int64{0, 1, -1, 99, 100, 123456789, -123456789, math.MaxInt64, math.MinInt64 + 1} {
+			text := FormatUnits(units, decimals)
+			got, err := ParseUnits(text, decimals)
+			if err != nil || got != units {
+				t.Errorf("ParseUnits(FormatUnits(%d, %d) = %q) = %d, %v", units, decimals, text, got, err)
+			}
+		}
+	}
+}
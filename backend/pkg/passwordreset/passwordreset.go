@@ -0,0 +1,199 @@
+// Package passwordreset lets users who forgot their password choose a new
+// one. A reset link carrying a random single-use token is emailed to the
+// account's address; only a hash of the token is stored and it expires
+// after a short time. Resetting the password signs the user out everywhere.
+package passwordreset
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/mail"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/utils"
+	"github.com/On-cure/Oncure/pkg/websocket"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// DefaultTokenTTL is how long a reset link works when
+	// PASSWORD_RESET_TTL_MINUTES is not set
+	DefaultTokenTTL = time.Hour
+
+	// DefaultResetURL is the page reset links point to when
+	// PASSWORD_RESET_URL is not set
+	DefaultResetURL = "http://localhost:3000/reset-password"
+
+	// MinPasswordLength is the shortest new password accepted
+	MinPasswordLength = 8
+
+	// maxRequestsPerHour bounds the reset emails sent to one account
+	maxRequestsPerHour = 3
+
+	// sendTimeout bounds the delivery of one email
+	sendTimeout = 30 * time.Second
+)
+
+var (
+	ErrInvalidToken = errors.New("the reset link is invalid or has expired")
+	ErrWeakPassword = fmt.Errorf("the password must be at least %d characters long", MinPasswordLength)
+)
+
+// Config holds the reset link settings
+type Config struct {
+	TokenTTL time.Duration
+	ResetURL string
+}
+
+// ConfigFromEnv reads PASSWORD_RESET_TTL_MINUTES and PASSWORD_RESET_URL,
+// the frontend page that receives the token as its token query parameter
+func ConfigFromEnv() (Config, error) {
+	config := Config{TokenTTL: DefaultTokenTTL, ResetURL: DefaultResetURL}
+
+	if value := os.Getenv("PASSWORD_RESET_TTL_MINUTES"); value != "" {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes <= 0 {
+			return Config{}, fmt.Errorf("invalid PASSWORD_RESET_TTL_MINUTES %q", value)
+		}
+		config.TokenTTL = time.Duration(minutes) * time.Minute
+	}
+	if value := os.Getenv("PASSWORD_RESET_URL"); value != "" {
+		parsed, err := url.Parse(value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return Config{}, fmt.Errorf("invalid PASSWORD_RESET_URL %q", value)
+		}
+		config.ResetURL = value
+	}
+	return config, nil
+}
+
+// Service issues and redeems password reset tokens
+type Service struct {
+	db     *sql.DB
+	mailer mail.Mailer
+	config Config
+	hub    *websocket.Hub
+}
+
+// NewService creates a password reset service. hub closes the live
+// connections of the sessions a reset signs out and may be nil.
+func NewService(db *sql.DB, mailer mail.Mailer, config Config, hub *websocket.Hub) *Service {
+	return &Service{db: db, mailer: mailer, config: config, hub: hub}
+}
+
+// Request emails a reset link to the account with an email address. To not
+// reveal which addresses have accounts it succeeds whether or not one
+// exists, and the email is sent in the background.
+func (s *Service) Request(email, requestedIP string) error {
+	email = strings.TrimSpace(email)
+	user, err := models.GetUserByEmail(s.db, email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		log.Printf("Password reset requested for unknown email from %s", requestedIP)
+		return nil
+	}
+
+	recent, err := models.CountPasswordResetTokensSince(s.db, user.ID, time.Now().Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if recent >= maxRequestsPerHour {
+		log.Printf("Password reset for user %d throttled after %d requests in the last hour", user.ID, recent)
+		return nil
+	}
+
+	token, err := utils.NewToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(s.config.TokenTTL)
+	if err := models.CreatePasswordResetToken(s.db, user.ID, utils.HashToken(token), requestedIP, expiresAt); err != nil {
+		return err
+	}
+
+	link := utils.TokenLink(s.config.ResetURL, token)
+	s.send(user.ID, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Oncure password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"We received a request to reset the password of your Oncure account. "+
+			"Choose a new password here:\n\n%s\n\n"+
+			"The link works once and expires in %s. "+
+			"If you did not ask for it you can ignore this email; your password stays the same.\n",
+			user.FirstName, link, utils.DescribeDuration(s.config.TokenTTL)),
+	})
+	return nil
+}
+
+// Reset replaces the password of the account a token was issued to and
+// signs it out of every session
+func (s *Service) Reset(token, password string) error {
+	if len(password) < MinPasswordLength {
+		return ErrWeakPassword
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ErrInvalidToken
+	}
+
+	resetToken, err := models.GetPasswordResetToken(s.db, utils.HashToken(token))
+	if err != nil {
+		return err
+	}
+	if resetToken == nil || resetToken.UsedAt != nil || time.Now().After(resetToken.ExpiresAt) {
+		return ErrInvalidToken
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	reset, sessionIDs, err := models.ResetPassword(s.db, resetToken, string(passwordHash))
+	if err != nil {
+		return err
+	}
+	if !reset {
+		return ErrInvalidToken
+	}
+	if s.hub != nil {
+		s.hub.CloseSessions(sessionIDs...)
+	}
+	log.Printf("Password of user %d reset; all sessions signed out", resetToken.UserID)
+
+	user, err := models.GetUserById(s.db, resetToken.UserID)
+	if err != nil || user == nil {
+		log.Printf("Failed to load user %d to confirm their password reset: %v", resetToken.UserID, err)
+		return nil
+	}
+	s.send(user.ID, mail.Message{
+		To:      user.Email,
+		Subject: "Your Oncure password was changed",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"The password of your Oncure account was just changed and you were signed out on all devices. "+
+			"If this was not you, reset your password again right away and contact support.\n",
+			user.FirstName),
+	})
+	return nil
+}
+
+// send delivers an email in the background, logging failures
+func (s *Service) send(userID int, msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send %q email to user %d: %v", msg.Subject, userID, err)
+		}
+	}()
+}
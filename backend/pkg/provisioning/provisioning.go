@@ -0,0 +1,198 @@
+// Package provisioning creates users' Hedera wallets from a persisted job
+// queue, so registration never waits on or fails because of the network.
+package provisioning
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	wallet "github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/websocket"
+)
+
+const (
+	// MaxAttempts is how many times a wallet is tried before the job fails
+	MaxAttempts = 8
+
+	// BaseBackoff is the delay before the first retry; each retry doubles it
+	BaseBackoff = 30 * time.Second
+
+	// MaxBackoff caps the delay between retries
+	MaxBackoff = time.Hour
+
+	// staleAfter is how long a job may stay running before it is assumed
+	// abandoned by a crashed worker and queued again
+	staleAfter = 10 * time.Minute
+
+	batchSize = 10
+)
+
+// Worker processes the wallet provisioning queue
+type Worker struct {
+	db     *sql.DB
+	ledger wallet.Ledger
+	token  *wallet.CommunityToken
+	hub    *websocket.Hub
+	wake   chan struct{}
+}
+
+// NewWorker creates a provisioning worker. hub may be nil, in which case
+// users are only told through notifications.
+func NewWorker(db *sql.DB, ledger wallet.Ledger, token *wallet.CommunityToken, hub *websocket.Hub) *Worker {
+	return &Worker{db: db, ledger: ledger, token: token, hub: hub, wake: make(chan struct{}, 1)}
+}
+
+// Wake asks the worker to process the queue now instead of at its next tick
+func (w *Worker) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes due jobs every interval and whenever the worker is woken
+func (w *Worker) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		w.ProcessDue()
+
+		select {
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// ProcessDue provisions every job that is due and returns how many wallets were created
+func (w *Worker) ProcessDue() int {
+	if released, err := models.ReleaseStaleWalletJobs(w.db, time.Now().Add(-staleAfter)); err != nil {
+		log.Printf("Failed to release stale wallet jobs: %v", err)
+	} else if released > 0 {
+		log.Printf("Requeued %d abandoned wallet jobs", released)
+	}
+
+	provisioned := 0
+	for {
+		jobs, err := models.ClaimDueWalletJobs(w.db, time.Now(), batchSize)
+		if err != nil {
+			log.Printf("Failed to claim wallet jobs: %v", err)
+			return provisioned
+		}
+		if len(jobs) == 0 {
+			return provisioned
+		}
+		for i := range jobs {
+			if w.process(&jobs[i]) {
+				provisioned++
+			}
+		}
+	}
+}
+
+// process runs one claimed job and records its outcome
+func (w *Worker) process(job *models.WalletJob) bool {
+	accountID, err := w.provision(job.UserID)
+	if err == nil {
+		if err := models.CompleteWalletJob(w.db, job); err != nil {
+			log.Printf("Failed to complete wallet job %d: %v", job.ID, err)
+		}
+		w.announce(job.UserID, "wallet_ready", fmt.Sprintf("Your wallet %s is ready", accountID), accountID)
+		return true
+	}
+
+	log.Printf("Wallet job %d for user %d failed (attempt %d): %v", job.ID, job.UserID, job.Attempts, err)
+	if job.Attempts >= MaxAttempts {
+		if err := models.FailWalletJob(w.db, job, err.Error()); err != nil {
+			log.Printf("Failed to mark wallet job %d failed: %v", job.ID, err)
+		}
+		w.announce(job.UserID, "wallet_failed", "We could not create your wallet. Please contact support.", "")
+		return false
+	}
+
+	if err := models.RetryWalletJob(w.db, job, time.Now().Add(Backoff(job.Attempts)), err.Error()); err != nil {
+		log.Printf("Failed to reschedule wallet job %d: %v", job.ID, err)
+	}
+	return false
+}
+
+// provision creates and stores the wallet of a user and returns its account ID
+func (w *Worker) provision(userID int) (string, error) {
+	// A previous attempt may have stored the wallet before it was interrupted
+	existing, err := models.GetUserWallet(w.db, userID)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return existing.HederaAccountID, nil
+	}
+
+	accountID, privateKey, err := w.ledger.CreateAccount(wallet.InitialAccountBalance)
+	if err != nil {
+		return "", fmt.Errorf("failed to create account: %v", err)
+	}
+
+	if err := wallet.CreateUserWalletWithDeposit(w.db, userID, accountID, privateKey); err != nil {
+		// The account exists on the ledger but is lost to us, so log it for recovery
+		log.Printf("Created account %s for user %d but failed to store it: %v", accountID, userID, err)
+		return "", fmt.Errorf("failed to store wallet: %v", err)
+	}
+
+	// Let the wallet receive community token rewards. A failure here is
+	// retried when the first reward is paid.
+	if w.token.Enabled() {
+		if err := w.token.Associate(accountID, privateKey); err != nil {
+			log.Printf("Failed to associate community token with wallet of user %d: %v", userID, err)
+		} else if err := models.MarkTokenAssociated(w.db, userID); err != nil {
+			log.Printf("Failed to record token association for user %d: %v", userID, err)
+		}
+	}
+	return accountID, nil
+}
+
+// announce notifies a user about their wallet and pushes the change to
+// their open websocket connections
+func (w *Worker) announce(userID int, notificationType, message, accountID string) {
+	notificationID, err := models.CreateNotification(w.db, userID, notificationType, message, 0)
+	if err != nil {
+		log.Printf("Failed to create %s notification for user %d: %v", notificationType, userID, err)
+	}
+	if w.hub == nil {
+		return
+	}
+
+	walletStatus := models.WalletStatusActive
+	if notificationType == "wallet_failed" {
+		walletStatus = models.WalletStatusFailed
+	}
+	event, err := json.Marshal(map[string]interface{}{
+		"type":              "notification",
+		"recipient_id":      userID,
+		"notification_id":   notificationID,
+		"notification_type": notificationType,
+		"message":           message,
+		"wallet_status":     walletStatus,
+		"hedera_account_id": accountID,
+	})
+	if err != nil {
+		return
+	}
+	w.hub.SendMessage(event)
+}
+
+// Backoff returns the delay before retrying a job that has failed attempts times
+func Backoff(attempts int) time.Duration {
+	delay := BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= MaxBackoff {
+			return MaxBackoff
+		}
+	}
+	return delay
+}
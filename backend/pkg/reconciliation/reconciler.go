@@ -0,0 +1,278 @@
+// Package reconciliation compares custodial wallets against a Hedera mirror
+// node. It refreshes cached balances, records transfers that arrived from
+// outside the platform as deposits and records any other drift between the
+// ledger and the transfers table.
+package reconciliation
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	wallet "github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/money"
+)
+
+const (
+	// DefaultTolerance is the balance difference ignored as transaction fees
+	// that are not tracked in the transfers table, such as token associations
+	DefaultTolerance = money.TinybarsPerHbar / 10
+
+	// settleTime is how long after a wallet was last synced its balance is
+	// left alone, since the mirror node trails consensus by a few seconds
+	settleTime = 2 * time.Minute
+
+	walletBatchSize          = 100
+	transactionsPerWalletRun = 500
+)
+
+// Summary counts what a reconciliation pass did
+type Summary struct {
+	Wallets  int `json:"wallets"`
+	Deposits int `json:"deposits"`
+	Drift    int `json:"drift"`
+	Errors   int `json:"errors"`
+}
+
+// Reconciler reconciles custodial wallets against the mirror node
+type Reconciler struct {
+	db        *sql.DB
+	mirror    *wallet.MirrorNode
+	tolerance money.Tinybars
+	mu        sync.Mutex
+}
+
+// NewReconciler creates a reconciler reading from mirror
+func NewReconciler(db *sql.DB, mirror *wallet.MirrorNode, tolerance money.Tinybars) *Reconciler {
+	return &Reconciler{db: db, mirror: mirror, tolerance: tolerance}
+}
+
+// NewReconcilerFromEnv creates a reconciler for the mirror node configured
+// by HEDERA_MIRROR_NODE_URL, ignoring balance differences up to
+// RECONCILIATION_TOLERANCE HBAR. It is disabled when no mirror node applies.
+func NewReconcilerFromEnv(db *sql.DB) (*Reconciler, error) {
+	tolerance := DefaultTolerance
+	if value := os.Getenv("RECONCILIATION_TOLERANCE"); value != "" {
+		parsed, err := money.ParseHbar(value)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid RECONCILIATION_TOLERANCE %q", value)
+		}
+		tolerance = parsed
+	}
+	return NewReconciler(db, wallet.NewMirrorNodeFromEnv(), tolerance), nil
+}
+
+// Enabled reports whether a mirror node is configured
+func (r *Reconciler) Enabled() bool {
+	return r != nil && r.mirror != nil
+}
+
+// Run reconciles every wallet every interval
+func (r *Reconciler) Run(interval time.Duration) {
+	if !r.Enabled() {
+		log.Printf("Balance reconciliation disabled: no mirror node for this ledger")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		summary := r.ReconcileAll()
+		if summary.Deposits > 0 || summary.Drift > 0 || summary.Errors > 0 {
+			log.Printf("Reconciled %d wallets: %d deposits, %d drift findings, %d errors",
+				summary.Wallets, summary.Deposits, summary.Drift, summary.Errors)
+		}
+		<-ticker.C
+	}
+}
+
+// ReconcileAll reconciles every custodial wallet. Passes never overlap.
+func (r *Reconciler) ReconcileAll() Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var summary Summary
+	lastID := 0
+	for {
+		wallets, err := models.GetWalletsAfter(r.db, lastID, walletBatchSize)
+		if err != nil {
+			log.Printf("Failed to list wallets for reconciliation: %v", err)
+			summary.Errors++
+			return summary
+		}
+		if len(wallets) == 0 {
+			return summary
+		}
+
+		for i := range wallets {
+			lastID = wallets[i].ID
+			summary.Wallets++
+			if err := r.reconcile(&wallets[i], &summary); err != nil {
+				log.Printf("Failed to reconcile wallet of user %d: %v", wallets[i].UserID, err)
+				summary.Errors++
+			}
+		}
+	}
+}
+
+// reconcile checks the transactions since the wallet's cursor and then its
+// balance. The cursor only moves once the balance check has accounted for
+// those transactions; until then they are checked again on the next pass,
+// which is safe because deposits and drift findings are recorded once.
+func (r *Reconciler) reconcile(w *models.UserWallet, summary *Summary) error {
+	if time.Since(w.UpdatedAt) < settleTime {
+		return nil
+	}
+
+	transactions, err := r.mirror.GetTransactions(w.HederaAccountID, w.MirrorCursor, transactionsPerWalletRun)
+	if err != nil {
+		return err
+	}
+
+	cursor := w.MirrorCursor
+	var unsynced money.Tinybars
+	for _, transaction := range transactions {
+		amount, err := r.checkTransaction(w, transaction, summary)
+		if err != nil {
+			return err
+		}
+		unsynced += amount
+		cursor = transaction.ConsensusTimestamp
+	}
+
+	settled, err := r.checkBalance(w, unsynced, summary)
+	if err != nil || !settled {
+		return err
+	}
+	return models.MarkWalletReconciled(r.db, w.ID, cursor)
+}
+
+// checkTransaction matches one ledger transaction against the transfers
+// table. It returns the amount the transaction moved that the cached balance
+// cannot reflect yet, because the transfer service did not make it.
+func (r *Reconciler) checkTransaction(w *models.UserWallet, transaction wallet.MirrorTransaction, summary *Summary) (money.Tinybars, error) {
+	if !transaction.Succeeded() {
+		return 0, nil
+	}
+	net := transaction.NetAmount(w.HederaAccountID)
+	if net == 0 {
+		// Token transfers and NFT deliveries move no HBAR for the user
+		return 0, nil
+	}
+
+	transfer, err := models.GetTransferByTransactionID(r.db, transaction.TransactionID)
+	if err != nil {
+		return 0, err
+	}
+
+	if transfer != nil {
+		expected := transfer.Amount
+		if transfer.ToUserID != w.UserID {
+			expected = -expected
+		}
+		if net != expected {
+			return 0, r.recordDrift(summary, w, models.DriftAmountMismatch, transaction.TransactionID, &transfer.ID, &expected, &net,
+				fmt.Sprintf("transfer %d records %s HBAR", transfer.ID, expected))
+		}
+		if transfer.Status == models.TransferStatusFailed || transfer.Status == models.TransferStatusNeedsReview {
+			return 0, r.recordDrift(summary, w, models.DriftStatusMismatch, transaction.TransactionID, &transfer.ID, nil, &net,
+				fmt.Sprintf("transfer %d is marked %s but succeeded on the ledger", transfer.ID, transfer.Status))
+		}
+		if transfer.Kind == models.TransferKindDeposit {
+			return net, nil
+		}
+		return 0, nil
+	}
+
+	if net > 0 {
+		sender, err := models.GetWalletByAccountID(r.db, transaction.PayerAccountID)
+		if err != nil {
+			return 0, err
+		}
+		if sender == nil {
+			return net, r.ingestDeposit(w, transaction, net, summary)
+		}
+	}
+
+	// Custodial accounts should only move HBAR through the transfer service
+	return net, r.recordDrift(summary, w, models.DriftUnrecordedTransaction, transaction.TransactionID, nil, nil, &net,
+		fmt.Sprintf("%s paid by %s has no transfer record", transaction.Name, transaction.PayerAccountID))
+}
+
+// ingestDeposit records HBAR sent to a user from an external account
+func (r *Reconciler) ingestDeposit(w *models.UserWallet, transaction wallet.MirrorTransaction, amount money.Tinybars, summary *Summary) error {
+	deposit, created, err := models.CreateDeposit(r.db, w.UserID, transaction.PayerAccountID, amount, transaction.TransactionID)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return nil
+	}
+	summary.Deposits++
+
+	message := fmt.Sprintf("You received a deposit of %s HBAR from %s", amount, transaction.PayerAccountID)
+	if _, err := models.CreateNotification(r.db, w.UserID, "deposit_received", message, deposit.ID); err != nil {
+		log.Printf("Failed to create deposit notification for transfer %d: %v", deposit.ID, err)
+	}
+	return nil
+}
+
+// checkBalance compares the ledger balance with the cached one plus the
+// unsynced amounts found in this pass, records unexplained differences and
+// refreshes the cache. It reports false when the wallet changed during the
+// pass, leaving the comparison to the next one.
+func (r *Reconciler) checkBalance(w *models.UserWallet, unsynced money.Tinybars, summary *Summary) (bool, error) {
+	balance, err := r.mirror.GetAccountBalance(w.HederaAccountID)
+	if err != nil {
+		return false, err
+	}
+
+	// A transfer may have synced the balance meanwhile, ahead of the mirror node
+	current, err := models.GetUserWallet(r.db, w.UserID)
+	if err != nil || current == nil {
+		return false, err
+	}
+	if !current.UpdatedAt.Equal(w.UpdatedAt) {
+		return false, nil
+	}
+
+	expected := current.TokenBalance + unsynced
+	difference := balance - expected
+	if difference > r.tolerance || difference < -r.tolerance {
+		if err := r.recordDrift(summary, current, models.DriftBalance, "", nil, &expected, &balance,
+			fmt.Sprintf("ledger balance differs by %s HBAR", difference)); err != nil {
+			return false, err
+		}
+	}
+	if balance != current.TokenBalance {
+		if err := models.SyncWalletBalance(r.db, current.UserID, balance); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// recordDrift stores a drift finding and counts it unless it was already recorded
+func (r *Reconciler) recordDrift(summary *Summary, w *models.UserWallet, kind, transactionID string, transferID *int, expected, actual *money.Tinybars, detail string) error {
+	recorded, err := models.RecordLedgerDrift(r.db, models.LedgerDrift{
+		UserID:          w.UserID,
+		HederaAccountID: w.HederaAccountID,
+		Kind:            kind,
+		TransactionID:   transactionID,
+		TransferID:      transferID,
+		Expected:        expected,
+		Actual:          actual,
+		Detail:          detail,
+	})
+	if err != nil || !recorded {
+		return err
+	}
+	summary.Drift++
+	log.Printf("Ledger drift for user %d (%s): %s", w.UserID, kind, detail)
+	return nil
+}
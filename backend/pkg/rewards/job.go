@@ -0,0 +1,257 @@
+package rewards
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	wallet "github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+// Defaults used when the REWARDS_* variables are not set
+const (
+	DefaultWindowDays      = 7
+	DefaultPool            = "1000"
+	DefaultMinScore        = 5
+	DefaultMaxSharePercent = 20
+)
+
+var (
+	ErrRunExists      = errors.New("a reward run already exists for this period")
+	ErrRunNotFound    = errors.New("reward run not found")
+	ErrRunNotApproved = errors.New("reward run is not awaiting approval")
+	ErrInvalidPeriod  = errors.New("reward period must end after it starts and not in the future")
+)
+
+// periodAnchor aligns reward periods so weekly windows run Monday to Monday UTC
+var periodAnchor = time.Date(1970, time.January, 5, 0, 0, 0, 0, time.UTC)
+
+// Config controls how rewards are scored and sized
+type Config struct {
+	Window          time.Duration
+	Pool            string // token amount shared by each run, e.g. "1000"
+	MinScore        int
+	MaxSharePercent int
+}
+
+// ConfigFromEnv reads the rewards configuration from REWARDS_WINDOW_DAYS,
+// REWARDS_POOL, REWARDS_MIN_SCORE and REWARDS_MAX_SHARE_PERCENT
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		Window:          DefaultWindowDays * 24 * time.Hour,
+		Pool:            DefaultPool,
+		MinScore:        DefaultMinScore,
+		MaxSharePercent: DefaultMaxSharePercent,
+	}
+
+	if value := os.Getenv("REWARDS_WINDOW_DAYS"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days <= 0 {
+			return config, fmt.Errorf("invalid REWARDS_WINDOW_DAYS %q", value)
+		}
+		config.Window = time.Duration(days) * 24 * time.Hour
+	}
+	if value := os.Getenv("REWARDS_POOL"); value != "" {
+		config.Pool = value
+	}
+	if value := os.Getenv("REWARDS_MIN_SCORE"); value != "" {
+		minScore, err := strconv.Atoi(value)
+		if err != nil || minScore < 0 {
+			return config, fmt.Errorf("invalid REWARDS_MIN_SCORE %q", value)
+		}
+		config.MinScore = minScore
+	}
+	if value := os.Getenv("REWARDS_MAX_SHARE_PERCENT"); value != "" {
+		percent, err := strconv.Atoi(value)
+		if err != nil || percent <= 0 || percent > 100 {
+			return config, fmt.Errorf("invalid REWARDS_MAX_SHARE_PERCENT %q", value)
+		}
+		config.MaxSharePercent = percent
+	}
+	return config, nil
+}
+
+// Job builds reward runs for completed periods and pays approved runs from
+// the community token treasury
+type Job struct {
+	db        *sql.DB
+	config    Config
+	token     *wallet.CommunityToken
+	transfers *wallet.TransferService
+	payMu     sync.Mutex
+}
+
+// NewJob creates a rewards job
+func NewJob(db *sql.DB, config Config, token *wallet.CommunityToken, transfers *wallet.TransferService) *Job {
+	return &Job{db: db, config: config, token: token, transfers: transfers}
+}
+
+// Enabled reports whether rewards can be paid, which needs a community token
+func (j *Job) Enabled() bool {
+	return j.token.Enabled()
+}
+
+// LastPeriod returns the most recent window that ended at or before now
+func (j *Job) LastPeriod(now time.Time) (time.Time, time.Time) {
+	elapsed := now.UTC().Sub(periodAnchor)
+	end := periodAnchor.Add(elapsed - elapsed%j.config.Window)
+	return end.Add(-j.config.Window), end
+}
+
+// Preview scores the period and sizes the allocations without storing anything
+func (j *Job) Preview(start, end time.Time) (*models.RewardRun, error) {
+	if !j.Enabled() {
+		return nil, wallet.ErrCommunityTokenDisabled
+	}
+	if !end.After(start) || end.After(time.Now()) {
+		return nil, ErrInvalidPeriod
+	}
+
+	poolUnits, err := j.token.Parse(j.config.Pool)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REWARDS_POOL %q: %v", j.config.Pool, err)
+	}
+
+	scores, err := ScoreContributors(j.db, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &models.RewardRun{
+		PeriodStart: start.UTC(),
+		PeriodEnd:   end.UTC(),
+		Status:      models.RewardRunPendingApproval,
+		PoolUnits:   poolUnits,
+		Allocations: Allocate(scores, poolUnits, j.config.MinScore, j.config.MaxSharePercent),
+	}
+	for _, allocation := range run.Allocations {
+		run.TotalUnits += allocation.AmountUnits
+	}
+	return run, nil
+}
+
+// CreateRun stores a run for the period awaiting admin approval
+func (j *Job) CreateRun(start, end time.Time) (*models.RewardRun, error) {
+	existing, err := models.GetRewardRunByPeriod(j.db, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrRunExists
+	}
+
+	run, err := j.Preview(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	runID, err := models.CreateRewardRun(j.db, *run)
+	if err != nil {
+		return nil, err
+	}
+	return models.GetRewardRun(j.db, runID)
+}
+
+// Approve approves a run and starts paying it in the background
+func (j *Job) Approve(runID, adminID int) (*models.RewardRun, error) {
+	approved, err := models.ApproveRewardRun(j.db, runID, adminID)
+	if err != nil {
+		return nil, err
+	}
+	if !approved {
+		run, err := models.GetRewardRun(j.db, runID)
+		if err != nil {
+			return nil, err
+		}
+		if run == nil {
+			return nil, ErrRunNotFound
+		}
+		return nil, ErrRunNotApproved
+	}
+
+	go func() {
+		if err := j.Pay(runID); err != nil {
+			log.Printf("Failed to pay reward run %d: %v", runID, err)
+		}
+	}()
+	return models.GetRewardRun(j.db, runID)
+}
+
+// Pay submits the pending allocations of an approved run one by one. Each
+// payment is tracked on its allocation, so Pay can be called again to retry
+// a run that was interrupted.
+func (j *Job) Pay(runID int) error {
+	j.payMu.Lock()
+	defer j.payMu.Unlock()
+
+	run, err := models.GetRewardRun(j.db, runID)
+	if err != nil {
+		return err
+	}
+	if run == nil {
+		return ErrRunNotFound
+	}
+	if run.Status != models.RewardRunApproved {
+		return nil
+	}
+
+	for i := range run.Allocations {
+		allocation := &run.Allocations[i]
+		if allocation.Status != models.RewardAllocationPending {
+			continue
+		}
+		if err := j.transfers.PayRewardAllocation(allocation); err != nil {
+			return fmt.Errorf("allocation %d: %v", allocation.ID, err)
+		}
+	}
+
+	// Runs without allocations have nothing to settle them
+	return models.CompleteRewardRunIfSettled(j.db, runID)
+}
+
+// Run creates a run for each completed period and finishes paying approved
+// runs every interval
+func (j *Job) Run(interval time.Duration) {
+	if !j.Enabled() {
+		log.Printf("Contributor rewards disabled: community token is not configured")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		j.tick()
+	}
+}
+
+// tick performs one pass of the background job
+func (j *Job) tick() {
+	start, end := j.LastPeriod(time.Now())
+	run, err := j.CreateRun(start, end)
+	switch {
+	case errors.Is(err, ErrRunExists):
+	case err != nil:
+		log.Printf("Failed to create reward run for %s: %v", start.Format("2006-01-02"), err)
+	default:
+		log.Printf("Created reward run %d for %s with %d allocations awaiting approval",
+			run.ID, start.Format("2006-01-02"), len(run.Allocations))
+	}
+
+	approved, err := models.GetRewardRuns(j.db, models.RewardRunApproved, 20)
+	if err != nil {
+		log.Printf("Failed to list approved reward runs: %v", err)
+		return
+	}
+	for _, approvedRun := range approved {
+		if err := j.Pay(approvedRun.ID); err != nil {
+			log.Printf("Failed to pay reward run %d: %v", approvedRun.ID, err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package rewards
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+// Points awarded per qualifying action and the caps that keep a single user
+// from farming the pool with bursts of low effort activity
+const (
+	PointsPerPost      = 3
+	MaxPostsPerDay     = 3
+	PointsPerComment   = 1
+	MaxCommentsPerDay  = 10
+	MinCommentLength   = 20
+	PointsPerLiker     = 1
+	MaxLikersPerWindow = 50
+)
+
+// Score breakdown categories
+const (
+	breakdownPosts         = "posts"
+	breakdownComments      = "comments"
+	breakdownLikesReceived = "likes_received"
+)
+
+// Score is a user's contribution score for one period
+type Score struct {
+	UserID    int
+	Breakdown map[string]int
+	Total     int
+}
+
+// ScoreContributors scores every wallet holder with activity in [start, end).
+// Posts and comments count up to a daily cap, comments only on other users'
+// posts, and likes count once per distinct liker up to a cap per window.
+func ScoreContributors(database *sql.DB, start, end time.Time) ([]Score, error) {
+	scores := make(map[int]*Score)
+	add := func(userID int, category string, points int) {
+		score, ok := scores[userID]
+		if !ok {
+			score = &Score{UserID: userID, Breakdown: make(map[string]int)}
+			scores[userID] = score
+		}
+		score.Breakdown[category] += points
+		score.Total += points
+	}
+
+	posts, err := models.GetDailyPostCounts(database, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range posts {
+		add(count.UserID, breakdownPosts, capAt(count.Count, MaxPostsPerDay)*PointsPerPost)
+	}
+
+	comments, err := models.GetDailyCommentCounts(database, start, end, MinCommentLength)
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range comments {
+		add(count.UserID, breakdownComments, capAt(count.Count, MaxCommentsPerDay)*PointsPerComment)
+	}
+
+	likers, err := models.GetDistinctLikersCounts(database, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range likers {
+		add(count.UserID, breakdownLikesReceived, capAt(count.Count, MaxLikersPerWindow)*PointsPerLiker)
+	}
+
+	result := make([]Score, 0, len(scores))
+	for _, score := range scores {
+		result = append(result, *score)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Total != result[j].Total {
+			return result[i].Total > result[j].Total
+		}
+		return result[i].UserID < result[j].UserID
+	})
+	return result, nil
+}
+
+// Allocate splits pool token units between scores of at least minScore in
+// proportion to their totals. No user receives more than maxSharePercent of
+// the pool; units left over by rounding or the cap stay in the treasury.
+func Allocate(scores []Score, poolUnits int64, minScore, maxSharePercent int) []models.RewardAllocation {
+	var eligible []Score
+	var totalScore int64
+	for _, score := range scores {
+		if score.Total >= minScore && score.Total > 0 {
+			eligible = append(eligible, score)
+			totalScore += int64(score.Total)
+		}
+	}
+	if totalScore == 0 || poolUnits <= 0 {
+		return nil
+	}
+
+	maxUnits := poolUnits * int64(maxSharePercent) / 100
+	allocations := make([]models.RewardAllocation, 0, len(eligible))
+	for _, score := range eligible {
+		units := poolUnits * int64(score.Total) / totalScore
+		if units > maxUnits {
+			units = maxUnits
+		}
+		if units <= 0 {
+			continue
+		}
+		allocations = append(allocations, models.RewardAllocation{
+			UserID:      score.UserID,
+			Score:       score.Total,
+			Breakdown:   score.Breakdown,
+			AmountUnits: units,
+			Status:      models.RewardAllocationPending,
+		})
+	}
+	return allocations
+}
+
+// capAt limits n to max
+func capAt(n, max int) int {
+	if n > max {
+		return max
+	}
+	return n
+}
@@ -0,0 +1,26 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/On-cure/Oncure/pkg/handlers"
+	"github.com/On-cure/Oncure/pkg/middleware"
+)
+
+// SetupAdminRoutes configures the staff routes for users, content, platform
+// statistics and the audit log
+func SetupAdminRoutes(router *Router, adminHandler *handlers.AdminHandler, authMiddleware func(http.Handler) http.Handler) {
+	// Staff routes (require authentication and an admin or moderator account)
+	router.AddRoute("GET", "/api/admin/users", WithAuth(middleware.RequireStaff(adminHandler.SearchUsers), authMiddleware))
+	router.AddRoute("GET", "/api/admin/users/{userID}", WithAuth(middleware.RequireStaff(adminHandler.GetUser), authMiddleware))
+	router.AddRoute("POST", "/api/admin/users/{userID}/suspend", WithAuth(middleware.RequireStaff(adminHandler.SuspendUser), authMiddleware))
+	router.AddRoute("POST", "/api/admin/users/{userID}/unsuspend", WithAuth(middleware.RequireStaff(adminHandler.UnsuspendUser), authMiddleware))
+	router.AddRoute("POST", "/api/admin/content/{contentType}/{contentID}/takedown", WithAuth(middleware.RequireStaff(adminHandler.TakeDownContent), authMiddleware))
+	router.AddRoute("POST", "/api/admin/content/{contentType}/{contentID}/hide", WithAuth(middleware.RequireStaff(adminHandler.HideContent), authMiddleware))
+	router.AddRoute("POST", "/api/admin/content/{contentType}/{contentID}/unhide", WithAuth(middleware.RequireStaff(adminHandler.UnhideContent), authMiddleware))
+	router.AddRoute("GET", "/api/admin/stats", WithAuth(middleware.RequireStaff(adminHandler.GetStats), authMiddleware))
+
+	// Admin routes (require authentication and an admin account)
+	router.AddRoute("PUT", "/api/admin/users/{userID}/staff-role", WithAuth(middleware.RequireAdmin(adminHandler.SetStaffRole), authMiddleware))
+	router.AddRoute("GET", "/api/admin/audit-log", WithAuth(middleware.RequireAdmin(adminHandler.GetAuditLog), authMiddleware))
+}
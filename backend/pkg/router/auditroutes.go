@@ -0,0 +1,13 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/On-cure/Oncure/pkg/handlers"
+)
+
+// SetupAuditRoutes configures the audit trail verification routes
+func SetupAuditRoutes(router *Router, auditHandler *handlers.AuditHandler, authMiddleware func(http.Handler) http.Handler) {
+	// recordType is tip, reward_payout or badge_mint
+	router.AddRoute("GET", "/api/audit/{recordType}/{recordID}", WithAuth(auditHandler.VerifyRecord, authMiddleware))
+}
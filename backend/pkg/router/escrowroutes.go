@@ -0,0 +1,25 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/On-cure/Oncure/pkg/emailverification"
+	"github.com/On-cure/Oncure/pkg/handlers"
+	"github.com/On-cure/Oncure/pkg/middleware"
+)
+
+// SetupEscrowRoutes configures paid mentorship session routes
+func SetupEscrowRoutes(router *Router, escrowHandler *handlers.EscrowHandler, authMiddleware func(http.Handler) http.Handler) {
+	// Participant routes
+	router.AddRoute("POST", "/api/sessions", WithAuth(middleware.RequireVerifiedEmail(emailverification.ActionSessions)(escrowHandler.BookSession), authMiddleware))
+	router.AddRoute("GET", "/api/sessions", WithAuth(escrowHandler.GetSessions, authMiddleware))
+	router.AddRoute("GET", "/api/sessions/{escrowID}", WithAuth(escrowHandler.GetSession, authMiddleware))
+	router.AddRoute("POST", "/api/sessions/{escrowID}/complete", WithAuth(escrowHandler.CompleteSession, authMiddleware))
+	router.AddRoute("POST", "/api/sessions/{escrowID}/cancel", WithAuth(escrowHandler.CancelSession, authMiddleware))
+	router.AddRoute("POST", "/api/sessions/{escrowID}/dispute", WithAuth(escrowHandler.DisputeSession, authMiddleware))
+
+	// Admin routes (require authentication and an admin account)
+	router.AddRoute("GET", "/api/admin/sessions", WithAuth(middleware.RequireAdmin(escrowHandler.GetAdminSessions), authMiddleware))
+	router.AddRoute("GET", "/api/admin/sessions/{escrowID}", WithAuth(middleware.RequireAdmin(escrowHandler.GetAdminSession), authMiddleware))
+	router.AddRoute("POST", "/api/admin/sessions/{escrowID}/resolve", WithAuth(middleware.RequireAdmin(escrowHandler.ResolveSession), authMiddleware))
+}
@@ -3,7 +3,9 @@ package router
 import (
 	"net/http"
 
+	"github.com/On-cure/Oncure/pkg/emailverification"
 	"github.com/On-cure/Oncure/pkg/handlers"
+	"github.com/On-cure/Oncure/pkg/middleware"
 )
 
 // SetupGroupRoutes configures group-related routes
@@ -24,13 +26,13 @@ func SetupGroupRoutes(router *Router, groupHandler *handlers.GroupHandler, group
 
 	// Group posts
 	router.AddRoute("GET", "/api/groups/{groupID}/posts", WithAuth(groupHandler.GetPosts, authMiddleware))
-	router.AddRoute("POST", "/api/groups/{groupID}/posts", WithAuth(groupHandler.CreatePost, authMiddleware))
+	router.AddRoute("POST", "/api/groups/{groupID}/posts", WithAuth(middleware.RequireVerifiedEmail(emailverification.ActionPosting)(groupHandler.CreatePost), authMiddleware))
 	router.AddRoute("GET", "/api/groups/{groupID}/posts/{postID}/reactions", WithAuth(groupHandler.GetGroupPostReactions, authMiddleware))
 	router.AddRoute("POST", "/api/groups/{groupID}/posts/{postID}/reactions", WithAuth(groupHandler.AddGroupPostReaction, authMiddleware))
 
 	// Group post comments
 	router.AddRoute("GET", "/api/groups/{groupID}/posts/{groupPostID}/comments", WithAuth(groupCommentHandler.GetGroupPostComments, authMiddleware))
-	router.AddRoute("POST", "/api/groups/{groupID}/posts/{groupPostID}/comments", WithAuth(groupCommentHandler.CreateGroupPostComment, authMiddleware))
+	router.AddRoute("POST", "/api/groups/{groupID}/posts/{groupPostID}/comments", WithAuth(middleware.RequireVerifiedEmail(emailverification.ActionPosting)(groupCommentHandler.CreateGroupPostComment), authMiddleware))
 
 	// Group comment routes
 	router.AddRoute("GET", "/api/groups/comments/{commentID}", WithAuth(groupCommentHandler.GetGroupPostComment, authMiddleware))
@@ -46,5 +48,5 @@ func SetupGroupRoutes(router *Router, groupHandler *handlers.GroupHandler, group
 
 	// Group chat
 	router.AddRoute("GET", "/api/groups/{groupID}/messages", WithAuth(messageHandler.GetGroupMessages, authMiddleware))
-	router.AddRoute("POST", "/api/groups/{groupID}/messages", WithAuth(messageHandler.SendGroupMessage, authMiddleware))
+	router.AddRoute("POST", "/api/groups/{groupID}/messages", WithAuth(middleware.RequireVerifiedEmail(emailverification.ActionMessaging)(messageHandler.SendGroupMessage), authMiddleware))
 }
@@ -0,0 +1,11 @@
+package router
+
+import (
+	"github.com/On-cure/Oncure/pkg/handlers"
+)
+
+// SetupHealthRoutes configures the unauthenticated liveness and readiness checks
+func SetupHealthRoutes(router *Router, healthHandler *handlers.HealthHandler) {
+	router.AddRoute("GET", "/api/health", healthHandler.GetHealth)
+	router.AddRoute("GET", "/api/health/ready", healthHandler.GetReadiness)
+}
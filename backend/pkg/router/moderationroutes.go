@@ -0,0 +1,19 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/On-cure/Oncure/pkg/handlers"
+	"github.com/On-cure/Oncure/pkg/middleware"
+)
+
+// SetupModerationRoutes configures content reporting and the moderation queue
+func SetupModerationRoutes(router *Router, moderationHandler *handlers.ModerationHandler, authMiddleware func(http.Handler) http.Handler) {
+	// Reporting (requires authentication)
+	router.AddRoute("POST", "/api/reports", WithAuth(moderationHandler.ReportContent, authMiddleware))
+
+	// Moderation queue (requires authentication and an admin or moderator account)
+	router.AddRoute("GET", "/api/admin/moderation/cases", WithAuth(middleware.RequireStaff(moderationHandler.GetQueue), authMiddleware))
+	router.AddRoute("GET", "/api/admin/moderation/cases/{caseID}", WithAuth(middleware.RequireStaff(moderationHandler.GetCase), authMiddleware))
+	router.AddRoute("POST", "/api/admin/moderation/cases/{caseID}/resolve", WithAuth(middleware.RequireStaff(moderationHandler.ResolveCase), authMiddleware))
+}
@@ -3,7 +3,9 @@ package router
 import (
 	"net/http"
 
+	"github.com/On-cure/Oncure/pkg/emailverification"
 	"github.com/On-cure/Oncure/pkg/handlers"
+	"github.com/On-cure/Oncure/pkg/middleware"
 )
 
 // SetupPostRoutes configures post-related routes
@@ -13,7 +15,7 @@ func SetupPostRoutes(router *Router, postHandler *handlers.PostHandler, commentH
 	router.AddRoute("GET", "/api/posts/liked", WithAuth(postHandler.GetLikedPosts, authMiddleware))
 	router.AddRoute("GET", "/api/posts/commented", WithAuth(postHandler.GetCommentedPosts, authMiddleware))
 	router.AddRoute("GET", "/api/posts/saved", WithAuth(postHandler.GetSavedPosts, authMiddleware))
-	router.AddRoute("POST", "/api/posts", WithAuth(postHandler.CreatePost, authMiddleware))
+	router.AddRoute("POST", "/api/posts", WithAuth(middleware.RequireVerifiedEmail(emailverification.ActionPosting)(postHandler.CreatePost), authMiddleware))
 	router.AddRoute("PUT", "/api/posts", WithAuth(postHandler.UpdatePost, authMiddleware))
 	router.AddRoute("DELETE", "/api/posts", WithAuth(postHandler.DeletePost, authMiddleware))
 
@@ -24,7 +26,7 @@ func SetupPostRoutes(router *Router, postHandler *handlers.PostHandler, commentH
 
 	// Post comments
 	router.AddRoute("GET", "/api/posts/{postID}/comments", WithAuth(commentHandler.GetPostComments, authMiddleware))
-	router.AddRoute("POST", "/api/posts/{postID}/comments", WithAuth(commentHandler.CreateComment, authMiddleware))
+	router.AddRoute("POST", "/api/posts/{postID}/comments", WithAuth(middleware.RequireVerifiedEmail(emailverification.ActionPosting)(commentHandler.CreateComment), authMiddleware))
 
 	// Post reactions
 	router.AddRoute("GET", "/api/posts/{postID}/reactions", WithAuth(postHandler.GetReactions, authMiddleware))
@@ -0,0 +1,15 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/On-cure/Oncure/pkg/handlers"
+	"github.com/On-cure/Oncure/pkg/middleware"
+)
+
+// SetupReconciliationRoutes configures the admin routes for ledger reconciliation
+func SetupReconciliationRoutes(router *Router, reconciliationHandler *handlers.ReconciliationHandler, authMiddleware func(http.Handler) http.Handler) {
+	// Admin routes (require authentication and an admin account)
+	router.AddRoute("GET", "/api/admin/ledger/drift", WithAuth(middleware.RequireAdmin(reconciliationHandler.GetLedgerDrift), authMiddleware))
+	router.AddRoute("POST", "/api/admin/ledger/reconcile", WithAuth(middleware.RequireAdmin(reconciliationHandler.Reconcile), authMiddleware))
+}
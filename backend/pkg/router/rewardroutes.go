@@ -0,0 +1,18 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/On-cure/Oncure/pkg/handlers"
+	"github.com/On-cure/Oncure/pkg/middleware"
+)
+
+// SetupRewardRoutes configures the admin routes for contributor rewards
+func SetupRewardRoutes(router *Router, rewardHandler *handlers.RewardHandler, authMiddleware func(http.Handler) http.Handler) {
+	// Admin routes (require authentication and an admin account)
+	router.AddRoute("GET", "/api/admin/rewards/preview", WithAuth(middleware.RequireAdmin(rewardHandler.PreviewRewards), authMiddleware))
+	router.AddRoute("GET", "/api/admin/rewards/runs", WithAuth(middleware.RequireAdmin(rewardHandler.GetRewardRuns), authMiddleware))
+	router.AddRoute("POST", "/api/admin/rewards/runs", WithAuth(middleware.RequireAdmin(rewardHandler.CreateRewardRun), authMiddleware))
+	router.AddRoute("GET", "/api/admin/rewards/runs/{runID}", WithAuth(middleware.RequireAdmin(rewardHandler.GetRewardRun), authMiddleware))
+	router.AddRoute("POST", "/api/admin/rewards/runs/{runID}/approve", WithAuth(middleware.RequireAdmin(rewardHandler.ApproveRewardRun), authMiddleware))
+}
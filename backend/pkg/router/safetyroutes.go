@@ -0,0 +1,23 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/On-cure/Oncure/pkg/handlers"
+	"github.com/On-cure/Oncure/pkg/middleware"
+)
+
+// SetupSafetyRoutes configures crisis resources, care circles and crisis alerts
+func SetupSafetyRoutes(router *Router, safetyHandler *handlers.SafetyHandler, authMiddleware func(http.Handler) http.Handler) {
+	// Crisis resources are public so they can be shown before signing in
+	router.AddRoute("GET", "/api/safety/resources", safetyHandler.GetResources)
+
+	// Care circle (requires authentication)
+	router.AddRoute("GET", "/api/safety/care-circle", WithAuth(safetyHandler.GetCareCircle, authMiddleware))
+	router.AddRoute("POST", "/api/safety/care-circle", WithAuth(safetyHandler.AddCareCircleContact, authMiddleware))
+	router.AddRoute("DELETE", "/api/safety/care-circle/{userID}", WithAuth(safetyHandler.RemoveCareCircleContact, authMiddleware))
+
+	// Crisis alerts (requires authentication and an admin or moderator account)
+	router.AddRoute("GET", "/api/admin/safety/alerts", WithAuth(middleware.RequireStaff(safetyHandler.GetAlerts), authMiddleware))
+	router.AddRoute("POST", "/api/admin/safety/alerts/{alertID}/acknowledge", WithAuth(middleware.RequireStaff(safetyHandler.AcknowledgeAlert), authMiddleware))
+}
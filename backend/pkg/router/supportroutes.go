@@ -3,7 +3,9 @@ package router
 import (
 	"net/http"
 
+	"github.com/On-cure/Oncure/pkg/emailverification"
 	"github.com/On-cure/Oncure/pkg/handlers"
+	"github.com/On-cure/Oncure/pkg/middleware"
 )
 
 // SetupUploadRoutes configures upload-related routes
@@ -26,7 +28,7 @@ func SetupMessageRoutes(router *Router, messageHandler *handlers.MessageHandler,
 	router.AddRoute("GET", "/api/messages/conversations", WithAuth(messageHandler.GetConversations, authMiddleware))
 	router.AddRoute("GET", "/api/messages/unread-count", WithAuth(messageHandler.GetUnreadMessageCount, authMiddleware))
 	router.AddRoute("GET", "/api/messages/{userID}", WithAuth(messageHandler.GetPrivateMessages, authMiddleware))
-	router.AddRoute("POST", "/api/messages/{userID}", WithAuth(messageHandler.SendPrivateMessage, authMiddleware))
+	router.AddRoute("POST", "/api/messages/{userID}", WithAuth(middleware.RequireVerifiedEmail(emailverification.ActionMessaging)(messageHandler.SendPrivateMessage), authMiddleware))
 	router.AddRoute("PUT", "/api/messages/{userID}/read", WithAuth(messageHandler.MarkMessagesAsRead, authMiddleware))
 }
 
@@ -3,14 +3,25 @@ package router
 import (
 	"net/http"
 
+	"github.com/On-cure/Oncure/pkg/emailverification"
 	"github.com/On-cure/Oncure/pkg/handlers"
+	"github.com/On-cure/Oncure/pkg/middleware"
 )
 
 // SetupTransferRoutes configures HBAR transfer routes
 func SetupTransferRoutes(router *Router, transferHandler *handlers.TransferHandler, authMiddleware func(http.Handler) http.Handler) {
 	// Transfer routes
-	router.AddRoute("POST", "/api/transfer/hbar", WithAuth(transferHandler.TransferHbar, authMiddleware))
+	router.AddRoute("POST", "/api/transfer/hbar", WithAuth(middleware.RequireVerifiedEmail(emailverification.ActionTipping)(transferHandler.TransferHbar), authMiddleware))
 	router.AddRoute("GET", "/api/transfer/balance", WithAuth(transferHandler.GetBalance, authMiddleware))
 	router.AddRoute("GET", "/api/transfer/balance/user", WithAuth(transferHandler.GetUserBalance, authMiddleware))
 	router.AddRoute("GET", "/api/transfer/history", WithAuth(transferHandler.GetTransferHistory, authMiddleware))
+	router.AddRoute("GET", "/api/transfer/history/export", WithAuth(transferHandler.ExportTransferHistory, authMiddleware))
+	router.AddRoute("GET", "/api/transfer/limits", WithAuth(transferHandler.GetTransferLimits, authMiddleware))
+
+	// Tips linked to the content they reward
+	router.AddRoute("POST", "/api/posts/{postID}/tips", WithAuth(middleware.RequireVerifiedEmail(emailverification.ActionTipping)(transferHandler.TipPost), authMiddleware))
+	router.AddRoute("POST", "/api/comments/{commentID}/tips", WithAuth(middleware.RequireVerifiedEmail(emailverification.ActionTipping)(transferHandler.TipComment), authMiddleware))
+
+	// Admin review of transfers refused by the transfer policy
+	router.AddRoute("GET", "/api/admin/transfers/rejections", WithAuth(middleware.RequireAdmin(transferHandler.GetTransferRejections), authMiddleware))
 }
\ No newline at end of file
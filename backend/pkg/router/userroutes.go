@@ -19,6 +19,8 @@ func SetupUserRoutes(router *Router, userHandler *handlers.UserHandler, authMidd
 	router.AddRoute("PUT", "/api/users/profile/privacy", WithAuth(userHandler.UpdateProfilePrivacy, authMiddleware))
 	router.AddRoute("GET", "/api/users/all", WithAuth(userHandler.GetAllUsers, authMiddleware))
 	router.AddRoute("POST", "/api/users/accept-message-request", WithAuth(userHandler.AcceptMessageRequestHandler, authMiddleware))
+	router.AddRoute("GET", "/api/users/settings/blocking", WithAuth(userHandler.GetBlockingSettings, authMiddleware))
+	router.AddRoute("PUT", "/api/users/settings/blocking", WithAuth(userHandler.UpdateBlockingSettings, authMiddleware))
 
 	// User-specific routes
 	router.AddRoute("GET", "/api/users/{userID}/profile", WithAuth(userHandler.GetProfile, authMiddleware))
@@ -30,12 +32,29 @@ func SetupUserRoutes(router *Router, userHandler *handlers.UserHandler, authMidd
 	router.AddRoute("DELETE", "/api/users/{userID}/follow", WithAuth(userHandler.UnfollowUser, authMiddleware))
 	router.AddRoute("DELETE", "/api/users/{userID}/follow-request", WithAuth(userHandler.CancelFollowRequest, authMiddleware))
 	router.AddRoute("POST", "/api/users/{userID}/accept-follow", WithAuth(userHandler.AcceptFollowRequest, authMiddleware))
+	router.AddRoute("POST", "/api/users/{userID}/block", WithAuth(userHandler.BlockUser, authMiddleware))
+	router.AddRoute("DELETE", "/api/users/{userID}/block", WithAuth(userHandler.UnblockUser, authMiddleware))
+	router.AddRoute("POST", "/api/users/{userID}/mute", WithAuth(userHandler.MuteUser, authMiddleware))
+	router.AddRoute("DELETE", "/api/users/{userID}/mute", WithAuth(userHandler.UnmuteUser, authMiddleware))
 }
 
 // SetupAuthRoutes configures authentication routes
-func SetupAuthRoutes(router *Router, authHandler *handlers.AuthHandler) {
+func SetupAuthRoutes(router *Router, authHandler *handlers.AuthHandler, authMiddleware func(http.Handler) http.Handler) {
 	router.AddRoute("POST", "/api/auth/register", authHandler.Register)
 	router.AddRoute("POST", "/api/auth/login", authHandler.Login)
 	router.AddRoute("POST", "/api/auth/logout", authHandler.Logout)
 	router.AddRoute("GET", "/api/auth/session", authHandler.GetSession)
+	router.AddRoute("GET", "/api/auth/sessions", WithAuth(authHandler.ListSessions, authMiddleware))
+	router.AddRoute("DELETE", "/api/auth/sessions/{sessionID}", WithAuth(authHandler.RevokeSession, authMiddleware))
+	router.AddRoute("POST", "/api/auth/logout/all", WithAuth(authHandler.LogoutEverywhere, authMiddleware))
+	router.AddRoute("POST", "/api/auth/password/forgot", authHandler.ForgotPassword)
+	router.AddRoute("POST", "/api/auth/password/reset", authHandler.ResetPassword)
+	router.AddRoute("POST", "/api/auth/email/confirm", authHandler.ConfirmEmail)
+	router.AddRoute("POST", "/api/auth/email/resend", WithAuth(authHandler.ResendVerificationEmail, authMiddleware))
+	router.AddRoute("POST", "/api/auth/login/2fa", authHandler.CompleteTwoFactorLogin)
+	router.AddRoute("GET", "/api/auth/2fa", WithAuth(authHandler.GetTwoFactorStatus, authMiddleware))
+	router.AddRoute("POST", "/api/auth/2fa/setup", WithAuth(authHandler.SetupTwoFactor, authMiddleware))
+	router.AddRoute("POST", "/api/auth/2fa/enable", WithAuth(authHandler.EnableTwoFactor, authMiddleware))
+	router.AddRoute("POST", "/api/auth/2fa/disable", WithAuth(authHandler.DisableTwoFactor, authMiddleware))
+	router.AddRoute("POST", "/api/auth/2fa/recovery-codes", WithAuth(authHandler.RegenerateRecoveryCodes, authMiddleware))
 }
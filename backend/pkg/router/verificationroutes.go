@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/On-cure/Oncure/pkg/handlers"
+	"github.com/On-cure/Oncure/pkg/middleware"
 )
 
 // SetupVerificationRoutes sets up verification-related routes
@@ -11,4 +12,11 @@ func SetupVerificationRoutes(router *Router, verificationHandler *handlers.Verif
 	// Verification routes (require authentication)
 	router.AddRoute("POST", "/api/verification/request", WithAuth(verificationHandler.RequestVerification, authMiddleware))
 	router.AddRoute("GET", "/api/verification/status", WithAuth(verificationHandler.GetVerificationStatus, authMiddleware))
+
+	// Admin review routes (require authentication and an admin account)
+	router.AddRoute("GET", "/api/admin/verification/requests", WithAuth(middleware.RequireAdmin(verificationHandler.GetAdminRequests), authMiddleware))
+	router.AddRoute("GET", "/api/admin/verification/requests/{requestID}", WithAuth(middleware.RequireAdmin(verificationHandler.GetAdminRequest), authMiddleware))
+	router.AddRoute("POST", "/api/admin/verification/requests/{requestID}/approve", WithAuth(middleware.RequireAdmin(verificationHandler.ApproveRequest), authMiddleware))
+	router.AddRoute("POST", "/api/admin/verification/requests/{requestID}/reject", WithAuth(middleware.RequireAdmin(verificationHandler.RejectRequest), authMiddleware))
+	router.AddRoute("POST", "/api/admin/verification/requests/{requestID}/request-info", WithAuth(middleware.RequireAdmin(verificationHandler.RequestMoreInfo), authMiddleware))
 }
\ No newline at end of file
@@ -0,0 +1,180 @@
+package safety
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+// Classifier decides whether text contains crisis language. Implementations
+// must be safe for concurrent use.
+type Classifier interface {
+	Classify(text string) Match
+}
+
+// Match is the outcome of classifying a text. The zero Match means no
+// crisis language was found.
+type Match struct {
+	Category string
+	Severity string
+	Phrases  []string
+}
+
+// Matched reports whether the text contained crisis language
+func (m Match) Matched() bool {
+	return len(m.Phrases) > 0
+}
+
+// Rule is a phrase that signals crisis language
+type Rule struct {
+	Phrase   string `json:"phrase"`
+	Category string `json:"category"`
+	Severity string `json:"severity"`
+}
+
+// RuleSet configures a RuleClassifier. Exclusions are phrases removed from
+// the text before the rules are applied, e.g. "dont want to die", which
+// would otherwise match "want to die".
+type RuleSet struct {
+	Rules      []Rule   `json:"rules"`
+	Exclusions []string `json:"exclusions"`
+}
+
+// DefaultRuleSet is used unless CRISIS_RULES_FILE names another one. It
+// leans towards catching explicit statements of intent; moderators review
+// every match, so an occasional false positive is acceptable.
+var DefaultRuleSet = RuleSet{
+	Rules: []Rule{
+		{Phrase: "kill myself", Category: "suicide", Severity: models.CrisisSeverityHigh},
+		{Phrase: "killing myself", Category: "suicide", Severity: models.CrisisSeverityHigh},
+		{Phrase: "end my life", Category: "suicide", Severity: models.CrisisSeverityHigh},
+		{Phrase: "ending my life", Category: "suicide", Severity: models.CrisisSeverityHigh},
+		{Phrase: "take my own life", Category: "suicide", Severity: models.CrisisSeverityHigh},
+		{Phrase: "commit suicide", Category: "suicide", Severity: models.CrisisSeverityHigh},
+		{Phrase: "suicidal", Category: "suicide", Severity: models.CrisisSeverityHigh},
+		{Phrase: "want to die", Category: "suicide", Severity: models.CrisisSeverityHigh},
+		{Phrase: "wanna die", Category: "suicide", Severity: models.CrisisSeverityHigh},
+		{Phrase: "dont want to live", Category: "suicide", Severity: models.CrisisSeverityHigh},
+		{Phrase: "dont want to be alive", Category: "suicide", Severity: models.CrisisSeverityHigh},
+		{Phrase: "better off dead", Category: "suicide", Severity: models.CrisisSeverityHigh},
+		{Phrase: "better off without me", Category: "suicide", Severity: models.CrisisSeverityHigh},
+		{Phrase: "no reason to live", Category: "hopelessness", Severity: models.CrisisSeverityMedium},
+		{Phrase: "nothing to live for", Category: "hopelessness", Severity: models.CrisisSeverityMedium},
+		{Phrase: "cant go on", Category: "hopelessness", Severity: models.CrisisSeverityMedium},
+		{Phrase: "give up on life", Category: "hopelessness", Severity: models.CrisisSeverityMedium},
+		{Phrase: "giving up on life", Category: "hopelessness", Severity: models.CrisisSeverityMedium},
+		{Phrase: "hurt myself", Category: "self_harm", Severity: models.CrisisSeverityMedium},
+		{Phrase: "hurting myself", Category: "self_harm", Severity: models.CrisisSeverityMedium},
+		{Phrase: "harm myself", Category: "self_harm", Severity: models.CrisisSeverityMedium},
+		{Phrase: "self harm", Category: "self_harm", Severity: models.CrisisSeverityMedium},
+		{Phrase: "cut myself", Category: "self_harm", Severity: models.CrisisSeverityMedium},
+		{Phrase: "cutting myself", Category: "self_harm", Severity: models.CrisisSeverityMedium},
+	},
+	Exclusions: []string{
+		"dont want to die",
+		"do not want to die",
+		"didnt want to die",
+		"self harm awareness",
+	},
+}
+
+// RuleClassifier matches normalized text against a set of phrases. Text is
+// lower-cased, apostrophes are dropped and other punctuation becomes
+// spaces, so "I can't go on..." matches the phrase "cant go on". Phrases
+// only match whole words.
+type RuleClassifier struct {
+	rules      []Rule
+	exclusions []string
+}
+
+// NewRuleClassifier creates a classifier from a rule set
+func NewRuleClassifier(ruleSet RuleSet) (*RuleClassifier, error) {
+	classifier := &RuleClassifier{}
+	for i, rule := range ruleSet.Rules {
+		phrase := normalize(rule.Phrase)
+		if phrase == "" {
+			return nil, fmt.Errorf("crisis rule %d has no phrase", i+1)
+		}
+		if rule.Category == "" {
+			return nil, fmt.Errorf("crisis rule %q has no category", rule.Phrase)
+		}
+		if rule.Severity != models.CrisisSeverityHigh && rule.Severity != models.CrisisSeverityMedium {
+			return nil, fmt.Errorf("crisis rule %q has severity %q; use high or medium", rule.Phrase, rule.Severity)
+		}
+		classifier.rules = append(classifier.rules, Rule{Phrase: phrase, Category: rule.Category, Severity: rule.Severity})
+	}
+	for _, exclusion := range ruleSet.Exclusions {
+		if phrase := normalize(exclusion); phrase != "" {
+			classifier.exclusions = append(classifier.exclusions, phrase)
+		}
+	}
+	return classifier, nil
+}
+
+// NewRuleClassifierFromEnv creates a classifier from the JSON rule set in
+// CRISIS_RULES_FILE, or from DefaultRuleSet when it is not set
+func NewRuleClassifierFromEnv() (*RuleClassifier, error) {
+	path := os.Getenv("CRISIS_RULES_FILE")
+	if path == "" {
+		return NewRuleClassifier(DefaultRuleSet)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CRISIS_RULES_FILE: %w", err)
+	}
+	var ruleSet RuleSet
+	if err := json.Unmarshal(data, &ruleSet); err != nil {
+		return nil, fmt.Errorf("parse CRISIS_RULES_FILE: %w", err)
+	}
+	if len(ruleSet.Rules) == 0 {
+		return nil, fmt.Errorf("CRISIS_RULES_FILE %s has no rules", path)
+	}
+	return NewRuleClassifier(ruleSet)
+}
+
+// Classify returns the phrases found in the text. The category and
+// severity are those of the most severe rule that matched.
+func (c *RuleClassifier) Classify(text string) Match {
+	normalized := " " + normalize(text) + " "
+	for _, exclusion := range c.exclusions {
+		normalized = strings.ReplaceAll(normalized, " "+exclusion+" ", " ")
+	}
+
+	var match Match
+	for _, rule := range c.rules {
+		if !strings.Contains(normalized, " "+rule.Phrase+" ") {
+			continue
+		}
+		match.Phrases = append(match.Phrases, rule.Phrase)
+		if match.Category == "" || (rule.Severity == models.CrisisSeverityHigh && match.Severity != models.CrisisSeverityHigh) {
+			match.Category = rule.Category
+			match.Severity = rule.Severity
+		}
+	}
+	return match
+}
+
+// normalize lower-cases text, drops apostrophes and turns everything else
+// that is not a letter or digit into single spaces
+func normalize(text string) string {
+	var b strings.Builder
+	space := true
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == '\'' || r == '’':
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+			space = false
+		case !space:
+			b.WriteRune(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,88 @@
+package safety
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"I can't go on...", "i cant go on"},
+		{"I can’t go on", "i cant go on"},
+		{"  Self-harm!!  ", "self harm"},
+		{"end\tmy\n\nlife", "end my life"},
+		{"...", ""},
+		{"Ça va?", "ça va"},
+	}
+	for _, test := range tests {
+		if got := normalize(test.text); got != test.want {
+			t.Errorf("normalize(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestRuleClassifierClassify(t *testing.T) {
+	classifier, err := NewRuleClassifier(DefaultRuleSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		text         string
+		wantPhrases  []string
+		wantCategory string
+		wantSeverity string
+	}{
+		{"no crisis language", "Chemo went well today, feeling hopeful", nil, "", ""},
+		{"phrase", "Some days I want to die", []string{"want to die"}, "suicide", models.CrisisSeverityHigh},
+		{"upper case", "I WANT TO DIE", []string{"want to die"}, "suicide", models.CrisisSeverityHigh},
+		{"apostrophe and trailing punctuation", "I can't go on...", []string{"cant go on"}, "hopelessness", models.CrisisSeverityMedium},
+		{"curly apostrophe", "I don’t want to live like this", []string{"dont want to live"}, "suicide", models.CrisisSeverityHigh},
+		{"punctuation inside the phrase", "I keep wanting to hurt-myself", []string{"hurt myself"}, "self_harm", models.CrisisSeverityMedium},
+		{"exclusion", "I don't want to die", nil, "", ""},
+		{"exclusion spelled out", "I do not want to die yet", nil, "", ""},
+		{"exclusion next to a match", "I don't want to die, but I want to die sometimes", []string{"want to die"}, "suicide", models.CrisisSeverityHigh},
+		{"exclusion phrase of a different rule", "Self harm awareness week starts Monday", nil, "", ""},
+		{"not a whole word", "She is suicidally brave", nil, "", ""},
+		{"phrase inside a longer word", "We scant go on walks", nil, "", ""},
+		{"high after medium", "I can't go on and I want to end my life", []string{"end my life", "cant go on"}, "suicide", models.CrisisSeverityHigh},
+		{"medium after high", "I feel suicidal and I cut myself", []string{"suicidal", "cut myself"}, "suicide", models.CrisisSeverityHigh},
+		{"only medium", "I have nothing to live for and I hurt myself", []string{"nothing to live for", "hurt myself"}, "hopelessness", models.CrisisSeverityMedium},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match := classifier.Classify(test.text)
+			if !reflect.DeepEqual(match.Phrases, test.wantPhrases) {
+				t.Errorf("phrases = %q, want %q", match.Phrases, test.wantPhrases)
+			}
+			if match.Matched() != (test.wantPhrases != nil) {
+				t.Errorf("Matched() = %v", match.Matched())
+			}
+			if match.Category != test.wantCategory || match.Severity != test.wantSeverity {
+				t.Errorf("category, severity = %q, %q; want %q, %q", match.Category, match.Severity, test.wantCategory, test.wantSeverity)
+			}
+		})
+	}
+}
+
+func TestNewRuleClassifierValidatesRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+	}{
+		{"no phrase", Rule{Phrase: "?!", Category: "suicide", Severity: models.CrisisSeverityHigh}},
+		{"no category", Rule{Phrase: "want to die", Severity: models.CrisisSeverityHigh}},
+		{"unknown severity", Rule{Phrase: "want to die", Category: "suicide", Severity: "low"}},
+	}
+	for _, test := range tests {
+		if _, err := NewRuleClassifier(RuleSet{Rules: []Rule{test.rule}}); err == nil {
+			t.Errorf("%s: rule accepted", test.name)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package safety
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fallbackLocale is used when none of the requested locales is known
+const fallbackLocale = "en"
+
+// Resource is a crisis line or service people can contact
+type Resource struct {
+	Name  string `json:"name"`
+	Phone string `json:"phone,omitempty"`
+	Text  string `json:"text,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// Support is the crisis-resource information returned to an author whose
+// content matched the crisis-language rules
+type Support struct {
+	Locale    string     `json:"locale"`
+	Message   string     `json:"message"`
+	Resources []Resource `json:"resources"`
+}
+
+// Directory holds crisis support by locale. Keys are lower-case language
+// tags such as "en", "en-gb" or "fr-fr" and must include fallbackLocale.
+type Directory map[string]Support
+
+// DefaultDirectory is used unless CRISIS_RESOURCES_FILE names another one.
+// Language-only entries point to the international directory of helplines;
+// region entries list the national crisis line.
+var DefaultDirectory = Directory{
+	"en": {
+		Message: "You're not alone. If you are thinking about ending your life or hurting yourself, please reach out now. If you are in immediate danger, call your local emergency number.",
+		Resources: []Resource{
+			{Name: "Find A Helpline", URL: "https://findahelpline.com"},
+		},
+	},
+	"en-us": {
+		Message: "You're not alone. If you are thinking about ending your life or hurting yourself, please reach out now. If you are in immediate danger, call 911.",
+		Resources: []Resource{
+			{Name: "988 Suicide & Crisis Lifeline", Phone: "988", Text: "988", URL: "https://988lifeline.org"},
+		},
+	},
+	"en-ca": {
+		Message: "You're not alone. If you are thinking about ending your life or hurting yourself, please reach out now. If you are in immediate danger, call 911.",
+		Resources: []Resource{
+			{Name: "9-8-8 Suicide Crisis Helpline", Phone: "988", Text: "988", URL: "https://988.ca"},
+		},
+	},
+	"en-gb": {
+		Message: "You're not alone. If you are thinking about ending your life or hurting yourself, please reach out now. If you are in immediate danger, call 999.",
+		Resources: []Resource{
+			{Name: "Samaritans", Phone: "116 123", URL: "https://www.samaritans.org"},
+		},
+	},
+	"en-ie": {
+		Message: "You're not alone. If you are thinking about ending your life or hurting yourself, please reach out now. If you are in immediate danger, call 112 or 999.",
+		Resources: []Resource{
+			{Name: "Samaritans", Phone: "116 123", URL: "https://www.samaritans.org"},
+		},
+	},
+	"en-au": {
+		Message: "You're not alone. If you are thinking about ending your life or hurting yourself, please reach out now. If you are in immediate danger, call 000.",
+		Resources: []Resource{
+			{Name: "Lifeline", Phone: "13 11 14", URL: "https://www.lifeline.org.au"},
+		},
+	},
+	"fr": {
+		Message: "Vous n'êtes pas seul·e. Si vous pensez à mettre fin à vos jours ou à vous faire du mal, demandez de l'aide maintenant. En cas de danger immédiat, appelez le numéro d'urgence local.",
+		Resources: []Resource{
+			{Name: "Find A Helpline", URL: "https://findahelpline.com"},
+		},
+	},
+	"fr-fr": {
+		Message: "Vous n'êtes pas seul·e. Si vous pensez à mettre fin à vos jours ou à vous faire du mal, demandez de l'aide maintenant. En cas de danger immédiat, appelez le 15 ou le 112.",
+		Resources: []Resource{
+			{Name: "3114, numéro national de prévention du suicide", Phone: "3114", URL: "https://3114.fr"},
+		},
+	},
+	"de": {
+		Message: "Du bist nicht allein. Wenn du daran denkst, dir das Leben zu nehmen oder dich zu verletzen, hol dir jetzt Hilfe. Bei akuter Gefahr wähle den örtlichen Notruf.",
+		Resources: []Resource{
+			{Name: "Find A Helpline", URL: "https://findahelpline.com"},
+		},
+	},
+	"de-de": {
+		Message: "Du bist nicht allein. Wenn du daran denkst, dir das Leben zu nehmen oder dich zu verletzen, hol dir jetzt Hilfe. Bei akuter Gefahr wähle die 112.",
+		Resources: []Resource{
+			{Name: "TelefonSeelsorge", Phone: "0800 111 0 111", URL: "https://www.telefonseelsorge.de"},
+		},
+	},
+	"es": {
+		Message: "No estás solo. Si estás pensando en quitarte la vida o en hacerte daño, pide ayuda ahora. Si estás en peligro inmediato, llama al número de emergencias local.",
+		Resources: []Resource{
+			{Name: "Find A Helpline", URL: "https://findahelpline.com"},
+		},
+	},
+	"es-es": {
+		Message: "No estás solo. Si estás pensando en quitarte la vida o en hacerte daño, pide ayuda ahora. Si estás en peligro inmediato, llama al 112.",
+		Resources: []Resource{
+			{Name: "Línea 024 de atención a la conducta suicida", Phone: "024"},
+		},
+	},
+}
+
+// NewDirectoryFromEnv returns the directory in the JSON file named by
+// CRISIS_RESOURCES_FILE, or DefaultDirectory when it is not set
+func NewDirectoryFromEnv() (Directory, error) {
+	path := os.Getenv("CRISIS_RESOURCES_FILE")
+	if path == "" {
+		return DefaultDirectory, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CRISIS_RESOURCES_FILE: %w", err)
+	}
+	var raw Directory
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse CRISIS_RESOURCES_FILE: %w", err)
+	}
+
+	directory := Directory{}
+	for locale, support := range raw {
+		if support.Message == "" {
+			return nil, fmt.Errorf("CRISIS_RESOURCES_FILE locale %q has no message", locale)
+		}
+		directory[strings.ToLower(locale)] = support
+	}
+	if _, ok := directory[fallbackLocale]; !ok {
+		return nil, fmt.Errorf("CRISIS_RESOURCES_FILE needs a %q locale", fallbackLocale)
+	}
+	return directory, nil
+}
+
+// Lookup returns the support for the best match of an Accept-Language
+// header: each requested locale in order of preference, then its language
+// alone, then fallbackLocale
+func (d Directory) Lookup(acceptLanguage string) Support {
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if support, ok := d.get(tag); ok {
+			return support
+		}
+		if language, _, found := strings.Cut(tag, "-"); found {
+			if support, ok := d.get(language); ok {
+				return support
+			}
+		}
+	}
+	support, _ := d.get(fallbackLocale)
+	return support
+}
+
+// get returns the support of a locale with the locale filled in
+func (d Directory) get(locale string) (Support, bool) {
+	support, ok := d[locale]
+	if !ok {
+		return Support{}, false
+	}
+	support.Locale = locale
+	if support.Resources == nil {
+		support.Resources = []Resource{}
+	}
+	return support, true
+}
+
+// parseAcceptLanguage returns the lower-case tags of an Accept-Language
+// header, most preferred first
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if q, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = q
+			}
+		}
+		if quality > 0 {
+			tags = append(tags, weighted{tag, quality})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].quality > tags[j].quality })
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
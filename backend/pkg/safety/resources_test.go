@@ -0,0 +1,59 @@
+package safety
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"en-US", []string{"en-us"}},
+		{"fr-FR,fr;q=0.9,en;q=0.8", []string{"fr-fr", "fr", "en"}},
+		{"en;q=0.5, de-DE;q=0.9, es", []string{"es", "de-de", "en"}},
+		{"fr;q=0.8, de;q=0.8", []string{"fr", "de"}},
+		{"en_GB", []string{"en-gb"}},
+		{"*, de;q=0", []string{}},
+		{"fr;q=abc", []string{"fr"}},
+	}
+	for _, test := range tests {
+		if got := parseAcceptLanguage(test.header); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseAcceptLanguage(%q) = %q, want %q", test.header, got, test.want)
+		}
+	}
+}
+
+func TestDirectoryLookup(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"en-US", "en-us"},
+		{"EN-gb", "en-gb"},
+		{"en-NZ", "en"},
+		{"fr-CA,fr;q=0.9", "fr"},
+		{"fr-FR", "fr-fr"},
+		{"de;q=0.5, es-ES;q=0.9", "es-es"},
+		{"ja-JP, de;q=0.3", "de"},
+		{"ja-JP, zh;q=0.8", "en"},
+		{"", "en"},
+		{"de;q=0", "en"},
+	}
+	for _, test := range tests {
+		support := DefaultDirectory.Lookup(test.header)
+		if support.Locale != test.want {
+			t.Errorf("Lookup(%q) locale = %q, want %q", test.header, support.Locale, test.want)
+		}
+		if support.Message != DefaultDirectory[test.want].Message {
+			t.Errorf("Lookup(%q) returned the message of another locale", test.header)
+		}
+	}
+
+	directory := Directory{"en": {Message: "Reach out"}}
+	if support := directory.Lookup("en"); support.Resources == nil {
+		t.Error("Lookup returned nil resources; want an empty list")
+	}
+}
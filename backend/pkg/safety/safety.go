@@ -0,0 +1,219 @@
+// Package safety looks for crisis language in new posts, comments and
+// messages. Content is never blocked: when the classifier finds crisis
+// language the author is shown crisis resources for their locale,
+// moderators get a high-priority alert and the contacts the author added to
+// their care circle are asked to reach out.
+package safety
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+const (
+	// careCircleCooldown is how long after telling a user's care circle
+	// further alerts are raised without telling it again
+	careCircleCooldown = 24 * time.Hour
+
+	// MaxCareCircleSize bounds the contacts in a care circle
+	MaxCareCircleSize = 5
+)
+
+var (
+	ErrContactNotFound = errors.New("user not found")
+	ErrOwnContact      = errors.New("you cannot add yourself to your care circle")
+	ErrCareCircleFull  = fmt.Errorf("a care circle has at most %d contacts", MaxCareCircleSize)
+	ErrAlertNotFound   = errors.New("crisis alert not found")
+	ErrAlreadyHandled  = errors.New("the alert has already been acknowledged")
+)
+
+// Service classifies new content and manages crisis alerts and care circles
+type Service struct {
+	db         *sql.DB
+	classifier Classifier
+	directory  Directory
+}
+
+// NewService creates a safety service
+func NewService(db *sql.DB, classifier Classifier, directory Directory) *Service {
+	return &Service{db: db, classifier: classifier, directory: directory}
+}
+
+// Check classifies new content. When it contains crisis language an alert
+// is raised and the support to show the author is returned; otherwise Check
+// returns nil. Failures are logged and never keep the content from being
+// published.
+func (s *Service) Check(author *models.User, contentType string, contentID int, text, acceptLanguage string) *Support {
+	match := s.classifier.Classify(text)
+	if !match.Matched() {
+		return nil
+	}
+	support := s.directory.Lookup(acceptLanguage)
+
+	alert, err := models.CreateCrisisAlert(s.db, models.CrisisAlert{
+		UserID:          author.ID,
+		ContentType:     contentType,
+		ContentID:       contentID,
+		ContentSnapshot: text,
+		Category:        match.Category,
+		Severity:        match.Severity,
+		MatchedPhrases:  match.Phrases,
+	})
+	if err != nil {
+		log.Printf("Failed to raise crisis alert for %s %d of user %d: %v", contentType, contentID, author.ID, err)
+		return &support
+	}
+	log.Printf("Crisis alert %d raised for %s %d of user %d (%s)", alert.ID, contentType, contentID, author.ID, match.Severity)
+
+	s.alertStaff(author, alert)
+	s.notifyCareCircle(author, alert)
+	return &support
+}
+
+// Resources returns the crisis support for the best match of an
+// Accept-Language header
+func (s *Service) Resources(acceptLanguage string) Support {
+	return s.directory.Lookup(acceptLanguage)
+}
+
+// Alerts returns crisis alerts with a status
+func (s *Service) Alerts(status string, limit int) ([]models.CrisisAlert, error) {
+	return models.GetCrisisAlerts(s.db, status, limit)
+}
+
+// Acknowledge records that a moderator followed up on an open alert
+func (s *Service) Acknowledge(alertID int, staff *models.User, note string) (*models.CrisisAlert, error) {
+	alert, err := models.GetCrisisAlert(s.db, alertID)
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return nil, ErrAlertNotFound
+	}
+	acknowledged, err := models.AcknowledgeCrisisAlert(s.db, alert, staff.ID, strings.TrimSpace(note))
+	if err != nil {
+		return nil, err
+	}
+	if !acknowledged {
+		return nil, ErrAlreadyHandled
+	}
+	return alert, nil
+}
+
+// CareCircle returns the contacts in a user's care circle
+func (s *Service) CareCircle(user *models.User) ([]models.CareCircleContact, error) {
+	return models.GetCareCircle(s.db, user.ID)
+}
+
+// AddContact adds a user to the care circle of another and lets them know
+func (s *Service) AddContact(user *models.User, contactID int) error {
+	if contactID == user.ID {
+		return ErrOwnContact
+	}
+	contact, err := models.GetUserById(s.db, contactID)
+	if err != nil {
+		return err
+	}
+	if contact == nil {
+		return ErrContactNotFound
+	}
+
+	contactIDs, err := models.GetCareCircleContactIDs(s.db, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, id := range contactIDs {
+		if id == contactID {
+			return nil
+		}
+	}
+	if len(contactIDs) >= MaxCareCircleSize {
+		return ErrCareCircleFull
+	}
+
+	added, err := models.AddCareCircleContact(s.db, user.ID, contactID)
+	if err != nil {
+		return err
+	}
+	if added {
+		s.notify(contactID, "care_circle_added", user.ID,
+			"%s added you to their care circle. We will let you know if they may need support.", displayName(user))
+	}
+	return nil
+}
+
+// RemoveContact takes a user out of another's care circle. It reports
+// false when they were not in it.
+func (s *Service) RemoveContact(user *models.User, contactID int) (bool, error) {
+	return models.RemoveCareCircleContact(s.db, user.ID, contactID)
+}
+
+// alertStaff notifies every admin and moderator of a new alert
+func (s *Service) alertStaff(author *models.User, alert *models.CrisisAlert) {
+	staffIDs, err := models.GetStaffUserIDs(s.db, models.StaffRoleAdmin, models.StaffRoleModerator)
+	if err != nil {
+		log.Printf("Failed to load staff for crisis alert %d: %v", alert.ID, err)
+		return
+	}
+	if len(staffIDs) == 0 {
+		log.Printf("Crisis alert %d has no staff to notify", alert.ID)
+		return
+	}
+
+	label := strings.ReplaceAll(alert.ContentType, "_", " ")
+	for _, staffID := range staffIDs {
+		s.notify(staffID, "crisis_alert", alert.ID,
+			"Urgent: possible crisis language (%s, %s severity) in a %s by %s. Please review crisis alert #%d.",
+			strings.ReplaceAll(alert.Category, "_", " "), alert.Severity, label, displayName(author), alert.ID)
+	}
+}
+
+// notifyCareCircle asks the author's care circle to reach out, at most
+// once per careCircleCooldown. Contacts are not shown the content.
+func (s *Service) notifyCareCircle(author *models.User, alert *models.CrisisAlert) {
+	contactIDs, err := models.GetCareCircleContactIDs(s.db, author.ID)
+	if err != nil {
+		log.Printf("Failed to load care circle of user %d: %v", author.ID, err)
+		return
+	}
+	if len(contactIDs) == 0 {
+		return
+	}
+	notified, err := models.CareCircleNotifiedSince(s.db, author.ID, time.Now().Add(-careCircleCooldown))
+	if err != nil {
+		log.Printf("Failed to check care circle notifications of user %d: %v", author.ID, err)
+		return
+	}
+	if notified {
+		return
+	}
+
+	for _, contactID := range contactIDs {
+		s.notify(contactID, "care_circle_alert", author.ID,
+			"%s may be going through a difficult time. Consider reaching out to them.", displayName(author))
+	}
+	if err := models.MarkCareCircleNotified(s.db, alert.ID); err != nil {
+		log.Printf("Failed to mark care circle notified for crisis alert %d: %v", alert.ID, err)
+	}
+}
+
+// notify creates a notification, logging failures
+func (s *Service) notify(userID int, notificationType string, relatedID int, format string, args ...interface{}) {
+	if _, err := models.CreateNotification(s.db, userID, notificationType, fmt.Sprintf(format, args...), relatedID); err != nil {
+		log.Printf("Failed to create %s notification for user %d: %v", notificationType, userID, err)
+	}
+}
+
+// displayName is a user's nickname, or their full name without one
+func displayName(user *models.User) string {
+	if user.Nickname != "" {
+		return user.Nickname
+	}
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
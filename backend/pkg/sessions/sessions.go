@@ -0,0 +1,192 @@
+// Package sessions issues the session cookies users stay signed in with.
+// Only the SHA-256 hash of a session token is stored, so the sessions table
+// cannot be used to sign in. A session expires after a period without use,
+// renewed as it is used up to an absolute lifetime, gets a new token when
+// the user's privileges change, and is deleted by a background sweeper once
+// expired.
+package sessions
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+const (
+	// CookieName is the cookie holding the session token
+	CookieName = "session_token"
+
+	// DefaultIdleTimeout is how long a session lasts without use when
+	// SESSION_IDLE_TIMEOUT_HOURS is not set
+	DefaultIdleTimeout = 7 * 24 * time.Hour
+
+	// DefaultMaxLifetime is how long a session lasts at most when
+	// SESSION_MAX_LIFETIME_DAYS is not set
+	DefaultMaxLifetime = 30 * 24 * time.Hour
+)
+
+// Config holds the session lifetimes
+type Config struct {
+	IdleTimeout time.Duration
+	MaxLifetime time.Duration
+}
+
+// ConfigFromEnv reads SESSION_IDLE_TIMEOUT_HOURS and SESSION_MAX_LIFETIME_DAYS
+func ConfigFromEnv() (Config, error) {
+	config := Config{IdleTimeout: DefaultIdleTimeout, MaxLifetime: DefaultMaxLifetime}
+
+	if value := os.Getenv("SESSION_IDLE_TIMEOUT_HOURS"); value != "" {
+		hours, err := strconv.Atoi(value)
+		if err != nil || hours <= 0 {
+			return Config{}, fmt.Errorf("invalid SESSION_IDLE_TIMEOUT_HOURS %q", value)
+		}
+		config.IdleTimeout = time.Duration(hours) * time.Hour
+	}
+	if value := os.Getenv("SESSION_MAX_LIFETIME_DAYS"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days <= 0 {
+			return Config{}, fmt.Errorf("invalid SESSION_MAX_LIFETIME_DAYS %q", value)
+		}
+		config.MaxLifetime = time.Duration(days) * 24 * time.Hour
+	}
+	if config.IdleTimeout > config.MaxLifetime {
+		return Config{}, fmt.Errorf("SESSION_IDLE_TIMEOUT_HOURS is longer than SESSION_MAX_LIFETIME_DAYS")
+	}
+	return config, nil
+}
+
+// Service creates, checks and renews sessions
+type Service struct {
+	db     *sql.DB
+	config Config
+}
+
+// NewService creates a session service
+func NewService(db *sql.DB, config Config) *Service {
+	return &Service{db: db, config: config}
+}
+
+// Start creates a session for a user signing in on the device making the
+// request and sets its cookie
+func (s *Service) Start(w http.ResponseWriter, r *http.Request, userID int) error {
+	token, err := utils.NewToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	absoluteExpiresAt := now.Add(s.config.MaxLifetime)
+	err = models.CreateSession(s.db, userID, utils.HashToken(token), r.UserAgent(), utils.ClientIP(r),
+		now.Add(s.config.IdleTimeout), absoluteExpiresAt)
+	if err != nil {
+		return err
+	}
+	SetCookie(w, token, absoluteExpiresAt)
+	return nil
+}
+
+// FromRequest returns the unexpired session of the request's cookie, or nil
+// when there is none
+func (s *Service) FromRequest(r *http.Request) (*models.Session, error) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, nil
+	}
+	session, err := models.GetSessionByTokenHash(s.db, utils.HashToken(cookie.Value))
+	if err != nil || session == nil {
+		return nil, err
+	}
+	// Expired sessions are left for the sweeper
+	if session.Expired(time.Now()) {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// Refresh records a request made with a session: it renews the session's
+// expiry, at most once a minute, and gives it a new token when the user's
+// privileges changed since it was issued
+func (s *Service) Refresh(w http.ResponseWriter, r *http.Request, session *models.Session) error {
+	expiresAt := time.Now().Add(s.config.IdleTimeout)
+	if expiresAt.After(session.AbsoluteExpiresAt) {
+		expiresAt = session.AbsoluteExpiresAt
+	}
+	if err := models.TouchSession(s.db, session, r.UserAgent(), utils.ClientIP(r), expiresAt); err != nil {
+		return err
+	}
+	if session.RotationDue {
+		return s.rotate(w, session)
+	}
+	return nil
+}
+
+// rotate replaces the token of a session and sets the new cookie. A request
+// racing with another that already rotated it keeps its old token until the
+// browser takes the new cookie.
+func (s *Service) rotate(w http.ResponseWriter, session *models.Session) error {
+	token, err := utils.NewToken()
+	if err != nil {
+		return err
+	}
+	tokenHash := utils.HashToken(token)
+	rotated, err := models.RotateSessionToken(s.db, session.ID, session.TokenHash, tokenHash)
+	if err != nil || !rotated {
+		return err
+	}
+	session.TokenHash = tokenHash
+	session.RotationDue = false
+	SetCookie(w, token, session.AbsoluteExpiresAt)
+	log.Printf("Rotated the token of session %d of user %d", session.ID, session.UserID)
+	return nil
+}
+
+// Run deletes expired sessions every interval
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deleted, err := models.DeleteExpiredSessions(s.db, time.Now())
+		if err != nil {
+			log.Printf("Failed to delete expired sessions: %v", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Deleted %d expired sessions", deleted)
+		}
+	}
+}
+
+// SetCookie sets the session cookie. Production serves the frontend from
+// another origin, so the cookie is sent cross-site there.
+func SetCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
+	http.SetCookie(w, cookie(token, expiresAt))
+}
+
+// ClearCookie removes the session cookie from the browser
+func ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, cookie("", time.Now().Add(-time.Hour)))
+}
+
+func cookie(value string, expiresAt time.Time) *http.Cookie {
+	isProduction := os.Getenv("DATABASE_URL") != ""
+	sameSite := http.SameSiteLaxMode
+	if isProduction {
+		sameSite = http.SameSiteNoneMode
+	}
+	return &http.Cookie{
+		Name:     CookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		SameSite: sameSite,
+		Secure:   isProduction,
+	}
+}
@@ -0,0 +1,11 @@
+package transferpolicy
+
+import (
+	"testing"
+
+	"github.com/On-cure/Oncure/pkg/db/dbtest"
+)
+
+func TestMain(m *testing.M) {
+	dbtest.Main(m)
+}
@@ -0,0 +1,436 @@
+// Package transferpolicy decides whether a user may send a transfer or tip.
+// It validates the amount and receiver, caps single transfers and the amount
+// sent per day by verified role, limits how many transfers a user sends per
+// hour and asks for the password again, or a two-factor code when the user
+// has one set up, above a threshold, so a stolen session cannot drain a
+// wallet. Failed confirmations count toward the hourly limit and lock the
+// step-up for a while after a few in a row. Refused attempts are recorded
+// for review.
+package transferpolicy
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/money"
+	"github.com/On-cure/Oncure/pkg/twofactor"
+)
+
+// Reasons an attempt is refused
+const (
+	ReasonInvalidAmount    = "invalid_amount"
+	ReasonInvalidReceiver  = "invalid_receiver"
+	ReasonSelfTransfer     = "self_transfer"
+	ReasonPerTransferLimit = "per_transfer_limit"
+	ReasonDailyLimit       = "daily_limit"
+	ReasonVelocityLimit    = "velocity_limit"
+	ReasonStepUpRequired   = "step_up_required"
+	ReasonStepUpFailed     = "step_up_failed"
+	ReasonStepUpLocked     = "step_up_locked"
+)
+
+// Limits are the caps applied to the transfers of one role. A zero value
+// means no limit.
+type Limits struct {
+	PerTransfer money.Tinybars `json:"per_transfer"`
+	Daily       money.Tinybars `json:"daily"`
+	PerHour     int            `json:"per_hour"`
+	StepUpAbove money.Tinybars `json:"step_up_above"`
+}
+
+// DefaultLimits are the limits of each role when TRANSFER_LIMITS_<ROLE> does
+// not override them. Roles not listed, and coaches and mentors who are not
+// verified, get the "user" limits.
+var DefaultLimits = map[string]Limits{
+	"user": {
+		PerTransfer: money.Hbar(100),
+		Daily:       money.Hbar(250),
+		PerHour:     20,
+		StepUpAbove: money.Hbar(10),
+	},
+	"coach": {
+		PerTransfer: money.Hbar(500),
+		Daily:       money.Hbar(1000),
+		PerHour:     60,
+		StepUpAbove: money.Hbar(50),
+	},
+	"mentor": {
+		PerTransfer: money.Hbar(500),
+		Daily:       money.Hbar(1000),
+		PerHour:     60,
+		StepUpAbove: money.Hbar(50),
+	},
+}
+
+const (
+	dailyWindow    = 24 * time.Hour
+	velocityWindow = time.Hour
+
+	// maxStepUpFailures failed confirmations within stepUpLockout lock the
+	// step-up until the oldest of them is stepUpLockout old
+	maxStepUpFailures = 5
+	stepUpLockout     = 15 * time.Minute
+)
+
+// Config holds the limits of every role
+type Config struct {
+	Roles map[string]Limits
+}
+
+// LimitsFor returns the limits of a role, falling back to the "user" limits
+func (c Config) LimitsFor(role string) Limits {
+	if limits, ok := c.Roles[strings.ToLower(role)]; ok {
+		return limits
+	}
+	return c.Roles["user"]
+}
+
+// LimitsForUser returns the limits of a user's role once their verification
+// is approved and the "user" limits until then
+func (c Config) LimitsForUser(user *models.User) Limits {
+	if user.VerificationStatus != models.VerificationStatusVerified {
+		return c.LimitsFor("user")
+	}
+	return c.LimitsFor(user.Role)
+}
+
+// ConfigFromEnv starts from DefaultLimits and applies every
+// TRANSFER_LIMITS_<ROLE> variable, a comma-separated list of
+// per_transfer, daily, per_hour and step_up_above settings such as
+// "per_transfer=50,daily=200,per_hour=10,step_up_above=5". Amounts are in
+// HBAR; settings left out keep the role's default and 0 removes a limit.
+func ConfigFromEnv() (Config, error) {
+	config := Config{Roles: map[string]Limits{}}
+	for role, limits := range DefaultLimits {
+		config.Roles[role] = limits
+	}
+
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		role, ok := strings.CutPrefix(name, "TRANSFER_LIMITS_")
+		if !ok || role == "" {
+			continue
+		}
+		role = strings.ToLower(role)
+
+		limits, ok := config.Roles[role]
+		if !ok {
+			limits = config.Roles["user"]
+		}
+		if err := parseLimits(value, &limits); err != nil {
+			return config, fmt.Errorf("invalid %s: %v", name, err)
+		}
+		config.Roles[role] = limits
+	}
+	return config, nil
+}
+
+// parseLimits applies "key=value" settings to limits
+func parseLimits(value string, limits *Limits) error {
+	for _, setting := range strings.Split(value, ",") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+		key, raw, ok := strings.Cut(setting, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", setting)
+		}
+		key, raw = strings.TrimSpace(key), strings.TrimSpace(raw)
+
+		if key == "per_hour" {
+			count, err := strconv.Atoi(raw)
+			if err != nil || count < 0 {
+				return fmt.Errorf("invalid per_hour %q", raw)
+			}
+			limits.PerHour = count
+			continue
+		}
+
+		amount, err := money.ParseHbar(raw)
+		if err != nil || amount < 0 {
+			return fmt.Errorf("invalid %s %q", key, raw)
+		}
+		switch key {
+		case "per_transfer":
+			limits.PerTransfer = amount
+		case "daily":
+			limits.Daily = amount
+		case "step_up_above":
+			limits.StepUpAbove = amount
+		default:
+			return fmt.Errorf("unknown setting %q", key)
+		}
+	}
+	return nil
+}
+
+// Attempt describes a transfer a user asked for
+type Attempt struct {
+	User           *models.User
+	Kind           string // one of the models.TransferAttempt* kinds
+	ToUserID       int
+	Amount         money.Tinybars
+	IdempotencyKey string
+	// Password re-authenticates the user for transfers above the step-up
+	// threshold; users with two-factor authentication give TwoFactorCode instead
+	Password      string
+	TwoFactorCode string
+	IPAddress     string
+}
+
+// SecondFactor checks the two-factor codes of users who turned it on.
+// VerifyCode returns twofactor.ErrLocked after too many wrong codes.
+type SecondFactor interface {
+	Enabled(user *models.User) (bool, error)
+	VerifyCode(user *models.User, code string) (bool, error)
+}
+
+// Rejection is the error returned for an attempt the policy refuses
+type Rejection struct {
+	Reason  string
+	Message string
+	Limits  Limits
+}
+
+func (r *Rejection) Error() string {
+	return r.Message
+}
+
+// Usage is how much of their limits a user has used. TransfersInHour
+// includes failed step-up confirmations, which count toward the limit.
+type Usage struct {
+	Limits          Limits          `json:"limits"`
+	SentToday       money.Tinybars  `json:"sent_today"`
+	RemainingToday  *money.Tinybars `json:"remaining_today"`
+	TransfersInHour int             `json:"transfers_in_hour"`
+}
+
+// Policy enforces the transfer limits
+type Policy struct {
+	db           *sql.DB
+	config       Config
+	secondFactor SecondFactor
+
+	mu    sync.Mutex
+	locks map[int]*senderLock
+}
+
+// senderLock serializes the transfers of one sender so concurrent requests
+// cannot all pass the limits before any of them is recorded
+type senderLock struct {
+	sync.Mutex
+	holders int
+}
+
+// NewPolicy creates a transfer policy
+func NewPolicy(db *sql.DB, config Config, secondFactor SecondFactor) *Policy {
+	return &Policy{db: db, config: config, secondFactor: secondFactor, locks: map[int]*senderLock{}}
+}
+
+// Usage reports a user's limits and what they sent in the current windows
+func (p *Policy) Usage(user *models.User) (*Usage, error) {
+	limits := p.config.LimitsForUser(user)
+	sentToday, _, err := models.GetOutgoingTransferTotals(p.db, user.ID, time.Now().Add(-dailyWindow))
+	if err != nil {
+		return nil, err
+	}
+	inHour, err := p.hourlyCount(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &Usage{Limits: limits, SentToday: sentToday, TransfersInHour: inHour}
+	if limits.Daily > 0 {
+		remaining := max(limits.Daily-sentToday, 0)
+		usage.RemainingToday = &remaining
+	}
+	return usage, nil
+}
+
+// Send checks an attempt and calls send if the policy allows it. Retries of
+// a transfer the sender already made with the same idempotency key skip the
+// limits, so the transfer service can return the original. A refused
+// attempt is recorded and returned as a *Rejection.
+func (p *Policy) Send(attempt Attempt, send func() (*models.Transfer, error)) (*models.Transfer, error) {
+	unlock := p.lock(attempt.User.ID)
+	defer unlock()
+
+	existing, err := models.GetTransferByIdempotencyKey(p.db, attempt.User.ID, attempt.IdempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return send()
+	}
+
+	rejection, err := p.check(attempt)
+	if err != nil {
+		return nil, err
+	}
+	if rejection != nil {
+		p.record(attempt, rejection)
+		return nil, rejection
+	}
+	return send()
+}
+
+// check returns the rejection of an attempt, or nil when it is allowed
+func (p *Policy) check(attempt Attempt) (*Rejection, error) {
+	limits := p.config.LimitsForUser(attempt.User)
+	reject := func(reason, format string, args ...interface{}) (*Rejection, error) {
+		return &Rejection{Reason: reason, Message: fmt.Sprintf(format, args...), Limits: limits}, nil
+	}
+
+	if attempt.Amount <= 0 {
+		return reject(ReasonInvalidAmount, "Amount must be greater than zero")
+	}
+	if attempt.ToUserID <= 0 {
+		return reject(ReasonInvalidReceiver, "A valid receiver is required")
+	}
+	if attempt.ToUserID == attempt.User.ID {
+		if attempt.Kind == models.TransferAttemptTransfer {
+			return reject(ReasonSelfTransfer, "You cannot send HBAR to yourself")
+		}
+		return reject(ReasonSelfTransfer, "You cannot tip your own content")
+	}
+	if limits.PerTransfer > 0 && attempt.Amount > limits.PerTransfer {
+		return reject(ReasonPerTransferLimit, "Transfers are limited to %s HBAR each", limits.PerTransfer)
+	}
+
+	if limits.PerHour > 0 {
+		count, err := p.hourlyCount(attempt.User.ID)
+		if err != nil {
+			return nil, err
+		}
+		if count >= limits.PerHour {
+			return reject(ReasonVelocityLimit, "You can send at most %d transfers per hour", limits.PerHour)
+		}
+	}
+	if limits.Daily > 0 {
+		sent, _, err := models.GetOutgoingTransferTotals(p.db, attempt.User.ID, time.Now().Add(-dailyWindow))
+		if err != nil {
+			return nil, err
+		}
+		if sent+attempt.Amount > limits.Daily {
+			return reject(ReasonDailyLimit, "This transfer would exceed your limit of %s HBAR per 24 hours (%s HBAR remaining)",
+				limits.Daily, max(limits.Daily-sent, 0))
+		}
+	}
+
+	if limits.StepUpAbove > 0 && attempt.Amount > limits.StepUpAbove {
+		if attempt.Password != "" || attempt.TwoFactorCode != "" {
+			failures, oldest, err := models.GetTransferRejectionStats(p.db, attempt.User.ID, ReasonStepUpFailed,
+				time.Now().Add(-stepUpLockout))
+			if err != nil {
+				return nil, err
+			}
+			if failures >= maxStepUpFailures {
+				return reject(ReasonStepUpLocked, "Too many incorrect confirmations; try again after %s",
+					oldest.Add(stepUpLockout).UTC().Format(time.RFC3339))
+			}
+		}
+
+		// Users with two-factor authentication confirm with a code instead
+		// of their password
+		twoFactor, err := p.secondFactor.Enabled(attempt.User)
+		if err != nil {
+			return nil, err
+		}
+		if twoFactor {
+			if attempt.TwoFactorCode == "" {
+				return reject(ReasonStepUpRequired, "Transfers above %s HBAR require a two-factor code", limits.StepUpAbove)
+			}
+			verified, err := p.secondFactor.VerifyCode(attempt.User, attempt.TwoFactorCode)
+			if errors.Is(err, twofactor.ErrLocked) {
+				return reject(ReasonStepUpLocked, "Too many incorrect two-factor codes; try again later")
+			}
+			if err != nil {
+				return nil, err
+			}
+			if !verified {
+				return reject(ReasonStepUpFailed, "Incorrect two-factor code")
+			}
+			return nil, nil
+		}
+
+		if attempt.Password == "" {
+			return reject(ReasonStepUpRequired, "Transfers above %s HBAR require your password", limits.StepUpAbove)
+		}
+		user, err := models.AuthenticateUser(p.db, attempt.User.Email, attempt.Password)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil || user.ID != attempt.User.ID {
+			return reject(ReasonStepUpFailed, "Incorrect password")
+		}
+	}
+	return nil, nil
+}
+
+// hourlyCount returns how many transfers a user sent in the velocity window
+// plus how many step-up confirmations they failed in it
+func (p *Policy) hourlyCount(userID int) (int, error) {
+	since := time.Now().Add(-velocityWindow)
+	_, sent, err := models.GetOutgoingTransferTotals(p.db, userID, since)
+	if err != nil {
+		return 0, err
+	}
+	failures, _, err := models.GetTransferRejectionStats(p.db, userID, ReasonStepUpFailed, since)
+	if err != nil {
+		return 0, err
+	}
+	return sent + failures, nil
+}
+
+// record stores a refused attempt for review
+func (p *Policy) record(attempt Attempt, rejection *Rejection) {
+	entry := models.TransferRejection{
+		UserID:    attempt.User.ID,
+		Kind:      attempt.Kind,
+		Reason:    rejection.Reason,
+		Detail:    rejection.Message,
+		IPAddress: attempt.IPAddress,
+	}
+	if attempt.ToUserID > 0 {
+		entry.ToUserID = &attempt.ToUserID
+	}
+	if attempt.Amount != 0 {
+		entry.Amount = &attempt.Amount
+	}
+	if err := models.RecordTransferRejection(p.db, entry); err != nil {
+		log.Printf("Failed to record transfer rejection for user %d: %v", attempt.User.ID, err)
+	}
+	log.Printf("Refused %s from user %d: %s", attempt.Kind, attempt.User.ID, rejection.Reason)
+}
+
+// lock takes the sender's lock and returns the function releasing it
+func (p *Policy) lock(userID int) func() {
+	p.mu.Lock()
+	l, ok := p.locks[userID]
+	if !ok {
+		l = &senderLock{}
+		p.locks[userID] = l
+	}
+	l.holders++
+	p.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		p.mu.Lock()
+		l.holders--
+		if l.holders == 0 {
+			delete(p.locks, userID)
+		}
+		p.mu.Unlock()
+	}
+}
@@ -0,0 +1,237 @@
+package transferpolicy
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	wallet "github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/db/dbtest"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/money"
+	"github.com/On-cure/Oncure/pkg/twofactor"
+)
+
+// fakeSecondFactor accepts one code, or returns err for every code
+type fakeSecondFactor struct {
+	enabled bool
+	code    string
+	err     error
+}
+
+func (f fakeSecondFactor) Enabled(*models.User) (bool, error) {
+	return f.enabled, nil
+}
+
+func (f fakeSecondFactor) VerifyCode(_ *models.User, code string) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	return code == f.code, nil
+}
+
+// testSetup is a policy in front of a transfer service on a memory ledger,
+// with a sender and a receiver holding 1000 HBAR each
+type testSetup struct {
+	policy    *Policy
+	transfers *wallet.TransferService
+	sender    *models.User
+	receiver  *models.User
+	sent      int
+}
+
+func newSetup(t *testing.T, config Config, secondFactor SecondFactor) *testSetup {
+	t.Helper()
+	ledger := wallet.NewMemoryLedger()
+	return &testSetup{
+		policy:    NewPolicy(dbtest.DB, config, secondFactor),
+		transfers: wallet.NewTransferService(dbtest.DB, ledger, nil, nil, nil, nil),
+		sender:    dbtest.NewWalletUser(t, ledger, "user", money.Hbar(1000)),
+		receiver:  dbtest.NewWalletUser(t, ledger, "user", money.Hbar(1000)),
+	}
+}
+
+// send asks the policy for a transfer from sender to receiver, under a fresh
+// idempotency key unless the attempt has one
+func (s *testSetup) send(t *testing.T, attempt Attempt) (*models.Transfer, error) {
+	t.Helper()
+	s.sent++
+	attempt.User = s.sender
+	attempt.Kind = models.TransferAttemptTransfer
+	attempt.ToUserID = s.receiver.ID
+	if attempt.IdempotencyKey == "" {
+		attempt.IdempotencyKey = fmt.Sprintf("%s-%d", t.Name(), s.sent)
+	}
+	return s.policy.Send(attempt, func() (*models.Transfer, error) {
+		return s.transfers.Transfer(s.sender.ID, s.receiver.ID, attempt.Amount, attempt.IdempotencyKey)
+	})
+}
+
+// defaultConfig returns a copy of DefaultLimits
+func defaultConfig() Config {
+	config := Config{Roles: map[string]Limits{}}
+	for role, limits := range DefaultLimits {
+		config.Roles[role] = limits
+	}
+	return config
+}
+
+// assertRejected checks that err is a rejection for reason
+func assertRejected(t *testing.T, err error, reason string) {
+	t.Helper()
+	var rejection *Rejection
+	if !errors.As(err, &rejection) {
+		t.Fatalf("error = %v, want a %s rejection", err, reason)
+	}
+	if rejection.Reason != reason {
+		t.Fatalf("rejection reason = %q (%s), want %q", rejection.Reason, rejection.Message, reason)
+	}
+}
+
+func TestSendEnforcesAmountLimits(t *testing.T) {
+	s := newSetup(t, defaultConfig(), fakeSecondFactor{})
+
+	_, err := s.send(t, Attempt{Amount: money.Hbar(101), Password: dbtest.Password})
+	assertRejected(t, err, ReasonPerTransferLimit)
+
+	for i := 0; i < 2; i++ {
+		transfer, err := s.send(t, Attempt{Amount: money.Hbar(100), Password: dbtest.Password})
+		if err != nil {
+			t.Fatalf("transfer %d: %v", i+1, err)
+		}
+		if transfer.Status != models.TransferStatusCompleted {
+			t.Fatalf("transfer %d status = %q (%s), want completed", i+1, transfer.Status, transfer.FailureReason)
+		}
+	}
+	_, err = s.send(t, Attempt{Amount: money.Hbar(51), Password: dbtest.Password})
+	assertRejected(t, err, ReasonDailyLimit)
+
+	if _, err := s.send(t, Attempt{Amount: money.Hbar(50), Password: dbtest.Password}); err != nil {
+		t.Fatalf("transfer up to the daily limit: %v", err)
+	}
+
+	usage, err := s.policy.Usage(s.sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.SentToday != money.Hbar(250) || usage.RemainingToday == nil || *usage.RemainingToday != 0 {
+		t.Errorf("usage = %s sent, %v remaining; want 250 sent and none remaining", usage.SentToday, usage.RemainingToday)
+	}
+}
+
+func TestSendEnforcesTheHourlyLimit(t *testing.T) {
+	config := defaultConfig()
+	limits := config.Roles["user"]
+	limits.PerHour = 3
+	config.Roles["user"] = limits
+	s := newSetup(t, config, fakeSecondFactor{})
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.send(t, Attempt{Amount: money.Hbar(1)}); err != nil {
+			t.Fatalf("transfer %d: %v", i+1, err)
+		}
+	}
+	_, err := s.send(t, Attempt{Amount: money.Hbar(1)})
+	assertRejected(t, err, ReasonVelocityLimit)
+
+	// A retry of a transfer already made skips the limits
+	retried, err := s.send(t, Attempt{Amount: money.Hbar(1), IdempotencyKey: fmt.Sprintf("%s-1", t.Name())})
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if retried.Status != models.TransferStatusCompleted {
+		t.Errorf("retried status = %q, want completed", retried.Status)
+	}
+}
+
+func TestSendRequiresThePasswordAboveTheThreshold(t *testing.T) {
+	s := newSetup(t, defaultConfig(), fakeSecondFactor{})
+
+	if _, err := s.send(t, Attempt{Amount: money.Hbar(10)}); err != nil {
+		t.Fatalf("transfer at the threshold: %v", err)
+	}
+	_, err := s.send(t, Attempt{Amount: money.Hbar(11)})
+	assertRejected(t, err, ReasonStepUpRequired)
+
+	for i := 0; i < maxStepUpFailures; i++ {
+		_, err := s.send(t, Attempt{Amount: money.Hbar(11), Password: "wrong"})
+		assertRejected(t, err, ReasonStepUpFailed)
+	}
+	// Locked even with the right password
+	_, err = s.send(t, Attempt{Amount: money.Hbar(11), Password: dbtest.Password})
+	assertRejected(t, err, ReasonStepUpLocked)
+
+	usage, err := s.policy.Usage(s.sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 1 + maxStepUpFailures; usage.TransfersInHour != want {
+		t.Errorf("transfers in hour = %d, want %d including failed confirmations", usage.TransfersInHour, want)
+	}
+}
+
+func TestSendAsksForTheTwoFactorCodeWhenEnabled(t *testing.T) {
+	s := newSetup(t, defaultConfig(), fakeSecondFactor{enabled: true, code: "123456"})
+
+	_, err := s.send(t, Attempt{Amount: money.Hbar(20), Password: dbtest.Password})
+	assertRejected(t, err, ReasonStepUpRequired)
+
+	_, err = s.send(t, Attempt{Amount: money.Hbar(20), TwoFactorCode: "654321"})
+	assertRejected(t, err, ReasonStepUpFailed)
+
+	if _, err := s.send(t, Attempt{Amount: money.Hbar(20), TwoFactorCode: "123456"}); err != nil {
+		t.Fatalf("transfer with the right code: %v", err)
+	}
+
+	locked := newSetup(t, defaultConfig(), fakeSecondFactor{enabled: true, err: twofactor.ErrLocked})
+	_, err = locked.send(t, Attempt{Amount: money.Hbar(20), TwoFactorCode: "123456"})
+	assertRejected(t, err, ReasonStepUpLocked)
+}
+
+func TestLimitsForUserRequireVerification(t *testing.T) {
+	config := defaultConfig()
+	coach, err := dbtest.CreateUser(dbtest.DB, "coach")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.LimitsForUser(coach); got != DefaultLimits["coach"] {
+		t.Errorf("verified coach limits = %+v, want the coach limits", got)
+	}
+
+	coach.VerificationStatus = models.VerificationStatusPending
+	if got := config.LimitsForUser(coach); got != DefaultLimits["user"] {
+		t.Errorf("unverified coach limits = %+v, want the user limits", got)
+	}
+}
+
+func TestConfigFromEnvOverridesRoles(t *testing.T) {
+	t.Setenv("TRANSFER_LIMITS_USER", "per_transfer=50,per_hour=0")
+
+	config, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := config.LimitsFor("user")
+	if user.PerTransfer != money.Hbar(50) || user.PerHour != 0 || user.Daily != DefaultLimits["user"].Daily {
+		t.Errorf("user limits = %+v", user)
+	}
+
+	t.Setenv("TRANSFER_LIMITS_USER", "per_transfer=lots")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("invalid setting accepted")
+	}
+}
+
+func TestConfigFromEnvAddsRoles(t *testing.T) {
+	t.Setenv("TRANSFER_LIMITS_ADMIN", "daily=0")
+
+	config, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultLimits["user"]
+	want.Daily = 0
+	if got := config.LimitsFor("admin"); got != want {
+		t.Errorf("admin limits = %+v, want the user limits without a daily cap", got)
+	}
+}
@@ -0,0 +1,70 @@
+package twofactor
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+// roles that can be required to use two-factor authentication: the staff
+// roles and the verified professional roles
+var roles = map[string]bool{
+	models.StaffRoleAdmin:     true,
+	models.StaffRoleModerator: true,
+	"coach":                   true,
+	"mentor":                  true,
+}
+
+// Policy lists the roles that must use two-factor authentication. The zero
+// Policy requires it of no one.
+type Policy struct {
+	required map[string]bool
+}
+
+// NewPolicy creates a policy requiring two-factor authentication of roles
+func NewPolicy(required ...string) (Policy, error) {
+	policy := Policy{required: map[string]bool{}}
+	for _, role := range required {
+		if !roles[role] {
+			return Policy{}, fmt.Errorf("unknown two-factor role %q", role)
+		}
+		policy.required[role] = true
+	}
+	return policy, nil
+}
+
+// PolicyFromEnv reads TWO_FACTOR_REQUIRED_FOR, a comma-separated list of
+// admin, moderator, coach and mentor. Unset, no one is required.
+func PolicyFromEnv() (Policy, error) {
+	var required []string
+	for _, role := range strings.Split(os.Getenv("TWO_FACTOR_REQUIRED_FOR"), ",") {
+		if role = strings.TrimSpace(role); role != "" && role != "none" {
+			required = append(required, role)
+		}
+	}
+	policy, err := NewPolicy(required...)
+	if err != nil {
+		return Policy{}, fmt.Errorf("invalid TWO_FACTOR_REQUIRED_FOR: %w", err)
+	}
+	return policy, nil
+}
+
+// Requires reports whether a user must use two-factor authentication. The
+// user's staff role must be loaded.
+func (p Policy) Requires(user *models.User) bool {
+	return p.required[user.Role] || (user.StaffRole != "" && p.required[user.StaffRole])
+}
+
+// Required returns the roles two-factor authentication is required of in
+// alphabetical order
+func (p Policy) Required() []string {
+	required := []string{}
+	for role := range p.required {
+		required = append(required, role)
+	}
+	sort.Strings(required)
+	return required
+}
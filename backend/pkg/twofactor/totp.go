@@ -0,0 +1,102 @@
+package twofactor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238 defaults, which authenticator apps expect)
+const (
+	stepSeconds = 30
+	codeDigits  = 6
+	secretBytes = 20
+
+	// skewSteps is how many steps before or after the current one a code
+	// is accepted, allowing for clock drift and slow typing
+	skewSteps = 1
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newSecret returns a random base32 TOTP secret
+func newSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return secretEncoding.EncodeToString(b), nil
+}
+
+// timeStep returns the TOTP time step a moment falls in
+func timeStep(t time.Time) int64 {
+	return t.Unix() / stepSeconds
+}
+
+// totpCode computes the code of a secret for a time step (RFC 4226 HOTP
+// with the step as counter)
+func totpCode(secret string, step int64) (string, error) {
+	key, err := secretEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", codeDigits, value%1000000), nil
+}
+
+// matchStep returns the time step near now whose code is code
+func matchStep(secret, code string, now time.Time) (int64, bool, error) {
+	current := timeStep(now)
+	for step := current - skewSteps; step <= current+skewSteps; step++ {
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// provisioningURI returns the otpauth:// URI authenticator apps enroll
+// from, usually shown as a QR code
+func provisioningURI(issuer, account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(codeDigits))
+	params.Set("period", fmt.Sprint(stepSeconds))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// isTOTPCode reports whether input looks like a TOTP code rather than a
+// recovery code
+func isTOTPCode(input string) bool {
+	if len(input) != codeDigits {
+		return false
+	}
+	for _, r := range input {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
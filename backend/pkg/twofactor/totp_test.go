@@ -0,0 +1,177 @@
+package twofactor
+
+import (
+	"encoding/base32"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/db/dbtest"
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; 6-digit codes are their last six digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, test := range tests {
+		got, err := totpCode(rfc6238Secret, timeStep(time.Unix(test.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("code at %d = %s, want %s", test.unix, got, test.want)
+		}
+	}
+
+	// Secrets are accepted in lower case, as some apps display them
+	lower, err := totpCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", timeStep(time.Unix(59, 0)))
+	if err != nil || lower != "287082" {
+		t.Errorf("lower-case secret code = %q, %v; want 287082", lower, err)
+	}
+	if _, err := totpCode("not base32!", 1); err == nil {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestMatchStepAllowsOneStepOfSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := timeStep(now)
+
+	tests := []struct {
+		name   string
+		offset int64
+		want   bool
+	}{
+		{"two steps early", -2, false},
+		{"one step early", -1, true},
+		{"current step", 0, true},
+		{"one step late", 1, true},
+		{"two steps late", 2, false},
+	}
+	for _, test := range tests {
+		code, err := totpCode(rfc6238Secret, current+test.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok, err := matchStep(rfc6238Secret, code, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != test.want {
+			t.Errorf("%s: matched = %v, want %v", test.name, ok, test.want)
+		}
+		if ok && step != current+test.offset {
+			t.Errorf("%s: step = %d, want %d", test.name, step, current+test.offset)
+		}
+	}
+}
+
+func TestIsTOTPCode(t *testing.T) {
+	for input, want := range map[string]bool{
+		"123456":   true,
+		"12345":    false,
+		"1234567":  false,
+		"12345a":   false,
+		"abcd2345": false,
+	} {
+		if got := isTOTPCode(input); got != want {
+			t.Errorf("isTOTPCode(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+// enroll turns two-factor authentication on for a new user and returns the
+// secret, the time step of the code that enabled it and the recovery codes
+func enroll(t *testing.T, service *Service) (*models.User, string, int64, []string) {
+	t.Helper()
+	user, err := dbtest.CreateUser(dbtest.DB, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrollment, err := service.Setup(user)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	step := timeStep(time.Now())
+	recoveryCodes, err := service.Enable(user, stepCode(t, enrollment.Secret, step))
+	if err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	return user, enrollment.Secret, step, recoveryCodes
+}
+
+// stepCode returns the code of a time step
+func stepCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := totpCode(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestVerifyCodeRejectsReplays(t *testing.T) {
+	service := NewService(dbtest.DB, DefaultIssuer, Policy{})
+	user, secret, step, recoveryCodes := enroll(t, service)
+
+	// The code used to enable two-factor authentication is spent
+	if ok, err := service.VerifyCode(user, stepCode(t, secret, step)); ok || err != nil {
+		t.Errorf("enrollment code replayed = %v, %v; want rejected", ok, err)
+	}
+
+	next := stepCode(t, secret, step+1)
+	if ok, err := service.VerifyCode(user, next); !ok || err != nil {
+		t.Fatalf("next code = %v, %v; want accepted", ok, err)
+	}
+	if ok, err := service.VerifyCode(user, next); ok || err != nil {
+		t.Errorf("next code replayed = %v, %v; want rejected", ok, err)
+	}
+
+	if ok, err := service.VerifyCode(user, recoveryCodes[0]); !ok || err != nil {
+		t.Fatalf("recovery code = %v, %v; want accepted", ok, err)
+	}
+	if ok, err := service.VerifyCode(user, recoveryCodes[0]); ok || err != nil {
+		t.Errorf("recovery code reused = %v, %v; want rejected", ok, err)
+	}
+}
+
+func TestVerifyCodeLocksAfterRepeatedWrongCodes(t *testing.T) {
+	service := NewService(dbtest.DB, DefaultIssuer, Policy{})
+	user, _, _, recoveryCodes := enroll(t, service)
+
+	// A correct code clears the count of wrong ones
+	for i := 0; i < maxCodeFailures-1; i++ {
+		if ok, err := service.VerifyCode(user, "wrong-code"); ok || err != nil {
+			t.Fatalf("wrong code %d = %v, %v; want rejected", i+1, ok, err)
+		}
+	}
+	if ok, err := service.VerifyCode(user, recoveryCodes[0]); !ok || err != nil {
+		t.Fatalf("recovery code = %v, %v; want accepted", ok, err)
+	}
+
+	for i := 0; i < maxCodeFailures-1; i++ {
+		if ok, err := service.VerifyCode(user, "wrong-code"); ok || err != nil {
+			t.Fatalf("wrong code %d after the reset = %v, %v; want rejected", i+1, ok, err)
+		}
+	}
+	if _, err := service.VerifyCode(user, "wrong-code"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("wrong code %d error = %v, want ErrLocked", maxCodeFailures, err)
+	}
+	// Locked even for a correct code
+	if _, err := service.VerifyCode(user, recoveryCodes[1]); !errors.Is(err, ErrLocked) {
+		t.Errorf("correct code while locked error = %v, want ErrLocked", err)
+	}
+}
@@ -0,0 +1,394 @@
+// Package twofactor adds TOTP (RFC 6238) two-factor authentication to
+// accounts. A user enrolls by adding the secret to an authenticator app,
+// usually by scanning the provisioning URI as a QR code, and confirming it
+// with a first code; they then get one-time recovery codes for when the app
+// is lost. Once enabled, signing in takes a second step with a code, and
+// large transfers ask for a code instead of the password. Too many wrong
+// codes in a row lock every code check of the user for a while.
+package twofactor
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/utils"
+)
+
+const (
+	// DefaultIssuer names the account in authenticator apps when
+	// TWO_FACTOR_ISSUER is not set
+	DefaultIssuer = "Oncure"
+
+	// recoveryCodeCount is how many recovery codes a user gets at a time
+	recoveryCodeCount = 10
+
+	// challengeTTL is how long the second sign-in step may take
+	challengeTTL = 5 * time.Minute
+
+	// maxChallengeAttempts bounds the wrong codes tried against one sign-in
+	maxChallengeAttempts = 5
+
+	// maxCodeFailures wrong codes in a row, wherever they are checked, lock
+	// a user's code checks for codeLockout
+	maxCodeFailures = 5
+	codeLockout     = 15 * time.Minute
+)
+
+var (
+	ErrAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrNotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrNotSetUp         = errors.New("start two-factor setup first")
+	ErrInvalidCode      = errors.New("invalid two-factor code")
+	ErrInvalidPassword  = errors.New("incorrect password")
+	ErrRequired         = errors.New("two-factor authentication is required for your account")
+	ErrInvalidChallenge = errors.New("the sign-in has expired; please sign in again")
+	ErrLocked           = errors.New("too many incorrect two-factor codes; try again later")
+)
+
+// Enrollment is what a user needs to add their account to an authenticator app
+type Enrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// Status describes a user's two-factor authentication
+type Status struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	Required               bool       `json:"required"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// Challenge is a sign-in waiting for its second step
+type Challenge struct {
+	Token     string    `json:"challenge"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Service enrolls users and checks their codes
+type Service struct {
+	db     *sql.DB
+	issuer string
+	policy Policy
+}
+
+// NewService creates a two-factor authentication service
+func NewService(db *sql.DB, issuer string, policy Policy) *Service {
+	return &Service{db: db, issuer: issuer, policy: policy}
+}
+
+// IssuerFromEnv reads TWO_FACTOR_ISSUER
+func IssuerFromEnv() string {
+	if issuer := strings.TrimSpace(os.Getenv("TWO_FACTOR_ISSUER")); issuer != "" {
+		return issuer
+	}
+	return DefaultIssuer
+}
+
+// Status reports a user's two-factor authentication
+func (s *Service) Status(user *models.User) (*Status, error) {
+	status := &Status{Required: s.policy.Requires(user)}
+	enrollment, err := models.GetTwoFactor(s.db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment == nil || !enrollment.Enabled() {
+		return status, nil
+	}
+
+	status.Enabled = true
+	status.EnabledAt = enrollment.EnabledAt
+	status.RecoveryCodesRemaining, err = models.CountRecoveryCodes(s.db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Enabled reports whether a user has two-factor authentication on
+func (s *Service) Enabled(user *models.User) (bool, error) {
+	return models.IsTwoFactorEnabled(s.db, user.ID)
+}
+
+// Setup starts enrollment with a new secret. Calling it again before
+// enrollment is confirmed replaces the secret.
+func (s *Service) Setup(user *models.User) (*Enrollment, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	saved, err := models.SaveTwoFactorSecret(s.db, user.ID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return nil, ErrAlreadyEnabled
+	}
+	return &Enrollment{Secret: secret, ProvisioningURI: provisioningURI(s.issuer, user.Email, secret)}, nil
+}
+
+// Enable confirms enrollment with a code from the authenticator app and
+// returns the user's recovery codes, which are only shown this once
+func (s *Service) Enable(user *models.User, code string) ([]string, error) {
+	enrollment, err := models.GetTwoFactor(s.db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment == nil {
+		return nil, ErrNotSetUp
+	}
+	if enrollment.Enabled() {
+		return nil, ErrAlreadyEnabled
+	}
+
+	var step int64
+	err = s.attempt(enrollment, func() error {
+		matched, ok, err := matchStep(enrollment.Secret, normalizeCode(code), time.Now())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidCode
+		}
+		step = matched
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := models.EnableTwoFactor(s.db, user.ID, step, hashes)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrAlreadyEnabled
+	}
+	log.Printf("Two-factor authentication enabled for user %d", user.ID)
+	return codes, nil
+}
+
+// Disable turns two-factor authentication off after checking the password
+// and a code. Users the policy requires it of cannot turn it off.
+func (s *Service) Disable(user *models.User, password, code string) error {
+	if s.policy.Requires(user) {
+		return ErrRequired
+	}
+	authenticated, err := models.AuthenticateUser(s.db, user.Email, password)
+	if err != nil {
+		return err
+	}
+	if authenticated == nil || authenticated.ID != user.ID {
+		return ErrInvalidPassword
+	}
+	if err := s.checkCode(user.ID, code); err != nil {
+		return err
+	}
+
+	if err := models.DisableTwoFactor(s.db, user.ID); err != nil {
+		return err
+	}
+	log.Printf("Two-factor authentication disabled for user %d", user.ID)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes after checking a code
+func (s *Service) RegenerateRecoveryCodes(user *models.User, code string) ([]string, error) {
+	if err := s.checkCode(user.ID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := models.ReplaceRecoveryCodes(s.db, user.ID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyCode checks a TOTP or recovery code of a user with two-factor
+// authentication enabled. A code is only accepted once. It returns ErrLocked
+// while the user's code checks are locked.
+func (s *Service) VerifyCode(user *models.User, code string) (bool, error) {
+	err := s.checkCode(user.ID, code)
+	if errors.Is(err, ErrInvalidCode) || errors.Is(err, ErrNotEnabled) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// StartLogin begins the second sign-in step for a user whose password was
+// accepted
+func (s *Service) StartLogin(user *models.User) (*Challenge, error) {
+	token, err := utils.NewToken()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(challengeTTL)
+	if err := models.CreateLoginChallenge(s.db, user.ID, utils.HashToken(token), expiresAt); err != nil {
+		return nil, err
+	}
+	return &Challenge{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// CompleteLogin checks the code of a sign-in challenge and returns the user
+// to sign in. A challenge is spent once used or after maxChallengeAttempts
+// wrong codes, which also count toward the user's code lockout.
+func (s *Service) CompleteLogin(token, code string) (*models.User, error) {
+	challenge, err := models.GetLoginChallenge(s.db, utils.HashToken(strings.TrimSpace(token)))
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil || challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) ||
+		challenge.Attempts >= maxChallengeAttempts {
+		return nil, ErrInvalidChallenge
+	}
+
+	if err := s.checkCode(challenge.UserID, code); err != nil {
+		if !errors.Is(err, ErrInvalidCode) {
+			return nil, err
+		}
+		counted, countErr := models.RecordLoginChallengeAttempt(s.db, challenge.ID, maxChallengeAttempts)
+		if countErr != nil {
+			return nil, countErr
+		}
+		if !counted {
+			return nil, ErrInvalidChallenge
+		}
+		return nil, ErrInvalidCode
+	}
+
+	completed, err := models.CompleteLoginChallenge(s.db, challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !completed {
+		return nil, ErrInvalidChallenge
+	}
+	user, err := models.GetUserById(s.db, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidChallenge
+	}
+	return user, nil
+}
+
+// checkCode accepts a TOTP code or an unused recovery code of a user with
+// two-factor authentication enabled
+func (s *Service) checkCode(userID int, code string) error {
+	enrollment, err := models.GetTwoFactor(s.db, userID)
+	if err != nil {
+		return err
+	}
+	if enrollment == nil || !enrollment.Enabled() {
+		return ErrNotEnabled
+	}
+	return s.attempt(enrollment, func() error {
+		return s.matchCode(userID, normalizeCode(code), enrollment)
+	})
+}
+
+// attempt runs a code check unless the user's code checks are locked. A
+// wrong code counts toward the lockout and an accepted one clears the count.
+func (s *Service) attempt(enrollment *models.TwoFactor, check func() error) error {
+	now := time.Now()
+	if enrollment.Locked(now) {
+		return ErrLocked
+	}
+
+	err := check()
+	if errors.Is(err, ErrInvalidCode) {
+		locked, recordErr := models.RecordTwoFactorFailure(s.db, enrollment.UserID, maxCodeFailures, now.Add(codeLockout))
+		if recordErr != nil {
+			return recordErr
+		}
+		if locked {
+			log.Printf("Locked two-factor code checks of user %d after %d incorrect codes", enrollment.UserID, maxCodeFailures)
+			return ErrLocked
+		}
+		return ErrInvalidCode
+	}
+	if err != nil {
+		return err
+	}
+	if enrollment.FailedAttempts > 0 {
+		return models.ResetTwoFactorFailures(s.db, enrollment.UserID)
+	}
+	return nil
+}
+
+// matchCode checks a normalized TOTP or recovery code and spends it
+func (s *Service) matchCode(userID int, code string, enrollment *models.TwoFactor) error {
+	if code == "" {
+		return ErrInvalidCode
+	}
+	if isTOTPCode(code) {
+		step, ok, err := matchStep(enrollment.Secret, code, time.Now())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidCode
+		}
+		// Each code works once
+		used, err := models.UseTwoFactorStep(s.db, userID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+
+	used, err := models.UseRecoveryCode(s.db, userID, utils.HashToken(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	log.Printf("User %d used a two-factor recovery code", userID)
+	return nil
+}
+
+// normalizeCode strips the spaces and dashes users type in codes and
+// lowercases recovery codes
+func normalizeCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// newRecoveryCodes returns new recovery codes, formatted as xxxxx-xxxxx, and
+// the hashes they are stored as
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := recoveryEncoding.EncodeToString(b)[:10]
+		codes = append(codes, fmt.Sprintf("%s-%s", raw[:5], raw[5:]))
+		hashes = append(hashes, utils.HashToken(raw))
+	}
+	return codes, hashes, nil
+}
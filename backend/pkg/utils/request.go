@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the address a request came from, preferring the first
+// X-Forwarded-For entry set by a proxy in front of the server. It is meant
+// for logs and review, not for access decisions.
+func ClientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(first)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
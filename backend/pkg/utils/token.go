@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// NewToken returns a random URL-safe token for links sent by email
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex SHA-256 hash a token is stored as
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenLink adds a token to a link as its token query parameter
+func TokenLink(link, token string) string {
+	separator := "?"
+	if strings.Contains(link, "?") {
+		separator = "&"
+	}
+	return link + separator + "token=" + url.QueryEscape(token)
+}
+
+// DescribeDuration describes a duration in whole hours or minutes, such as
+// "1 hour" or "90 minutes", for emails
+func DescribeDuration(d time.Duration) string {
+	if d%time.Hour == 0 {
+		if hours := int(d / time.Hour); hours != 1 {
+			return fmt.Sprintf("%d hours", hours)
+		}
+		return "1 hour"
+	}
+	if minutes := int(d / time.Minute); minutes != 1 {
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+	return "1 minute"
+}
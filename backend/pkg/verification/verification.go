@@ -0,0 +1,258 @@
+// Package verification reviews the requests of users who want to be
+// verified as a coach or mentor. A request is pending until an admin
+// approves it, rejects it with a reason or asks the applicant for more
+// information. Approval grants the requested role; after a rejection the
+// applicant may apply again once the reapply period has passed.
+package verification
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/On-cure/Oncure/pkg/models"
+)
+
+// DefaultReapplyAfter is how long a rejected applicant waits before applying
+// again when VERIFICATION_REAPPLY_DAYS is not set
+const DefaultReapplyAfter = 30 * 24 * time.Hour
+
+// maxDocuments bounds the documents attached to one request
+const maxDocuments = 10
+
+var (
+	ErrNotFound         = errors.New("verification request not found")
+	ErrInvalidRole      = errors.New("requested_role must be coach or mentor")
+	ErrNoDocuments      = errors.New("at least one document is required")
+	ErrTooManyDocuments = fmt.Errorf("at most %d documents can be attached", maxDocuments)
+	ErrAlreadyOpen      = errors.New("you already have an open verification request")
+	ErrAlreadyVerified  = errors.New("you are already verified for this role")
+	ErrWrongStatus      = errors.New("the request cannot be reviewed in its current status")
+	ErrReasonRequired   = errors.New("a reason is required")
+	ErrMessageRequired  = errors.New("a message is required")
+)
+
+// ReapplyError is returned when a rejected applicant applies again before
+// the reapply period has passed
+type ReapplyError struct {
+	After time.Time
+}
+
+func (e *ReapplyError) Error() string {
+	return fmt.Sprintf("your last request was rejected; you can apply again after %s", e.After.UTC().Format(time.RFC3339))
+}
+
+// Service submits and reviews verification requests
+type Service struct {
+	db           *sql.DB
+	reapplyAfter time.Duration
+}
+
+// NewService creates a verification service
+func NewService(db *sql.DB, reapplyAfter time.Duration) *Service {
+	return &Service{db: db, reapplyAfter: reapplyAfter}
+}
+
+// ReapplyAfterFromEnv reads VERIFICATION_REAPPLY_DAYS. Zero lets rejected
+// applicants apply again right away.
+func ReapplyAfterFromEnv() (time.Duration, error) {
+	value := os.Getenv("VERIFICATION_REAPPLY_DAYS")
+	if value == "" {
+		return DefaultReapplyAfter, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("invalid VERIFICATION_REAPPLY_DAYS %q", value)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// Submit files a verification request for a role. When the applicant's open
+// request is waiting for more information, the new documents and notes are
+// added to it, keeping the ones sent before, and it goes back to the review
+// queue instead.
+func (s *Service) Submit(user *models.User, role string, documents []string, notes string) (*models.VerificationRequest, error) {
+	if role != "coach" && role != "mentor" {
+		return nil, ErrInvalidRole
+	}
+	documents = cleanDocuments(documents)
+	if len(documents) == 0 {
+		return nil, ErrNoDocuments
+	}
+	if len(documents) > maxDocuments {
+		return nil, ErrTooManyDocuments
+	}
+	if user.Role == role && user.VerificationStatus == models.VerificationStatusVerified {
+		return nil, ErrAlreadyVerified
+	}
+
+	latest, err := models.GetLatestVerificationRequest(s.db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil {
+		switch latest.Status {
+		case models.VerificationRequestPending:
+			return nil, ErrAlreadyOpen
+		case models.VerificationRequestNeedsInfo:
+			if latest.RequestedRole != role {
+				return nil, ErrAlreadyOpen
+			}
+			if countNew(latest.Documents, documents)+len(latest.Documents) > maxDocuments {
+				return nil, ErrTooManyDocuments
+			}
+			resubmitted, err := models.ResubmitVerificationRequest(s.db, latest.ID, documents, strings.TrimSpace(notes))
+			if err != nil {
+				return nil, err
+			}
+			if !resubmitted {
+				return nil, ErrAlreadyOpen
+			}
+			return models.GetVerificationRequestByID(s.db, latest.ID)
+		case models.VerificationRequestRejected:
+			if latest.ReviewedAt != nil {
+				if after := latest.ReviewedAt.Add(s.reapplyAfter); time.Now().Before(after) {
+					return nil, &ReapplyError{After: after}
+				}
+			}
+		}
+	}
+
+	return models.CreateVerificationRequest(s.db, user.ID, role, documents, strings.TrimSpace(notes))
+}
+
+// Latest returns a user's most recent request, or nil if they never applied
+func (s *Service) Latest(userID int) (*models.VerificationRequest, error) {
+	return models.GetLatestVerificationRequest(s.db, userID)
+}
+
+// ReapplyAt returns when a rejected request's applicant may apply again
+func (s *Service) ReapplyAt(request *models.VerificationRequest) *time.Time {
+	if request == nil || request.Status != models.VerificationRequestRejected || request.ReviewedAt == nil {
+		return nil
+	}
+	after := request.ReviewedAt.Add(s.reapplyAfter)
+	return &after
+}
+
+// List returns the requests with a status and their applicants
+func (s *Service) List(status string, limit int) ([]models.VerificationRequest, error) {
+	return models.GetVerificationRequests(s.db, status, limit)
+}
+
+// Get returns a request with its applicant
+func (s *Service) Get(requestID int) (*models.VerificationRequest, error) {
+	request, err := models.GetVerificationRequestByID(s.db, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, ErrNotFound
+	}
+	return request, nil
+}
+
+// Approve grants the applicant the requested role and marks them verified
+func (s *Service) Approve(requestID int, admin *models.User, note string) (*models.VerificationRequest, error) {
+	request, err := s.review(requestID, admin, models.VerificationRequestApproved, note)
+	if err != nil {
+		return nil, err
+	}
+	s.notify(request.UserID, "verification_approved", request.ID,
+		"Your verification as a %s was approved", request.RequestedRole)
+	return request, nil
+}
+
+// Reject declines a request with a reason shown to the applicant
+func (s *Service) Reject(requestID int, admin *models.User, reason string) (*models.VerificationRequest, error) {
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	request, err := s.review(requestID, admin, models.VerificationRequestRejected, reason)
+	if err != nil {
+		return nil, err
+	}
+	message := fmt.Sprintf("Your verification as a %s was rejected: %s", request.RequestedRole, reason)
+	if reapplyAt := s.ReapplyAt(request); reapplyAt != nil && s.reapplyAfter > 0 {
+		message += fmt.Sprintf(". You can apply again after %s", reapplyAt.UTC().Format("2006-01-02"))
+	}
+	s.notify(request.UserID, "verification_rejected", request.ID, "%s", message)
+	return request, nil
+}
+
+// RequestInfo sends a request back to the applicant with a message saying
+// what is missing. It returns to the queue when they resubmit.
+func (s *Service) RequestInfo(requestID int, admin *models.User, message string) (*models.VerificationRequest, error) {
+	if message == "" {
+		return nil, ErrMessageRequired
+	}
+	request, err := s.review(requestID, admin, models.VerificationRequestNeedsInfo, message)
+	if err != nil {
+		return nil, err
+	}
+	s.notify(request.UserID, "verification_info_requested", request.ID,
+		"More information is needed for your verification: %s", message)
+	return request, nil
+}
+
+// review records an admin's decision on a pending request
+func (s *Service) review(requestID int, admin *models.User, status, note string) (*models.VerificationRequest, error) {
+	request, err := s.Get(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != models.VerificationRequestPending {
+		return nil, ErrWrongStatus
+	}
+
+	reviewed, err := models.ReviewVerificationRequest(s.db, request, admin.ID, status, note)
+	if err != nil {
+		return nil, err
+	}
+	if !reviewed {
+		return nil, ErrWrongStatus
+	}
+	log.Printf("Verification request %d of user %d %s by admin %d", request.ID, request.UserID, status, admin.ID)
+	return s.Get(request.ID)
+}
+
+// notify creates a notification about a verification request, logging failures
+func (s *Service) notify(userID int, notificationType string, requestID int, format string, args ...interface{}) {
+	if _, err := models.CreateNotification(s.db, userID, notificationType, fmt.Sprintf(format, args...), requestID); err != nil {
+		log.Printf("Failed to create %s notification for verification request %d: %v", notificationType, requestID, err)
+	}
+}
+
+// countNew counts the documents not already attached
+func countNew(attached, documents []string) int {
+	count := 0
+	for _, document := range documents {
+		found := false
+		for _, existing := range attached {
+			if existing == document {
+				found = true
+				break
+			}
+		}
+		if !found {
+			count++
+		}
+	}
+	return count
+}
+
+// cleanDocuments trims document references and drops empty ones
+func cleanDocuments(documents []string) []string {
+	cleaned := []string{}
+	for _, document := range documents {
+		if document = strings.TrimSpace(document); document != "" {
+			cleaned = append(cleaned, document)
+		}
+	}
+	return cleaned
+}
@@ -42,6 +42,9 @@ type Client struct {
 	// User ID
 	UserID int
 
+	// Session the connection was opened with
+	SessionID int
+
 	// Username
 	Username string
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 
+	"github.com/On-cure/Oncure/pkg/emailverification"
 	"github.com/On-cure/Oncure/pkg/models"
 )
 
@@ -22,11 +23,18 @@ type Hub struct {
 	// Unregister requests from clients
 	Unregister chan *Client
 
+	// Sessions whose connections must be closed, buffered so revoking
+	// never waits on Run
+	revoke chan []int
+
 	// User ID to clients mapping for targeted messaging
 	userClients map[int][]*Client
 
 	// Database connection for permission validation
 	db *sql.DB
+
+	// Keeps users without a verified email from messaging when it restricts it
+	emailPolicy emailverification.Policy
 }
 
 // GetOnlineUserIDs returns a slice of user IDs that are currently connected
@@ -60,14 +68,54 @@ func (h *Hub) SendMessageToUser(userID int, message []byte) {
 	}
 }
 
+// CloseSessions closes the connections opened with the given sessions, e.g.
+// after they are revoked. It never blocks the caller: if Run has fallen
+// revokeBuffer calls behind, the sessions are logged and their connections
+// stay open until they disconnect.
+func (h *Hub) CloseSessions(sessionIDs ...int) {
+	if len(sessionIDs) == 0 {
+		return
+	}
+	select {
+	case h.revoke <- sessionIDs:
+	default:
+		log.Printf("Revocation queue full; could not close the connections of sessions %v", sessionIDs)
+	}
+}
+
 // IsUserOnline checks if a user is currently connected
 func (h *Hub) IsUserOnline(userID int) bool {
 	clients, exists := h.userClients[userID]
 	return exists && len(clients) > 0
 }
 
+// canMessage checks that a user is not suspended and that the email
+// verification policy lets them send messages. Suspension closes the user's
+// connections, but a message already in flight is checked here too.
+func (h *Hub) canMessage(senderID int) (bool, error) {
+	sender, err := models.GetUserById(h.db, senderID)
+	if err != nil || sender == nil {
+		return false, err
+	}
+	suspension, err := models.GetActiveSuspension(h.db, senderID)
+	if err != nil || suspension != nil {
+		return false, err
+	}
+	return h.emailPolicy.Allows(sender, emailverification.ActionMessaging), nil
+}
+
 // canSendMessage checks if a user can send a message to another user
 func (h *Hub) canSendMessage(senderID, recipientID int) (bool, error) {
+	if allowed, err := h.canMessage(senderID); err != nil || !allowed {
+		return false, err
+	}
+
+	// Blocked users cannot message each other
+	blocked, err := models.IsBlockedBetween(h.db, senderID, recipientID)
+	if err != nil || blocked {
+		return false, err
+	}
+
 	// Get recipient user
 	recipient, err := models.GetUserById(h.db, recipientID)
 	if err != nil {
@@ -116,15 +164,53 @@ func (h *Hub) removeUserClient(userID int, client *Client) {
 	}
 }
 
+// unregister removes a client and closes its connection, telling the other
+// clients when its user goes offline
+func (h *Hub) unregister(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	delete(h.clients, client)
+	h.removeUserClient(client.UserID, client)
+	close(client.Send)
+	log.Printf("Client disconnected: %d", client.UserID)
+
+	// Broadcast offline status if no more clients for this user
+	if !h.IsUserOnline(client.UserID) {
+		offlineMsg := map[string]interface{}{
+			"type":      "user_online_status",
+			"user_id":   client.UserID,
+			"is_online": false,
+		}
+		offlineJSON, _ := json.Marshal(offlineMsg)
+
+		// Send to all remaining clients
+		for otherClient := range h.clients {
+			select {
+			case otherClient.Send <- offlineJSON:
+			default:
+				close(otherClient.Send)
+				delete(h.clients, otherClient)
+				h.removeUserClient(otherClient.UserID, otherClient)
+			}
+		}
+	}
+}
+
+// revokeBuffer is how many CloseSessions calls can wait for Run
+const revokeBuffer = 256
+
 // NewHub creates a new hub
-func NewHub(db *sql.DB) *Hub {
+func NewHub(db *sql.DB, emailPolicy emailverification.Policy) *Hub {
 	return &Hub{
 		broadcast:   make(chan []byte),
 		Register:    make(chan *Client),
 		Unregister:  make(chan *Client),
+		revoke:      make(chan []int, revokeBuffer),
 		clients:     make(map[*Client]bool),
 		userClients: make(map[int][]*Client),
 		db:          db,
+		emailPolicy: emailPolicy,
 	}
 }
 
@@ -167,33 +253,24 @@ func (h *Hub) Run() {
 			}
 
 		case client := <-h.Unregister:
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				h.removeUserClient(client.UserID, client)
-				close(client.Send)
-				log.Printf("Client disconnected: %d", client.UserID)
-
-				// Broadcast offline status if no more clients for this user
-				if !h.IsUserOnline(client.UserID) {
-					offlineMsg := map[string]interface{}{
-						"type":      "user_online_status",
-						"user_id":   client.UserID,
-						"is_online": false,
-					}
-					offlineJSON, _ := json.Marshal(offlineMsg)
+			h.unregister(client)
 
-					// Send to all remaining clients
-					for otherClient := range h.clients {
-						select {
-						case otherClient.Send <- offlineJSON:
-						default:
-							close(otherClient.Send)
-							delete(h.clients, otherClient)
-							h.removeUserClient(otherClient.UserID, otherClient)
-						}
-					}
+		case sessionIDs := <-h.revoke:
+			// Close the connections opened with revoked sessions
+			revoked := map[int]bool{}
+			for _, id := range sessionIDs {
+				revoked[id] = true
+			}
+			var closing []*Client
+			for client := range h.clients {
+				if revoked[client.SessionID] {
+					closing = append(closing, client)
 				}
 			}
+			for _, client := range closing {
+				log.Printf("Closing connection of revoked session %d", client.SessionID)
+				h.unregister(client)
+			}
 
 		case message := <-h.broadcast:
 			// Parse message to determine recipients
@@ -258,7 +335,13 @@ func (h *Hub) Run() {
 					continue
 				}
 
-				// Send typing indicator to recipient only
+				// Send typing indicator to recipient only, unless one blocked the other
+				if senderID, ok := msg["sender_id"].(float64); ok {
+					blocked, err := models.IsBlockedBetween(h.db, int(senderID), int(recipientID))
+					if err != nil || blocked {
+						continue
+					}
+				}
 				h.SendMessageToUser(int(recipientID), message)
 
 			case "group", "group_message":
@@ -269,6 +352,18 @@ func (h *Hub) Run() {
 					continue
 				}
 
+				if senderID, ok := msg["sender_id"].(float64); ok {
+					allowed, err := h.canMessage(int(senderID))
+					if err != nil {
+						log.Printf("Error checking message permissions: %v", err)
+						continue
+					}
+					if !allowed {
+						log.Printf("User %d cannot send group messages before verifying their email", int(senderID))
+						continue
+					}
+				}
+
 				// Get group members from database
 				members, err := models.GetGroupMembers(h.db, int(groupID))
 				if err != nil {
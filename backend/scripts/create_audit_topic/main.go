@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/On-cure/Oncure/accounts"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load environment variables
+	if err := godotenv.Load(".env"); err != nil {
+		log.Printf("Warning: Could not load .env file: %v", err)
+	}
+
+	ledger, err := wallet.NewHederaLedgerFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize Hedera client: %v", err)
+	}
+
+	topicID, err := wallet.CreateAuditTopic(ledger)
+	if err != nil {
+		log.Fatalf("Failed to create audit topic: %v", err)
+	}
+
+	fmt.Printf("Created audit topic %s\n", topicID)
+	fmt.Println("\nAdd this to your .env:")
+	fmt.Printf("AUDIT_TOPIC_ID=%s\n", topicID)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/money"
+	hedera "github.com/hashgraph/hedera-sdk-go/v2"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load environment variables
+	if err := godotenv.Load(".env"); err != nil {
+		log.Printf("Warning: Could not load .env file: %v", err)
+	}
+
+	config := wallet.BadgeConfigFromEnv()
+	if config.TokenID != "" {
+		log.Fatalf("BADGE_TOKEN_ID is already set to %s", config.TokenID)
+	}
+
+	ledger, err := wallet.NewHederaLedgerFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize Hedera client: %v", err)
+	}
+
+	// Create a dedicated treasury account funded for its own fees
+	config.TreasuryAccountID, config.TreasuryPrivateKey, err = ledger.CreateAccount(money.Hbar(1))
+	if err != nil {
+		log.Fatalf("Failed to create treasury account: %v", err)
+	}
+
+	supplyKey, err := hedera.GeneratePrivateKey()
+	if err != nil {
+		log.Fatalf("Failed to generate supply key: %v", err)
+	}
+	config.SupplyPrivateKey = supplyKey.String()
+
+	config.TokenID, err = ledger.CreateToken(config.Spec())
+	if err != nil {
+		log.Fatalf("Failed to create badge collection: %v", err)
+	}
+
+	fmt.Printf("Created NFT collection %s (%s) with treasury %s\n",
+		config.Name, config.Symbol, config.TreasuryAccountID)
+	fmt.Println("\nAdd these to your .env:")
+	fmt.Printf("BADGE_TOKEN_ID=%s\n", config.TokenID)
+	fmt.Printf("BADGE_TREASURY_ID=%s\n", config.TreasuryAccountID)
+	fmt.Printf("BADGE_TREASURY_KEY=%s\n", config.TreasuryPrivateKey)
+	fmt.Printf("BADGE_SUPPLY_KEY=%s\n", config.SupplyPrivateKey)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/money"
+	hedera "github.com/hashgraph/hedera-sdk-go/v2"
+	"github.com/joho/godotenv"
+)
+
+// initialSupply is the whole-token supply minted into the new treasury
+const initialSupply = "1000000"
+
+func main() {
+	// Load environment variables
+	if err := godotenv.Load(".env"); err != nil {
+		log.Printf("Warning: Could not load .env file: %v", err)
+	}
+
+	config, err := wallet.TokenConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid token configuration: %v", err)
+	}
+	if config.TokenID != "" {
+		log.Fatalf("COMMUNITY_TOKEN_ID is already set to %s", config.TokenID)
+	}
+
+	ledger, err := wallet.NewHederaLedgerFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize Hedera client: %v", err)
+	}
+
+	// Create a dedicated treasury account funded for its own fees
+	config.TreasuryAccountID, config.TreasuryPrivateKey, err = ledger.CreateAccount(money.Hbar(1))
+	if err != nil {
+		log.Fatalf("Failed to create treasury account: %v", err)
+	}
+
+	supplyKey, err := hedera.GeneratePrivateKey()
+	if err != nil {
+		log.Fatalf("Failed to generate supply key: %v", err)
+	}
+	config.SupplyPrivateKey = supplyKey.String()
+
+	config.TokenID, err = ledger.CreateToken(config.Spec())
+	if err != nil {
+		log.Fatalf("Failed to create token: %v", err)
+	}
+
+	supply, err := money.ParseUnits(initialSupply, config.Decimals)
+	if err != nil {
+		log.Fatalf("Invalid initial supply: %v", err)
+	}
+	if err := ledger.MintToken(config.TokenID, config.SupplyPrivateKey, supply); err != nil {
+		log.Fatalf("Failed to mint initial supply: %v", err)
+	}
+
+	fmt.Printf("Created %s (%s) with %s tokens in treasury %s\n",
+		config.Name, config.Symbol, initialSupply, config.TreasuryAccountID)
+	fmt.Println("\nAdd these to your .env:")
+	fmt.Printf("COMMUNITY_TOKEN_ID=%s\n", config.TokenID)
+	fmt.Printf("COMMUNITY_TOKEN_TREASURY_ID=%s\n", config.TreasuryAccountID)
+	fmt.Printf("COMMUNITY_TOKEN_TREASURY_KEY=%s\n", config.TreasuryPrivateKey)
+	fmt.Printf("COMMUNITY_TOKEN_SUPPLY_KEY=%s\n", config.SupplyPrivateKey)
+	fmt.Printf("COMMUNITY_TOKEN_DECIMALS=%d\n", config.Decimals)
+}
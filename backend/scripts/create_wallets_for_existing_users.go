@@ -6,7 +6,6 @@ import (
 	"log"
 
 
-	"github.com/On-cure/Oncure/accounts"
 	"github.com/On-cure/Oncure/pkg/db/sqlite"
 	"github.com/On-cure/Oncure/pkg/models"
 	"github.com/joho/godotenv"
@@ -36,19 +35,19 @@ func main() {
 		return
 	}
 
-	fmt.Printf("Found %d users without wallets. Creating wallets...\n", len(users))
+	fmt.Printf("Found %d users without wallets. Queueing wallets...\n", len(users))
 
 	successCount := 0
 	for _, userID := range users {
-		if err := createWalletForUser(db, userID); err != nil {
-			log.Printf("Failed to create wallet for user %d: %v", userID, err)
+		if err := models.EnqueueWalletProvisioning(db, userID); err != nil {
+			log.Printf("Failed to queue wallet for user %d: %v", userID, err)
 		} else {
 			successCount++
-			fmt.Printf("✓ Created wallet for user %d\n", userID)
+			fmt.Printf("✓ Queued wallet for user %d\n", userID)
 		}
 	}
 
-	fmt.Printf("\nCompleted: %d/%d wallets created successfully\n", successCount, len(users))
+	fmt.Printf("\nCompleted: %d/%d wallets queued; the server creates them in the background\n", successCount, len(users))
 }
 
 func getUsersWithoutWallets(db *sql.DB) ([]int, error) {
@@ -76,14 +75,3 @@ func getUsersWithoutWallets(db *sql.DB) ([]int, error) {
 
 	return userIDs, nil
 }
-
-func createWalletForUser(db *sql.DB, userID int) error {
-	// Create Hedera wallet
-	accountID, privateKey, err := wallet.CreateUserWallet()
-	if err != nil {
-		return fmt.Errorf("failed to create Hedera wallet: %v", err)
-	}
-
-	// Store in database
-	return models.CreateUserWallet(db, userID, accountID, privateKey)
-}
\ No newline at end of file
@@ -51,7 +51,7 @@ func main() {
 }
 
 func getAllWallets(db *sql.DB) ([]models.UserWallet, error) {
-	query := `SELECT id, user_id, hedera_account_id, encrypted_private_key, token_balance, created_at, updated_at FROM user_wallets`
+	query := `SELECT id, user_id, hedera_account_id, encrypted_private_key, balance_tinybars, created_at, updated_at FROM user_wallets`
 	
 	rows, err := db.Query(query)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"log"
 
 	"github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/money"
 	"github.com/joho/godotenv"
 )
 
@@ -18,7 +19,7 @@ func main() {
 	fromAccount := "0.0.5793590" // Replace with actual account ID  
 	toAccount := "0.0.6874833"   // Replace with actual account ID       
 	privateKey := "" // Replace with actual private key
-	amount := 10.0 // 1 HBAR
+	amount := money.Hbar(10) // 10 HBAR
 
 	fmt.Printf("Testing HBAR transfer from %s to %s\n", fromAccount, toAccount)
 
@@ -27,14 +28,14 @@ func main() {
 	if err != nil {
 		log.Printf("Failed to get from balance: %v", err)
 	} else {
-		fmt.Printf("From account balance: %.8f HBAR\n", fromBalance)
+		fmt.Printf("From account balance: %s HBAR\n", fromBalance)
 	}
 
 	toBalance, err := wallet.GetAccountBalance(toAccount)
 	if err != nil {
 		log.Printf("Failed to get to balance: %v", err)
 	} else {
-		fmt.Printf("To account balance: %.8f HBAR\n", toBalance)
+		fmt.Printf("To account balance: %s HBAR\n", toBalance)
 	}
 
 	// Perform transfer
@@ -43,20 +44,20 @@ func main() {
 		log.Fatalf("Transfer failed: %v", err)
 	}
 
-	fmt.Printf("✓ Successfully transferred %.8f HBAR, transaction id: %s\n", amount, txID)
+	fmt.Printf("✓ Successfully transferred %s HBAR, transaction id: %s\n", amount, txID)
 
 	// Get final balances
 	fromBalanceAfter, err := wallet.GetAccountBalance(fromAccount)
 	if err != nil {
 		log.Printf("Failed to get from balance after: %v", err)
 	} else {
-		fmt.Printf("From account balance after: %.8f HBAR\n", fromBalanceAfter)
+		fmt.Printf("From account balance after: %s HBAR\n", fromBalanceAfter)
 	}
 
 	toBalanceAfter, err := wallet.GetAccountBalance(toAccount)
 	if err != nil {
 		log.Printf("Failed to get to balance after: %v", err)
 	} else {
-		fmt.Printf("To account balance after: %.8f HBAR\n", toBalanceAfter)
+		fmt.Printf("To account balance after: %s HBAR\n", toBalanceAfter)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,94 @@
+// Command kms_standin serves the wrap/unwrap API the "http" wallet key
+// provider expects, holding master keys from KMS_STANDIN_KEYS in memory. It
+// is meant for development and testing, not for production use.
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/On-cure/Oncure/pkg/keys"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load environment variables
+	if err := godotenv.Load(".env"); err != nil {
+		log.Printf("Warning: Could not load .env file: %v", err)
+	}
+
+	// KMS_STANDIN_KEYS uses the same id:base64key list as WALLET_ENCRYPTION_KEYS
+	os.Setenv("WALLET_ENCRYPTION_KEYS", os.Getenv("KMS_STANDIN_KEYS"))
+	provider, err := keys.NewEnvProvider()
+	if err != nil {
+		log.Fatalf("Failed to load KMS_STANDIN_KEYS: %v", err)
+	}
+	token := os.Getenv("WALLET_KMS_TOKEN")
+
+	addr := os.Getenv("KMS_STANDIN_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:8200"
+	}
+
+	http.HandleFunc("/v1/keys/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		keyID, operation, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/keys/"), "/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		var request map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		var input, output []byte
+		var field string
+		var err error
+		switch operation {
+		case "wrap":
+			input, err = base64.StdEncoding.DecodeString(request["plaintext"])
+			if err == nil {
+				output, err = provider.Wrap(keyID, input)
+			}
+			field = "ciphertext"
+		case "unwrap":
+			input, err = base64.StdEncoding.DecodeString(request["ciphertext"])
+			if err == nil {
+				output, err = provider.Unwrap(keyID, input)
+			}
+			field = "plaintext"
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err == keys.ErrUnknownKey {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{field: base64.StdEncoding.EncodeToString(output)})
+	})
+
+	log.Printf("KMS stand-in listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, nil))
+}
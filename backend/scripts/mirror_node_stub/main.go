@@ -0,0 +1,134 @@
+// Command mirror_node_stub serves the subset of the Hedera mirror node REST
+// API used by balance reconciliation from a JSON fixture, so reconciliation
+// can run against the in-memory ledger. Point HEDERA_MIRROR_NODE_URL at it.
+//
+// The fixture (MIRROR_STUB_FIXTURE) is re-read on every request:
+//
+//	{
+//	  "balances": {"0.0.1006": 500000000},
+//	  "transactions": [{"transaction_id": "0.0.9-1700000000-000000000",
+//	    "consensus_timestamp": "1700000001.000000000", "name": "CRYPTOTRANSFER",
+//	    "result": "SUCCESS", "charged_tx_fee": 100000,
+//	    "transfers": [{"account": "0.0.9", "amount": -100100000}, {"account": "0.0.1006", "amount": 100000000}]}]
+//	}
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type fixture struct {
+	Balances     map[string]int64 `json:"balances"`
+	Transactions []transaction    `json:"transactions"`
+}
+
+type transaction struct {
+	TransactionID      string `json:"transaction_id"`
+	ConsensusTimestamp string `json:"consensus_timestamp"`
+	Name               string `json:"name"`
+	Result             string `json:"result"`
+	ChargedTxFee       int64  `json:"charged_tx_fee"`
+	Transfers          []struct {
+		Account string `json:"account"`
+		Amount  int64  `json:"amount"`
+	} `json:"transfers"`
+}
+
+func main() {
+	path := os.Getenv("MIRROR_STUB_FIXTURE")
+	if path == "" {
+		log.Fatal("MIRROR_STUB_FIXTURE is not set")
+	}
+	addr := os.Getenv("MIRROR_STUB_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:5551"
+	}
+
+	load := func(w http.ResponseWriter) (*fixture, bool) {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return nil, false
+		}
+		var f fixture
+		if err := json.Unmarshal(contents, &f); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return nil, false
+		}
+		return &f, true
+	}
+
+	http.HandleFunc("/api/v1/balances", func(w http.ResponseWriter, r *http.Request) {
+		f, ok := load(w)
+		if !ok {
+			return
+		}
+		accountID := r.URL.Query().Get("account.id")
+		balances := []map[string]interface{}{}
+		if balance, ok := f.Balances[accountID]; ok {
+			balances = append(balances, map[string]interface{}{"account": accountID, "balance": balance})
+		}
+		respond(w, map[string]interface{}{"balances": balances, "links": map[string]interface{}{"next": nil}})
+	})
+
+	http.HandleFunc("/api/v1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		f, ok := load(w)
+		if !ok {
+			return
+		}
+		query := r.URL.Query()
+		accountID := query.Get("account.id")
+		after := strings.TrimPrefix(query.Get("timestamp"), "gt:")
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit <= 0 {
+			limit = 25
+		}
+
+		matches := []transaction{}
+		for _, t := range f.Transactions {
+			if after != "" && !timestampAfter(t.ConsensusTimestamp, after) {
+				continue
+			}
+			for _, transfer := range t.Transfers {
+				if transfer.Account == accountID {
+					matches = append(matches, t)
+					break
+				}
+			}
+		}
+		sort.Slice(matches, func(i, j int) bool {
+			return timestampAfter(matches[j].ConsensusTimestamp, matches[i].ConsensusTimestamp)
+		})
+		if len(matches) > limit {
+			matches = matches[:limit]
+		}
+		respond(w, map[string]interface{}{"transactions": matches, "links": map[string]interface{}{"next": nil}})
+	})
+
+	log.Printf("Mirror node stub listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, nil))
+}
+
+// timestampAfter reports whether consensus timestamp a (seconds.nanos) is later than b
+func timestampAfter(a, b string) bool {
+	aSeconds, aNanos, _ := strings.Cut(a, ".")
+	bSeconds, bNanos, _ := strings.Cut(b, ".")
+	if len(aSeconds) != len(bSeconds) {
+		return len(aSeconds) > len(bSeconds)
+	}
+	if aSeconds != bSeconds {
+		return aSeconds > bSeconds
+	}
+	return aNanos > bNanos
+}
+
+func respond(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	db "github.com/On-cure/Oncure/pkg/db"
+	"github.com/On-cure/Oncure/pkg/keys"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	batchSize := flag.Int("batch", 100, "wallets and two-factor secrets to load per batch")
+	flag.Parse()
+
+	// Load environment variables
+	if err := godotenv.Load(".env"); err != nil {
+		log.Printf("Warning: Could not load .env file: %v", err)
+	}
+
+	keyring, err := keys.NewKeyringFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load wallet encryption keys: %v", err)
+	}
+	models.SetWalletKeyring(keyring)
+
+	dbConn, err := db.InitDB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer dbConn.Close()
+
+	fmt.Printf("Rotating wallet keys to master key %s...\n", keyring.CurrentKeyID())
+	rotated, err := models.RotateWalletKeys(dbConn, *batchSize)
+	if err != nil {
+		log.Fatalf("Rotation stopped after %d wallets: %v", rotated, err)
+	}
+	fmt.Printf("Rotated %d wallets\n", rotated)
+
+	// Two-factor secrets are sealed with the same keys
+	rotated, err = models.RotateTwoFactorSecrets(dbConn, *batchSize)
+	if err != nil {
+		log.Fatalf("Rotation stopped after %d two-factor secrets: %v", rotated, err)
+	}
+	fmt.Printf("Rotated %d two-factor secrets\n", rotated)
+}
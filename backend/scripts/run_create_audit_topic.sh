@@ -0,0 +1,7 @@
+#!/bin/bash
+
+# Script to create the HCS topic for the reward audit trail and print its configuration
+cd "$(dirname "$0")/.."
+
+echo "Creating audit topic..."
+go run ./scripts/create_audit_topic
@@ -0,0 +1,7 @@
+#!/bin/bash
+
+# Script to create the achievement badge NFT collection and print its configuration
+cd "$(dirname "$0")/.."
+
+echo "Creating badge collection..."
+go run ./scripts/create_badge_collection
@@ -0,0 +1,7 @@
+#!/bin/bash
+
+# Script to create the community reward token and print its configuration
+cd "$(dirname "$0")/.."
+
+echo "Creating community token..."
+go run ./scripts/create_community_token
@@ -0,0 +1,9 @@
+#!/bin/bash
+
+# Script to re-wrap every wallet private key with the current master key.
+# Safe to run while the server is up, as long as the server still knows the
+# previous master keys.
+cd "$(dirname "$0")/.."
+
+echo "Rotating wallet encryption keys..."
+go run ./scripts/rotate_wallet_keys "$@"
@@ -1,7 +1,7 @@
 #!/bin/bash
 
-# Script to run wallet creation for existing users
+# Script to queue wallet creation for existing users without a wallet
 cd "$(dirname "$0")/.."
 
-echo "Creating wallets for existing users..."
+echo "Queueing wallets for existing users..."
 go run scripts/create_wallets_for_existing_users.go
\ No newline at end of file
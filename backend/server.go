@@ -7,14 +7,40 @@ import (
 	"os"
 	"time"
 
+	wallet "github.com/On-cure/Oncure/accounts"
+	"github.com/On-cure/Oncure/pkg/audit"
+	"github.com/On-cure/Oncure/pkg/badges"
 	db "github.com/On-cure/Oncure/pkg/db"
+	"github.com/On-cure/Oncure/pkg/emailverification"
+	"github.com/On-cure/Oncure/pkg/escrow"
 	"github.com/On-cure/Oncure/pkg/handlers"
+	"github.com/On-cure/Oncure/pkg/keys"
+	"github.com/On-cure/Oncure/pkg/mail"
 	"github.com/On-cure/Oncure/pkg/middleware"
+	"github.com/On-cure/Oncure/pkg/models"
+	"github.com/On-cure/Oncure/pkg/moderation"
+	"github.com/On-cure/Oncure/pkg/passwordreset"
+	"github.com/On-cure/Oncure/pkg/provisioning"
+	"github.com/On-cure/Oncure/pkg/reconciliation"
+	"github.com/On-cure/Oncure/pkg/rewards"
 	r "github.com/On-cure/Oncure/pkg/router"
+	"github.com/On-cure/Oncure/pkg/safety"
+	"github.com/On-cure/Oncure/pkg/sessions"
+	"github.com/On-cure/Oncure/pkg/transferpolicy"
+	"github.com/On-cure/Oncure/pkg/twofactor"
+	"github.com/On-cure/Oncure/pkg/verification"
 	"github.com/On-cure/Oncure/pkg/websocket"
 )
 
 func main() {
+	// Load the master keys wallet private keys are encrypted with; production
+	// refuses to start without a strong key
+	walletKeyring, err := keys.NewKeyringFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize wallet encryption keys: %v", err)
+	}
+	models.SetWalletKeyring(walletKeyring)
+
 	// Initialize database
 	dbConn, err := db.InitDB()
 	if err != nil {
@@ -27,28 +53,182 @@ func main() {
 		log.Fatalf("Failed to apply migrations: %v", err)
 	}
 
+	// Initialize ledger
+	ledger, err := wallet.NewLedgerFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize ledger: %v", err)
+	}
+	if _, ok := ledger.(*wallet.MemoryLedger); ok {
+		// The in-memory ledger starts empty and hands out the same account
+		// IDs on every start, so wallets from an earlier run would point at
+		// accounts that no longer exist or now belong to someone else
+		wallets, err := models.CountUserWallets(dbConn)
+		if err != nil {
+			log.Fatalf("Failed to count wallets: %v", err)
+		}
+		if wallets > 0 {
+			log.Fatalf("LEDGER_BACKEND=memory needs a database without wallets, found %d; start from a fresh database", wallets)
+		}
+	}
+
+	communityToken, err := wallet.NewCommunityTokenFromEnv(ledger)
+	if err != nil {
+		log.Fatalf("Failed to initialize community token: %v", err)
+	}
+
+	badgeCollection, err := wallet.NewBadgeCollectionFromEnv(ledger)
+	if err != nil {
+		log.Fatalf("Failed to initialize badge collection: %v", err)
+	}
+
+	escrowAccount, err := wallet.NewEscrowAccountFromEnv(ledger)
+	if err != nil {
+		log.Fatalf("Failed to initialize escrow account: %v", err)
+	}
+
+	// Resolve transfers left pending by a crash or lost ledger response
+	transferService := wallet.NewTransferService(dbConn, ledger, wallet.NewMirrorNodeFromEnv(), communityToken, badgeCollection, escrowAccount)
+	go transferService.RunRecoveryWorker(time.Minute, 2*time.Minute)
+
+	// Sessions slide forward while used and expired ones are swept hourly
+	sessionConfig, err := sessions.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load session configuration: %v", err)
+	}
+	sessionService := sessions.NewService(dbConn, sessionConfig)
+	go sessionService.Run(time.Hour)
+
+	// TOTP two-factor authentication for sign-in and large transfers
+	twoFactorPolicy, err := twofactor.PolicyFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load two-factor authentication policy: %v", err)
+	}
+	middleware.SetTwoFactorPolicy(twoFactorPolicy)
+	twoFactorService := twofactor.NewService(dbConn, twofactor.IssuerFromEnv(), twoFactorPolicy)
+
+	// Validate transfers and enforce per-role limits before they are sent
+	transferPolicyConfig, err := transferpolicy.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load transfer limits: %v", err)
+	}
+	transferPolicy := transferpolicy.NewPolicy(dbConn, transferPolicyConfig, twoFactorService)
+
+	// Hold paid session payments in escrow and auto-complete finished sessions
+	autoCompleteAfter, err := escrow.AutoCompleteAfterFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load escrow configuration: %v", err)
+	}
+	escrowService := escrow.NewService(dbConn, transferService, escrowAccount, autoCompleteAfter)
+	go escrowService.Run(time.Minute)
+
+	// Review coach and mentor verification requests
+	reapplyAfter, err := verification.ReapplyAfterFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load verification configuration: %v", err)
+	}
+	verificationService := verification.NewService(dbConn, reapplyAfter)
+
+	// Look for crisis language in new posts, comments and messages
+	crisisClassifier, err := safety.NewRuleClassifierFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load crisis rules: %v", err)
+	}
+	crisisResources, err := safety.NewDirectoryFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load crisis resources: %v", err)
+	}
+	safetyService := safety.NewService(dbConn, crisisClassifier, crisisResources)
+
+	// Anchor tips, reward payouts and badge mints on the HCS audit topic
+	auditTopic, err := wallet.NewAuditTopicFromEnv(ledger)
+	if err != nil {
+		log.Fatalf("Failed to initialize audit topic: %v", err)
+	}
+	auditTrail := audit.NewTrail(dbConn, ledger, auditTopic)
+	go auditTrail.Run(time.Minute)
+
+	// Award achievement badges and mint them to users' wallets
+	badgeAwarder := badges.NewAwarder(dbConn, transferService)
+	go badgeAwarder.Run(time.Minute)
+
+	// Build weekly contributor reward runs and pay the approved ones
+	rewardsConfig, err := rewards.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load rewards configuration: %v", err)
+	}
+	rewardJob := rewards.NewJob(dbConn, rewardsConfig, communityToken, transferService)
+	go rewardJob.Run(time.Hour)
+
+	// Reconcile wallet balances and deposits against the mirror node
+	reconciler, err := reconciliation.NewReconcilerFromEnv(dbConn)
+	if err != nil {
+		log.Fatalf("Failed to load reconciliation configuration: %v", err)
+	}
+	go reconciler.Run(5 * time.Minute)
+
+	// Email password reset and verification links
+	mailer, err := mail.NewMailerFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+	passwordResetConfig, err := passwordreset.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load password reset configuration: %v", err)
+	}
+
+	// Confirm email addresses and limit what unverified accounts can do
+	emailVerificationConfig, err := emailverification.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load email verification configuration: %v", err)
+	}
+	emailPolicy, err := emailverification.PolicyFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load email verification policy: %v", err)
+	}
+	middleware.SetEmailVerificationPolicy(emailPolicy)
+	emailVerificationService := emailverification.NewService(dbConn, mailer, emailVerificationConfig)
+
 	// Initialize websocket hub
-	hub := websocket.NewHub(dbConn)
+	hub := websocket.NewHub(dbConn, emailPolicy)
 	go hub.Run()
 
+	// Password resets sign the account out of every session and device
+	passwordResetService := passwordreset.NewService(dbConn, mailer, passwordResetConfig, hub)
+
+	// Gather content reports into the moderation queue; suspensions sign
+	// the user out everywhere
+	moderationService := moderation.NewService(dbConn, hub)
+
+	// Create wallets for new users in the background, retrying with backoff
+	walletWorker := provisioning.NewWorker(dbConn, ledger, communityToken, hub)
+	go walletWorker.Run(30 * time.Second)
+
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(dbConn)
-	postHandler := handlers.NewPostHandler(dbConn)
-	commentHandler := handlers.NewCommentHandler(dbConn)
+	authHandler := handlers.NewAuthHandler(dbConn, walletWorker, passwordResetService, emailVerificationService, twoFactorService, hub, sessionService)
+	postHandler := handlers.NewPostHandler(dbConn, safetyService)
+	commentHandler := handlers.NewCommentHandler(dbConn, safetyService)
 	groupHandler := handlers.NewGroupHandler(dbConn)
 	groupCommentHandler := handlers.NewGroupCommentHandler(dbConn)
 	userHandler := handlers.NewUserHandler(dbConn, hub)
-	messageHandler := handlers.NewMessageHandler(dbConn, hub)
+	messageHandler := handlers.NewMessageHandler(dbConn, hub, safetyService)
 	activityHandler := handlers.NewActivityHandler(dbConn)
 	uploadHandler := handlers.NewUploadHandler()
-	wsHandler := handlers.NewWebSocketHandler(hub, dbConn)
+	wsHandler := handlers.NewWebSocketHandler(hub, dbConn, sessionService)
 	notificationHandler := handlers.NewNotificationHandler(dbConn)
-	verificationHandler := handlers.NewVerificationHandler(dbConn)
-	transferHandler := handlers.NewTransferHandler(dbConn)
+	verificationHandler := handlers.NewVerificationHandler(dbConn, verificationService)
+	transferHandler := handlers.NewTransferHandler(dbConn, ledger, transferService, communityToken, transferPolicy)
+	rewardHandler := handlers.NewRewardHandler(dbConn, rewardJob)
+	auditHandler := handlers.NewAuditHandler(auditTrail)
+	reconciliationHandler := handlers.NewReconciliationHandler(dbConn, reconciler)
+	escrowHandler := handlers.NewEscrowHandler(dbConn, escrowService, transferPolicy)
+	adminHandler := handlers.NewAdminHandler(dbConn, moderationService)
+	moderationHandler := handlers.NewModerationHandler(dbConn, moderationService)
+	safetyHandler := handlers.NewSafetyHandler(dbConn, safetyService)
+	healthHandler := handlers.NewHealthHandler(dbConn, ledger)
 
 	// Create router
 	router := r.NewRouter()
-	authMiddleware := middleware.Auth(dbConn)
+	authMiddleware := middleware.Auth(dbConn, sessionService)
 
 	// Serve static files for uploads
 	uploadsDir := os.Getenv("UPLOAD_PATH")
@@ -61,7 +241,8 @@ func main() {
 	})
 
 	// Setup all routes
-	r.SetupAuthRoutes(router, authHandler)
+	r.SetupHealthRoutes(router, healthHandler)
+	r.SetupAuthRoutes(router, authHandler, authMiddleware)
 	r.SetupPostRoutes(router, postHandler, commentHandler, authMiddleware)
 	r.SetupGroupRoutes(router, groupHandler, groupCommentHandler, messageHandler, authMiddleware)
 	r.SetupUserRoutes(router, userHandler, authMiddleware)
@@ -72,6 +253,13 @@ func main() {
 	r.SetupWebSocketRoutes(router, wsHandler)
 	r.SetupVerificationRoutes(router, verificationHandler, authMiddleware)
 	r.SetupTransferRoutes(router, transferHandler, authMiddleware)
+	r.SetupEscrowRoutes(router, escrowHandler, authMiddleware)
+	r.SetupAdminRoutes(router, adminHandler, authMiddleware)
+	r.SetupModerationRoutes(router, moderationHandler, authMiddleware)
+	r.SetupSafetyRoutes(router, safetyHandler, authMiddleware)
+	r.SetupRewardRoutes(router, rewardHandler, authMiddleware)
+	r.SetupReconciliationRoutes(router, reconciliationHandler, authMiddleware)
+	r.SetupAuditRoutes(router, auditHandler, authMiddleware)
 
 	// Apply global middleware and use our router
 	var handler http.Handler = router
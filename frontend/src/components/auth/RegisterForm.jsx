@@ -43,7 +43,6 @@ export default function RegisterForm() {
     date_of_birth: '',
     nickname: '',
     about_me: '',
-    role: 'user',
     is_public: true
   });
 
@@ -285,25 +284,10 @@ export default function RegisterForm() {
             {errors.date_of_birth && <p className="text-red-500 text-xs mt-1">{errors.date_of_birth}</p>}
           </div>
 
-          {/* Role Selection */}
-          <div className="md:col-span-2">
-            <label className="block text-sm font-medium mb-1">I am a:</label>
-            <select
-              name="role"
-              value={formData.role}
-              onChange={handleChange}
-              className="w-full bg-background-lighter border border-border rounded-md p-2 focus:outline-none focus:ring-2 focus:ring-primary"
-            >
-              <option value="user">Patient/Survivor/Caregiver</option>
-              <option value="coach">Health Coach (requires verification)</option>
-              <option value="mentor">Mentor (requires verification)</option>
-            </select>
-            {(formData.role === 'coach' || formData.role === 'mentor') && (
-              <p className="text-xs text-text-secondary mt-1">
-                You can complete verification after registration
-              </p>
-            )}
-          </div>
+          {/* Coach and mentor roles are granted by verification after registration */}
+          <p className="md:col-span-2 text-xs text-text-secondary">
+            Health coaches and mentors can apply for verification on the Verification page after registering.
+          </p>
 
           {/* Privacy Setting */}
           <div className="md:col-span-2">
@@ -17,11 +17,9 @@ export default function TipButton({ postId, recipientId, recipientName, onTipSen
     try {
       // Convert KSH to HBAR (1 HBAR = 50 KSH)
       const hbarAmount = parseFloat(amount) / 50;
-      const transaction = await tokenomics.sendTip(
-        recipientId,
-        hbarAmount,
-        message
-      );
+      const transaction = postId
+        ? await tokenomics.tipPost(postId, hbarAmount)
+        : await tokenomics.sendTip(recipientId, hbarAmount, message);
       
       if (onTipSent) {
         onTipSent(transaction);
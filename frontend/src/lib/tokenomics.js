@@ -39,7 +39,8 @@ export const tokenomics = {
   getBalance: async () => {
     try {
       const response = await fetchAPI('/api/transfer/balance')
-      const hbarBalance = response.balance || 0
+      // Amounts arrive as decimal HBAR strings, e.g. "4.50000000"
+      const hbarBalance = parseFloat(response.balance) || 0
       return {
         hbar: hbarBalance,
         ksh: hbarBalance * HBAR_TO_KSH_RATE
@@ -60,7 +61,8 @@ export const tokenomics = {
         method: 'POST',
         body: JSON.stringify({
           to_user_id: recipientId,
-          amount: amount
+          // The API takes at most 8 decimal places (whole tinybars)
+          amount: Number(amount).toFixed(8)
         })
       })
       
@@ -80,6 +82,26 @@ export const tokenomics = {
     }
   },
 
+  // Tip the author of a post; the tip is linked to the post and counted in its tip total
+  tipPost: async (postId, amount) => {
+    return fetchAPI(`/api/posts/${postId}/tips`, {
+      method: 'POST',
+      body: JSON.stringify({
+        amount: Number(amount).toFixed(8)
+      })
+    })
+  },
+
+  // Tip the author of a comment
+  tipComment: async (commentId, amount) => {
+    return fetchAPI(`/api/comments/${commentId}/tips`, {
+      method: 'POST',
+      body: JSON.stringify({
+        amount: Number(amount).toFixed(8)
+      })
+    })
+  },
+
   // Get transaction history
   getTransactions: async (page = 1, limit = 20) => {
     try {
@@ -89,8 +111,8 @@ export const tokenomics = {
       const transactions = transfers.map(transfer => ({
         id: transfer.id,
         type: transfer.from_user_id ? 'tip_sent' : 'tip_received',
-        amount: transfer.amount,
-        ksh_amount: transfer.amount * HBAR_TO_KSH_RATE,
+        amount: parseFloat(transfer.amount),
+        ksh_amount: parseFloat(transfer.amount) * HBAR_TO_KSH_RATE,
         timestamp: transfer.created_at,
         status: transfer.status,
         transaction_hash: transfer.transaction_id || 'N/A',